@@ -3,18 +3,20 @@ package build
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
 
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/file_reader"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/ssh_agent"
@@ -26,6 +28,12 @@ import (
 	"github.com/werf/werf/pkg/werf/global_warnings"
 )
 
+var cmdData struct {
+	Since              string
+	Distributed        bool
+	DistributedSession string
+}
+
 var commonCmdData common.CmdData
 
 func NewCmd() *cobra.Command {
@@ -42,12 +50,22 @@ func NewCmd() *cobra.Command {
   $ werf build --introspect-error
 
   # Build images and store/use stages from repo
-  $ werf build --repo harbor.company.io/werf`,
+  $ werf build --repo harbor.company.io/werf
+
+  # Build only images affected by the changes since the main branch, other images are reused
+  $ werf build --repo harbor.company.io/werf --since origin/main
+
+  # Build images on several hosts at once, run the same command on each host
+  $ werf build --repo harbor.company.io/werf --synchronization https://synchronization.company.io/CLIENT_ID --distributed`,
 		Long: common.GetLongCommandDescription(`Build images that are described in werf.yaml.
 
 The result of build command is built images pushed into the specified repo (or locally if repo is not specified).
 
-If one or more IMAGE_NAME parameters specified, werf will build only these images`),
+If one or more IMAGE_NAME parameters specified, werf will build only these images.
+
+If --since is specified, werf will build only images affected by the changes between the specified commit and the current commit: changes in the git mappings and stageDependencies of stapel images, in the context of dockerfile images, in werf.yaml and in the images they depend on. Other images are not built and reported as reused from the image metadata of the specified commit.
+
+If --distributed is specified, werf processes of the same session split images between each other using the http synchronization server: each process builds the images it has claimed first, then waits for the rest images to be built by other processes and uses them from the repo`),
 		DisableFlagsInUseLine: true,
 		Annotations: map[string]string{
 			common.CmdEnvAnno: common.EnvsDescription(common.WerfDebugAnsibleArgs),
@@ -86,6 +104,7 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
 
@@ -116,6 +135,10 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 	common.SetupAllowedVolumeUsageMargin(&commonCmdData, cmd)
 	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
 
+	cmd.Flags().StringVarP(&cmdData.Since, "since", "", os.Getenv("WERF_SINCE"), "Build only images affected by the changes since the specified commit, branch or tag, which has been built before (default $WERF_SINCE)")
+	cmd.Flags().BoolVarP(&cmdData.Distributed, "distributed", "", common.GetBoolEnvironmentDefaultFalse("WERF_DISTRIBUTED"), "Build images together with other werf processes of the same session, requires the http synchronization server (default $WERF_DISTRIBUTED)")
+	cmd.Flags().StringVarP(&cmdData.DistributedSession, "distributed-session", "", os.Getenv("WERF_DISTRIBUTED_SESSION"), "Distributed build session, should be the same for all werf processes, which build the project together (default $WERF_DISTRIBUTED_SESSION or the current commit)")
+
 	return cmd
 }
 
@@ -207,7 +230,10 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 	}
 	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
 
-	containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+	containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+	if err != nil {
+		return err
+	}
 
 	stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
 	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
@@ -239,6 +265,32 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 		return err
 	}
 
+	if cmdData.Since != "" {
+		sinceOptions, err := getSinceOptions(ctx, giterminismManager)
+		if err != nil {
+			return err
+		}
+		buildOptions.Since = sinceOptions
+	}
+
+	if cmdData.Distributed {
+		buildCoordinator, err := common.GetBuildCoordinator(synchronization)
+		if err != nil {
+			return err
+		}
+
+		sessionID := cmdData.DistributedSession
+		if sessionID == "" {
+			sessionID = giterminismManager.HeadCommit()
+		}
+
+		buildOptions.Distributed = build.DistributedBuildOptions{
+			Coordinator: buildCoordinator,
+			SessionID:   sessionID,
+			RunnerID:    uuid.New().String(),
+		}
+	}
+
 	conveyorOptions, err := common.GetConveyorOptionsWithParallel(&commonCmdData, buildOptions)
 	if err != nil {
 		return err
@@ -257,3 +309,36 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 
 	return nil
 }
+
+func getSinceOptions(ctx context.Context, giterminismManager giterminism_manager.Interface) (build.SinceOptions, error) {
+	sinceCommit, err := giterminismManager.LocalGitRepo().ResolveRevision(ctx, cmdData.Since)
+	if err != nil {
+		return build.SinceOptions{}, fmt.Errorf("bad --since=%q: %s", cmdData.Since, err)
+	}
+
+	var configPaths []string
+
+	customWerfConfigRelPath, err := common.GetCustomWerfConfigRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return build.SinceOptions{}, err
+	}
+	if customWerfConfigRelPath != "" {
+		configPaths = append(configPaths, customWerfConfigRelPath)
+	} else {
+		configPaths = append(configPaths, file_reader.DefaultWerfConfigNames...)
+	}
+
+	customWerfConfigTemplatesDirRelPath, err := common.GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, &commonCmdData)
+	if err != nil {
+		return build.SinceOptions{}, err
+	}
+	if customWerfConfigTemplatesDirRelPath != "" {
+		configPaths = append(configPaths, customWerfConfigTemplatesDirRelPath)
+	} else {
+		configPaths = append(configPaths, file_reader.DefaultWerfConfigTemplatesDirName)
+	}
+
+	configPaths = append(configPaths, file_reader.GiterminismConfigName)
+
+	return build.SinceOptions{Commit: sinceCommit, ConfigPaths: configPaths}, nil
+}
@@ -1,6 +1,7 @@
 package apply
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -33,6 +34,7 @@ import (
 	"github.com/werf/logboek/pkg/level"
 
 	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/werf"
 )
 
@@ -249,6 +251,8 @@ func runApply() error {
 	})
 
 	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
-		return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, bundle.Dir})
+		return tracing.Do(ctx, "helm.Upgrade", func(ctx context.Context) error {
+			return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, bundle.Dir})
+		}, "helm.release", releaseName, "helm.namespace", namespace)
 	})
 }
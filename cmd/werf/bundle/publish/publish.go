@@ -33,7 +33,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/ssh_agent"
@@ -100,6 +99,7 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo and to pull base images")
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
 
@@ -245,7 +245,10 @@ func runPublish(ctx context.Context) error {
 	var imagesRepository string
 
 	if len(werfConfig.StapelImages) != 0 || len(werfConfig.ImagesFromDockerfile) != 0 {
-		containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+		containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+		if err != nil {
+			return err
+		}
 		stagesStorage, err := common.GetStagesStorage(repoAddress, containerRuntime, &commonCmdData)
 		if err != nil {
 			return err
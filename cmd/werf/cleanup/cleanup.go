@@ -3,6 +3,7 @@ package cleanup
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 
@@ -10,6 +11,7 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/cleaning"
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/git_repo"
@@ -24,6 +26,10 @@ import (
 )
 
 var commonCmdData common.CmdData
+var cmdData struct {
+	PlanFile      string
+	ApplyPlanFile string
+}
 
 func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -34,8 +40,14 @@ func NewCmd() *cobra.Command {
 
 The command works according to special rules called cleanup policies, which the user defines in werf.yaml (https://werf.io/documentation/reference/werf_yaml.html#configuring-cleanup-policies).
 
-It is safe to run this command periodically (daily is enough) by automated cleanup job in parallel with other werf commands such as build, converge and host cleanup.`),
-		Example: `  $ werf cleanup --repo registry.mydomain.com/myproject/werf`,
+It is safe to run this command periodically (daily is enough) by automated cleanup job in parallel with other werf commands such as build, converge and host cleanup.
+
+The deletions can be reviewed before they happen: the command with --plan-file only saves the stages, image metadata and import metadata to delete with the reasons into the plan file, and the command with --apply-plan deletes them later, if nothing new references the planned stages.`),
+		Example: `  $ werf cleanup --repo registry.mydomain.com/myproject/werf
+
+  # Save the cleanup plan for review and apply it later
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --plan-file plan.json
+  $ werf cleanup --repo registry.mydomain.com/myproject/werf --apply-plan plan.json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := common.BackgroundContext()
 
@@ -47,6 +59,11 @@ It is safe to run this command periodically (daily is enough) by automated clean
 			}
 			common.LogVersion()
 
+			if cmdData.PlanFile != "" && cmdData.ApplyPlanFile != "" {
+				common.PrintHelp(cmd)
+				return fmt.Errorf("--plan-file and --apply-plan cannot be used together")
+			}
+
 			return common.LogRunningTime(func() error {
 				return runCleanup(ctx)
 			})
@@ -83,8 +100,12 @@ It is safe to run this command periodically (daily is enough) by automated clean
 	common.SetupKubeConfigBase64(&commonCmdData, cmd)
 	common.SetupKubeContext(&commonCmdData, cmd)
 	common.SetupWithoutKube(&commonCmdData, cmd)
+	common.SetupAllowListFiles(&commonCmdData, cmd)
 	common.SetupKeepStagesBuiltWithinLastNHours(&commonCmdData, cmd)
 
+	cmd.Flags().StringVarP(&cmdData.PlanFile, "plan-file", "", os.Getenv("WERF_PLAN_FILE"), "Save the stages, image metadata and import metadata to delete into the specified file instead of deleting them (default $WERF_PLAN_FILE)")
+	cmd.Flags().StringVarP(&cmdData.ApplyPlanFile, "apply-plan", "", os.Getenv("WERF_APPLY_PLAN"), "Delete the stages, image metadata and import metadata from the specified plan file created with --plan-file, if nothing new references the planned stages (default $WERF_APPLY_PLAN)")
+
 	common.SetupDisableAutoHostCleanup(&commonCmdData, cmd)
 	common.SetupAllowedVolumeUsage(&commonCmdData, cmd)
 	common.SetupAllowedVolumeUsageMargin(&commonCmdData, cmd)
@@ -157,7 +178,10 @@ func runCleanup(ctx context.Context) error {
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
-	if !werfConfig.Meta.GitWorktree.GetForceShallowClone() && !werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
+	// the age-based cleanup and applying the plan do not use the git history
+	isGitHistoryBasedCleanup := werfConfig.Meta.Cleanup.GetStrategy() == config.MetaCleanupStrategyGitHistory && cmdData.ApplyPlanFile == ""
+
+	if isGitHistoryBasedCleanup && !werfConfig.Meta.GitWorktree.GetForceShallowClone() && !werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
 		isShallow, err := giterminismManager.LocalGitRepo().IsShallowClone()
 		if err != nil {
 			return fmt.Errorf("check shallow clone failed: %s", err)
@@ -172,7 +196,7 @@ func runCleanup(ctx context.Context) error {
 		}
 	}
 
-	if werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
+	if isGitHistoryBasedCleanup && werfConfig.Meta.GitWorktree.GetAllowFetchingOriginBranchesAndTags() {
 		if err := giterminismManager.LocalGitRepo().SyncWithOrigin(ctx); err != nil {
 			return fmt.Errorf("synchronization failed: %s", err)
 		}
@@ -222,15 +246,24 @@ func runCleanup(ctx context.Context) error {
 		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
 	}
 
+	kubernetesDynamicClientByContext, err := common.GetKubernetesDynamicClientByContext(&commonCmdData, kubernetesContextClients)
+	if err != nil {
+		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
+	}
+
 	cleanupOptions := cleaning.CleanupOptions{
 		ImageNameList:                           imagesNames,
 		LocalGit:                                giterminismManager.LocalGitRepo(),
 		KubernetesContextClients:                kubernetesContextClients,
 		KubernetesNamespaceRestrictionByContext: common.GetKubernetesNamespaceRestrictionByContext(&commonCmdData, kubernetesContextClients),
+		KubernetesDynamicClientByContext:        kubernetesDynamicClientByContext,
 		WithoutKube:                             *commonCmdData.WithoutKube,
+		AllowListFiles:                          common.GetAllowListFiles(&commonCmdData),
 		GitHistoryBasedCleanupOptions:           werfConfig.Meta.Cleanup,
 		KeepStagesBuiltWithinLastNHours:         *commonCmdData.KeepStagesBuiltWithinLastNHours,
 		DryRun:                                  *commonCmdData.DryRun,
+		PlanFile:                                cmdData.PlanFile,
+		ApplyPlanFile:                           cmdData.ApplyPlanFile,
 	}
 
 	logboek.LogOptionalLn()
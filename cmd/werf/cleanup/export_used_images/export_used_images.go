@@ -0,0 +1,131 @@
+package export_used_images
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/cleaning/allow_list"
+)
+
+var commonCmdData common.CmdData
+var cmdData struct {
+	OutputFile          string
+	Format              string
+	KubernetesResources []string
+}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "export-used-images",
+		DisableFlagsInUseLine: true,
+		Short:                 "Export images used in Kubernetes cluster into allow-list file",
+		Long: common.GetLongCommandDescription(`Export images used in Kubernetes cluster into allow-list file.
+
+The command is intended for clusters, which are unreachable by werf cleanup (e.g. production clusters unreachable from CI): run the command inside each cluster and pass the resulting files to werf cleanup with --allow-list-file option, so that the images used in these clusters are never deleted.
+
+The command scans the same kinds of objects as werf cleanup does. Other resources that reference images (e.g. custom resources of operators) can be scanned using --kubernetes-resource option.`),
+		Example: `  # Export images used in all namespaces of the current cluster
+  $ werf cleanup export-used-images --output used-images.txt
+
+  # Export images used by Argo Rollouts as well
+  $ werf cleanup export-used-images --format json --output used-images.json \
+      --kubernetes-resource 'rollouts.v1alpha1.argoproj.io={.spec.template.spec.containers[*].image}'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			switch cmdData.Format {
+			case allow_list.FileFormatText, allow_list.FileFormatJson:
+			default:
+				common.PrintHelp(cmd)
+				return fmt.Errorf("invalid --format %q: expected %s or %s", cmdData.Format, allow_list.FileFormatText, allow_list.FileFormatJson)
+			}
+
+			return run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdData.OutputFile, "output", "o", os.Getenv("WERF_OUTPUT"), "Write images into the specified file instead of stdout, the logs are written to stderr when the images are written to stdout (default $WERF_OUTPUT)")
+	cmd.Flags().StringVarP(&cmdData.Format, "format", "", allow_list.FileFormatText, fmt.Sprintf("Output format: %s (one image per line) or %s", allow_list.FileFormatText, allow_list.FileFormatJson))
+	cmd.Flags().StringArrayVarP(&cmdData.KubernetesResources, "kubernetes-resource", "", []string{}, "Scan additional resource for images in the form RESOURCE.VERSION[.GROUP]=JSONPATH (e.g. rollouts.v1alpha1.argoproj.io={.spec.template.spec.containers[*].image})")
+
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+	common.SetupScanContextNamespaceOnly(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	// the images are written to stdout, so the logs are written to stderr to keep the output usable
+	if cmdData.OutputFile == "" {
+		ctx = logboek.NewContext(ctx, logboek.NewSubLogger(os.Stderr, os.Stderr))
+	}
+
+	var extraResources []*allow_list.KubernetesResource
+	for _, value := range cmdData.KubernetesResources {
+		resource, err := allow_list.ParseKubernetesResource(value)
+		if err != nil {
+			return err
+		}
+
+		extraResources = append(extraResources, resource)
+	}
+
+	kubernetesContextClients, err := common.GetKubernetesContextClients(&commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
+	}
+
+	kubernetesNamespaceRestrictionByContext := common.GetKubernetesNamespaceRestrictionByContext(&commonCmdData, kubernetesContextClients)
+
+	kubernetesDynamicClientByContext, err := common.GetKubernetesDynamicClientByContext(&commonCmdData, kubernetesContextClients)
+	if err != nil {
+		return fmt.Errorf("unable to get Kubernetes clusters connections: %s", err)
+	}
+
+	var images []string
+	for _, contextClient := range kubernetesContextClients {
+		if err := logboek.Context(ctx).Info().LogProcessInline("Getting deployed docker images (context %s)", contextClient.ContextName).
+			DoError(func() error {
+				contextImages, err := allow_list.DeployedDockerImages(contextClient.Client, kubernetesDynamicClientByContext[contextClient.ContextName], kubernetesNamespaceRestrictionByContext[contextClient.ContextName], extraResources)
+				if err != nil {
+					return fmt.Errorf("cannot get deployed images: %s", err)
+				}
+
+				images = append(images, contextImages...)
+
+				return nil
+			}); err != nil {
+			return err
+		}
+	}
+
+	data, err := allow_list.RenderFile(images, cmdData.Format)
+	if err != nil {
+		return err
+	}
+
+	if cmdData.OutputFile == "" {
+		fmt.Print(string(data))
+		return nil
+	}
+
+	if err := ioutil.WriteFile(cmdData.OutputFile, data, 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", cmdData.OutputFile, err)
+	}
+
+	return nil
+}
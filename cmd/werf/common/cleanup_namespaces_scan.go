@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/werf/kubedog/pkg/kube"
 	"github.com/werf/logboek"
+	"k8s.io/client-go/dynamic"
 )
 
 func SetupScanContextNamespaceOnly(cmdData *CmdData, cmd *cobra.Command) {
@@ -13,6 +14,17 @@ func SetupScanContextNamespaceOnly(cmdData *CmdData, cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(cmdData.ScanContextNamespaceOnly, "scan-context-namespace-only", "", GetBoolEnvironmentDefaultFalse("WERF_SCAN_CONTEXT_NAMESPACE_ONLY"), "Scan for used images only in namespace linked with context for each available context in kube-config (or only for the context specified with option --kube-context). When disabled will scan all namespaces in all contexts (or only for the context specified with option --kube-context). (Default $WERF_SCAN_CONTEXT_NAMESPACE_ONLY)")
 }
 
+func SetupAllowListFiles(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.AllowListFiles = new([]string)
+	cmd.Flags().StringArrayVarP(cmdData.AllowListFiles, "allow-list-file", "", []string{}, `Keep images listed in the specified allow-list files in addition to the images used in reachable Kubernetes clusters.
+The files with images used in unreachable clusters can be created by werf cleanup export-used-images command inside each cluster (one image per line or JSON).
+Also, can be specified with $WERF_ALLOW_LIST_FILE_* (e.g. $WERF_ALLOW_LIST_FILE_1=..., $WERF_ALLOW_LIST_FILE_2=...)`)
+}
+
+func GetAllowListFiles(cmdData *CmdData) []string {
+	return append(predefinedValuesByEnvNamePrefix("WERF_ALLOW_LIST_FILE_"), *cmdData.AllowListFiles...)
+}
+
 func GetKubernetesContextClients(cmdData *CmdData) ([]*kube.ContextClient, error) {
 	var res []*kube.ContextClient
 	if contextClients, err := kube.GetAllContextsClients(kube.GetAllContextsClientsOptions{KubeConfig: *cmdData.KubeConfig}); err != nil {
@@ -41,6 +53,25 @@ func GetKubernetesContextClients(cmdData *CmdData) ([]*kube.ContextClient, error
 	return res, nil
 }
 
+func GetKubernetesDynamicClientByContext(cmdData *CmdData, contextClients []*kube.ContextClient) (map[string]dynamic.Interface, error) {
+	res := map[string]dynamic.Interface{}
+	for _, contextClient := range contextClients {
+		config, err := kube.GetKubeConfig(kube.KubeConfigOptions{ConfigPath: *cmdData.KubeConfig, Context: contextClient.ContextName})
+		if err != nil {
+			return nil, fmt.Errorf("unable to load kube config (context %q): %s", contextClient.ContextName, err)
+		}
+
+		dynamicClient, err := dynamic.NewForConfig(config.Config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create kubernetes dynamic client (context %q): %s", contextClient.ContextName, err)
+		}
+
+		res[contextClient.ContextName] = dynamicClient
+	}
+
+	return res, nil
+}
+
 func GetKubernetesNamespaceRestrictionByContext(cmdData *CmdData, contextClients []*kube.ContextClient) map[string]string {
 	res := map[string]string{}
 	for _, contextClient := range contextClients {
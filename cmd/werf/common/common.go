@@ -2,6 +2,7 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -26,6 +27,7 @@ import (
 	"github.com/werf/werf/pkg/giterminism_manager"
 	"github.com/werf/werf/pkg/logging"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
@@ -69,11 +71,13 @@ type CmdData struct {
 	SkipBuild *bool
 	StubTags  *bool
 
-	Synchronization    *string
-	Parallel           *bool
-	ParallelTasksLimit *int64
+	Synchronization      *string
+	SynchronizationToken *string
+	Parallel             *bool
+	ParallelTasksLimit   *int64
 
 	DockerConfig                    *string
+	ContainerRuntime                *string
 	InsecureRegistry                *bool
 	SkipTlsVerifyRegistry           *bool
 	DryRun                          *bool
@@ -106,6 +110,7 @@ type CmdData struct {
 	VirtualMergeIntoCommit *string
 
 	ScanContextNamespaceOnly *bool
+	AllowListFiles           *[]string
 
 	Tag *string
 
@@ -224,6 +229,21 @@ func SetupReportFormat(cmdData *CmdData, cmd *cobra.Command) {
 			"DockerTag": "<TAG>"
 			"DockerImageName": "<REPO>:<TAG>",
 			"DockerImageID": "<SHA256>",
+			"Stages": [
+				{
+					"Name": "<STAGE_NAME>",
+					"Digest": "<DIGEST>",
+					"ContentDigest": "<CONTENT_DIGEST>",
+					"Source": "cache|secondary|built",
+					"DockerImageName": "<REPO>:<STAGE_TAG>",
+					"DockerImageID": "<SHA256>",
+					"Size": <BYTES>,
+					"StartedAt": "<RFC3339_TIME>",
+					"DurationSeconds": <SECONDS>,
+					"GitCommits": {"<GIT_MAPPING>": "<COMMIT>"}
+				},
+				...
+			]
 		},
 		...
 	  }
@@ -233,7 +253,8 @@ func SetupReportFormat(cmdData *CmdData, cmd *cobra.Command) {
 	...
 <FORMATTED_WERF_IMAGE_NAME> is werf image name from werf.yaml modified according to the following rules:
 - all characters are uppercase (app -> APP);
-- charset /- is replaced with _ (DEV/APP-FRONTEND -> DEV_APP_FRONTEND)`, string(build.ReportJSON), string(build.ReportEnvFile)))
+- charset /- is replaced with _ (DEV/APP-FRONTEND -> DEV_APP_FRONTEND).
+The stages of the images are reported only in the %[1]s format`, string(build.ReportJSON), string(build.ReportEnvFile)))
 }
 
 func GetReportFormat(cmdData *CmdData) (build.ReportFormat, error) {
@@ -362,6 +383,7 @@ func SetupCommonRepoData(cmdData *CmdData, cmd *cobra.Command) {
 func SetupSecondaryStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.SecondaryStagesStorage = new([]string)
 	cmd.Flags().StringArrayVarP(cmdData.SecondaryStagesStorage, "secondary-repo", "", []string{}, `Specify one or multiple secondary read-only repos with images that will be used as a cache.
+OCI image layout directory could be specified as oci-dir://PATH.
 Also, can be specified with $WERF_SECONDARY_REPO_* (e.g. $WERF_SECONDARY_REPO_1=..., $WERF_SECONDARY_REPO_2=...)`)
 }
 
@@ -374,7 +396,7 @@ func SetupStagesStorageOptions(cmdData *CmdData, cmd *cobra.Command) {
 
 func setupStagesStorage(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.StagesStorage = new(string)
-	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo to store stages (default $WERF_REPO)"))
+	cmd.Flags().StringVarP(cmdData.StagesStorage, "repo", "", os.Getenv("WERF_REPO"), fmt.Sprintf("Docker Repo to store stages or OCI image layout directory specified as %sPATH (default $WERF_REPO)", storage.OciDirStorageAddressPrefix))
 }
 
 func SetupStatusProgressPeriod(cmdData *CmdData, cmd *cobra.Command) {
@@ -913,7 +935,7 @@ func GetStagesStorage(stagesStorageAddress string, containerRuntime container_ru
 
 func GetSecondaryStagesStorageList(stagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) ([]storage.StagesStorage, error) {
 	var res []storage.StagesStorage
-	if stagesStorage.Address() != storage.LocalStorageAddress {
+	if _, isLocalDockerServerRuntime := containerRuntime.(*container_runtime.LocalDockerServerRuntime); isLocalDockerServerRuntime && stagesStorage.Address() != storage.LocalStorageAddress {
 		localStagesStorage, err := storage.NewStagesStorage(storage.LocalStorageAddress, containerRuntime, storage.StagesStorageOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("unable to create local secondary stages storage: %s", err)
@@ -1367,6 +1389,11 @@ func TerminateWithError(errMsg string, exitCode int) {
 
 	logboek.Streams().DisableLineWrapping()
 	logboek.Error().LogLn(msg)
+
+	if err := tracing.Shutdown(BackgroundContext(), errors.New(errMsg)); err != nil {
+		logboek.Warn().LogF("WARNING: %s\n", err)
+	}
+
 	os.Exit(exitCode)
 }
 
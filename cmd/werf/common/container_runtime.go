@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/buildah"
+	"github.com/werf/werf/pkg/container_runtime"
+)
+
+const (
+	DockerContainerRuntime  = "docker"
+	BuildahContainerRuntime = "buildah"
+)
+
+func SetupContainerRuntime(cmdData *CmdData, cmd *cobra.Command) {
+	defaultValue := os.Getenv("WERF_CONTAINER_RUNTIME")
+	if defaultValue == "" {
+		defaultValue = DockerContainerRuntime
+	}
+
+	cmdData.ContainerRuntime = new(string)
+	cmd.Flags().StringVarP(cmdData.ContainerRuntime, "container-runtime", "", defaultValue, fmt.Sprintf(`Container runtime to build and store images: %[1]q or %[2]q.
+The %[2]q runtime does not require docker daemon, builds rootless using the buildah binary and supports only a registry repo as the stages storage.
+Buildah could be configured with $WERF_BUILDAH_BINARY, $WERF_BUILDAH_STORAGE_DRIVER and $WERF_BUILDAH_ISOLATION (default $WERF_CONTAINER_RUNTIME or %[1]q)`, DockerContainerRuntime, BuildahContainerRuntime))
+}
+
+func GetContainerRuntime(cmdData *CmdData) (container_runtime.ContainerRuntime, error) {
+	if cmdData.ContainerRuntime == nil {
+		return &container_runtime.LocalDockerServerRuntime{}, nil
+	}
+
+	switch *cmdData.ContainerRuntime {
+	case DockerContainerRuntime, "":
+		return &container_runtime.LocalDockerServerRuntime{}, nil
+	case BuildahContainerRuntime:
+		if err := buildah.Init(buildah.InitOptions{
+			BinPath:       os.Getenv("WERF_BUILDAH_BINARY"),
+			StorageDriver: os.Getenv("WERF_BUILDAH_STORAGE_DRIVER"),
+			Isolation:     os.Getenv("WERF_BUILDAH_ISOLATION"),
+		}); err != nil {
+			return nil, fmt.Errorf("unable to init buildah: %s", err)
+		}
+
+		return container_runtime.NewBuildahRuntime(), nil
+	default:
+		return nil, fmt.Errorf("bad --container-runtime=%q: expected %q or %q", *cmdData.ContainerRuntime, DockerContainerRuntime, BuildahContainerRuntime)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/werf/werf/pkg/werf/global_warnings"
@@ -24,6 +25,7 @@ import (
 	"github.com/werf/werf/pkg/storage/synchronization_server"
 
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
 
@@ -40,6 +42,9 @@ Default:
  - %s if --repo has been specified.
 
 The same address should be specified for all werf processes that work with a single repo. :local address allows execution of werf processes from a single host only`, storage.DefaultHttpSynchronizationServer))
+
+	cmdData.SynchronizationToken = new(string)
+	cmd.Flags().StringVarP(cmdData.SynchronizationToken, "synchronization-token", "", os.Getenv("WERF_SYNCHRONIZATION_TOKEN"), "Bearer token for the http synchronization server started with --auth-token (default $WERF_SYNCHRONIZATION_TOKEN)")
 }
 
 type SynchronizationType string
@@ -54,6 +59,7 @@ type SynchronizationParams struct {
 	Address             string
 	SynchronizationType SynchronizationType
 	KubeParams          *storage.KubernetesSynchronizationParams
+	Token               string
 }
 
 func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
@@ -125,7 +131,10 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 		var address string
 		if err := logboek.Default().LogProcess(fmt.Sprintf("Getting client id for the http synchronization server")).
 			DoError(func() error {
-				if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, synchronization_server.NewSynchronizationClient(synchronization), stagesStorage); err != nil {
+				client := synchronization_server.NewSynchronizationClient(synchronization)
+				client.HttpClient = synchronization_server.NewHttpClient(*cmdData.SynchronizationToken)
+
+				if clientID, err := synchronization_server.GetOrCreateClientID(ctx, projectName, client, stagesStorage); err != nil {
 					return fmt.Errorf("unable to get synchronization client id: %s", err)
 				} else {
 					address = fmt.Sprintf("%s/%s", synchronization, clientID)
@@ -136,12 +145,14 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 			return nil, err
 		}
 
-		return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization}, nil
+		return &SynchronizationParams{Address: address, SynchronizationType: HttpSynchronization, Token: *cmdData.SynchronizationToken}, nil
 	}
 
 	if *cmdData.Synchronization == "" {
 		if stagesStorage.Address() == storage.LocalStorageAddress {
 			return &SynchronizationParams{SynchronizationType: LocalSynchronization, Address: storage.LocalStorageAddress}, nil
+		} else if storage.IsOciDirStorageAddress(stagesStorage.Address()) {
+			return &SynchronizationParams{SynchronizationType: LocalSynchronization, Address: stagesStorage.Address()}, nil
 		} else {
 			return getHttpParamsFunc(storage.DefaultHttpSynchronizationServer, stagesStorage)
 		}
@@ -160,6 +171,9 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.StagesStorageCache, error) {
 	switch synchronization.SynchronizationType {
 	case LocalSynchronization:
+		if storage.IsOciDirStorageAddress(synchronization.Address) {
+			return storage.NewFileStagesStorageCache(filepath.Join(werf.GetStagesStorageCacheDir(), "oci-dir", util.MurmurHash(synchronization.Address))), nil
+		}
 		return storage.NewFileStagesStorageCache(werf.GetStagesStorageCacheDir()), nil
 	case KubernetesSynchronization:
 		if config, err := kube.GetKubeConfig(kube.KubeConfigOptions{
@@ -176,7 +190,9 @@ func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.Stag
 			}), nil
 		}
 	case HttpSynchronization:
-		return synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address)), nil
+		client := synchronization_server.NewStagesStorageCacheHttpClient(fmt.Sprintf("%s/stages-storage-cache", synchronization.Address))
+		client.HttpClient = synchronization_server.NewHttpClient(synchronization.Token)
+		return client, nil
 	default:
 		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
 	}
@@ -203,10 +219,23 @@ func GetStorageLockManager(ctx context.Context, synchronization *Synchronization
 			}), nil
 		}
 	case HttpSynchronization:
-		locker := distributed_locker.NewHttpLocker(fmt.Sprintf("%s/locker", synchronization.Address))
+		backend := distributed_locker.NewHttpBackend(fmt.Sprintf("%s/locker", synchronization.Address))
+		backend.HttpClient = synchronization_server.NewHttpClient(synchronization.Token)
+		locker := distributed_locker.NewDistributedLocker(backend)
 		lockerWithRetry := locker_with_retry.NewLockerWithRetry(ctx, locker, locker_with_retry.LockerWithRetryOptions{MaxAcquireAttempts: 10, MaxReleaseAttempts: 10})
 		return storage.NewGenericLockManager(lockerWithRetry), nil
 	default:
 		panic(fmt.Sprintf("unsupported synchronization address %q", synchronization.Address))
 	}
 }
+
+func GetBuildCoordinator(synchronization *SynchronizationParams) (storage.BuildCoordinator, error) {
+	switch synchronization.SynchronizationType {
+	case HttpSynchronization:
+		client := synchronization_server.NewBuildCoordinatorHttpClient(fmt.Sprintf("%s/build-coordinator", synchronization.Address))
+		client.HttpClient = synchronization_server.NewHttpClient(synchronization.Token)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("distributed build requires --synchronization=http[s]://HOST:PORT/CLIENT_ID, got %q", synchronization.Address)
+	}
+}
@@ -0,0 +1,223 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	formatDot     = "dot"
+	formatMermaid = "mermaid"
+	formatJson    = "json"
+)
+
+var commonCmdData common.CmdData
+var cmdData struct {
+	format string
+}
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "graph [IMAGE_NAME...]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print dependency graph of images and artifacts defined in werf.yaml",
+		Long: common.GetLongCommandDescription(`Print dependency graph of images and artifacts defined in werf.yaml.
+
+The edges are created by fromImage, fromArtifact, import and dependencies directives, each edge contains the stage of the image, which uses the dependency, and the stage of the dependency (the last stage by default).
+The images are grouped by sets in the build order: werf builds the sets one by one, the images of the same set do not depend on each other and are built in parallel.
+
+All images are printed by default, the images specified by IMAGE_NAME are printed with their dependencies otherwise.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			switch cmdData.format {
+			case formatDot, formatMermaid, formatJson:
+			default:
+				common.PrintHelp(cmd)
+				return fmt.Errorf("invalid --format %q: expected %s, %s or %s", cmdData.format, formatDot, formatMermaid, formatJson)
+			}
+
+			return run(args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&cmdData.format, "format", "f", formatDot, fmt.Sprintf("Output format: %s, %s or %s", formatDot, formatMermaid, formatJson))
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func run(imageNames []string) error {
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := git_repo.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfig, err := common.GetRequiredWerfConfig(common.BackgroundContext(), &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return err
+	}
+
+	images := werfConfig.GetAllImages()
+	if len(imageNames) != 0 {
+		images = nil
+		for _, imageName := range imageNames {
+			image := werfConfig.GetImage(imageName)
+			if image == nil {
+				if artifact := werfConfig.GetArtifact(imageName); artifact != nil {
+					image = artifact
+				}
+			}
+
+			if image == nil {
+				return fmt.Errorf("image %q is not defined in werf.yaml", imageName)
+			}
+
+			images = append(images, image)
+		}
+	}
+
+	graph, err := werfConfig.GetImageGraph(images)
+	if err != nil {
+		return err
+	}
+
+	switch cmdData.format {
+	case formatJson:
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to marshal graph: %s", err)
+		}
+
+		fmt.Println(string(data))
+	case formatMermaid:
+		fmt.Print(renderMermaid(graph))
+	default:
+		fmt.Print(renderDot(graph))
+	}
+
+	return nil
+}
+
+func renderDot(graph *config.ImageGraph) string {
+	var b strings.Builder
+
+	b.WriteString("digraph werf {\n")
+	b.WriteString("  rankdir=BT;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for ind, set := range graph.Sets {
+		fmt.Fprintf(&b, "  subgraph cluster_set_%d {\n", ind)
+		fmt.Fprintf(&b, "    label=%q;\n", fmt.Sprintf("set %d", ind+1))
+		b.WriteString("    style=dashed;\n")
+		for _, name := range set {
+			node := graph.GetNode(name)
+			style := ""
+			if node.Type == config.ImageGraphNodeArtifact {
+				style = ", style=rounded"
+			}
+			fmt.Fprintf(&b, "    %q [label=%q%s];\n", nodeID(name), nodeLabel(node), style)
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", nodeID(edge.From), nodeID(edge.To), edgeLabel(edge))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func renderMermaid(graph *config.ImageGraph) string {
+	var b strings.Builder
+
+	ids := map[string]string{}
+	for ind, node := range graph.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", ind)
+	}
+
+	b.WriteString("flowchart BT\n")
+
+	for ind, set := range graph.Sets {
+		fmt.Fprintf(&b, "  subgraph set_%d [\"set %d\"]\n", ind+1, ind+1)
+		for _, name := range set {
+			node := graph.GetNode(name)
+			if node.Type == config.ImageGraphNodeArtifact {
+				fmt.Fprintf(&b, "    %s(\"%s\")\n", ids[name], mermaidEscape(nodeLabel(node)))
+			} else {
+				fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[name], mermaidEscape(nodeLabel(node)))
+			}
+		}
+		b.WriteString("  end\n")
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[edge.From], mermaidEscape(edgeLabel(edge)), ids[edge.To])
+	}
+
+	return b.String()
+}
+
+func nodeID(name string) string {
+	if name == "" {
+		return "~"
+	}
+
+	return name
+}
+
+func nodeLabel(node *config.ImageGraphNode) string {
+	return fmt.Sprintf("%s %s", node.Type, nodeID(node.Name))
+}
+
+// edgeLabel describes stages: `import: setup -> importsBeforeSetup` means the files of the dependency setup stage are imported by the image importsBeforeSetup stage
+func edgeLabel(edge *config.ImageGraphEdge) string {
+	dependencyStage := edge.DependencyStage
+	if dependencyStage == "" {
+		dependencyStage = "last stage"
+	}
+
+	return fmt.Sprintf("%s: %s -> %s", edge.Type, dependencyStage, edge.Stage)
+}
+
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", ">", "#gt;").Replace(s)
+}
@@ -0,0 +1,23 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "schema",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print JSON Schema of werf.yaml config sections",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Print(config.WerfYamlSchema())
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,98 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "validate",
+		DisableFlagsInUseLine: true,
+		Short:                 "Validate werf.yaml",
+		Long: common.GetLongCommandDescription(`Validate werf.yaml with the JSON Schema of werf.yaml config sections.
+
+All found errors are reported with positions in the rendered werf.yaml instead of stopping at the first one.
+The schema could be printed with the werf config schema command and used by editors.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+				return fmt.Errorf("initialization error: %s", err)
+			}
+
+			if err := git_repo.Init(); err != nil {
+				return err
+			}
+
+			if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+				return err
+			}
+
+			ctx := common.BackgroundContext()
+
+			giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+			if err != nil {
+				return err
+			}
+
+			configOpts := common.GetWerfConfigOptions(&commonCmdData, true)
+
+			customWerfConfigRelPath, err := common.GetCustomWerfConfigRelPath(giterminismManager, &commonCmdData)
+			if err != nil {
+				return err
+			}
+
+			customWerfConfigTemplatesDirRelPath, err := common.GetCustomWerfConfigTemplatesDirRelPath(giterminismManager, &commonCmdData)
+			if err != nil {
+				return err
+			}
+
+			validationErrors, err := config.ValidateWerfConfig(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, configOpts)
+			if err != nil {
+				return err
+			}
+
+			if len(validationErrors) != 0 {
+				for _, validationError := range validationErrors {
+					logboek.Context(ctx).Warn().LogLn(validationError.Error())
+				}
+
+				return fmt.Errorf("werf config is not valid: %d error(s) found", len(validationErrors))
+			}
+
+			logboek.Context(ctx).Default().LogLn("werf config is valid")
+
+			return nil
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
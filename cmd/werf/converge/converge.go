@@ -29,7 +29,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/lock_manager"
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
@@ -40,6 +39,7 @@ import (
 	"github.com/werf/werf/pkg/storage/lrumeta"
 	"github.com/werf/werf/pkg/storage/manager"
 	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/werf"
 	"github.com/werf/werf/pkg/werf/global_warnings"
@@ -107,6 +107,7 @@ werf converge --repo registry.mydomain.com/web --env production`,
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo, to pull base images")
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
 
@@ -265,7 +266,10 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 		if err != nil {
 			return err
 		}
-		containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+		containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+		if err != nil {
+			return err
+		}
 		stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
 		if err != nil {
 			return err
@@ -403,7 +407,9 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	}
 	maintenanceHelper := createMaintenanceHelper(ctx, actionConfig, kubeConfigOptions)
 
-	if err := migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, postRenderer, valueOpts, filepath.Join(giterminismManager.ProjectDir(), chartDir)); err != nil {
+	if err := tracing.Do(ctx, "helm.MigrateHelm2ToHelm3", func(ctx context.Context) error {
+		return migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, postRenderer, valueOpts, filepath.Join(giterminismManager.ProjectDir(), chartDir))
+	}); err != nil {
 		return err
 	}
 
@@ -422,7 +428,9 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 	})
 
 	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
-		return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, filepath.Join(giterminismManager.ProjectDir(), chartDir)})
+		return tracing.Do(ctx, "helm.Upgrade", func(ctx context.Context) error {
+			return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, filepath.Join(giterminismManager.ProjectDir(), chartDir)})
+		}, "helm.release", releaseName, "helm.namespace", namespace)
 	})
 }
 
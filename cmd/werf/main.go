@@ -23,6 +23,8 @@ import (
 	"github.com/werf/werf/cmd/werf/slugify"
 	"github.com/werf/werf/cmd/werf/synchronization"
 
+	cleanup_export_used_images "github.com/werf/werf/cmd/werf/cleanup/export_used_images"
+
 	managed_images_add "github.com/werf/werf/cmd/werf/managed_images/add"
 	managed_images_ls "github.com/werf/werf/cmd/werf/managed_images/ls"
 	managed_images_rm "github.com/werf/werf/cmd/werf/managed_images/rm"
@@ -37,8 +39,11 @@ import (
 	bundle_export "github.com/werf/werf/cmd/werf/bundle/export"
 	bundle_publish "github.com/werf/werf/cmd/werf/bundle/publish"
 
+	config_graph "github.com/werf/werf/cmd/werf/config/graph"
 	config_list "github.com/werf/werf/cmd/werf/config/list"
 	config_render "github.com/werf/werf/cmd/werf/config/render"
+	config_schema "github.com/werf/werf/cmd/werf/config/schema"
+	config_validate "github.com/werf/werf/cmd/werf/config/validate"
 	"github.com/werf/werf/cmd/werf/render"
 
 	"github.com/werf/werf/cmd/werf/completion"
@@ -46,10 +51,12 @@ import (
 	"github.com/werf/werf/cmd/werf/version"
 
 	stage_image "github.com/werf/werf/cmd/werf/stage/image"
+	stages_sync "github.com/werf/werf/cmd/werf/stages/sync"
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/cmd/werf/common/templates"
 	"github.com/werf/werf/pkg/process_exterminator"
+	"github.com/werf/werf/pkg/tracing"
 )
 
 func main() {
@@ -61,11 +68,27 @@ func main() {
 		common.TerminateWithError(fmt.Sprintf("process exterminator initialization failed: %s", err), 1)
 	}
 
+	if err := tracing.Init(tracing.InitOptions{
+		File:         os.Getenv(tracing.FileEnvVar),
+		Endpoint:     os.Getenv(tracing.EndpointEnvVar),
+		RootSpanName: "werf",
+	}); err != nil {
+		common.TerminateWithError(fmt.Sprintf("tracing initialization failed: %s", err), 1)
+	}
+
 	rootCmd := constructRootCmd()
 
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if cmd != nil {
+		tracing.RootSpan().SetAttribute("werf.command", cmd.CommandPath())
+	}
+	if err != nil {
 		common.TerminateWithError(err.Error(), 1)
 	}
+
+	if err := tracing.Shutdown(common.BackgroundContext(), nil); err != nil {
+		logboek.Warn().LogF("WARNING: %s\n", err)
+	}
 }
 
 func constructRootCmd() *cobra.Command {
@@ -96,7 +119,7 @@ Find more information at https://werf.io`),
 		{
 			Message: "Cleaning commands",
 			Commands: []*cobra.Command{
-				cleanup.NewCmd(),
+				cleanupCmd(),
 				purge.NewCmd(),
 			},
 		},
@@ -116,6 +139,7 @@ Find more information at https://werf.io`),
 			Commands: []*cobra.Command{
 				configCmd(),
 				managedImagesCmd(),
+				stagesCmd(),
 				hostCmd(),
 				helm.NewCmd(),
 			},
@@ -176,6 +200,18 @@ func configCmd() *cobra.Command {
 	cmd.AddCommand(
 		config_render.NewCmd(),
 		config_list.NewCmd(),
+		config_validate.NewCmd(),
+		config_schema.NewCmd(),
+		config_graph.NewCmd(),
+	)
+
+	return cmd
+}
+
+func cleanupCmd() *cobra.Command {
+	cmd := cleanup.NewCmd()
+	cmd.AddCommand(
+		cleanup_export_used_images.NewCmd(),
 	)
 
 	return cmd
@@ -195,6 +231,18 @@ func managedImagesCmd() *cobra.Command {
 	return cmd
 }
 
+func stagesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stages",
+		Short: "Work with stages, which are stored in the repo",
+	}
+	cmd.AddCommand(
+		stages_sync.NewCmd(),
+	)
+
+	return cmd
+}
+
 func stageCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:    "stage",
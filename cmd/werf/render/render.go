@@ -19,7 +19,6 @@ import (
 
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
-	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/deploy/helm"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
@@ -91,6 +90,7 @@ func NewCmd() *cobra.Command {
 	common.SetupStagesStorageOptions(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read, pull and push images into the specified repo and to pull base images")
+	common.SetupContainerRuntime(&commonCmdData, cmd)
 	common.SetupInsecureRegistry(&commonCmdData, cmd)
 	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
 
@@ -238,7 +238,10 @@ func runRender() error {
 		stagesStorageAddress := common.GetOptionalStagesStorageAddress(&commonCmdData)
 
 		if stagesStorageAddress != storage.LocalStorageAddress {
-			containerRuntime := &container_runtime.LocalDockerServerRuntime{} // TODO
+			containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+			if err != nil {
+				return err
+			}
 			stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
 			if err != nil {
 				return err
@@ -0,0 +1,235 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tmp_manager"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var cmdData struct {
+	From    string
+	Images  []string
+	Digests []string
+	MaxAge  string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "sync",
+		DisableFlagsInUseLine: true,
+		Short:                 "Copy stages, image metadata, import metadata and managed images from one repo to another",
+		Long: common.GetLongCommandDescription(`Copy stages, image metadata, import metadata and managed images of the project from the --from repo to the --repo.
+
+Stages could be filtered by images (stages of the images with all parent stages and import sources), digests and age.
+Records, which already exist in the --repo, are skipped, so the interrupted sync can be resumed by running the command again.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return run()
+		},
+	}
+
+	common.SetupProjectName(&commonCmdData, cmd)
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupContainerRuntime(&commonCmdData, cmd)
+
+	common.SetupDockerConfig(&commonCmdData, cmd, "Command needs granted permissions to read images from the --from repo and to write images to the --repo")
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	common.SetupSynchronization(&commonCmdData, cmd)
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupDryRun(&commonCmdData, cmd)
+	common.SetupParallelOptions(&commonCmdData, cmd, common.DefaultCleanupParallelTasksLimit)
+
+	cmd.Flags().StringVarP(&cmdData.From, "from", "", os.Getenv("WERF_FROM"), fmt.Sprintf("Docker Repo or OCI image layout directory specified as %sPATH to copy stages from (default $WERF_FROM)", storage.OciDirStorageAddressPrefix))
+	cmd.Flags().StringArrayVarP(&cmdData.Images, "image", "", []string{}, "Copy only stages of the specified images (can specify multiple)")
+	cmd.Flags().StringArrayVarP(&cmdData.Digests, "digest", "", []string{}, "Copy only stages with the specified digests (can specify multiple)")
+	cmd.Flags().StringVarP(&cmdData.MaxAge, "max-age", "", os.Getenv("WERF_MAX_AGE"), "Copy only stages built within the specified period, e.g. 72h (default $WERF_MAX_AGE)")
+
+	return cmd
+}
+
+func run() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := git_repo.Init(); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	if err := image.Init(); err != nil {
+		return err
+	}
+
+	if err := lrumeta.Init(); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(&commonCmdData); err != nil {
+		return err
+	}
+
+	if err := docker.Init(ctx, *commonCmdData.DockerConfig, *commonCmdData.LogVerbose, *commonCmdData.LogDebug); err != nil {
+		return err
+	}
+
+	ctxWithDockerCli, err := docker.NewContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = ctxWithDockerCli
+
+	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
+	if err != nil {
+		return fmt.Errorf("getting project tmp dir failed: %s", err)
+	}
+	defer tmp_manager.ReleaseProjectDir(projectTmpDir)
+
+	if cmdData.From == "" {
+		return fmt.Errorf("--from=ADDRESS param required")
+	}
+
+	var maxAge time.Duration
+	if cmdData.MaxAge != "" {
+		maxAge, err = time.ParseDuration(cmdData.MaxAge)
+		if err != nil {
+			return fmt.Errorf("bad --max-age=%q: %s", cmdData.MaxAge, err)
+		}
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	werfConfig, err := common.GetOptionalWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	var projectName string
+	if werfConfig != nil {
+		projectName = werfConfig.Meta.Project
+	} else if *commonCmdData.ProjectName != "" {
+		projectName = *commonCmdData.ProjectName
+	} else {
+		return fmt.Errorf("run command in the project directory with werf.yaml or specify --project-name=PROJECT_NAME param")
+	}
+
+	containerRuntime, err := common.GetContainerRuntime(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	stagesStorageAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+	stagesStorage, err := common.GetStagesStorage(stagesStorageAddress, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	if cmdData.From == stagesStorageAddress {
+		return fmt.Errorf("--from and --repo should be different")
+	}
+
+	sourceStagesStorage, err := common.GetStagesStorage(cmdData.From, containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	synchronization, err := common.GetSynchronization(ctx, &commonCmdData, projectName, stagesStorage)
+	if err != nil {
+		return err
+	}
+	stagesStorageCache, err := common.GetStagesStorageCache(synchronization)
+	if err != nil {
+		return err
+	}
+	storageLockManager, err := common.GetStorageLockManager(ctx, synchronization)
+	if err != nil {
+		return err
+	}
+
+	storageManager := manager.NewStorageManager(projectName, stagesStorage, nil, storageLockManager, stagesStorageCache)
+
+	if *commonCmdData.Parallel {
+		storageManager.StagesStorageManager.EnableParallel(int(*commonCmdData.ParallelTasksLimit))
+	}
+
+	syncOptions := manager.SyncStagesOptions{
+		ImageNameList:    cmdData.Images,
+		OnlyImagesStages: len(cmdData.Images) != 0,
+		DigestList:       cmdData.Digests,
+		MaxAge:           maxAge,
+		DryRun:           *commonCmdData.DryRun,
+	}
+
+	if len(syncOptions.ImageNameList) == 0 && werfConfig != nil {
+		imagesNames, err := common.GetManagedImagesNames(ctx, projectName, sourceStagesStorage, werfConfig)
+		if err != nil {
+			return err
+		}
+		syncOptions.ImageNameList = imagesNames
+	}
+
+	logboek.LogOptionalLn()
+	if err := logboek.Context(ctx).Default().LogProcess("Syncing %s into %s", sourceStagesStorage.String(), stagesStorage.String()).DoError(func() error {
+		return storageManager.SyncStagesFromStagesStorage(ctx, sourceStagesStorage, containerRuntime, syncOptions)
+	}); err != nil {
+		return err
+	}
+
+	if *commonCmdData.DryRun {
+		return nil
+	}
+
+	return storageManager.ResetStagesStorageCache(ctx)
+}
@@ -27,19 +27,30 @@ var cmdData struct {
 	Local                          bool
 	LocalLockManagerBaseDir        string
 	LocalStagesStorageCacheBaseDir string
+	LocalDatabase                  string
 
 	TTL  string
 	Host string
 	Port string
+
+	AuthToken   string
+	TLSCertFile string
+	TLSKeyFile  string
 }
 
 var commonCmdData common.CmdData
 
 func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:                   "synchronization",
-		Short:                 "Run synchronization server",
-		Long:                  common.GetLongCommandDescription(`Run synchronization server`),
+		Use:   "synchronization",
+		Short: "Run synchronization server",
+		Long: common.GetLongCommandDescription(`Run synchronization server.
+
+By default locks are kept in memory and lost on restart. Use --local-database to persist locks, stages-storage-cache records and distributed build sessions into the single file.
+
+Use --auth-token to allow only requests with the same --synchronization-token, and --tls-cert-file with --tls-key-file to serve https.
+
+Prometheus metrics of locks, stages-storage-cache and requests are served at /metrics`),
 		DisableFlagsInUseLine: true,
 		Annotations:           map[string]string{},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -68,6 +79,7 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&cmdData.Local, "local", "", common.GetBoolEnvironmentDefaultTrue("WERF_LOCAL"), "Use file lock-manager and file stages-storage-cache (true by default or $WERF_LOCAL)")
 	cmd.Flags().StringVarP(&cmdData.LocalLockManagerBaseDir, "local-lock-manager-base-dir", "", os.Getenv("WERF_LOCAL_LOCK_MANAGER_BASE_DIR"), "Use specified directory as base for file lock-manager (~/.werf/synchronization_server/lock_manager by default or $WERF_LOCAL_LOCK_MANAGER_BASE_DIR)")
 	cmd.Flags().StringVarP(&cmdData.LocalStagesStorageCacheBaseDir, "local-stages-storage-cache-base-dir", "", os.Getenv("WERF_LOCAL_STAGES_STORAGE_CACHE_BASE_DIR"), "Use specified directory as base for file stages-storage-cache (~/.werf/synchronization_server/stages_storage_cache by default or $WERF_LOCAL_STAGES_STORAGE_CACHE_BASE_DIR)")
+	cmd.Flags().StringVarP(&cmdData.LocalDatabase, "local-database", "", os.Getenv("WERF_LOCAL_DATABASE"), "Use specified file as persistent database for lock-manager, stages-storage-cache and distributed build coordinator instead of in-memory lock-manager, file stages-storage-cache and in-memory distributed build coordinator (default $WERF_LOCAL_DATABASE)")
 
 	cmd.Flags().BoolVarP(&cmdData.Kubernetes, "kubernetes", "", common.GetBoolEnvironmentDefaultFalse("WERF_KUBERNETES"), "Use kubernetes lock-manager stages-storage-cache (default $WERF_KUBERNETES)")
 	cmd.Flags().StringVarP(&cmdData.KubernetesNamespacePrefix, "kubernetes-namespace-prefix", "", os.Getenv("WERF_KUBERNETES_NAMESPACE_PREFIX"), "Use specified prefix for namespaces created for lock-manager and stages-storage-cache (defaults to 'werf-synchronization-' when --kubernetes option is used or $WERF_KUBERNETES_NAMESPACE_PREFIX)")
@@ -76,6 +88,10 @@ func NewCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&cmdData.Host, "host", "", os.Getenv("WERF_HOST"), "Bind synchronization server to the specified host (default localhost or $WERF_HOST)")
 	cmd.Flags().StringVarP(&cmdData.Port, "port", "", os.Getenv("WERF_PORT"), "Bind synchronization server to the specified port (default 55581 or $WERF_PORT)")
 
+	cmd.Flags().StringVarP(&cmdData.AuthToken, "auth-token", "", os.Getenv("WERF_AUTH_TOKEN"), "Require specified bearer token for all requests except health checks (default $WERF_AUTH_TOKEN)")
+	cmd.Flags().StringVarP(&cmdData.TLSCertFile, "tls-cert-file", "", os.Getenv("WERF_TLS_CERT_FILE"), "Serve https using specified certificate file, requires --tls-key-file (default $WERF_TLS_CERT_FILE)")
+	cmd.Flags().StringVarP(&cmdData.TLSKeyFile, "tls-key-file", "", os.Getenv("WERF_TLS_KEY_FILE"), "Serve https using specified private key file, requires --tls-cert-file (default $WERF_TLS_KEY_FILE)")
+
 	return cmd
 }
 
@@ -98,8 +114,15 @@ func runSynchronization() error {
 		port = "55581"
 	}
 
+	if (cmdData.TLSCertFile == "") != (cmdData.TLSKeyFile == "") {
+		return fmt.Errorf("both --tls-cert-file and --tls-key-file should be specified")
+	}
+
 	var distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error)
 	var stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error)
+	buildCoordinatorFactoryFunc := func(clientID string) (storage.BuildCoordinator, error) {
+		return storage.NewMemoryBuildCoordinator(), nil
+	}
 
 	if cmdData.Kubernetes {
 		if err := kube.Init(kube.InitOptions{kube.KubeConfigOptions{
@@ -137,6 +160,27 @@ func runSynchronization() error {
 				return fmt.Sprintf("werf-%s", clientID)
 			}), nil
 		}
+	} else if cmdData.LocalDatabase != "" {
+		database, err := synchronization_server.OpenFileDatabase(cmdData.LocalDatabase)
+		if err != nil {
+			return err
+		}
+
+		distributedLockerBackendFactoryFunc = func(clientID string) (distributed_locker.DistributedLockerBackend, error) {
+			store, err := synchronization_server.NewDatabaseOptimisticLockingStore(database, fmt.Sprintf("locks/%s", clientID))
+			if err != nil {
+				return nil, err
+			}
+			return distributed_locker.NewOptimisticLockingStorageBasedBackend(store), nil
+		}
+
+		stagesStorageCacheFactoryFunc = func(clientID string) (storage.StagesStorageCache, error) {
+			return synchronization_server.NewDatabaseStagesStorageCache(database, fmt.Sprintf("stages-storage-cache/%s", clientID)), nil
+		}
+
+		buildCoordinatorFactoryFunc = func(clientID string) (storage.BuildCoordinator, error) {
+			return storage.NewMemoryBuildCoordinatorWithStateStore(synchronization_server.NewDatabaseBuildCoordinatorStateStore(database, fmt.Sprintf("build-coordinator/%s", clientID)))
+		}
 	} else {
 		stagesStorageCacheBaseDir := cmdData.LocalStagesStorageCacheBaseDir
 		if stagesStorageCacheBaseDir == "" {
@@ -153,5 +197,9 @@ func runSynchronization() error {
 		}
 	}
 
-	return synchronization_server.RunSynchronizationServer(ctx, host, port, distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
+	return synchronization_server.RunSynchronizationServer(ctx, host, port, distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc, buildCoordinatorFactoryFunc, synchronization_server.SynchronizationServerOptions{
+		AuthToken:   cmdData.AuthToken,
+		TLSCertFile: cmdData.TLSCertFile,
+		TLSKeyFile:  cmdData.TLSKeyFile,
+	})
 }
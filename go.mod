@@ -56,6 +56,7 @@ require (
 	github.com/otiai10/curr v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/prashantv/gostub v1.0.0
+	github.com/prometheus/client_golang v1.8.0
 	github.com/rodaine/table v1.0.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.8.0
@@ -68,7 +69,7 @@ require (
 	github.com/werf/lockgate v0.0.0-20200729113342-ec2c142f71ea
 	github.com/werf/logboek v0.5.3
 	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
-	github.com/xeipuuv/gojsonschema v1.2.0 // indirect
+	github.com/xeipuuv/gojsonschema v1.2.0
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
 	golang.org/x/net v0.0.0-20201224014010-6772e930b67b
 	gopkg.in/dancannon/gorethink.v3 v3.0.5 // indirect
@@ -77,6 +78,7 @@ require (
 	gopkg.in/ini.v1 v1.57.0
 	gopkg.in/oleiade/reflections.v1 v1.0.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776
 	helm.sh/helm/v3 v3.5.1
 	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
@@ -0,0 +1,329 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/logging"
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+type SinceOptions struct {
+	// Commit enables building of only those images, which are affected by the changes between the commit and the current head commit
+	Commit string
+	// ConfigPaths are werf config files and templates directories relative to the project directory, any change in these paths affects all images.
+	// The files included by the werf config from the project directory are added by the conveyor
+	ConfigPaths []string
+}
+
+// selectImagesAffectedSince returns names of images and artifacts, which should be built.
+// Images, which are not affected by the changes since the commit, are added to the report as reused from the image metadata of the commit.
+func (c *Conveyor) selectImagesAffectedSince(ctx context.Context, opts SinceOptions) ([]string, error) {
+	var imageNames []string
+
+	if err := logboek.Context(ctx).Default().LogProcess("Selecting images affected since commit %s", opts.Commit).DoError(func() error {
+		changedPaths, err := c.getChangedPathsSince(ctx, opts.Commit)
+		if err != nil {
+			return err
+		}
+
+		affectedImages := c.getAffectedImages(changedPaths, opts.ConfigPaths)
+
+		reusedImagesRecords, err := c.getReusedImagesReportRecords(ctx, opts.Commit, affectedImages)
+		if err != nil {
+			return err
+		}
+
+		// images, which have not been built for the commit, affect the images they are dependencies of
+		for _, imageName := range propagateAffectedImages(affectedImages, c.getImagesDependencies(), c.isArtifact) {
+			delete(reusedImagesRecords, imageName)
+		}
+
+		c.reusedImagesReportRecords = reusedImagesRecords
+
+		for _, imageName := range c.getImageNamesToSelectFrom() {
+			if reason, ok := affectedImages[imageName]; ok {
+				logboek.Context(ctx).Default().LogF("%s: %s\n", logging.ImageLogName(imageName, c.werfConfig.GetArtifact(imageName) != nil), reason)
+				imageNames = append(imageNames, imageName)
+			} else if record, ok := reusedImagesRecords[imageName]; ok {
+				logboek.Context(ctx).Default().LogF("%s: reused %s\n", logging.ImageLogName(imageName, false), record.DockerImageName)
+			} else {
+				logboek.Context(ctx).Default().LogF("%s: not affected\n", logging.ImageLogName(imageName, true))
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return imageNames, nil
+}
+
+func (c *Conveyor) getImageNamesToSelectFrom() []string {
+	if len(c.imageNamesToProcess) != 0 {
+		return c.imageNamesToProcess
+	}
+
+	var imageNames []string
+	for _, imageConfig := range c.werfConfig.GetAllImages() {
+		imageNames = append(imageNames, imageConfig.GetName())
+	}
+
+	return imageNames
+}
+
+func (c *Conveyor) getChangedPathsSince(ctx context.Context, commit string) ([]string, error) {
+	patch, err := c.giterminismManager.LocalGitRepo().GetOrCreatePatch(ctx, git_repo.PatchOptions{
+		FromCommit:  commit,
+		ToCommit:    c.giterminismManager.HeadCommit(),
+		PathMatcher: path_matcher.NewTruePathMatcher(),
+		WithBinary:  true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get changes between commits %s and %s: %s", commit, c.giterminismManager.HeadCommit(), err)
+	}
+
+	logboek.Context(ctx).Info().LogF("Changed paths since commit %s: %d\n", commit, len(patch.GetPaths()))
+
+	return patch.GetPaths(), nil
+}
+
+// getAffectedImages returns the reason of the change by the name of each affected image or artifact
+func (c *Conveyor) getAffectedImages(changedPaths []string, configPaths []string) map[string]string {
+	allImages := c.getAllImagesAndArtifacts()
+
+	affected := map[string]string{}
+
+	var configGlobs []string
+	for _, configPath := range append(append([]string{}, configPaths...), c.werfConfig.LocalIncludeRelPaths...) {
+		configGlobs = append(configGlobs, filepath.Join(c.giterminismManager.RelativeToGitProjectDir(), configPath))
+	}
+
+	if len(configGlobs) != 0 {
+		if configPath := findMatchedPath(changedPaths, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{IncludeGlobs: configGlobs})); configPath != "" {
+			for _, imageConfig := range allImages {
+				affected[imageConfig.GetName()] = fmt.Sprintf("werf config changed (%s)", configPath)
+			}
+
+			return affected
+		}
+	}
+
+	for _, imageConfig := range allImages {
+		if reason := c.getImageChangeReason(imageConfig, changedPaths); reason != "" {
+			affected[imageConfig.GetName()] = reason
+		}
+	}
+
+	propagateAffectedImages(affected, c.getImagesDependencies(), c.isArtifact)
+
+	return affected
+}
+
+func (c *Conveyor) getAllImagesAndArtifacts() []config.ImageInterface {
+	var allImages []config.ImageInterface
+	allImages = append(allImages, c.werfConfig.GetAllImages()...)
+	for _, artifact := range c.werfConfig.Artifacts {
+		allImages = append(allImages, artifact)
+	}
+
+	return allImages
+}
+
+// getImagesDependencies returns names of the images each image or artifact is based on or imports files from
+func (c *Conveyor) getImagesDependencies() map[string][]string {
+	dependencies := map[string][]string{}
+	for _, imageConfig := range c.getAllImagesAndArtifacts() {
+		dependencies[imageConfig.GetName()] = nil
+		for _, dep := range c.werfConfig.GetImageDependencies(imageConfig) {
+			dependencies[imageConfig.GetName()] = append(dependencies[imageConfig.GetName()], dep.GetName())
+		}
+	}
+
+	return dependencies
+}
+
+func (c *Conveyor) isArtifact(imageName string) bool {
+	return c.werfConfig.GetArtifact(imageName) != nil
+}
+
+// propagateAffectedImages marks images affected by the changes of the images they are based on or import files from.
+// Names of newly affected images are returned.
+func propagateAffectedImages(affected map[string]string, dependencies map[string][]string, isArtifact func(imageName string) bool) []string {
+	var imageNames []string
+	for imageName := range dependencies {
+		imageNames = append(imageNames, imageName)
+	}
+	sort.Strings(imageNames)
+
+	var newAffectedImageNames []string
+	for {
+		var newAffected bool
+
+		for _, imageName := range imageNames {
+			if _, ok := affected[imageName]; ok {
+				continue
+			}
+
+			for _, depName := range dependencies[imageName] {
+				if _, ok := affected[depName]; ok {
+					affected[imageName] = fmt.Sprintf("dependency %s affected", logging.ImageLogName(depName, isArtifact(depName)))
+					newAffectedImageNames = append(newAffectedImageNames, imageName)
+					newAffected = true
+					break
+				}
+			}
+		}
+
+		if !newAffected {
+			break
+		}
+	}
+
+	return newAffectedImageNames
+}
+
+func (c *Conveyor) getImageChangeReason(imageConfig config.ImageInterface, changedPaths []string) string {
+	switch i := imageConfig.(type) {
+	case config.StapelImageInterface:
+		imageBaseConfig := i.ImageBaseConfig()
+		if imageBaseConfig.Git == nil {
+			return ""
+		}
+
+		for _, remoteGitMappingConfig := range imageBaseConfig.Git.Remote {
+			if remoteGitMappingConfig.Commit == "" && remoteGitMappingConfig.Tag == "" {
+				return fmt.Sprintf("remote git %s is not pinned to a commit or a tag", remoteGitMappingConfig.Name)
+			}
+		}
+
+		for _, localGitMappingConfig := range imageBaseConfig.Git.Local {
+			gitMappingPathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+				BasePath:     localGitMappingConfig.GitMappingAdd(),
+				IncludeGlobs: localGitMappingConfig.GitMappingIncludePaths(),
+				ExcludeGlobs: localGitMappingConfig.GitMappingExcludePath(),
+			})
+
+			changedPath := findMatchedPath(changedPaths, gitMappingPathMatcher)
+			if changedPath == "" {
+				continue
+			}
+
+			return fmt.Sprintf("git mapping changed (%s)", changedPath)
+		}
+	case *config.ImageFromDockerfile:
+		if len(i.ContextAddFile) != 0 {
+			return "contextAddFile files are not tracked by git"
+		}
+
+		contextPath := filepath.Join(c.giterminismManager.RelativeToGitProjectDir(), i.Context)
+		dockerfilePath := filepath.Join(contextPath, i.Dockerfile)
+
+		if changedPath := findMatchedPath(changedPaths, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{IncludeGlobs: []string{contextPath, dockerfilePath}})); changedPath != "" {
+			return fmt.Sprintf("dockerfile context changed (%s)", changedPath)
+		}
+	}
+
+	return ""
+}
+
+func (c *Conveyor) getReusedImagesReportRecords(ctx context.Context, commit string, affectedImages map[string]string) (map[string]ReportImageRecord, error) {
+	var imageNames []string
+	for _, imageName := range c.getImageNamesToSelectFrom() {
+		if _, ok := affectedImages[imageName]; !ok && c.werfConfig.GetArtifact(imageName) == nil {
+			imageNames = append(imageNames, imageName)
+		}
+	}
+
+	if len(imageNames) == 0 {
+		return nil, nil
+	}
+
+	imageMetadataByImageName, _, err := c.StorageManager.StagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, c.projectName(), imageNames)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image metadata: %s", err)
+	}
+
+	records := map[string]ReportImageRecord{}
+	for _, imageName := range imageNames {
+		var stageIDs []string
+		for stageID, commits := range imageMetadataByImageName[imageName] {
+			for _, imageCommit := range commits {
+				if imageCommit == commit {
+					stageIDs = append(stageIDs, stageID)
+					break
+				}
+			}
+		}
+
+		desc, err := c.getNewestStageDescription(ctx, stageIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		if desc == nil {
+			affectedImages[imageName] = fmt.Sprintf("image has not been built for commit %s", commit)
+			continue
+		}
+
+		records[imageName] = ReportImageRecord{
+			WerfImageName:    imageName,
+			DockerRepo:       desc.Info.Repository,
+			DockerTag:        desc.Info.Tag,
+			DockerImageID:    desc.Info.ID,
+			DockerImageName:  desc.Info.Name,
+			ReusedFromCommit: commit,
+		}
+	}
+
+	return records, nil
+}
+
+func (c *Conveyor) getNewestStageDescription(ctx context.Context, stageIDs []string) (*imagePkg.StageDescription, error) {
+	var ids []*imagePkg.StageID
+	for _, stageID := range stageIDs {
+		id, err := imagePkg.ParseStageID(stageID)
+		if err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: Skipping image metadata with stage ID %s: %s\n", stageID, err)
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].UniqueID > ids[j].UniqueID
+	})
+
+	for _, id := range ids {
+		desc, err := c.StorageManager.StagesStorage.GetStageDescription(ctx, c.projectName(), id.Digest, id.UniqueID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get stage %s description: %s", id.String(), err)
+		}
+
+		if desc != nil {
+			return desc, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func findMatchedPath(paths []string, pathMatcher path_matcher.PathMatcher) string {
+	for _, path := range paths {
+		if pathMatcher.IsPathMatched(filepath.FromSlash(strings.TrimPrefix(path, "/"))) {
+			return path
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,221 @@
+package build
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+type fakeAffectedImagesGiterminismManager struct {
+	giterminism_manager.Interface
+}
+
+func (m fakeAffectedImagesGiterminismManager) RelativeToGitProjectDir() string {
+	return "project"
+}
+
+func newTestStapelImage(name string, gitLocal ...*config.ExportBase) *config.StapelImage {
+	gitManager := &config.GitManager{}
+	for _, exportBase := range gitLocal {
+		gitManager.Local = append(gitManager.Local, &config.GitLocal{
+			GitLocalExport: &config.GitLocalExport{
+				GitExportBase: &config.GitExportBase{
+					GitExport: &config.GitExport{ExportBase: exportBase},
+				},
+			},
+		})
+	}
+
+	return &config.StapelImage{StapelImageBase: &config.StapelImageBase{Name: name, Git: gitManager}}
+}
+
+func TestGetImageChangeReason(t *testing.T) {
+	tests := []struct {
+		name         string
+		image        config.ImageInterface
+		changedPaths []string
+		expected     string
+	}{
+		{
+			name:         "image without git mappings",
+			image:        &config.StapelImage{StapelImageBase: &config.StapelImageBase{Name: "app"}},
+			changedPaths: []string{"app/main.go"},
+			expected:     "",
+		},
+		{
+			name:         "changed path inside git mapping",
+			image:        newTestStapelImage("app", &config.ExportBase{Add: "/app", To: "/app"}),
+			changedPaths: []string{"README.md", "app/main.go"},
+			expected:     "git mapping changed (app/main.go)",
+		},
+		{
+			name:         "changed path outside git mapping",
+			image:        newTestStapelImage("app", &config.ExportBase{Add: "/app", To: "/app"}),
+			changedPaths: []string{"README.md", "backend/main.go"},
+			expected:     "",
+		},
+		{
+			name:         "changed path excluded from git mapping",
+			image:        newTestStapelImage("app", &config.ExportBase{Add: "/", To: "/app", ExcludePaths: []string{"docs"}}),
+			changedPaths: []string{"docs/index.md"},
+			expected:     "",
+		},
+		{
+			name:         "changed path included into git mapping",
+			image:        newTestStapelImage("app", &config.ExportBase{Add: "/", To: "/app", IncludePaths: []string{"src"}}),
+			changedPaths: []string{"docs/index.md", "src/main.go"},
+			expected:     "git mapping changed (src/main.go)",
+		},
+		{
+			name: "remote git mapping without commit and tag",
+			image: &config.StapelImage{StapelImageBase: &config.StapelImageBase{
+				Name: "app",
+				Git: &config.GitManager{Remote: []*config.GitRemote{
+					{Name: "lib", GitRemoteExport: &config.GitRemoteExport{Branch: "main"}},
+				}},
+			}},
+			expected: "remote git lib is not pinned to a commit or a tag",
+		},
+		{
+			name: "remote git mapping pinned to commit",
+			image: &config.StapelImage{StapelImageBase: &config.StapelImageBase{
+				Name: "app",
+				Git: &config.GitManager{Remote: []*config.GitRemote{
+					{Name: "lib", GitRemoteExport: &config.GitRemoteExport{Commit: "9f1b8e4a5f64a7c0d0f3c8a0a2b8b4f0e2c7d6a1"}},
+				}},
+			}},
+			expected: "",
+		},
+	}
+
+	c := &Conveyor{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.getImageChangeReason(tt.image, tt.changedPaths); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPropagateAffectedImages(t *testing.T) {
+	// artifact <- base <- app <- app2, other is independent
+	dependencies := map[string][]string{
+		"artifact": nil,
+		"base":     {"artifact"},
+		"app":      {"base"},
+		"app2":     {"app"},
+		"other":    nil,
+	}
+	isArtifact := func(imageName string) bool { return imageName == "artifact" }
+
+	tests := []struct {
+		name                 string
+		affected             []string
+		expectedNewAffected  []string
+		expectedReasonByName map[string]string
+	}{
+		{
+			name:                "nothing affected",
+			expectedNewAffected: nil,
+		},
+		{
+			name:                "leaf affected",
+			affected:            []string{"app2"},
+			expectedNewAffected: nil,
+		},
+		{
+			name:                "artifact affected",
+			affected:            []string{"artifact"},
+			expectedNewAffected: []string{"app", "app2", "base"},
+			expectedReasonByName: map[string]string{
+				"base": "dependency artifact affected",
+				"app":  "dependency base affected",
+				"app2": "dependency app affected",
+			},
+		},
+		{
+			name:                "middle image affected",
+			affected:            []string{"app", "other"},
+			expectedNewAffected: []string{"app2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			affected := map[string]string{}
+			for _, imageName := range tt.affected {
+				affected[imageName] = "changed"
+			}
+
+			newAffected := propagateAffectedImages(affected, dependencies, isArtifact)
+			sort.Strings(newAffected)
+
+			if !reflect.DeepEqual(newAffected, tt.expectedNewAffected) {
+				t.Errorf("expected newly affected images %v, got %v", tt.expectedNewAffected, newAffected)
+			}
+
+			if len(affected) != len(tt.affected)+len(tt.expectedNewAffected) {
+				t.Errorf("unexpected affected images %v", affected)
+			}
+
+			for imageName, expectedReason := range tt.expectedReasonByName {
+				if affected[imageName] != expectedReason {
+					t.Errorf("expected %s reason %q, got %q", imageName, expectedReason, affected[imageName])
+				}
+			}
+		})
+	}
+}
+
+func TestConveyor_GetAffectedImages(t *testing.T) {
+	c := &Conveyor{
+		werfConfig: &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				newTestStapelImage("app", &config.ExportBase{Add: "/project/app", To: "/app"}),
+				newTestStapelImage("other", &config.ExportBase{Add: "/project/other", To: "/other"}),
+			},
+			LocalIncludeRelPaths: []string{"services/backend/werf.yaml"},
+		},
+		giterminismManager: fakeAffectedImagesGiterminismManager{},
+	}
+
+	tests := []struct {
+		name         string
+		changedPaths []string
+		expected     map[string]string
+	}{
+		{
+			name:         "werf config changed",
+			changedPaths: []string{"project/app/main.go", "project/werf.yaml"},
+			expected: map[string]string{
+				"app":   "werf config changed (project/werf.yaml)",
+				"other": "werf config changed (project/werf.yaml)",
+			},
+		},
+		{
+			name:         "file included by werf config changed",
+			changedPaths: []string{"project/services/backend/werf.yaml"},
+			expected: map[string]string{
+				"app":   "werf config changed (project/services/backend/werf.yaml)",
+				"other": "werf config changed (project/services/backend/werf.yaml)",
+			},
+		},
+		{
+			name:         "file not included by werf config changed",
+			changedPaths: []string{"project/services/frontend/werf.yaml", "project/app/main.go"},
+			expected:     map[string]string{"app": "git mapping changed (project/app/main.go)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if affected := c.getAffectedImages(tt.changedPaths, []string{"werf.yaml"}); !reflect.DeepEqual(affected, tt.expected) {
+				t.Errorf("expected affected images %v, got %v", tt.expected, affected)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	imagePkg "github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -42,6 +43,9 @@ type BuildOptions struct {
 	ReportPath   string
 	ReportFormat ReportFormat
 
+	Since       SinceOptions
+	Distributed DistributedBuildOptions
+
 	DryRun bool
 }
 
@@ -68,7 +72,7 @@ func NewBuildPhase(c *Conveyor, opts BuildPhaseOptions) *BuildPhase {
 	return &BuildPhase{
 		BasePhase:         BasePhase{c},
 		BuildPhaseOptions: opts,
-		ImagesReport:      &ImagesReport{Images: make(map[string]ReportImageRecord)},
+		ImagesReport:      NewImagesReport(),
 	}
 }
 
@@ -92,6 +96,17 @@ type ReportFormat string
 type ImagesReport struct {
 	mux    sync.Mutex
 	Images map[string]ReportImageRecord
+
+	stagesRecords         map[string][]ReportStageRecord
+	platformStagesRecords map[string]map[string][]ReportStageRecord
+}
+
+func NewImagesReport() *ImagesReport {
+	return &ImagesReport{
+		Images:                make(map[string]ReportImageRecord),
+		stagesRecords:         make(map[string][]ReportStageRecord),
+		platformStagesRecords: make(map[string]map[string][]ReportStageRecord),
+	}
 }
 
 func (report *ImagesReport) SetImageRecord(name string, imageRecord ReportImageRecord) {
@@ -100,6 +115,35 @@ func (report *ImagesReport) SetImageRecord(name string, imageRecord ReportImageR
 	report.Images[name] = imageRecord
 }
 
+func (report *ImagesReport) AddStageRecord(imageName string, stageRecord ReportStageRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+	report.stagesRecords[imageName] = append(report.stagesRecords[imageName], stageRecord)
+}
+
+func (report *ImagesReport) GetStageRecords(imageName string) []ReportStageRecord {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+	return report.stagesRecords[imageName]
+}
+
+// SetPlatformStageRecords sets the stage records of the image built for the platform by the platform conveyor
+func (report *ImagesReport) SetPlatformStageRecords(imageName, platform string, stageRecords []ReportStageRecord) {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+
+	if report.platformStagesRecords[imageName] == nil {
+		report.platformStagesRecords[imageName] = map[string][]ReportStageRecord{}
+	}
+	report.platformStagesRecords[imageName][platform] = stageRecords
+}
+
+func (report *ImagesReport) GetPlatformStageRecords(imageName string) map[string][]ReportStageRecord {
+	report.mux.Lock()
+	defer report.mux.Unlock()
+	return report.platformStagesRecords[imageName]
+}
+
 func (report *ImagesReport) ToJsonData() ([]byte, error) {
 	report.mux.Lock()
 	defer report.mux.Unlock()
@@ -113,6 +157,7 @@ func (report *ImagesReport) ToJsonData() ([]byte, error) {
 	return data, nil
 }
 
+// ToEnvFileData renders the docker image names of the images only, the stages are not reported in this format
 func (report *ImagesReport) ToEnvFileData() []byte {
 	report.mux.Lock()
 	defer report.mux.Unlock()
@@ -148,6 +193,48 @@ type ReportImageRecord struct {
 	DockerTag       string
 	DockerImageID   string
 	DockerImageName string
+	Stages          []ReportStageRecord
+	// ReusedFromCommit is set when the image is not affected by the changes since the commit and has not been built
+	ReusedFromCommit string `json:",omitempty"`
+	// Platforms are set when the image is the manifest list of the images built for these platforms
+	Platforms []string `json:",omitempty"`
+	// PlatformStages contains the stages of the images of the manifest list by platform
+	PlatformStages map[string][]ReportStageRecord `json:",omitempty"`
+}
+
+type ReportStageSource string
+
+const (
+	ReportStageSourceCache     ReportStageSource = "cache"
+	ReportStageSourceSecondary ReportStageSource = "secondary"
+	ReportStageSourceBuilt     ReportStageSource = "built"
+)
+
+// newReportStageSource classifies the stage: found in the stages storage (or stored there by another process meanwhile),
+// copied from the secondary stages storage or built
+func newReportStageSource(foundInStagesStorage, foundInSecondaryStagesStorage bool) ReportStageSource {
+	switch {
+	case foundInStagesStorage:
+		return ReportStageSourceCache
+	case foundInSecondaryStagesStorage:
+		return ReportStageSourceSecondary
+	default:
+		return ReportStageSourceBuilt
+	}
+}
+
+type ReportStageRecord struct {
+	Name            string
+	Digest          string
+	ContentDigest   string
+	Source          ReportStageSource
+	DockerImageName string
+	DockerImageID   string
+	Size            int64
+	StartedAt       time.Time
+	DurationSeconds float64
+	// GitCommits contains commits of the git mappings (by git mapping full name) the stage has been built with
+	GitCommits map[string]string `json:",omitempty"`
 }
 
 func (phase *BuildPhase) Name() string {
@@ -163,6 +250,10 @@ func (phase *BuildPhase) AfterImages(ctx context.Context) error {
 }
 
 func (phase *BuildPhase) createReport(ctx context.Context) error {
+	for imageName, record := range phase.Conveyor.reusedImagesReportRecords {
+		phase.ImagesReport.SetImageRecord(imageName, record)
+	}
+
 	for _, img := range phase.Conveyor.images {
 		if img.isArtifact {
 			continue
@@ -175,6 +266,19 @@ func (phase *BuildPhase) createReport(ctx context.Context) error {
 			DockerTag:       desc.Info.Tag,
 			DockerImageID:   desc.Info.ID,
 			DockerImageName: desc.Info.Name,
+			Stages:          phase.ImagesReport.GetStageRecords(img.GetName()),
+		})
+	}
+
+	for _, img := range phase.Conveyor.multiplatformImages {
+		phase.ImagesReport.SetImageRecord(img.name, ReportImageRecord{
+			WerfImageName:   img.name,
+			DockerRepo:      img.stageDesc.Info.Repository,
+			DockerTag:       img.stageDesc.Info.Tag,
+			DockerImageID:   img.stageDesc.Info.ID,
+			DockerImageName: img.stageDesc.Info.Name,
+			Platforms:       img.platforms,
+			PlatformStages:  phase.ImagesReport.GetPlatformStageRecords(img.name),
 		})
 	}
 
@@ -229,7 +333,7 @@ func (phase *BuildPhase) AfterImageStages(ctx context.Context, img *Image) error
 		return err
 	}
 
-	if err := phase.publishImageMetadata(ctx, img); err != nil {
+	if err := phase.Conveyor.publishImageMetadata(ctx, img.GetName(), img.GetStageID()); err != nil {
 		return err
 	}
 
@@ -246,28 +350,28 @@ func (phase *BuildPhase) addManagedImage(ctx context.Context, img *Image) error
 	return nil
 }
 
-func (phase *BuildPhase) publishImageMetadata(ctx context.Context, img *Image) error {
-	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Processing image %s git metadata", img.GetName())).
+func (c *Conveyor) publishImageMetadata(ctx context.Context, imageName, stageID string) error {
+	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Processing image %s git metadata", imageName)).
 		DoError(func() error {
 			var commits []string
 
-			headCommit := phase.Conveyor.giterminismManager.HeadCommit()
+			headCommit := c.giterminismManager.HeadCommit()
 			commits = append(commits, headCommit)
 
-			if phase.Conveyor.GetLocalGitRepoVirtualMergeOptions().VirtualMerge {
-				fromCommit := phase.Conveyor.GetLocalGitRepoVirtualMergeOptions().VirtualMergeFromCommit
+			if c.GetLocalGitRepoVirtualMergeOptions().VirtualMerge {
+				fromCommit := c.GetLocalGitRepoVirtualMergeOptions().VirtualMergeFromCommit
 				commits = append(commits, fromCommit)
 			}
 
 			for _, commit := range commits {
-				exists, err := phase.Conveyor.StorageManager.StagesStorage.IsImageMetadataExist(ctx, phase.Conveyor.projectName(), img.GetName(), commit, img.GetStageID())
+				exists, err := c.StorageManager.StagesStorage.IsImageMetadataExist(ctx, c.projectName(), imageName, commit, stageID)
 				if err != nil {
-					return fmt.Errorf("unable to get image %s metadata by commit %s and stage ID %s: %s", img.GetName(), commit, img.GetStageID(), err)
+					return fmt.Errorf("unable to get image %s metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
 				}
 
 				if !exists {
-					if err := phase.Conveyor.StorageManager.StagesStorage.PutImageMetadata(ctx, phase.Conveyor.projectName(), img.GetName(), commit, img.GetStageID()); err != nil {
-						return fmt.Errorf("unable to put image %s metadata by commit %s and stage ID %s: %s", img.GetName(), commit, img.GetStageID(), err)
+					if err := c.StorageManager.StagesStorage.PutImageMetadata(ctx, c.projectName(), imageName, commit, stageID); err != nil {
+						return fmt.Errorf("unable to put image %s metadata by commit %s and stage ID %s: %s", imageName, commit, stageID, err)
 					}
 				}
 			}
@@ -286,9 +390,11 @@ func (phase *BuildPhase) getPrevNonEmptyStageImageSize() int64 {
 }
 
 func (phase *BuildPhase) OnImageStage(ctx context.Context, img *Image, stg stage.Interface) error {
-	return phase.StagesIterator.OnImageStage(ctx, img, stg, func(img *Image, stg stage.Interface, isEmpty bool) error {
-		return phase.onImageStage(ctx, img, stg, isEmpty)
-	})
+	return tracing.Do(ctx, "build.OnImageStage", func(ctx context.Context) error {
+		return phase.StagesIterator.OnImageStage(ctx, img, stg, func(img *Image, stg stage.Interface, isEmpty bool) error {
+			return phase.onImageStage(ctx, img, stg, isEmpty)
+		})
+	}, "werf.image", img.GetName(), "werf.stage", string(stg.Name()))
 }
 
 func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage.Interface, isEmpty bool) error {
@@ -296,11 +402,13 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		return nil
 	}
 
+	startedAt := time.Now()
+
 	if err := stg.FetchDependencies(ctx, phase.Conveyor, phase.Conveyor.ContainerRuntime); err != nil {
 		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
 	}
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if !isImageFirstStage(stg) {
 		if phase.StagesIterator.PrevNonEmptyStage == nil {
 			panic(fmt.Sprintf("expected PrevNonEmptyStage to be set for image %q stage %s", img.GetName(), stg.Name()))
 		}
@@ -333,6 +441,8 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 			}
 		}
 
+		phase.addReportStageRecord(img, stg, newReportStageSource(true, false), startedAt)
+
 		return nil
 	}
 
@@ -347,6 +457,21 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 			return fmt.Errorf("stages required")
 		}
 
+		if phase.Distributed.IsEnabled() {
+			if foundStage, err := phase.claimStageOrWaitForStoredStage(ctx, img, stg); err != nil {
+				return err
+			} else if foundStage {
+				logboek.Context(ctx).Default().LogFHighlight("Use cache image for %s built by another process\n", stg.LogDetailedName())
+				logImageInfo(ctx, stg.GetImage(), phase.getPrevNonEmptyStageImageSize(), true)
+
+				logboek.Context(ctx).LogOptionalLn()
+
+				phase.addReportStageRecord(img, stg, newReportStageSource(true, false), startedAt)
+
+				return nil
+			}
+		}
+
 		// Will build a new stage
 		i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), uuid.New().String())
 		stg.SetImage(i)
@@ -354,6 +479,15 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		if err := phase.fetchBaseImageForStage(ctx, img, stg); err != nil {
 			return err
 		}
+
+		// Resources acquired while preparing the stage (e.g. locks of cache mounts) are released right after the build container run
+		// or here, if the stage has not been built
+		defer func() {
+			if err := stg.PostRunHook(ctx, phase.Conveyor); err != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: %s postRunHook failed: %s\n", stg.LogDetailedName(), err)
+			}
+		}()
+
 		if err := phase.prepareStageInstructions(ctx, img, stg); err != nil {
 			return err
 		}
@@ -369,16 +503,46 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 	// Add managed image record only if there was at least one newly built stage
 	phase.ShouldAddManagedImageRecord = true
 
+	phase.addReportStageRecord(img, stg, newReportStageSource(false, foundSuitableSecondaryStage), startedAt)
+
 	return nil
 }
 
+func (phase *BuildPhase) addReportStageRecord(img *Image, stg stage.Interface, source ReportStageSource, startedAt time.Time) {
+	desc := stg.GetImage().GetStageDescription()
+
+	var gitCommits map[string]string
+	for _, gm := range stg.GetGitMappings() {
+		// stages preceding the gitArchive stage have no git mappings commits
+		if commitInfo, err := gm.GetBuiltImageCommitInfo(desc.Info.Labels); err == nil {
+			if gitCommits == nil {
+				gitCommits = map[string]string{}
+			}
+			gitCommits[gm.GetFullName()] = commitInfo.Commit
+		}
+	}
+
+	phase.ImagesReport.AddStageRecord(img.GetName(), ReportStageRecord{
+		Name:            string(stg.Name()),
+		Digest:          stg.GetDigest(),
+		ContentDigest:   stg.GetContentDigest(),
+		Source:          source,
+		DockerImageName: desc.Info.Name,
+		DockerImageID:   desc.Info.ID,
+		Size:            desc.Info.Size,
+		StartedAt:       startedAt,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+		GitCommits:      gitCommits,
+	})
+}
+
 func (phase *BuildPhase) findAndFetchStageFromSecondaryStagesStorage(ctx context.Context, img *Image, stg stage.Interface) (bool, error) {
 	foundSuitableStage := false
 
 	atomicCopySuitableStageFromSecondaryStagesStorage := func(secondaryStageDesc *image.StageDescription, secondaryStagesStorage storage.StagesStorage) error {
 		// Lock the primary stages storage
-		if lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest()); err != nil {
-			return fmt.Errorf("unable to lock project %s digest %s: %s", phase.Conveyor.projectName(), stg.GetDigest(), err)
+		if lock, err := phase.lockStage(ctx, stg); err != nil {
+			return err
 		} else {
 			defer phase.Conveyor.StorageLockManager.Unlock(ctx, lock)
 		}
@@ -455,7 +619,7 @@ func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image,
 		if err := img.FetchBaseImage(ctx, phase.Conveyor); err != nil {
 			return fmt.Errorf("unable to fetch base image %s for stage %s: %s", img.GetBaseImage().Name(), stg.LogDetailedName(), err)
 		}
-	} else if stg.Name() == "dockerfile" {
+	} else if isImageFirstStage(stg) {
 		return nil
 	} else {
 		return phase.Conveyor.StorageManager.FetchStage(ctx, phase.StagesIterator.PrevBuiltStage)
@@ -532,7 +696,7 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 	}
 
 	switch stg.(type) {
-	case *stage.DockerfileStage:
+	case *stage.DockerfileStage, *stage.DockerfileInstructionStage:
 		var buildArgs []string
 
 		for key, value := range serviceLabels {
@@ -623,11 +787,19 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 	}
 
 	if err := logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
-		return stageImage.Build(ctx, phase.ImageBuildOptions)
+		return tracing.Do(ctx, "build.BuildStageImage", func(ctx context.Context) error {
+			return stageImage.Build(ctx, phase.ImageBuildOptions)
+		}, "werf.container_runtime", phase.Conveyor.ContainerRuntime.String())
 	}); err != nil {
 		return fmt.Errorf("failed to build image for stage %s with digest %s: %s", stg.Name(), stg.GetDigest(), err)
 	}
 
+	// Resources of the build container (e.g. locks of cache mounts) are released before locking the stage,
+	// so these locks are never held while waiting for the stage lock
+	if err := stg.PostRunHook(ctx, phase.Conveyor); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: %s postRunHook failed: %s\n", stg.LogDetailedName(), err)
+	}
+
 	if v := os.Getenv("WERF_TEST_ATOMIC_STAGE_BUILD__SLEEP_SECONDS_BEFORE_STAGE_SAVE"); v != "" {
 		seconds := 0
 		fmt.Sscanf(v, "%d", &seconds)
@@ -635,8 +807,8 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 		time.Sleep(time.Duration(seconds) * time.Second)
 	}
 
-	if lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest()); err != nil {
-		return fmt.Errorf("unable to lock project %s digest %s: %s", phase.Conveyor.projectName(), stg.GetDigest(), err)
+	if lock, err := phase.lockStage(ctx, stg); err != nil {
+		return err
 	} else {
 		defer phase.Conveyor.StorageLockManager.Unlock(ctx, lock)
 	}
@@ -664,7 +836,9 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 			phase.Conveyor.SetStageImage(stageImageObj)
 
 			if err := logboek.Context(ctx).Info().LogProcess("Store stage").DoError(func() error {
-				if err := phase.Conveyor.StorageManager.StagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage}); err != nil {
+				if err := tracing.Do(ctx, "storage.StoreImage", func(ctx context.Context) error {
+					return phase.Conveyor.StorageManager.StagesStorage.StoreImage(ctx, &container_runtime.DockerImage{Image: stageImage})
+				}, "werf.storage", phase.Conveyor.StorageManager.StagesStorage.String()); err != nil {
 					return fmt.Errorf("unable to store stage %s digest %s image %s into repo %s: %s", stg.LogDetailedName(), stg.GetDigest(), stageImage.Name(), phase.Conveyor.StorageManager.StagesStorage.String(), err)
 				}
 				if desc, err := phase.Conveyor.StorageManager.StagesStorage.GetStageDescription(ctx, phase.Conveyor.projectName(), stg.GetDigest(), uniqueID); err != nil {
@@ -688,6 +862,69 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 	}
 }
 
+// claimStageOrWaitForStoredStage claims the stage to be built by the process in the distributed build.
+// If the stage is claimed by another alive process, the stage is waited for to be stored into the repo,
+// the claim of the dead process expires after the lease TTL and the stage is claimed by the process.
+func (phase *BuildPhase) claimStageOrWaitForStoredStage(ctx context.Context, img *Image, stg stage.Interface) (bool, error) {
+	opts := phase.Distributed
+
+	for logWaiting := true; ; logWaiting = false {
+		if claimed, err := opts.Coordinator.ClaimStage(ctx, phase.Conveyor.projectName(), opts.SessionID, opts.RunnerID, stg.GetDigest()); err != nil {
+			return false, fmt.Errorf("unable to claim stage %s digest %s using build coordinator %s: %s", stg.LogDetailedName(), stg.GetDigest(), opts.Coordinator.String(), err)
+		} else if claimed {
+			return false, nil
+		}
+
+		if logWaiting {
+			logboek.Context(ctx).Default().LogF("Waiting for stage %s digest %s being built by another process\n", stg.LogDetailedName(), stg.GetDigest())
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(distributedStagePollPeriod):
+		}
+
+		if foundStage, err := phase.selectStoredStage(ctx, img, stg); err != nil {
+			return false, err
+		} else if foundStage {
+			return true, nil
+		}
+	}
+}
+
+// selectStoredStage sets the stage image if the suitable stage has been stored into the repo by another process
+func (phase *BuildPhase) selectStoredStage(ctx context.Context, img *Image, stg stage.Interface) (bool, error) {
+	stages, err := phase.Conveyor.StorageManager.GetStagesByDigest(ctx, stg.LogDetailedName(), stg.GetDigest())
+	if err != nil {
+		return false, err
+	}
+
+	stageDesc, err := phase.Conveyor.StorageManager.SelectSuitableStage(ctx, phase.Conveyor, stg, stages)
+	if err != nil {
+		return false, err
+	} else if stageDesc == nil {
+		return false, nil
+	}
+
+	i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), stageDesc.Info.Name)
+	i.SetStageDescription(stageDesc)
+	stg.SetImage(i)
+
+	return true, nil
+}
+
+func (phase *BuildPhase) lockStage(ctx context.Context, stg stage.Interface) (storage.LockHandle, error) {
+	_, span := tracing.StartSpan(ctx, "storage.LockStage", "werf.stage_digest", stg.GetDigest())
+	lock, err := phase.Conveyor.StorageLockManager.LockStage(ctx, phase.Conveyor.projectName(), stg.GetDigest())
+	span.End(err)
+	if err != nil {
+		return storage.LockHandle{}, fmt.Errorf("unable to lock project %s digest %s: %s", phase.Conveyor.projectName(), stg.GetDigest(), err)
+	}
+
+	return lock, nil
+}
+
 func introspectStage(ctx context.Context, s stage.Interface) error {
 	return logboek.Context(ctx).Info().LogProcess("Introspecting stage %s", s.Name()).
 		Options(func(options types.LogProcessOptionsInterface) {
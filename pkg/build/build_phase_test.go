@@ -0,0 +1,218 @@
+package build
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/git_repo"
+	imagePkg "github.com/werf/werf/pkg/image"
+)
+
+type fakeReportStageImage struct {
+	container_runtime.ImageInterface
+
+	desc *imagePkg.StageDescription
+}
+
+func (i fakeReportStageImage) GetStageDescription() *imagePkg.StageDescription {
+	return i.desc
+}
+
+type fakeReportStage struct {
+	stage.Interface
+
+	name          stage.StageName
+	digest        string
+	contentDigest string
+	image         container_runtime.ImageInterface
+	gitMappings   []*stage.GitMapping
+}
+
+func (s fakeReportStage) Name() stage.StageName                      { return s.name }
+func (s fakeReportStage) GetDigest() string                          { return s.digest }
+func (s fakeReportStage) GetContentDigest() string                   { return s.contentDigest }
+func (s fakeReportStage) GetImage() container_runtime.ImageInterface { return s.image }
+func (s fakeReportStage) GetGitMappings() []*stage.GitMapping        { return s.gitMappings }
+
+func newTestGitMapping(repoName, name string) *stage.GitMapping {
+	gm := stage.NewGitMapping()
+	gm.Name = name
+	gm.LocalGitRepo = &git_repo.Local{Base: git_repo.Base{Name: repoName}}
+
+	return gm
+}
+
+func newTestReportStage(name stage.StageName, labels map[string]string, gitMappings ...*stage.GitMapping) fakeReportStage {
+	return fakeReportStage{
+		name:          name,
+		digest:        string(name) + "-digest",
+		contentDigest: string(name) + "-content-digest",
+		image: fakeReportStageImage{desc: &imagePkg.StageDescription{
+			StageID: &imagePkg.StageID{Digest: string(name) + "-digest", UniqueID: 1},
+			Info:    &imagePkg.Info{Name: "repo:" + string(name), ID: "sha256:" + string(name), Size: 100, Labels: labels},
+		}},
+		gitMappings: gitMappings,
+	}
+}
+
+func TestNewReportStageSource(t *testing.T) {
+	tests := []struct {
+		foundInStagesStorage          bool
+		foundInSecondaryStagesStorage bool
+		expected                      ReportStageSource
+	}{
+		{true, false, ReportStageSourceCache},
+		{false, true, ReportStageSourceSecondary},
+		{false, false, ReportStageSourceBuilt},
+	}
+
+	for _, tt := range tests {
+		if source := newReportStageSource(tt.foundInStagesStorage, tt.foundInSecondaryStagesStorage); source != tt.expected {
+			t.Errorf("found in stages storage %v, found in secondary stages storage %v: expected source %q, got %q", tt.foundInStagesStorage, tt.foundInSecondaryStagesStorage, tt.expected, source)
+		}
+	}
+}
+
+func TestBuildPhase_AddReportStageRecord(t *testing.T) {
+	own := newTestGitMapping("own", "")
+	other := newTestGitMapping("own", "other")
+	remote := newTestGitMapping("remote", "")
+
+	fromStage := newTestReportStage(stage.From, nil)
+	gitArchiveStage := newTestReportStage(stage.GitArchive, map[string]string{
+		own.ImageGitCommitLabel():    "own-commit",
+		remote.ImageGitCommitLabel(): "remote-commit",
+	}, own, other, remote)
+
+	phase := &BuildPhase{ImagesReport: NewImagesReport()}
+	img := &Image{name: "app"}
+
+	startedAt := time.Now().Add(-time.Minute)
+	phase.addReportStageRecord(img, fromStage, ReportStageSourceCache, startedAt)
+	phase.addReportStageRecord(img, gitArchiveStage, ReportStageSourceBuilt, startedAt)
+
+	records := phase.ImagesReport.GetStageRecords("app")
+	if len(records) != 2 {
+		t.Fatalf("expected 2 stage records, got %d", len(records))
+	}
+
+	if record := records[0]; record.Name != "from" || record.Digest != "from-digest" || record.ContentDigest != "from-content-digest" ||
+		record.Source != ReportStageSourceCache || record.DockerImageName != "repo:from" || record.DockerImageID != "sha256:from" || record.Size != 100 {
+		t.Errorf("unexpected from stage record %+v", record)
+	}
+
+	if record := records[0]; !record.StartedAt.Equal(startedAt) || record.DurationSeconds < 60 {
+		t.Errorf("unexpected from stage record timing: started at %s, duration %f", record.StartedAt, record.DurationSeconds)
+	}
+
+	if records[0].GitCommits != nil {
+		t.Errorf("expected no git commits for the stage without git mappings, got %v", records[0].GitCommits)
+	}
+
+	// the git mappings without the commit label are skipped
+	if expected := map[string]string{"own": "own-commit", "remote": "remote-commit"}; !reflect.DeepEqual(records[1].GitCommits, expected) {
+		t.Errorf("expected git commits %v, got %v", expected, records[1].GitCommits)
+	}
+
+	if records[1].Source != ReportStageSourceBuilt {
+		t.Errorf("expected source %q, got %q", ReportStageSourceBuilt, records[1].Source)
+	}
+
+	if records := phase.ImagesReport.GetStageRecords("other"); records != nil {
+		t.Errorf("expected no stage records for another image, got %v", records)
+	}
+}
+
+func TestImagesReport_StageRecords(t *testing.T) {
+	report := NewImagesReport()
+	report.AddStageRecord("app", ReportStageRecord{Name: "from", Source: ReportStageSourceCache})
+	report.AddStageRecord("app", ReportStageRecord{Name: "gitArchive", Source: ReportStageSourceSecondary, GitCommits: map[string]string{"own": "commit"}})
+	report.SetImageRecord("app", ReportImageRecord{WerfImageName: "app", DockerImageName: "repo:tag", Stages: report.GetStageRecords("app")})
+
+	data, err := report.ToJsonData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rawReport struct {
+		Images map[string]struct {
+			Stages []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &rawReport); err != nil {
+		t.Fatal(err)
+	}
+
+	stages := rawReport.Images["app"].Stages
+	if len(stages) != 2 {
+		t.Fatalf("expected 2 stages in json report, got %d:\n%s", len(stages), data)
+	}
+
+	if stages[0]["Name"] != "from" || stages[0]["Source"] != "cache" {
+		t.Errorf("unexpected first stage %v", stages[0])
+	}
+
+	if _, ok := stages[0]["GitCommits"]; ok {
+		t.Errorf("expected empty git commits to be omitted, got %v", stages[0])
+	}
+
+	if stages[1]["Name"] != "gitArchive" || stages[1]["Source"] != "secondary" || !reflect.DeepEqual(stages[1]["GitCommits"], map[string]interface{}{"own": "commit"}) {
+		t.Errorf("unexpected second stage %v", stages[1])
+	}
+
+	// the stages are not reported in the envfile format
+	if expected := "WERF_APP_DOCKER_IMAGE_NAME=repo:tag\n"; string(report.ToEnvFileData()) != expected {
+		t.Errorf("expected envfile report %q, got %q", expected, report.ToEnvFileData())
+	}
+}
+
+func TestConveyor_AddPlatformStageRecordsToReport(t *testing.T) {
+	c := &Conveyor{multiplatformImages: []*multiplatformImage{
+		{name: "app", platforms: []string{"linux/amd64", "linux/arm64"}},
+		{name: "other", platforms: []string{"linux/arm64"}},
+	}}
+
+	report := NewImagesReport()
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		platformReport := NewImagesReport()
+		platformReport.AddStageRecord("app", ReportStageRecord{Name: "from", DockerImageName: "repo:" + platform})
+		c.addPlatformStageRecordsToReport(report, platform, platformReport)
+	}
+
+	expected := map[string][]ReportStageRecord{
+		"linux/amd64": {{Name: "from", DockerImageName: "repo:linux/amd64"}},
+		"linux/arm64": {{Name: "from", DockerImageName: "repo:linux/arm64"}},
+	}
+	if records := report.GetPlatformStageRecords("app"); !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected platform stage records %v, got %v", expected, records)
+	}
+
+	// the image without stage records in the platform reports is skipped
+	if records := report.GetPlatformStageRecords("other"); records != nil {
+		t.Errorf("expected no platform stage records for another image, got %v", records)
+	}
+
+	report.SetImageRecord("app", ReportImageRecord{WerfImageName: "app", Platforms: []string{"linux/amd64", "linux/arm64"}, PlatformStages: report.GetPlatformStageRecords("app")})
+
+	data, err := report.ToJsonData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rawReport struct {
+		Images map[string]struct {
+			PlatformStages map[string][]map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &rawReport); err != nil {
+		t.Fatal(err)
+	}
+
+	if stages := rawReport.Images["app"].PlatformStages["linux/arm64"]; len(stages) != 1 || stages[0]["DockerImageName"] != "repo:linux/arm64" {
+		t.Errorf("unexpected stages of platform linux/arm64 in json report:\n%s", data)
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/werf/werf/pkg/path_matcher"
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/manager"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/util/parallel"
 )
@@ -54,6 +55,11 @@ type Conveyor struct {
 	images    []*Image
 	imageSets [][]*Image
 
+	multiplatformImages []*multiplatformImage
+
+	reusedImagesReportRecords map[string]ReportImageRecord
+	distributedBuildOptions   DistributedBuildOptions
+
 	stageImages        map[string]*container_runtime.StageImage
 	giterminismManager giterminism_manager.Interface
 	remoteGitRepos     map[string]*git_repo.Remote
@@ -79,6 +85,8 @@ type ConveyorOptions struct {
 	Parallel                        bool
 	ParallelTasksLimit              int64
 	LocalGitRepoVirtualMergeOptions stage.VirtualMergeOptions
+	// Platform is the target platform (os/arch[/variant]) of all images processed by the conveyor, the host platform is used by default
+	Platform string
 }
 
 func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock string, containerRuntime container_runtime.ContainerRuntime, storageManager *manager.StorageManager, storageLockManager storage.LockManager, opts ConveyorOptions) *Conveyor {
@@ -189,6 +197,10 @@ func (c *Conveyor) GetLocalGitRepoVirtualMergeOptions() stage.VirtualMergeOption
 }
 
 func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error) {
+	if _, ok := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime); !ok {
+		return nil, fmt.Errorf("imports are not supported by %s container runtime", c.ContainerRuntime.String())
+	}
+
 	c.getServiceRWMutex("ImportServer").Lock()
 	defer c.getServiceRWMutex("ImportServer").Unlock()
 
@@ -293,6 +305,21 @@ func (c *Conveyor) GetRemoteGitRepo(key string) *git_repo.Remote {
 }
 
 func (c *Conveyor) ShouldBeBuilt(ctx context.Context) error {
+	if c.hasMultiplatformImagesToProcess(ctx) {
+		hostPlatformImageNames, err := c.processMultiplatformImages(ctx, requireManifestLists, func(ctx context.Context, platformConveyor *Conveyor) error {
+			return platformConveyor.ShouldBeBuilt(ctx)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(hostPlatformImageNames) == 0 {
+			return nil
+		}
+
+		c.imageNamesToProcess = hostPlatformImageNames
+	}
+
 	if err := c.determineStages(ctx); err != nil {
 		return err
 	}
@@ -315,12 +342,16 @@ func (c *Conveyor) FetchLastImageStage(ctx context.Context, imageName string) er
 
 func (c *Conveyor) GetImageInfoGetters() (images []*imagePkg.InfoGetter) {
 	for _, img := range c.images {
-		if img.isArtifact {
+		if img.isArtifact || c.isMultiplatformImage(img.name) {
 			continue
 		}
 		images = append(images, img.GetImageInfoGetter())
 	}
 
+	for _, img := range c.multiplatformImages {
+		images = append(images, img.GetImageInfoGetter())
+	}
+
 	return images
 }
 
@@ -328,39 +359,76 @@ func (c *Conveyor) GetExportedImagesNames() []string {
 	var res []string
 
 	for _, img := range c.images {
-		if img.isArtifact {
+		if img.isArtifact || c.isMultiplatformImage(img.name) {
 			continue
 		}
 
 		res = append(res, img.name)
 	}
 
+	for _, img := range c.multiplatformImages {
+		res = append(res, img.name)
+	}
+
 	return res
 }
 
 func (c *Conveyor) GetImagesEnvArray() []string {
 	var envArray []string
 	for _, img := range c.images {
-		if img.isArtifact {
+		if img.isArtifact || c.isMultiplatformImage(img.name) {
 			continue
 		}
 
 		envArray = append(envArray, generateImageEnv(img.name, c.GetImageNameForLastImageStage(img.name)))
 	}
 
+	for _, img := range c.multiplatformImages {
+		envArray = append(envArray, generateImageEnv(img.name, img.stageDesc.Info.Name))
+	}
+
 	return envArray
 }
 
 func (c *Conveyor) Build(ctx context.Context, opts BuildOptions) error {
+	buildPhase := NewBuildPhase(c, BuildPhaseOptions{
+		BuildOptions: opts,
+	})
+
+	return c.buildWithPhase(ctx, opts, buildPhase)
+}
+
+// buildWithPhase builds the images with the given build phase, so the caller can use its report
+func (c *Conveyor) buildWithPhase(ctx context.Context, opts BuildOptions, buildPhase *BuildPhase) error {
+	if opts.Since.Commit != "" {
+		imageNames, err := c.selectImagesAffectedSince(ctx, opts.Since)
+		if err != nil {
+			return err
+		}
+
+		if len(imageNames) == 0 {
+			logboek.Context(ctx).Default().LogFDetails("No images affected since commit %s\n", opts.Since.Commit)
+			return buildPhase.createReport(ctx)
+		}
+
+		c.imageNamesToProcess = imageNames
+	}
+
+	c.distributedBuildOptions = opts.Distributed
+
+	if c.hasMultiplatformImagesToProcess(ctx) {
+		return c.buildMultiplatformImages(ctx, opts, buildPhase)
+	}
+
+	return c.build(ctx, opts, buildPhase)
+}
+
+func (c *Conveyor) build(ctx context.Context, opts BuildOptions, buildPhase *BuildPhase) error {
 	if err := c.determineStages(ctx); err != nil {
 		return err
 	}
 
-	phases := []Phase{
-		NewBuildPhase(c, BuildPhaseOptions{
-			BuildOptions: opts,
-		}),
-	}
+	phases := []Phase{buildPhase}
 
 	if opts.DryRun {
 		fmt.Printf("Build DryRun\n")
@@ -382,7 +450,10 @@ func (c *Conveyor) determineStages(ctx context.Context) error {
 
 func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 	imageConfigsToProcess := getImageConfigsToProcess(ctx, c)
-	configSets := c.werfConfig.ImagesWithDependenciesBySets(imageConfigsToProcess)
+	configSets, err := c.werfConfig.ImagesWithDependenciesBySets(imageConfigsToProcess)
+	if err != nil {
+		return err
+	}
 
 	for _, iteration := range configSets {
 		var imageSet []*Image
@@ -435,7 +506,15 @@ func (c *Conveyor) doDetermineStages(ctx context.Context) error {
 	return nil
 }
 
-func (c *Conveyor) runPhases(ctx context.Context, phases []Phase, logImages bool) error {
+func (c *Conveyor) runPhases(ctx context.Context, phases []Phase, logImages bool) (err error) {
+	var phasesNames []string
+	for _, phase := range phases {
+		phasesNames = append(phasesNames, phase.Name())
+	}
+
+	ctx, span := tracing.StartSpan(ctx, "conveyor.runPhases", "werf.phases", strings.Join(phasesNames, ","))
+	defer func() { span.End(err) }()
+
 	for _, phase := range phases {
 		logProcess := logboek.Context(ctx).Debug().LogProcess("Phase %s -- BeforeImages()", phase.Name())
 		logProcess.Start()
@@ -467,6 +546,10 @@ func (c *Conveyor) runPhases(ctx context.Context, phases []Phase, logImages bool
 }
 
 func (c *Conveyor) doImages(ctx context.Context, phases []Phase, logImages bool) error {
+	if c.distributedBuildOptions.IsEnabled() {
+		return c.doImagesDistributed(ctx, phases, logImages)
+	}
+
 	if c.Parallel && len(c.images) > 1 {
 		return c.doImagesInParallel(ctx, phases, logImages)
 	} else {
@@ -528,7 +611,10 @@ func (c *Conveyor) doImagesInParallel(ctx context.Context, phases []Phase, logIm
 	return nil
 }
 
-func (c *Conveyor) doImage(ctx context.Context, img *Image, phases []Phase, logImages bool) error {
+func (c *Conveyor) doImage(ctx context.Context, img *Image, phases []Phase, logImages bool) (err error) {
+	ctx, span := tracing.StartSpan(ctx, "conveyor.doImage", "werf.image", img.GetName())
+	defer func() { span.End(err) }()
+
 	var imagesLogger types.ManagerInterface
 	if logImages {
 		imagesLogger = logboek.Context(ctx).Default()
@@ -612,7 +698,8 @@ func (c *Conveyor) GetOrCreateStageImage(fromImage *container_runtime.StageImage
 		return img
 	}
 
-	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime))
+	img := container_runtime.NewStageImage(fromImage, name, c.ContainerRuntime)
+	img.SetPlatform(c.Platform)
 	c.SetStageImage(img)
 	return img
 }
@@ -660,6 +747,10 @@ func (c *Conveyor) GetImageIDForLastImageStage(imageName string) string {
 	return c.GetImage(imageName).GetLastNonEmptyStage().GetImage().GetStageDescription().Info.ID
 }
 
+func (c *Conveyor) GetImageRepoDigestForLastImageStage(imageName string) string {
+	return c.GetImage(imageName).GetLastNonEmptyStage().GetImage().GetStageDescription().Info.RepoDigest
+}
+
 func (c *Conveyor) GetImageIDForImageStage(imageName, stageName string) string {
 	return c.getImageStage(imageName, stageName).GetImage().GetStageDescription().Info.ID
 }
@@ -770,9 +861,11 @@ func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.S
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:        imageName,
 		ConfigMounts:     imageBaseConfig.Mount,
+		ConfigSecrets:    imageBaseConfig.Secrets,
 		ImageTmpDir:      c.GetImageTmpDir(imageBaseConfig.Name),
 		ContainerWerfDir: c.containerWerfDir,
 		ProjectName:      c.werfConfig.Meta.Project,
+		Platform:         c.Platform,
 	}
 
 	gitArchiveStageOptions := &stage.NewGitArchiveStageOptions{
@@ -1138,9 +1231,14 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 
 	dockerTargetStage := dockerStages[dockerTargetIndex]
 
+	dockerBuildArgsHash := util.MapStringInterfaceToMapStringString(imageFromDockerfileConfig.Args)
+	for key, placeholder := range stage.GetDependenciesBuildArgsPlaceholders(imageFromDockerfileConfig.Dependencies) {
+		dockerBuildArgsHash[key] = placeholder
+	}
+
 	ds, err := stage.NewDockerStages(
 		dockerStages,
-		util.MapStringInterfaceToMapStringString(imageFromDockerfileConfig.Args),
+		dockerBuildArgsHash,
 		dockerMetaArgs,
 		dockerTargetIndex,
 	)
@@ -1153,13 +1251,18 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		return nil, err
 	}
 
-	if err := handleImageFromName(ctx, resolvedBaseName, false, img, c); err != nil {
+	if dependencyImageName, err := stage.GetDependencyImageNameByBaseName(imageFromDockerfileConfig.Dependencies, dockerTargetStage.BaseName, resolvedBaseName); err != nil {
+		return nil, err
+	} else if dependencyImageName != "" {
+		img.baseImageImageName = dependencyImageName
+	} else if err := handleImageFromName(ctx, resolvedBaseName, false, img, c); err != nil {
 		return nil, err
 	}
 
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:   imageFromDockerfileConfig.Name,
 		ProjectName: c.werfConfig.Meta.Project,
+		Platform:    c.Platform,
 	}
 
 	dockerfileStage := stage.GenerateDockerfileStage(
@@ -1175,9 +1278,27 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		),
 		ds,
 		stage.NewContextChecksum(dockerignorePathMatcher),
+		imageFromDockerfileConfig.Dependencies,
 		baseStageOptions,
 	)
 
+	if imageFromDockerfileConfig.Staged {
+		instructionStages, err := stage.GenerateDockerfileInstructionStages(dockerfileStage, baseStageOptions)
+		if err != nil {
+			return nil, fmt.Errorf("unable to split dockerfile into stages: %s", err)
+		}
+
+		// dockerfile without instructions is built as a single stage
+		if len(instructionStages) != 0 {
+			for _, instructionStage := range instructionStages {
+				img.stages = append(img.stages, instructionStage)
+				logboek.Context(ctx).Info().LogFDetails("Using stage %s: %s\n", instructionStage.Name(), instructionStage.Instruction())
+			}
+
+			return img, nil
+		}
+	}
+
 	img.stages = append(img.stages, dockerfileStage)
 
 	logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
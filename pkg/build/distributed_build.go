@@ -0,0 +1,152 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+// distributedStagePollPeriod is the period of checking whether the stage claimed by another process is stored into the repo
+const distributedStagePollPeriod = 5 * time.Second
+
+type DistributedBuildOptions struct {
+	// Coordinator enables the distributed build: images are split between the werf processes of the same session
+	Coordinator storage.BuildCoordinator
+	// SessionID is the same for all werf processes, which build the project together
+	SessionID string
+	// RunnerID is unique for each werf process
+	RunnerID string
+}
+
+func (opts DistributedBuildOptions) IsEnabled() bool {
+	return opts.Coordinator != nil
+}
+
+// doImagesDistributed processes images set by set. The process claims the images of the set, which are not claimed by other processes of the session, and builds them first.
+// Then it processes the rest images of the set: stages built by other processes are used from the repo, stages claimed by other processes are waited for.
+// The process renews its lease in the background, so its claims are released for other processes only if the process is dead.
+func (c *Conveyor) doImagesDistributed(ctx context.Context, phases []Phase, logImages bool) error {
+	stopHeartbeat := c.startDistributedBuildHeartbeat(ctx)
+	defer stopHeartbeat()
+
+	for setId := range c.imageSets {
+		imageSet := c.imageSets[setId]
+		nextImageFunc := c.newDistributedImageQueue(imageSet)
+
+		if c.Parallel && len(imageSet) > 1 {
+			if err := parallel.DoTasks(ctx, len(imageSet), parallel.DoTasksOptions{
+				InitDockerCLIForEachWorker: true,
+				MaxNumberOfWorkers:         int(c.ParallelTasksLimit),
+				IsLiveOutputOn:             true,
+			}, func(ctx context.Context, _ int) error {
+				img, err := nextImageFunc(ctx)
+				if err != nil {
+					return err
+				}
+
+				var taskPhases []Phase
+				for _, phase := range phases {
+					taskPhases = append(taskPhases, phase.Clone())
+				}
+
+				return c.doImage(ctx, img, taskPhases, logImages)
+			}); err != nil {
+				return err
+			}
+		} else {
+			for range imageSet {
+				img, err := nextImageFunc(ctx)
+				if err != nil {
+					return err
+				}
+
+				if err := c.doImage(ctx, img, phases, logImages); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// newDistributedImageQueue returns the function, which returns the next image of the set to process: claimed by the process first, then the rest ones
+func (c *Conveyor) newDistributedImageQueue(imageSet []*Image) func(ctx context.Context) (*Image, error) {
+	opts := c.distributedBuildOptions
+
+	var mutex sync.Mutex
+	taken := map[*Image]bool{}
+
+	return func(ctx context.Context) (*Image, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		var imageNames []string
+		for _, img := range imageSet {
+			if !taken[img] {
+				imageNames = append(imageNames, img.GetName())
+			}
+		}
+
+		claimedImageName, err := opts.Coordinator.ClaimImage(ctx, c.projectName(), opts.SessionID, opts.RunnerID, imageNames)
+		if err != nil {
+			return nil, fmt.Errorf("unable to claim image using build coordinator %s: %s", opts.Coordinator.String(), err)
+		}
+
+		for _, img := range imageSet {
+			if taken[img] {
+				continue
+			}
+
+			if claimedImageName != "" && img.GetName() != claimedImageName {
+				continue
+			}
+
+			if claimedImageName != "" {
+				logboek.Context(ctx).Info().LogF("Claimed %s in the distributed build session %s\n", img.LogDetailedName(), opts.SessionID)
+			} else {
+				logboek.Context(ctx).Info().LogF("%s has been claimed by another process of the distributed build session %s\n", img.LogDetailedName(), opts.SessionID)
+			}
+
+			taken[img] = true
+			return img, nil
+		}
+
+		panic(fmt.Sprintf("unexpected claimed image %q", claimedImageName))
+	}
+}
+
+func (c *Conveyor) startDistributedBuildHeartbeat(ctx context.Context) func() {
+	opts := c.distributedBuildOptions
+	doneCh := make(chan struct{})
+	stoppedCh := make(chan struct{})
+
+	go func() {
+		defer close(stoppedCh)
+
+		ticker := time.NewTicker(storage.BuildRunnerHeartbeatPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-doneCh:
+				return
+			case <-ticker.C:
+				if err := opts.Coordinator.Heartbeat(ctx, c.projectName(), opts.SessionID, opts.RunnerID); err != nil {
+					logboek.Context(ctx).Warn().LogF("WARNING: unable to renew lease of the distributed build session %s using build coordinator %s: %s\n", opts.SessionID, opts.Coordinator.String(), err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(doneCh)
+		<-stoppedCh
+	}
+}
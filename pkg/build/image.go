@@ -150,10 +150,12 @@ func (i *Image) GetBaseImage() *container_runtime.StageImage {
 func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	switch i.baseImageType {
 	case ImageFromRegistryAsBaseImage:
-		containerRuntime := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		containerRuntime := c.ContainerRuntime.(container_runtime.LocalImagesRuntime)
 
 		if inspect, err := containerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
 			return fmt.Errorf("unable to inspect local image %s: %s", i.baseImage.Name(), err)
+		} else if inspect != nil && !isImageInspectOfPlatform(inspect, c.Platform) {
+			logboek.Context(ctx).Info().LogF("Local image %s is not of the target platform %s\n", i.baseImage.Name(), c.Platform)
 		} else if inspect != nil {
 			// TODO: do not use container_runtime.StageImage for base image
 			i.baseImage.SetStageDescription(&image.StageDescription{
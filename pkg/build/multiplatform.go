@@ -0,0 +1,303 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	dockerTypes "github.com/docker/docker/api/types"
+
+	"github.com/werf/logboek"
+	stylePkg "github.com/werf/logboek/pkg/style"
+	"github.com/werf/logboek/pkg/types"
+
+	imagePkg "github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+)
+
+type manifestListsMode int
+
+const (
+	// publishManifestLists publishes manifest lists, which do not exist in the stages storage
+	publishManifestLists manifestListsMode = iota
+	// requireManifestLists requires manifest lists to exist in the stages storage
+	requireManifestLists
+	// skipManifestLists does not handle manifest lists, images are not built for platforms (e.g. dry run)
+	skipManifestLists
+)
+
+// multiplatformImage is the image with platforms in werf.yaml, which is built for each platform by the separate conveyor
+// and published as the manifest list of the images built for the platforms
+type multiplatformImage struct {
+	name               string
+	platforms          []string
+	platformStageDescs map[string]*imagePkg.StageDescription
+	stageDesc          *imagePkg.StageDescription
+}
+
+func (img *multiplatformImage) GetImageInfoGetter() *imagePkg.InfoGetter {
+	return imagePkg.NewInfoGetter(img.name, img.stageDesc.Info.Name, img.stageDesc.Info.Tag)
+}
+
+// getStageDigest returns the digest of the manifest list stage, which is derived from the stages of all platforms
+func (img *multiplatformImage) getStageDigest() string {
+	var args []string
+	for _, platform := range img.platforms {
+		args = append(args, platform, img.platformStageDescs[platform].StageID.String())
+	}
+
+	return util.Sha3_224Hash(args...)
+}
+
+// selectManifestListStage returns the oldest manifest list stage of the same stages of platforms
+func selectManifestListStage(stages []*imagePkg.StageDescription) *imagePkg.StageDescription {
+	var res *imagePkg.StageDescription
+	for _, stageDesc := range stages {
+		if res == nil || stageDesc.StageID.UniqueIDAsTime().Before(res.StageID.UniqueIDAsTime()) {
+			res = stageDesc
+		}
+	}
+
+	return res
+}
+
+func (c *Conveyor) hasMultiplatformImagesToProcess(ctx context.Context) bool {
+	if c.Platform != "" {
+		return false
+	}
+
+	for _, imageConfig := range getImageConfigsToProcess(ctx, c) {
+		if len(imageConfig.GetPlatforms()) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Conveyor) isMultiplatformImage(imageName string) bool {
+	for _, img := range c.multiplatformImages {
+		if img.name == imageName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// processMultiplatformImages runs the conveyor for each target platform of images to process and handles manifest lists of multiplatform images.
+// Names of images without platforms are returned to be processed by the conveyor itself.
+func (c *Conveyor) processMultiplatformImages(ctx context.Context, mode manifestListsMode, processFunc func(ctx context.Context, platformConveyor *Conveyor) error) ([]string, error) {
+	var hostPlatformImageNames []string
+	imageNamesByPlatform := map[string][]string{}
+	for _, imageConfig := range getImageConfigsToProcess(ctx, c) {
+		platforms := imageConfig.GetPlatforms()
+		if len(platforms) == 0 {
+			hostPlatformImageNames = append(hostPlatformImageNames, imageConfig.GetName())
+			continue
+		}
+
+		for _, platform := range platforms {
+			imageNamesByPlatform[platform] = append(imageNamesByPlatform[platform], imageConfig.GetName())
+		}
+
+		c.multiplatformImages = append(c.multiplatformImages, &multiplatformImage{
+			name:               imageConfig.GetName(),
+			platforms:          platforms,
+			platformStageDescs: map[string]*imagePkg.StageDescription{},
+		})
+	}
+
+	var platforms []string
+	for platform := range imageNamesByPlatform {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	for _, platform := range platforms {
+		platformConveyor := c.newPlatformConveyor(ctx, platform, imageNamesByPlatform[platform])
+
+		if err := logboek.Context(ctx).Default().LogProcess("Processing images for platform %s", platform).
+			Options(func(options types.LogProcessOptionsInterface) {
+				options.Style(stylePkg.Highlight())
+			}).
+			DoError(func() error {
+				return processFunc(ctx, platformConveyor)
+			}); err != nil {
+			return nil, err
+		}
+
+		if mode == skipManifestLists {
+			continue
+		}
+
+		for _, img := range c.multiplatformImages {
+			for _, imagePlatform := range img.platforms {
+				if imagePlatform == platform {
+					img.platformStageDescs[platform] = platformConveyor.GetImage(img.name).GetLastNonEmptyStage().GetImage().GetStageDescription()
+				}
+			}
+		}
+	}
+
+	if mode != skipManifestLists {
+		for _, img := range c.multiplatformImages {
+			if err := c.handleManifestList(ctx, img, mode); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return hostPlatformImageNames, nil
+}
+
+func (c *Conveyor) newPlatformConveyor(ctx context.Context, platform string, imageNamesToProcess []string) *Conveyor {
+	opts := c.ConveyorOptions
+	opts.Platform = platform
+
+	platformConveyor := NewConveyor(c.werfConfig, c.giterminismManager, imageNamesToProcess, c.projectDir, c.baseTmpDir, c.sshAuthSock, c.ContainerRuntime, c.StorageManager, c.StorageLockManager, opts)
+	c.AppendOnTerminateFunc(func() error {
+		return platformConveyor.Terminate(ctx)
+	})
+
+	return platformConveyor
+}
+
+func (c *Conveyor) handleManifestList(ctx context.Context, img *multiplatformImage, mode manifestListsMode) error {
+	stageDigest := img.getStageDigest()
+
+	stages, err := c.StorageManager.GetStagesByDigest(ctx, img.name, stageDigest)
+	if err != nil {
+		return fmt.Errorf("unable to get manifest lists of image %s by digest %s: %s", img.name, stageDigest, err)
+	}
+
+	desc := selectManifestListStage(stages)
+	if desc == nil {
+		if mode == requireManifestLists {
+			return fmt.Errorf("manifest list of image %s for platforms %s by digest %s should be built", img.name, strings.Join(img.platforms, ", "), stageDigest)
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Publishing manifest list of image %s for platforms %s", img.name, strings.Join(img.platforms, ", ")).
+			DoError(func() error {
+				desc, err = c.atomicStoreManifestList(ctx, img, stageDigest)
+				if err != nil {
+					return err
+				}
+
+				logboek.Context(ctx).Default().LogFDetails("  name: %s\n", desc.Info.Name)
+
+				return nil
+			}); err != nil {
+			return err
+		}
+	}
+
+	img.stageDesc = desc
+
+	if mode == publishManifestLists {
+		return c.publishImageMetadata(ctx, img.name, desc.Info.Tag)
+	}
+
+	return nil
+}
+
+// atomicStoreManifestList stores the manifest list with the timestamp-based unique ID the same way as the stage of the image,
+// the manifest list stored by another process is used if any
+func (c *Conveyor) atomicStoreManifestList(ctx context.Context, img *multiplatformImage, stageDigest string) (*imagePkg.StageDescription, error) {
+	if lock, err := c.StorageLockManager.LockStage(ctx, c.projectName(), stageDigest); err != nil {
+		return nil, fmt.Errorf("unable to lock manifest list of image %s by digest %s: %s", img.name, stageDigest, err)
+	} else {
+		defer c.StorageLockManager.Unlock(ctx, lock)
+	}
+
+	stages, err := c.StorageManager.GetStagesByDigest(ctx, img.name, stageDigest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get manifest lists of image %s by digest %s: %s", img.name, stageDigest, err)
+	}
+
+	if desc := selectManifestListStage(stages); desc != nil {
+		return desc, nil
+	}
+
+	_, uniqueID := c.StorageManager.GenerateStageUniqueID(stageDigest, stages)
+	desc, err := c.StorageManager.StagesStorage.StoreManifestList(ctx, c.projectName(), stageDigest, uniqueID, img.platformStageDescs)
+	if err != nil {
+		return nil, err
+	}
+
+	var stageIDs []imagePkg.StageID
+	for _, stageDesc := range stages {
+		stageIDs = append(stageIDs, *stageDesc.StageID)
+	}
+	stageIDs = append(stageIDs, *desc.StageID)
+
+	if err := c.StorageManager.AtomicStoreStagesByDigestToCache(ctx, img.name, stageDigest, stageIDs); err != nil {
+		return nil, err
+	}
+
+	return desc, nil
+}
+
+func (c *Conveyor) buildMultiplatformImages(ctx context.Context, opts BuildOptions, buildPhase *BuildPhase) error {
+	platformOpts := opts
+	platformOpts.ReportPath = ""
+	platformOpts.Since = SinceOptions{}
+
+	mode := publishManifestLists
+	if opts.DryRun {
+		mode = skipManifestLists
+	}
+
+	hostPlatformImageNames, err := c.processMultiplatformImages(ctx, mode, func(ctx context.Context, platformConveyor *Conveyor) error {
+		platformBuildPhase := NewBuildPhase(platformConveyor, BuildPhaseOptions{
+			BuildOptions: platformOpts,
+		})
+
+		if err := platformConveyor.buildWithPhase(ctx, platformOpts, platformBuildPhase); err != nil {
+			return err
+		}
+
+		c.addPlatformStageRecordsToReport(buildPhase.ImagesReport, platformConveyor.Platform, platformBuildPhase.ImagesReport)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(hostPlatformImageNames) == 0 {
+		if opts.DryRun {
+			return nil
+		}
+
+		return buildPhase.createReport(ctx)
+	}
+
+	c.imageNamesToProcess = hostPlatformImageNames
+
+	return c.build(ctx, opts, buildPhase)
+}
+
+// addPlatformStageRecordsToReport merges the stage records of the multiplatform images reported by the platform conveyor into the report
+func (c *Conveyor) addPlatformStageRecordsToReport(report *ImagesReport, platform string, platformReport *ImagesReport) {
+	for _, img := range c.multiplatformImages {
+		if stageRecords := platformReport.GetStageRecords(img.name); stageRecords != nil {
+			report.SetPlatformStageRecords(img.name, platform, stageRecords)
+		}
+	}
+}
+
+// isImageInspectOfPlatform returns true if the local image is of the platform or the platform is not specified
+func isImageInspectOfPlatform(inspect *dockerTypes.ImageInspect, platform string) bool {
+	if platform == "" {
+		return true
+	}
+
+	parts := strings.Split(platform, "/")
+	if inspect.Os != parts[0] || inspect.Architecture != parts[1] {
+		return false
+	}
+
+	return len(parts) < 3 || inspect.Variant == "" || inspect.Variant == parts[2]
+}
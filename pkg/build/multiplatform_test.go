@@ -0,0 +1,80 @@
+package build
+
+import (
+	"testing"
+
+	dockerTypes "github.com/docker/docker/api/types"
+
+	imagePkg "github.com/werf/werf/pkg/image"
+)
+
+func newTestStageDescription(digest string, uniqueID int64) *imagePkg.StageDescription {
+	return &imagePkg.StageDescription{StageID: &imagePkg.StageID{Digest: digest, UniqueID: uniqueID}}
+}
+
+func newTestMultiplatformImage(platformUniqueIDs map[string]int64, platforms ...string) *multiplatformImage {
+	img := &multiplatformImage{name: "app", platforms: platforms, platformStageDescs: map[string]*imagePkg.StageDescription{}}
+	for _, platform := range platforms {
+		img.platformStageDescs[platform] = newTestStageDescription("digest", platformUniqueIDs[platform])
+	}
+
+	return img
+}
+
+func TestMultiplatformImage_GetStageDigest(t *testing.T) {
+	uniqueIDs := map[string]int64{"linux/amd64": 1600000000000, "linux/arm64": 1600000000001}
+	digest := newTestMultiplatformImage(uniqueIDs, "linux/amd64", "linux/arm64").getStageDigest()
+
+	if newDigest := newTestMultiplatformImage(uniqueIDs, "linux/amd64", "linux/arm64").getStageDigest(); newDigest != digest {
+		t.Errorf("expected the same digest for the same platforms stages, got %s and %s", digest, newDigest)
+	}
+
+	if newDigest := newTestMultiplatformImage(map[string]int64{"linux/amd64": 1600000000000, "linux/arm64": 1600000000002}, "linux/amd64", "linux/arm64").getStageDigest(); newDigest == digest {
+		t.Errorf("expected another digest for another platform stage, got %s", newDigest)
+	}
+
+	if newDigest := newTestMultiplatformImage(uniqueIDs, "linux/amd64").getStageDigest(); newDigest == digest {
+		t.Errorf("expected another digest for another platforms, got %s", newDigest)
+	}
+}
+
+func TestSelectManifestListStage(t *testing.T) {
+	if desc := selectManifestListStage(nil); desc != nil {
+		t.Fatalf("expected no stage, got %v", desc.StageID)
+	}
+
+	stages := []*imagePkg.StageDescription{
+		newTestStageDescription("digest", 1600000000002),
+		newTestStageDescription("digest", 1600000000001),
+		newTestStageDescription("digest", 1600000000003),
+	}
+
+	if desc := selectManifestListStage(stages); desc != stages[1] {
+		t.Fatalf("expected the oldest stage %s, got %s", stages[1].StageID, desc.StageID)
+	}
+}
+
+func TestIsImageInspectOfPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		inspect  dockerTypes.ImageInspect
+		platform string
+		expected bool
+	}{
+		{"platform is not specified", dockerTypes.ImageInspect{Os: "linux", Architecture: "arm64"}, "", true},
+		{"os and arch match", dockerTypes.ImageInspect{Os: "linux", Architecture: "amd64"}, "linux/amd64", true},
+		{"arch does not match", dockerTypes.ImageInspect{Os: "linux", Architecture: "arm64"}, "linux/amd64", false},
+		{"os does not match", dockerTypes.ImageInspect{Os: "windows", Architecture: "amd64"}, "linux/amd64", false},
+		{"variant matches", dockerTypes.ImageInspect{Os: "linux", Architecture: "arm", Variant: "v7"}, "linux/arm/v7", true},
+		{"variant does not match", dockerTypes.ImageInspect{Os: "linux", Architecture: "arm", Variant: "v6"}, "linux/arm/v7", false},
+		{"variant is not set in image", dockerTypes.ImageInspect{Os: "linux", Architecture: "arm"}, "linux/arm/v7", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := isImageInspectOfPlatform(&tt.inspect, tt.platform); res != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/cache_mount"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
@@ -63,9 +64,11 @@ var (
 type NewBaseStageOptions struct {
 	ImageName        string
 	ConfigMounts     []*config.Mount
+	ConfigSecrets    []*config.Secret
 	ImageTmpDir      string
 	ContainerWerfDir string
 	ProjectName      string
+	Platform         string
 }
 
 func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
@@ -73,9 +76,11 @@ func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
 	s.name = name
 	s.imageName = options.ImageName
 	s.configMounts = options.ConfigMounts
+	s.configSecrets = options.ConfigSecrets
 	s.imageTmpDir = options.ImageTmpDir
 	s.containerWerfDir = options.ContainerWerfDir
 	s.projectName = options.ProjectName
+	s.platform = options.Platform
 	return s
 }
 
@@ -89,7 +94,10 @@ type BaseStage struct {
 	imageTmpDir      string
 	containerWerfDir string
 	configMounts     []*config.Mount
+	configSecrets    []*config.Secret
 	projectName      string
+	cacheMounts      []*cache_mount.CacheMount
+	platform         string
 }
 
 func (s *BaseStage) LogDetailedName() string {
@@ -245,6 +253,10 @@ func (s *BaseStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 		return fmt.Errorf("error adding mounts volumes: %s", err)
 	}
 
+	if err := s.addCacheMountsVolumes(ctx, image); err != nil {
+		return fmt.Errorf("error adding cache mounts volumes: %s", err)
+	}
+
 	return nil
 }
 
@@ -252,6 +264,51 @@ func (s *BaseStage) PreRunHook(_ context.Context, _ Conveyor) error {
 	return nil
 }
 
+func (s *BaseStage) PostRunHook(_ context.Context, _ Conveyor) error {
+	return s.releaseCacheMounts()
+}
+
+// addCacheMountsVolumes acquires cache mounts instances, which are held until the stage is built
+func (s *BaseStage) addCacheMountsVolumes(ctx context.Context, image container_runtime.ImageInterface) error {
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type != "cache" {
+			continue
+		}
+
+		cacheMount, err := cache_mount.Acquire(ctx, cache_mount.AcquireOptions{
+			ProjectName: s.projectName,
+			ImageName:   s.imageName,
+			Id:          mountCfg.Id,
+			Sharing:     mountCfg.Sharing,
+			Scope:       mountCfg.Scope,
+		})
+		if err != nil {
+			return err
+		}
+		s.cacheMounts = append(s.cacheMounts, cacheMount)
+
+		image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s", cacheMount.HostPath, path.Join("/", mountCfg.To)))
+	}
+
+	return nil
+}
+
+func (s *BaseStage) releaseCacheMounts() error {
+	var errMsgs []string
+	for _, cacheMount := range s.cacheMounts {
+		if err := cacheMount.Release(); err != nil {
+			errMsgs = append(errMsgs, err.Error())
+		}
+	}
+	s.cacheMounts = nil
+
+	if len(errMsgs) != 0 {
+		return fmt.Errorf("unable to release cache mounts: %s", strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
+
 func (s *BaseStage) getServiceMounts(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
 	return mergeMounts(s.getServiceMountsFromLabels(prevBuiltImage), s.getServiceMountsFromConfig())
 }
@@ -36,6 +36,10 @@ func (s *BeforeInstallStage) PrepareImage(ctx context.Context, c Conveyor, prevB
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image.BuilderContainer()); err != nil {
+		return err
+	}
+
 	if err := s.builder.BeforeInstall(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
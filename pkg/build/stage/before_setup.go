@@ -42,6 +42,10 @@ func (s *BeforeSetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBui
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image.BuilderContainer()); err != nil {
+		return err
+	}
+
 	if err := s.builder.BeforeSetup(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
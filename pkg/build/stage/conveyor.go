@@ -18,6 +18,7 @@ type Conveyor interface {
 
 	GetImageNameForLastImageStage(imageName string) string
 	GetImageIDForLastImageStage(imageName string) string
+	GetImageRepoDigestForLastImageStage(imageName string) string
 
 	GetImageNameForImageStage(imageName, stageName string) string
 	GetImageIDForImageStage(imageName, stageName string) string
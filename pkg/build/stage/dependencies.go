@@ -0,0 +1,136 @@
+package stage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/werf/werf/pkg/config"
+	imagePkg "github.com/werf/werf/pkg/image"
+)
+
+const dependencyBuildArgPlaceholderPrefix = "werf-dependency-placeholder-"
+
+func getDependencies(imageBaseConfig *config.StapelImageBase, options *getImportsOptions) []*config.Dependency {
+	var dependencies []*config.Dependency
+	for _, elm := range imageBaseConfig.Dependencies {
+		if options.Before != "" && elm.Before != "" && elm.Before == string(options.Before) {
+			dependencies = append(dependencies, elm)
+		} else if options.After != "" && elm.After != "" && elm.After == string(options.After) {
+			dependencies = append(dependencies, elm)
+		}
+	}
+
+	return dependencies
+}
+
+// getDependenciesChecksumArgs returns the content digests of dependencies images, the targets and the values of imported properties,
+// the values are included because the image name, tag or id can change while the content digest stays the same
+func getDependenciesChecksumArgs(c Conveyor, dependencies []*config.Dependency) ([]string, error) {
+	var args []string
+	for _, dependency := range dependencies {
+		args = append(args, "Dependency", dependency.ImageName, c.GetImageContentDigest(dependency.ImageName))
+
+		for _, imp := range dependency.Imports {
+			value, err := getDependencyImportValue(c, dependency.ImageName, imp.Type)
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, imp.Type, imp.TargetBuildArg, imp.TargetEnv, value)
+		}
+	}
+
+	return args, nil
+}
+
+// getDependenciesImportsValues returns the properties of built dependencies images by targets (build args or envs)
+func getDependenciesImportsValues(c Conveyor, dependencies []*config.Dependency) (map[string]string, error) {
+	values := map[string]string{}
+	for _, dependency := range dependencies {
+		for _, imp := range dependency.Imports {
+			value, err := getDependencyImportValue(c, dependency.ImageName, imp.Type)
+			if err != nil {
+				return nil, err
+			}
+
+			values[imp.TargetBuildArg+imp.TargetEnv] = value
+		}
+	}
+
+	return values, nil
+}
+
+func getDependencyImportValue(c Conveyor, imageName, importType string) (string, error) {
+	dockerImageName := c.GetImageNameForLastImageStage(imageName)
+	repository, tag := imagePkg.ParseRepositoryAndTag(dockerImageName)
+
+	switch importType {
+	case config.DependencyImportImageName:
+		return dockerImageName, nil
+	case config.DependencyImportImageRepo:
+		return repository, nil
+	case config.DependencyImportImageTag:
+		return tag, nil
+	case config.DependencyImportImageID:
+		return c.GetImageIDForLastImageStage(imageName), nil
+	case config.DependencyImportImageDigest:
+		repoDigest := c.GetImageRepoDigestForLastImageStage(imageName)
+		if repoDigest == "" {
+			return "", fmt.Errorf("unable to get digest of dependency image %q: the image %s has no repo digest (the repo should be specified)", imageName, dockerImageName)
+		}
+
+		// the local image repo digest is in the form REPO@DIGEST
+		return repoDigest[strings.LastIndex(repoDigest, "@")+1:], nil
+	default:
+		return "", fmt.Errorf("unknown dependency import type %q", importType)
+	}
+}
+
+// GetDependenciesBuildArgsPlaceholders returns placeholders of dependencies build args,
+// which are used to parse the Dockerfile until the dependencies images are built
+func GetDependenciesBuildArgsPlaceholders(dependencies []*config.Dependency) map[string]string {
+	placeholders := map[string]string{}
+	for ind, dependency := range dependencies {
+		for _, imp := range dependency.Imports {
+			placeholders[imp.TargetBuildArg] = dependencyBuildArgPlaceholder(ind, imp.Type)
+		}
+	}
+
+	return placeholders
+}
+
+// GetDependencyImageNameByBaseName returns the name of the dependency image, which is used by the Dockerfile as the base image
+// by the build arg of the ImageName type, or an empty string if the base image is not a dependency
+func GetDependencyImageNameByBaseName(dependencies []*config.Dependency, baseName, resolvedBaseName string) (string, error) {
+	if !strings.Contains(resolvedBaseName, dependencyBuildArgPlaceholderPrefix) {
+		return "", nil
+	}
+
+	for ind, dependency := range dependencies {
+		if resolvedBaseName == dependencyBuildArgPlaceholder(ind, config.DependencyImportImageName) {
+			return dependency.ImageName, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid base image %q: only the build arg of the dependency import with type %s can be used in FROM instruction as the whole base image name", baseName, config.DependencyImportImageName)
+}
+
+func dependencyBuildArgPlaceholder(dependencyIndex int, importType string) string {
+	return fmt.Sprintf("%s%d-%s", dependencyBuildArgPlaceholderPrefix, dependencyIndex, strings.ToLower(importType))
+}
+
+func dependenciesBuildArgs(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []string
+	for _, key := range keys {
+		result = append(result, fmt.Sprintf("--build-arg=%s=%s", key, values[key]))
+	}
+
+	return result
+}
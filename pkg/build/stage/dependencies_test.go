@@ -0,0 +1,180 @@
+package stage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+type fakeDependenciesConveyor struct {
+	Conveyor
+
+	imageNames map[string]string
+	imageIDs   map[string]string
+}
+
+func (c *fakeDependenciesConveyor) GetImageContentDigest(imageName string) string {
+	return imageName + "-content-digest"
+}
+
+func (c *fakeDependenciesConveyor) GetImageNameForLastImageStage(imageName string) string {
+	return c.imageNames[imageName]
+}
+
+func (c *fakeDependenciesConveyor) GetImageIDForLastImageStage(imageName string) string {
+	return c.imageIDs[imageName]
+}
+
+func (c *fakeDependenciesConveyor) GetImageRepoDigestForLastImageStage(_ string) string {
+	return ""
+}
+
+func newTestDependencies() []*config.Dependency {
+	return []*config.Dependency{
+		{
+			ImageName: "base",
+			Imports: []*config.DependencyImport{
+				{Type: config.DependencyImportImageName, TargetBuildArg: "BASE_IMAGE"},
+				{Type: config.DependencyImportImageID, TargetBuildArg: "BASE_ID"},
+			},
+		},
+		{
+			ImageName: "assets",
+			Imports: []*config.DependencyImport{
+				{Type: config.DependencyImportImageTag, TargetBuildArg: "ASSETS_TAG"},
+			},
+		},
+	}
+}
+
+func TestGetDependenciesBuildArgsPlaceholders(t *testing.T) {
+	expected := map[string]string{
+		"BASE_IMAGE": "werf-dependency-placeholder-0-imagename",
+		"BASE_ID":    "werf-dependency-placeholder-0-imageid",
+		"ASSETS_TAG": "werf-dependency-placeholder-1-imagetag",
+	}
+
+	if placeholders := GetDependenciesBuildArgsPlaceholders(newTestDependencies()); !reflect.DeepEqual(placeholders, expected) {
+		t.Fatalf("expected placeholders %v, got %v", expected, placeholders)
+	}
+}
+
+func TestGetDependencyImageNameByBaseName(t *testing.T) {
+	tests := []struct {
+		name              string
+		resolvedBaseName  string
+		expectedImageName string
+		expectedErr       bool
+	}{
+		{"base image is not a dependency", "alpine:3.13", "", false},
+		{"base image is the dependency image name", "werf-dependency-placeholder-0-imagename", "base", false},
+		{"base image is the dependency image id", "werf-dependency-placeholder-0-imageid", "", true},
+		{"base image contains the dependency image tag", "registry/assets:werf-dependency-placeholder-1-imagetag", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageName, err := GetDependencyImageNameByBaseName(newTestDependencies(), "${BASE}", tt.resolvedBaseName)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got image name %q", imageName)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if imageName != tt.expectedImageName {
+				t.Fatalf("expected image name %q, got %q", tt.expectedImageName, imageName)
+			}
+		})
+	}
+}
+
+func TestGetDependencyImportValue(t *testing.T) {
+	c := &fakeDependenciesConveyor{
+		imageNames: map[string]string{"base": "registry.example.com/project:a1b2c3"},
+		imageIDs:   map[string]string{"base": "sha256:d4e5f6"},
+	}
+
+	tests := []struct {
+		importType    string
+		expectedValue string
+		expectedErr   bool
+	}{
+		{config.DependencyImportImageName, "registry.example.com/project:a1b2c3", false},
+		{config.DependencyImportImageRepo, "registry.example.com/project", false},
+		{config.DependencyImportImageTag, "a1b2c3", false},
+		{config.DependencyImportImageID, "sha256:d4e5f6", false},
+		{config.DependencyImportImageDigest, "", true},
+		{"ImageSize", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.importType, func(t *testing.T) {
+			value, err := getDependencyImportValue(c, "base", tt.importType)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got value %q", value)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != tt.expectedValue {
+				t.Fatalf("expected value %q, got %q", tt.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestGetDependenciesChecksumArgs(t *testing.T) {
+	c := &fakeDependenciesConveyor{
+		imageNames: map[string]string{"base": "project:a1b2c3", "assets": "project:d4e5f6"},
+		imageIDs:   map[string]string{"base": "sha256:a1b2c3", "assets": "sha256:d4e5f6"},
+	}
+
+	args, err := getDependenciesChecksumArgs(c, newTestDependencies())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The content digests are the same, but the image tags and ids differ
+	c.imageNames["assets"] = "project:g7h8i9"
+	c.imageIDs["base"] = "sha256:g7h8i9"
+
+	newArgs, err := getDependenciesChecksumArgs(c, newTestDependencies())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(args, newArgs) {
+		t.Fatalf("expected checksum args to change with the imported values, got %v", newArgs)
+	}
+
+	c.imageIDs["base"] = "sha256:a1b2c3"
+	c.imageNames["assets"] = "project:d4e5f6"
+
+	newArgs, err = getDependenciesChecksumArgs(c, newTestDependencies())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(args, newArgs) {
+		t.Fatalf("expected checksum args %v, got %v", args, newArgs)
+	}
+}
+
+func TestDependenciesBuildArgs(t *testing.T) {
+	expected := []string{"--build-arg=A=1", "--build-arg=B=2"}
+
+	if args := dependenciesBuildArgs(map[string]string{"B": "2", "A": "1"}); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected build args %v, got %v", expected, args)
+	}
+}
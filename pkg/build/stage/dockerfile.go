@@ -17,6 +17,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/context_manager"
 	"github.com/werf/werf/pkg/docker_registry"
@@ -27,8 +28,10 @@ import (
 	"github.com/werf/werf/pkg/util"
 )
 
-func GenerateDockerfileStage(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
-	return newDockerfileStage(dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
+func GenerateDockerfileStage(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, dependencies []*config.Dependency, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
+	s := newDockerfileStage(dockerRunArgs, dockerStages, contextChecksum, baseStageOptions)
+	s.dependencies = dependencies
+	return s
 }
 
 func newDockerfileStage(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, baseStageOptions *NewBaseStageOptions) *DockerfileStage {
@@ -46,6 +49,11 @@ type DockerfileStage struct {
 	*DockerStages
 	*ContextChecksum
 	*BaseStage
+
+	dockerStagesDependencies [][]string
+
+	dependencies          []*config.Dependency
+	dependenciesBuildArgs map[string]string
 }
 
 func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFile []string, buildArgs map[string]interface{}, addHost []string, network, ssh string) *DockerRunArgs {
@@ -89,6 +97,7 @@ type DockerStages struct {
 	dockerStages           []instructions.Stage
 	dockerTargetStageIndex int
 	dockerBuildArgsHash    map[string]string
+	dockerMetaArgs         []instructions.ArgCommand
 	dockerMetaArgsHash     map[string]string
 	dockerStageArgsHash    map[int]map[string]string
 	dockerStageEnvs        map[int]map[string]string
@@ -101,6 +110,7 @@ func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[
 		dockerStages:             dockerStages,
 		dockerTargetStageIndex:   dockerTargetStageIndex,
 		dockerBuildArgsHash:      dockerBuildArgsHash,
+		dockerMetaArgs:           dockerMetaArgs,
 		dockerStageArgsHash:      map[int]map[string]string{},
 		dockerStageEnvs:          map[int]map[string]string{},
 		imageOnBuildInstructions: map[string][]string{},
@@ -125,6 +135,36 @@ func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[
 	return ds, nil
 }
 
+// withInitialState returns DockerStages with the same docker stages and build args, but without resolved docker stages ARG and ENV values
+func (ds *DockerStages) withInitialState() (*DockerStages, error) {
+	newDs, err := NewDockerStages(ds.dockerStages, ds.dockerBuildArgsHash, ds.dockerMetaArgs, ds.dockerTargetStageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	newDs.imageOnBuildInstructions = ds.imageOnBuildInstructions
+
+	return newDs, nil
+}
+
+// getDockerStagesChain returns indexes of the docker stages the docker stage is based on and the index of the docker stage itself
+func (ds *DockerStages) getDockerStagesChain(dockerStageID int) []int {
+	chain := []int{dockerStageID}
+
+outerLoop:
+	for {
+		baseName := ds.dockerStages[chain[0]].BaseName
+		for ind := chain[0] - 1; ind >= 0; ind-- {
+			if ds.dockerStages[ind].Name == baseName {
+				chain = append([]int{ind}, chain...)
+				continue outerLoop
+			}
+		}
+
+		return chain
+	}
+}
+
 // addDockerMetaArg function sets --build-arg value or resolved meta ARG value
 func (ds *DockerStages) addDockerMetaArg(key, value string) (string, string, error) {
 	resolvedKey, err := ds.ShlexProcessWordWithMetaArgs(key)
@@ -263,8 +303,39 @@ type dockerfileInstructionInterface interface {
 	Name() string
 }
 
-func (s *DockerfileStage) FetchDependencies(ctx context.Context, _ Conveyor, cr container_runtime.ContainerRuntime) error {
-	containerRuntime := cr.(*container_runtime.LocalDockerServerRuntime)
+// setupDependenciesBuildArgs replaces placeholders of dependencies build args with properties of built dependencies images,
+// the dependencies images are built before the image, so values are available since the digest calculation
+func (s *DockerfileStage) setupDependenciesBuildArgs(c Conveyor) error {
+	if len(s.dependencies) == 0 || s.dependenciesBuildArgs != nil {
+		return nil
+	}
+
+	values, err := getDependenciesImportsValues(c, s.dependencies)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range values {
+		s.dockerBuildArgsHash[key] = value
+	}
+
+	dockerStages, err := s.DockerStages.withInitialState()
+	if err != nil {
+		return err
+	}
+
+	s.DockerStages = dockerStages
+	s.dependenciesBuildArgs = values
+
+	return nil
+}
+
+func (s *DockerfileStage) FetchDependencies(ctx context.Context, c Conveyor, cr container_runtime.ContainerRuntime) error {
+	if err := s.setupDependenciesBuildArgs(c); err != nil {
+		return err
+	}
+
+	containerRuntime := cr.(container_runtime.LocalImagesRuntime)
 
 outerLoop:
 	for ind, stage := range s.dockerStages {
@@ -347,6 +418,41 @@ func isUnsupportedMediaTypeError(err error) bool {
 var imageNotExistLocally = errors.New("IMAGE_NOT_EXIST_LOCALLY")
 
 func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
+	if err := s.setupDependenciesBuildArgs(c); err != nil {
+		return "", err
+	}
+
+	stagesDependencies, err := s.getDockerStagesDependencies(ctx, c.GiterminismManager())
+	if err != nil {
+		return "", err
+	}
+
+	dockerfileStageDependencies := stagesDependencies[s.dockerTargetStageIndex]
+	if s.platform != "" {
+		dockerfileStageDependencies = append(append([]string{}, dockerfileStageDependencies...), s.platform)
+	}
+
+	if len(s.dependencies) != 0 {
+		dependenciesArgs, err := getDependenciesChecksumArgs(c, s.dependencies)
+		if err != nil {
+			return "", err
+		}
+		dockerfileStageDependencies = append(append([]string{}, dockerfileStageDependencies...), dependenciesArgs...)
+	}
+
+	if dockerfileStageDependenciesDebug() {
+		logboek.Context(ctx).LogLn(dockerfileStageDependencies)
+	}
+
+	return util.Sha256Hash(dockerfileStageDependencies...), nil
+}
+
+// getDockerStagesDependencies returns dependencies of each docker stage including dependencies of the docker stages it is based on or copies files from
+func (s *DockerfileStage) getDockerStagesDependencies(ctx context.Context, giterminismManager giterminism_manager.Interface) ([][]string, error) {
+	if s.dockerStagesDependencies != nil {
+		return s.dockerStagesDependencies, nil
+	}
+
 	var stagesDependencies [][]string
 	var stagesOnBuildDependencies [][]string
 
@@ -358,7 +464,7 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 
 		resolvedBaseName, err := s.ShlexProcessWordWithMetaArgs(stage.BaseName)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		dependencies = append(dependencies, resolvedBaseName)
@@ -366,9 +472,9 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		onBuildInstructions, ok := s.imageOnBuildInstructions[resolvedBaseName]
 		if ok {
 			for _, instruction := range onBuildInstructions {
-				_, iOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, c.GiterminismManager(), ind, instruction, true)
+				_, iOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, giterminismManager, ind, instruction, true)
 				if err != nil {
-					return "", err
+					return nil, err
 				}
 
 				dependencies = append(dependencies, iOnBuildDependencies...)
@@ -376,9 +482,9 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		}
 
 		for _, cmd := range stage.Commands {
-			cmdDependencies, cmdOnBuildDependencies, err := s.dockerfileInstructionDependencies(ctx, c.GiterminismManager(), ind, cmd, false, false)
+			cmdDependencies, cmdOnBuildDependencies, err := s.dockerfileInstructionDependencies(ctx, giterminismManager, ind, cmd, false, false)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 
 			dependencies = append(dependencies, cmdDependencies...)
@@ -414,13 +520,9 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		}
 	}
 
-	dockerfileStageDependencies := stagesDependencies[s.dockerTargetStageIndex]
+	s.dockerStagesDependencies = stagesDependencies
 
-	if dockerfileStageDependenciesDebug() {
-		logboek.Context(ctx).LogLn(dockerfileStageDependencies)
-	}
-
-	return util.Sha256Hash(dockerfileStageDependencies...), nil
+	return stagesDependencies, nil
 }
 
 func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context, giterminismManager giterminism_manager.Interface, dockerStageID int, cmd interface{}, isOnbuildInstruction bool, isBaseImageOnbuildInstruction bool) ([]string, []string, error) {
@@ -616,7 +718,12 @@ func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img c
 		return err
 	}
 
+	if err := s.setupDependenciesBuildArgs(c); err != nil {
+		return err
+	}
+
 	img.DockerfileImageBuilder().AppendBuildArgs(s.DockerBuildArgs()...)
+	img.DockerfileImageBuilder().AppendBuildArgs(dependenciesBuildArgs(s.dependenciesBuildArgs)...)
 	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=%s", image.WerfProjectRepoCommitLabel, c.GiterminismManager().HeadCommit()))
 	img.DockerfileImageBuilder().SetFilePathToStdin(archivePath)
 
@@ -673,22 +780,29 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 		result = append(result, fmt.Sprintf("--target=%s", s.target))
 	}
 
-	if len(s.buildArgs) != 0 {
-		for key, value := range s.buildArgs {
+	return append(result, s.commonDockerBuildArgs()...)
+}
+
+// commonDockerBuildArgs returns build args, which do not depend on the dockerfile and the target
+func (d *DockerRunArgs) commonDockerBuildArgs() []string {
+	var result []string
+
+	if len(d.buildArgs) != 0 {
+		for key, value := range d.buildArgs {
 			result = append(result, fmt.Sprintf("--build-arg=%s=%v", key, value))
 		}
 	}
 
-	for _, addHost := range s.addHost {
+	for _, addHost := range d.addHost {
 		result = append(result, fmt.Sprintf("--add-host=%s", addHost))
 	}
 
-	if s.network != "" {
-		result = append(result, fmt.Sprintf("--network=%s", s.network))
+	if d.network != "" {
+		result = append(result, fmt.Sprintf("--network=%s", d.network))
 	}
 
-	if s.ssh != "" {
-		result = append(result, fmt.Sprintf("--ssh=%s", s.ssh))
+	if d.ssh != "" {
+		result = append(result, fmt.Sprintf("--ssh=%s", d.ssh))
 	}
 
 	return result
@@ -0,0 +1,235 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/context_manager"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+)
+
+const dockerfileInstructionStageNamePrefix = "dockerfileInstruction"
+
+// GenerateDockerfileInstructionStages splits the build of the dockerfile target into stages by instructions.
+// The target instructions are preceded by the instructions of the docker stages the target is based on.
+func GenerateDockerfileInstructionStages(dockerfileStage *DockerfileStage, baseStageOptions *NewBaseStageOptions) ([]*DockerfileInstructionStage, error) {
+	instructionsDockerStages, err := dockerfileStage.DockerStages.withInitialState()
+	if err != nil {
+		return nil, err
+	}
+
+	// ARG and ENV values are resolved instruction by instruction, so the separate docker stages state is used
+	instructionsResolver := newDockerfileStage(dockerfileStage.DockerRunArgs, instructionsDockerStages, dockerfileStage.ContextChecksum, baseStageOptions)
+
+	chain := dockerfileStage.getDockerStagesChain(dockerfileStage.dockerTargetStageIndex)
+
+	resolvedBaseName, err := dockerfileStage.ShlexProcessWordWithMetaArgs(dockerfileStage.dockerStages[chain[0]].BaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	var stages []*DockerfileInstructionStage
+	for _, dockerStageID := range chain {
+		var argInstructions []string
+		var lastCmdInstruction string
+
+		for _, cmd := range dockerfileStage.dockerStages[dockerStageID].Commands {
+			instruction := cmd.(dockerfileInstructionInterface)
+
+			precedingInstructions := append([]string{}, argInstructions...)
+
+			switch cmd.(type) {
+			case *instructions.OnbuildCommand:
+				return nil, fmt.Errorf("ONBUILD instruction is not supported: %s", instruction.String())
+			case *instructions.ArgCommand:
+				argInstructions = append(argInstructions, instruction.String())
+			case *instructions.CmdCommand:
+				lastCmdInstruction = instruction.String()
+			case *instructions.EntrypointCommand:
+				// ENTRYPOINT resets CMD, which is not set in the same build
+				if lastCmdInstruction != "" {
+					precedingInstructions = append(precedingInstructions, lastCmdInstruction)
+				}
+			}
+
+			s := newDockerfileInstructionStage(len(stages)+1, baseStageOptions)
+			s.dockerfileStage = dockerfileStage
+			s.instructionsResolver = instructionsResolver
+			s.dockerStageID = dockerStageID
+			s.instruction = instruction
+			s.precedingInstructions = precedingInstructions
+
+			if len(stages) == 0 {
+				s.baseImageName = resolvedBaseName
+			}
+
+			stages = append(stages, s)
+		}
+	}
+
+	return stages, nil
+}
+
+func newDockerfileInstructionStage(number int, baseStageOptions *NewBaseStageOptions) *DockerfileInstructionStage {
+	s := &DockerfileInstructionStage{}
+	s.BaseStage = newBaseStage(StageName(fmt.Sprintf("%s%d", dockerfileInstructionStageNamePrefix, number)), baseStageOptions)
+	return s
+}
+
+type DockerfileInstructionStage struct {
+	*BaseStage
+
+	dockerfileStage       *DockerfileStage
+	instructionsResolver  *DockerfileStage
+	dockerStageID         int
+	instruction           dockerfileInstructionInterface
+	precedingInstructions []string
+	baseImageName         string
+}
+
+// IsFirst returns true if the stage is based on the base image rather than on the previous stage
+func (s *DockerfileInstructionStage) IsFirst() bool {
+	return s.baseImageName != ""
+}
+
+func (s *DockerfileInstructionStage) Instruction() string {
+	return s.instruction.String()
+}
+
+func (s *DockerfileInstructionStage) FetchDependencies(ctx context.Context, c Conveyor, cr container_runtime.ContainerRuntime) error {
+	return s.dockerfileStage.FetchDependencies(ctx, c, cr)
+}
+
+func (s *DockerfileInstructionStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
+	var dependencies []string
+
+	if s.IsFirst() {
+		dependencies = append(dependencies, s.dockerfileStage.addHost...)
+		dependencies = append(dependencies, s.baseImageName)
+
+		if s.platform != "" {
+			dependencies = append(dependencies, s.platform)
+		}
+
+		for _, instruction := range s.instructionsResolver.imageOnBuildInstructions[s.baseImageName] {
+			_, iOnBuildDependencies, err := s.instructionsResolver.dockerfileOnBuildInstructionDependencies(ctx, c.GiterminismManager(), s.dockerStageID, instruction, true)
+			if err != nil {
+				return "", err
+			}
+
+			dependencies = append(dependencies, iOnBuildDependencies...)
+		}
+	}
+
+	instructionDependencies, _, err := s.instructionsResolver.dockerfileInstructionDependencies(ctx, c.GiterminismManager(), s.dockerStageID, s.instruction, false, false)
+	if err != nil {
+		return "", err
+	}
+	dependencies = append(dependencies, instructionDependencies...)
+
+	if relatedStageIndex := s.copyFromDockerStageIndex(); relatedStageIndex != -1 {
+		stagesDependencies, err := s.dockerfileStage.getDockerStagesDependencies(ctx, c.GiterminismManager())
+		if err != nil {
+			return "", err
+		}
+
+		dependencies = append(dependencies, stagesDependencies[relatedStageIndex]...)
+	}
+
+	if dockerfileStageDependenciesDebug() {
+		logboek.Context(ctx).LogLn(dependencies)
+	}
+
+	return util.Sha256Hash(dependencies...), nil
+}
+
+func (s *DockerfileInstructionStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, img container_runtime.ImageInterface) error {
+	fromImageName := s.baseImageName
+	if !s.IsFirst() {
+		fromImageName = prevBuiltImage.Name()
+	}
+
+	var contextArchivePath string
+	if s.isContextUsed() {
+		archivePath, err := s.dockerfileStage.prepareContextArchive(ctx, c.GiterminismManager())
+		if err != nil {
+			return err
+		}
+
+		contextArchivePath = archivePath
+	}
+
+	dockerfileName := fmt.Sprintf(".werf-%s.Dockerfile", uuid.New().String())
+	archivePath, err := context_manager.AddFileToContextArchive(ctx, contextArchivePath, dockerfileName, s.generateDockerfile(fromImageName))
+	if err != nil {
+		return fmt.Errorf("unable to add dockerfile to build context archive: %s", err)
+	}
+
+	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--file=%s", dockerfileName))
+	img.DockerfileImageBuilder().AppendBuildArgs(s.dockerfileStage.commonDockerBuildArgs()...)
+	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=%s", image.WerfProjectRepoCommitLabel, c.GiterminismManager().HeadCommit()))
+	img.DockerfileImageBuilder().SetFilePathToStdin(archivePath)
+
+	if c.GiterminismManager().Dev() {
+		img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=true", image.WerfDevLabel))
+	}
+
+	return nil
+}
+
+// generateDockerfile returns the dockerfile with the single instruction based on the previous stage image or the base image.
+// Docker stages referenced by COPY --from are added as is to be built within the same build.
+func (s *DockerfileInstructionStage) generateDockerfile(fromImageName string) []byte {
+	var lines []string
+	for _, metaArg := range s.dockerfileStage.dockerMetaArgs {
+		lines = append(lines, metaArg.String())
+	}
+
+	if relatedStageIndex := s.copyFromDockerStageIndex(); relatedStageIndex != -1 {
+		for _, dockerStage := range s.dockerfileStage.dockerStages[:relatedStageIndex+1] {
+			lines = append(lines, dockerStage.SourceCode)
+			for _, cmd := range dockerStage.Commands {
+				lines = append(lines, cmd.(dockerfileInstructionInterface).String())
+			}
+		}
+	}
+
+	lines = append(lines, fmt.Sprintf("FROM %s", fromImageName))
+	lines = append(lines, s.precedingInstructions...)
+	lines = append(lines, s.instruction.String())
+
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func (s *DockerfileInstructionStage) isContextUsed() bool {
+	switch s.instruction.(type) {
+	case *instructions.AddCommand, *instructions.CopyCommand:
+		return true
+	default:
+		return false
+	}
+}
+
+// copyFromDockerStageIndex returns index of the docker stage referenced by COPY --from or -1
+func (s *DockerfileInstructionStage) copyFromDockerStageIndex() int {
+	c, ok := s.instruction.(*instructions.CopyCommand)
+	if !ok || c.From == "" {
+		return -1
+	}
+
+	relatedStageIndex, err := strconv.Atoi(c.From)
+	if err != nil || relatedStageIndex >= s.dockerStageID {
+		return -1
+	}
+
+	return relatedStageIndex
+}
@@ -0,0 +1,188 @@
+package stage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+const testStagedDockerfile = `ARG BASE=alpine:3.13
+
+FROM ${BASE} AS builder
+ARG VERSION=1
+RUN make build
+
+FROM builder AS app
+CMD ["app"]
+ENTRYPOINT ["/bin/app"]
+COPY --from=0 /src /dst
+`
+
+func newTestDockerfileStage(t *testing.T, dockerfile string, target string) *DockerfileStage {
+	p, err := parser.Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerStages, dockerMetaArgs, err := instructions.Parse(p.AST)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dockerTargetIndex := len(dockerStages) - 1
+	for i, s := range dockerStages {
+		if s.Name == target {
+			dockerTargetIndex = i
+		}
+	}
+
+	ds, err := NewDockerStages(dockerStages, map[string]string{}, dockerMetaArgs, dockerTargetIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return GenerateDockerfileStage(
+		NewDockerRunArgs("Dockerfile", target, ".", nil, nil, nil, "", ""),
+		ds,
+		NewContextChecksum(nil),
+		nil,
+		&NewBaseStageOptions{ImageName: "app"},
+	)
+}
+
+func TestGenerateDockerfileInstructionStages(t *testing.T) {
+	type expectedStage struct {
+		name                  StageName
+		instruction           string
+		precedingInstructions []string
+		baseImageName         string
+		isContextUsed         bool
+		copyFromIndex         int
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		expected []expectedStage
+	}{
+		{
+			name:   "target with the parent docker stage",
+			target: "app",
+			expected: []expectedStage{
+				{name: "dockerfileInstruction1", instruction: "ARG VERSION=1", baseImageName: "alpine:3.13", copyFromIndex: -1},
+				{name: "dockerfileInstruction2", instruction: "RUN make build", precedingInstructions: []string{"ARG VERSION=1"}, copyFromIndex: -1},
+				{name: "dockerfileInstruction3", instruction: `CMD ["app"]`, copyFromIndex: -1},
+				{name: "dockerfileInstruction4", instruction: `ENTRYPOINT ["/bin/app"]`, precedingInstructions: []string{`CMD ["app"]`}, copyFromIndex: -1},
+				{name: "dockerfileInstruction5", instruction: "COPY --from=0 /src /dst", isContextUsed: true, copyFromIndex: 0},
+			},
+		},
+		{
+			name:   "first docker stage",
+			target: "builder",
+			expected: []expectedStage{
+				{name: "dockerfileInstruction1", instruction: "ARG VERSION=1", baseImageName: "alpine:3.13", copyFromIndex: -1},
+				{name: "dockerfileInstruction2", instruction: "RUN make build", precedingInstructions: []string{"ARG VERSION=1"}, copyFromIndex: -1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := GenerateDockerfileInstructionStages(newTestDockerfileStage(t, testStagedDockerfile, tt.target), &NewBaseStageOptions{ImageName: "app"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stages) != len(tt.expected) {
+				t.Fatalf("expected %d stages, got %d", len(tt.expected), len(stages))
+			}
+
+			for i, s := range stages {
+				expected := tt.expected[i]
+
+				if s.Name() != expected.name {
+					t.Errorf("stage %d: expected name %q, got %q", i, expected.name, s.Name())
+				}
+
+				if s.Instruction() != expected.instruction {
+					t.Errorf("stage %d: expected instruction %q, got %q", i, expected.instruction, s.Instruction())
+				}
+
+				if len(s.precedingInstructions) != 0 || len(expected.precedingInstructions) != 0 {
+					if !reflect.DeepEqual(s.precedingInstructions, expected.precedingInstructions) {
+						t.Errorf("stage %d: expected preceding instructions %q, got %q", i, expected.precedingInstructions, s.precedingInstructions)
+					}
+				}
+
+				if s.baseImageName != expected.baseImageName || s.IsFirst() != (i == 0) {
+					t.Errorf("stage %d: unexpected base image name %q", i, s.baseImageName)
+				}
+
+				if s.isContextUsed() != expected.isContextUsed {
+					t.Errorf("stage %d: expected context used %v", i, expected.isContextUsed)
+				}
+
+				if s.copyFromDockerStageIndex() != expected.copyFromIndex {
+					t.Errorf("stage %d: expected COPY --from index %d, got %d", i, expected.copyFromIndex, s.copyFromDockerStageIndex())
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfileInstructionStages_Onbuild(t *testing.T) {
+	dockerfileStage := newTestDockerfileStage(t, "FROM alpine\nONBUILD RUN make\n", "")
+	if _, err := GenerateDockerfileInstructionStages(dockerfileStage, &NewBaseStageOptions{ImageName: "app"}); err == nil {
+		t.Fatal("expected error for ONBUILD instruction")
+	}
+}
+
+func TestDockerfileInstructionStage_GenerateDockerfile(t *testing.T) {
+	stages, err := GenerateDockerfileInstructionStages(newTestDockerfileStage(t, testStagedDockerfile, "app"), &NewBaseStageOptions{ImageName: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		stage    *DockerfileInstructionStage
+		from     string
+		expected string
+	}{
+		{
+			name:     "first stage",
+			stage:    stages[0],
+			from:     "alpine:3.13",
+			expected: "ARG BASE=alpine:3.13\nFROM alpine:3.13\nARG VERSION=1\n",
+		},
+		{
+			name:     "preceding ARG instructions",
+			stage:    stages[1],
+			from:     "prev-stage",
+			expected: "ARG BASE=alpine:3.13\nFROM prev-stage\nARG VERSION=1\nRUN make build\n",
+		},
+		{
+			name:     "ENTRYPOINT keeps CMD",
+			stage:    stages[3],
+			from:     "prev-stage",
+			expected: "ARG BASE=alpine:3.13\nFROM prev-stage\nCMD [\"app\"]\nENTRYPOINT [\"/bin/app\"]\n",
+		},
+		{
+			name:     "COPY --from adds the related docker stages",
+			stage:    stages[4],
+			from:     "prev-stage",
+			expected: "ARG BASE=alpine:3.13\nFROM ${BASE} AS builder\nARG VERSION=1\nRUN make build\nFROM prev-stage\nCOPY --from=0 /src /dst\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.stage.generateDockerfile(tt.from)); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
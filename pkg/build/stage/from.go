@@ -68,6 +68,10 @@ func (s *FromStage) GetDependencies(_ context.Context, c Conveyor, prevImage, _
 		args = append(args, prevImage.Name())
 	}
 
+	if s.platform != "" {
+		args = append(args, s.platform)
+	}
+
 	return util.Sha256Hash(args...), nil
 }
 
@@ -38,17 +38,20 @@ func getImports(imageBaseConfig *config.StapelImageBase, options *getImportsOpti
 	return imports
 }
 
-func newImportsStage(imports []*config.Import, name StageName, baseStageOptions *NewBaseStageOptions) *ImportsStage {
+func newImportsStage(imports []*config.Import, dependencies []*config.Dependency, name StageName, baseStageOptions *NewBaseStageOptions) *ImportsStage {
 	s := &ImportsStage{}
 	s.imports = imports
+	s.dependencies = dependencies
 	s.BaseStage = newBaseStage(name, baseStageOptions)
 	return s
 }
 
+// ImportsStage copies files of imports and sets envs with properties of dependencies images
 type ImportsStage struct {
 	*BaseStage
 
-	imports []*config.Import
+	imports      []*config.Import
+	dependencies []*config.Dependency
 }
 
 func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
@@ -69,6 +72,12 @@ func (s *ImportsStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 		args = append(args, elm.Group, elm.Owner)
 	}
 
+	dependenciesArgs, err := getDependenciesChecksumArgs(c, s.dependencies)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, dependenciesArgs...)
+
 	return util.Sha256Hash(args...), nil
 }
 
@@ -99,6 +108,15 @@ func (s *ImportsStage) PrepareImage(ctx context.Context, c Conveyor, _, image co
 		imageServiceCommitChangeOptions.AddLabel(map[string]string{labelKey: labelValue})
 	}
 
+	if len(s.dependencies) != 0 {
+		envs, err := getDependenciesImportsValues(c, s.dependencies)
+		if err != nil {
+			return err
+		}
+
+		image.Container().ServiceCommitChangeOptions().AddEnv(envs)
+	}
+
 	return nil
 }
 
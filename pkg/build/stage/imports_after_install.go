@@ -4,16 +4,17 @@ import "github.com/werf/werf/pkg/config"
 
 func GenerateImportsAfterInstallStage(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) *ImportsAfterInstallStage {
 	imports := getImports(imageBaseConfig, &getImportsOptions{After: Install})
-	if len(imports) != 0 {
-		return newImportsAfterInstallStage(imports, baseStageOptions)
+	dependencies := getDependencies(imageBaseConfig, &getImportsOptions{After: Install})
+	if len(imports) != 0 || len(dependencies) != 0 {
+		return newImportsAfterInstallStage(imports, dependencies, baseStageOptions)
 	}
 
 	return nil
 }
 
-func newImportsAfterInstallStage(imports []*config.Import, baseStageOptions *NewBaseStageOptions) *ImportsAfterInstallStage {
+func newImportsAfterInstallStage(imports []*config.Import, dependencies []*config.Dependency, baseStageOptions *NewBaseStageOptions) *ImportsAfterInstallStage {
 	s := &ImportsAfterInstallStage{}
-	s.ImportsStage = newImportsStage(imports, ImportsAfterInstall, baseStageOptions)
+	s.ImportsStage = newImportsStage(imports, dependencies, ImportsAfterInstall, baseStageOptions)
 	return s
 }
 
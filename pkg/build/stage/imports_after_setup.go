@@ -4,16 +4,17 @@ import "github.com/werf/werf/pkg/config"
 
 func GenerateImportsAfterSetupStage(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) *ImportsAfterSetupStage {
 	imports := getImports(imageBaseConfig, &getImportsOptions{After: Setup})
-	if len(imports) != 0 {
-		return newImportsAfterSetupStage(imports, baseStageOptions)
+	dependencies := getDependencies(imageBaseConfig, &getImportsOptions{After: Setup})
+	if len(imports) != 0 || len(dependencies) != 0 {
+		return newImportsAfterSetupStage(imports, dependencies, baseStageOptions)
 	}
 
 	return nil
 }
 
-func newImportsAfterSetupStage(imports []*config.Import, baseStageOptions *NewBaseStageOptions) *ImportsAfterSetupStage {
+func newImportsAfterSetupStage(imports []*config.Import, dependencies []*config.Dependency, baseStageOptions *NewBaseStageOptions) *ImportsAfterSetupStage {
 	s := &ImportsAfterSetupStage{}
-	s.ImportsStage = newImportsStage(imports, ImportsAfterSetup, baseStageOptions)
+	s.ImportsStage = newImportsStage(imports, dependencies, ImportsAfterSetup, baseStageOptions)
 	return s
 }
 
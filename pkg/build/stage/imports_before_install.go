@@ -4,16 +4,17 @@ import "github.com/werf/werf/pkg/config"
 
 func GenerateImportsBeforeInstallStage(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) *ImportsBeforeInstallStage {
 	imports := getImports(imageBaseConfig, &getImportsOptions{Before: Install})
-	if len(imports) != 0 {
-		return newImportsBeforeInstallStage(imports, baseStageOptions)
+	dependencies := getDependencies(imageBaseConfig, &getImportsOptions{Before: Install})
+	if len(imports) != 0 || len(dependencies) != 0 {
+		return newImportsBeforeInstallStage(imports, dependencies, baseStageOptions)
 	}
 
 	return nil
 }
 
-func newImportsBeforeInstallStage(imports []*config.Import, baseStageOptions *NewBaseStageOptions) *ImportsBeforeInstallStage {
+func newImportsBeforeInstallStage(imports []*config.Import, dependencies []*config.Dependency, baseStageOptions *NewBaseStageOptions) *ImportsBeforeInstallStage {
 	s := &ImportsBeforeInstallStage{}
-	s.ImportsStage = newImportsStage(imports, ImportsBeforeInstall, baseStageOptions)
+	s.ImportsStage = newImportsStage(imports, dependencies, ImportsBeforeInstall, baseStageOptions)
 	return s
 }
 
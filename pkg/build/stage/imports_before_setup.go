@@ -4,16 +4,17 @@ import "github.com/werf/werf/pkg/config"
 
 func GenerateImportsBeforeSetupStage(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) *ImportsBeforeSetupStage {
 	imports := getImports(imageBaseConfig, &getImportsOptions{Before: Setup})
-	if len(imports) != 0 {
-		return newImportsBeforeSetupStage(imports, baseStageOptions)
+	dependencies := getDependencies(imageBaseConfig, &getImportsOptions{Before: Setup})
+	if len(imports) != 0 || len(dependencies) != 0 {
+		return newImportsBeforeSetupStage(imports, dependencies, baseStageOptions)
 	}
 
 	return nil
 }
 
-func newImportsBeforeSetupStage(imports []*config.Import, baseStageOptions *NewBaseStageOptions) *ImportsBeforeSetupStage {
+func newImportsBeforeSetupStage(imports []*config.Import, dependencies []*config.Dependency, baseStageOptions *NewBaseStageOptions) *ImportsBeforeSetupStage {
 	s := &ImportsBeforeSetupStage{}
-	s.ImportsStage = newImportsStage(imports, ImportsBeforeSetup, baseStageOptions)
+	s.ImportsStage = newImportsStage(imports, dependencies, ImportsBeforeSetup, baseStageOptions)
 	return s
 }
 
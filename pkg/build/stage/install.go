@@ -42,6 +42,10 @@ func (s *InstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltIm
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image.BuilderContainer()); err != nil {
+		return err
+	}
+
 	if err := s.builder.Install(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
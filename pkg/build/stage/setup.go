@@ -42,6 +42,10 @@ func (s *SetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImag
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image.BuilderContainer()); err != nil {
+		return err
+	}
+
 	if err := s.builder.Setup(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
@@ -2,7 +2,11 @@ package stage
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/werf/logboek"
 
@@ -34,6 +38,8 @@ type UserStage struct {
 	*BaseStage
 
 	builder builder.Builder
+
+	secretsDir string
 }
 
 func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor, name StageName) (string, error) {
@@ -57,6 +63,77 @@ func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor
 	return util.Sha256Hash(args...), nil
 }
 
+func (s *UserStage) PostRunHook(ctx context.Context, c Conveyor) error {
+	var errMsgs []string
+	if err := s.BaseStage.PostRunHook(ctx, c); err != nil {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	if err := s.removeSecretsFiles(); err != nil {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	if len(errMsgs) != 0 {
+		return fmt.Errorf("%s", strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
+
+// addSecretsVolumes mounts secrets only into the build container of the stage,
+// so secrets values are neither committed into the stage image nor taken into account in the stage digest.
+// Values of env secrets are written to temporary files, which are removed by PostRunHook after the stage is built
+func (s *UserStage) addSecretsVolumes(container builder.Container) error {
+	for _, secret := range s.configSecrets {
+		var hostPath string
+		if secret.Env != "" {
+			value, exist := os.LookupEnv(secret.Env)
+			if !exist {
+				return fmt.Errorf("unable to mount secret %q: environment variable %s is not set", secret.Id, secret.Env)
+			}
+
+			secretsDir := filepath.Join(s.imageTmpDir, "secrets", string(s.Name()))
+			if err := os.MkdirAll(secretsDir, 0o700); err != nil {
+				return fmt.Errorf("unable to create dir %s: %s", secretsDir, err)
+			}
+			s.secretsDir = secretsDir
+
+			hostPath = filepath.Join(secretsDir, secret.Id)
+			if err := ioutil.WriteFile(hostPath, []byte(value), 0o600); err != nil {
+				return fmt.Errorf("unable to write secret %q: %s", secret.Id, err)
+			}
+		} else {
+			hostPath = util.ExpandPath(secret.Src)
+
+			exist, err := util.FileExists(hostPath)
+			if err != nil {
+				return fmt.Errorf("unable to check existence of secret %q file %s: %s", secret.Id, hostPath, err)
+			}
+
+			if !exist {
+				return fmt.Errorf("unable to mount secret %q: file %s not found", secret.Id, hostPath)
+			}
+		}
+
+		container.AddVolume(fmt.Sprintf("%s:%s:ro", hostPath, secret.To))
+	}
+
+	return nil
+}
+
+func (s *UserStage) removeSecretsFiles() error {
+	if s.secretsDir == "" {
+		return nil
+	}
+
+	if err := os.RemoveAll(s.secretsDir); err != nil {
+		return fmt.Errorf("unable to remove secrets dir %s: %s", s.secretsDir, err)
+	}
+	s.secretsDir = ""
+
+	return nil
+}
+
 func debugUserStageChecksum() bool {
 	return os.Getenv("WERF_DEBUG_USER_STAGE_CHECKSUM") == "1"
 }
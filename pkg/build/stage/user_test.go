@@ -0,0 +1,133 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+type fakeBuilderContainer struct {
+	volumes []string
+}
+
+func (c *fakeBuilderContainer) AddRunCommands(_ ...string)        {}
+func (c *fakeBuilderContainer) AddServiceRunCommands(_ ...string) {}
+func (c *fakeBuilderContainer) AddVolumeFrom(_ ...string)         {}
+func (c *fakeBuilderContainer) AddVolume(volumes ...string) {
+	c.volumes = append(c.volumes, volumes...)
+}
+func (c *fakeBuilderContainer) AddExpose(_ ...string)        {}
+func (c *fakeBuilderContainer) AddEnv(_ map[string]string)   {}
+func (c *fakeBuilderContainer) AddLabel(_ map[string]string) {}
+
+func newTestUserStage(t *testing.T, secrets []*config.Secret) *UserStage {
+	tmpDir, err := ioutil.TempDir("", "werf-user-stage-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	return newUserStage(nil, Install, &NewBaseStageOptions{ImageTmpDir: tmpDir, ConfigSecrets: secrets})
+}
+
+func TestUserStage_EnvSecretFileIsRemovedByPostRunHook(t *testing.T) {
+	const envName = "WERF_TEST_USER_STAGE_SECRET"
+	os.Setenv(envName, "s3cr3t")
+	defer os.Unsetenv(envName)
+
+	s := newTestUserStage(t, []*config.Secret{{Id: "token", Env: envName, To: "/run/secrets/token"}})
+	container := &fakeBuilderContainer{}
+
+	if err := s.addSecretsVolumes(container); err != nil {
+		t.Fatal(err)
+	}
+
+	hostPath := filepath.Join(s.imageTmpDir, "secrets", string(Install), "token")
+	if expected := fmt.Sprintf("%s:/run/secrets/token:ro", hostPath); len(container.volumes) != 1 || container.volumes[0] != expected {
+		t.Fatalf("expected volumes [%s], got %v", expected, container.volumes)
+	}
+
+	data, err := ioutil.ReadFile(hostPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "s3cr3t" {
+		t.Fatalf("expected secret file content %q, got %q", "s3cr3t", string(data))
+	}
+
+	if err := s.PostRunHook(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Dir(hostPath)); !os.IsNotExist(err) {
+		t.Fatalf("expected secrets dir %s to be removed, got %v", filepath.Dir(hostPath), err)
+	}
+}
+
+func TestUserStage_SrcSecretIsMountedAsIs(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "werf-user-stage-test-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	src := filepath.Join(srcDir, "credentials")
+	if err := ioutil.WriteFile(src, []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestUserStage(t, []*config.Secret{{Id: "credentials", Src: src, To: "/root/.aws/credentials"}})
+	container := &fakeBuilderContainer{}
+
+	if err := s.addSecretsVolumes(container); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := fmt.Sprintf("%s:/root/.aws/credentials:ro", src); len(container.volumes) != 1 || container.volumes[0] != expected {
+		t.Fatalf("expected volumes [%s], got %v", expected, container.volumes)
+	}
+
+	if err := s.PostRunHook(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		t.Fatalf("expected src secret file %s to be kept: %s", src, err)
+	}
+}
+
+func TestUserStage_AddSecretsVolumesErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      *config.Secret
+		expectedErr string
+	}{
+		{
+			name:        "env is not set",
+			secret:      &config.Secret{Id: "token", Env: "WERF_TEST_USER_STAGE_UNSET_SECRET", To: "/run/secrets/token"},
+			expectedErr: "environment variable WERF_TEST_USER_STAGE_UNSET_SECRET is not set",
+		},
+		{
+			name:        "src does not exist",
+			secret:      &config.Secret{Id: "token", Src: "/nonexistent/werf/secret", To: "/run/secrets/token"},
+			expectedErr: "file /nonexistent/werf/secret not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestUserStage(t, []*config.Secret{tt.secret})
+
+			err := s.addSecretsVolumes(&fakeBuilderContainer{})
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -48,7 +48,7 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 	}
 	logboek.Context(ctx).Debug().LogF("%s stage is empty: %v\n", stg.LogDetailedName(), isEmpty)
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if !isImageFirstStage(stg) {
 		if iterator.PrevStage == nil {
 			panic(fmt.Sprintf("expected PrevStage to be set for image %q stage %s!", img.GetName(), stg.Name()))
 		}
@@ -78,3 +78,12 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 
 	return nil
 }
+
+// isImageFirstStage returns true for the stages, which are based on the base image rather than on the previous stage
+func isImageFirstStage(stg stage.Interface) bool {
+	if s, ok := stg.(*stage.DockerfileInstructionStage); ok {
+		return s.IsFirst()
+	}
+
+	return stg.Name() == stage.From || stg.Name() == stage.Dockerfile
+}
@@ -0,0 +1,235 @@
+package buildah
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/werf/logboek"
+)
+
+const (
+	DefaultStorageDriver = "vfs"
+	DefaultIsolation     = "chroot"
+)
+
+var (
+	buildahBinPath = "buildah"
+	storageDriver  = DefaultStorageDriver
+	isolation      = DefaultIsolation
+)
+
+type InitOptions struct {
+	BinPath       string
+	StorageDriver string
+	Isolation     string
+}
+
+func Init(opts InitOptions) error {
+	if opts.BinPath != "" {
+		buildahBinPath = opts.BinPath
+	}
+	if opts.StorageDriver != "" {
+		storageDriver = opts.StorageDriver
+	}
+	if opts.Isolation != "" {
+		isolation = opts.Isolation
+	}
+
+	if _, err := exec.LookPath(buildahBinPath); err != nil {
+		return fmt.Errorf("buildah binary %q not found: %s", buildahBinPath, err)
+	}
+
+	return nil
+}
+
+type RunOptions struct {
+	Volumes    []string
+	User       string
+	WorkingDir string
+}
+
+// From creates a working container with the specified name from the image
+func From(ctx context.Context, ref, containerName string) error {
+	_, err := runRecorded(ctx, "from", "--pull-never", "--name", containerName, ref)
+	return err
+}
+
+func Run(ctx context.Context, containerName string, opts RunOptions, command ...string) error {
+	args := []string{"run", "--isolation", isolation}
+	for _, volume := range opts.Volumes {
+		args = append(args, "--volume", volume)
+	}
+	if opts.User != "" {
+		args = append(args, "--user", opts.User)
+	}
+	if opts.WorkingDir != "" {
+		args = append(args, "--workingdir", opts.WorkingDir)
+	}
+	args = append(args, containerName, "--")
+	args = append(args, command...)
+
+	return runLive(ctx, args...)
+}
+
+// Config changes the configuration of the working container, args are buildah-config flags
+func Config(ctx context.Context, containerName string, args ...string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	_, err := runRecorded(ctx, append(append([]string{"config"}, args...), containerName)...)
+	return err
+}
+
+// Commit creates an image from the working container and returns the id of the new image
+func Commit(ctx context.Context, containerName string) (string, error) {
+	output, err := runRecorded(ctx, "commit", "--format", "docker", "--quiet", containerName)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+func Rm(ctx context.Context, containerName string) error {
+	_, err := runRecorded(ctx, "rm", containerName)
+	return err
+}
+
+// Mount mounts the root filesystem of the working container and returns the mount point
+func Mount(ctx context.Context, containerName string) (string, error) {
+	output, err := runRecorded(ctx, "mount", containerName)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func ContainerExist(ctx context.Context, containerName string) (bool, error) {
+	output, err := runRecorded(ctx, "containers", "--quiet", "--filter", fmt.Sprintf("name=^%s$", containerName))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// Bud builds an image from the Dockerfile using the specified build context directory
+func Bud(ctx context.Context, contextDir string, args ...string) error {
+	budArgs := append([]string{"bud", "--format", "docker", "--isolation", isolation}, args...)
+	budArgs = append(budArgs, contextDir)
+	return runLive(ctx, budArgs...)
+}
+
+// Pull pulls the image, the last argument is the image reference and the others are options (e.g. --platform)
+func Pull(ctx context.Context, args ...string) error {
+	return runLive(ctx, append([]string{"pull"}, args...)...)
+}
+
+func Push(ctx context.Context, ref string) error {
+	return runLive(ctx, "push", ref, fmt.Sprintf("docker://%s", ref))
+}
+
+// PushToArchive saves the image into the docker-archive tarball
+func PushToArchive(ctx context.Context, ref, archivePath string) error {
+	_, err := runRecorded(ctx, "push", "--quiet", ref, fmt.Sprintf("docker-archive:%s:%s", archivePath, ref))
+	return err
+}
+
+// PullFromArchive loads the image from the docker-archive tarball
+func PullFromArchive(ctx context.Context, archivePath string) error {
+	_, err := runRecorded(ctx, "pull", "--quiet", fmt.Sprintf("docker-archive:%s", archivePath))
+	return err
+}
+
+func Tag(ctx context.Context, ref string, newNames ...string) error {
+	_, err := runRecorded(ctx, append([]string{"tag", ref}, newNames...)...)
+	return err
+}
+
+func Rmi(ctx context.Context, refs ...string) error {
+	_, err := runRecorded(ctx, append([]string{"rmi"}, refs...)...)
+	return err
+}
+
+type ImageConfig struct {
+	Labels     map[string]string `json:"Labels"`
+	Env        []string          `json:"Env"`
+	Cmd        []string          `json:"Cmd"`
+	Entrypoint []string          `json:"Entrypoint"`
+	WorkingDir string            `json:"WorkingDir"`
+	User       string            `json:"User"`
+	Image      string            `json:"Image"`
+	OnBuild    []string          `json:"OnBuild"`
+}
+
+type ImageInspect struct {
+	FromImageID string `json:"FromImageID"`
+	Docker      struct {
+		Created time.Time   `json:"created"`
+		Parent  string      `json:"parent"`
+		Size    int64       `json:"size"`
+		Config  ImageConfig `json:"config"`
+	} `json:"Docker"`
+}
+
+// Inspect returns nil if the image does not exist in the local storage
+func Inspect(ctx context.Context, ref string) (*ImageInspect, error) {
+	output, err := runRecorded(ctx, "inspect", "--type", "image", ref)
+	if err != nil {
+		if isImageNotKnownErr(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	res := &ImageInspect{}
+	if err := json.Unmarshal([]byte(output), res); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal buildah inspect output: %s", err)
+	}
+
+	return res, nil
+}
+
+func isImageNotKnownErr(err error) bool {
+	return strings.Contains(err.Error(), "image not known") || strings.Contains(err.Error(), "no such image") || strings.Contains(err.Error(), "image not found")
+}
+
+func prepareCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmdArgs := append([]string{"--storage-driver", storageDriver}, args...)
+	logboek.Context(ctx).Debug().LogF("Buildah command:\n%s %s\n", buildahBinPath, strings.Join(cmdArgs, " "))
+
+	cmd := exec.CommandContext(ctx, buildahBinPath, cmdArgs...)
+	cmd.Env = os.Environ()
+	return cmd
+}
+
+func runLive(ctx context.Context, args ...string) error {
+	cmd := prepareCommand(ctx, args...)
+	cmd.Stdout = logboek.Context(ctx).OutStream()
+	cmd.Stderr = logboek.Context(ctx).ErrStream()
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buildah %s failed: %s", args[0], err)
+	}
+	return nil
+}
+
+func runRecorded(ctx context.Context, args ...string) (string, error) {
+	cmd := prepareCommand(ctx, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("buildah %s failed: %s\n%s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
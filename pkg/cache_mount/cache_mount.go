@@ -0,0 +1,152 @@
+package cache_mount
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/slug"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	CacheMountsVersion = "1"
+
+	SharingShared  = "shared"
+	SharingLocked  = "locked"
+	SharingPrivate = "private"
+
+	ScopeProject = "project"
+	ScopeImage   = "image"
+
+	sharedInstanceName    = "shared"
+	privateInstancePrefix = "private-"
+	instanceDataDir       = "data"
+	instanceLastUsedAt    = "last_used_at"
+)
+
+func GetCacheMountsDir() string {
+	return filepath.Join(werf.GetLocalCacheDir(), "cache_mounts", CacheMountsVersion)
+}
+
+type AcquireOptions struct {
+	ProjectName string
+	ImageName   string
+	Id          string
+	Sharing     string
+	Scope       string
+}
+
+// CacheMount is the host dir of the cache mount instance, which is used by the build container until Release
+type CacheMount struct {
+	HostPath string
+
+	instanceDir string
+	lock        lockgate.LockHandle
+}
+
+// Acquire locks the instance of the cache mount according to the sharing mode.
+// The only instance of the shared cache is used by concurrent builds simultaneously,
+// the only instance of the locked cache is used by one build at a time and other builds wait,
+// the first free instance of the private cache is used by the build or the new one is created if all instances are busy.
+func Acquire(ctx context.Context, opts AcquireOptions) (*CacheMount, error) {
+	scopeDir, err := scopeDirName(opts.Scope, opts.ImageName)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir := filepath.Join(GetCacheMountsDir(), opts.ProjectName, scopeDir, opts.Id)
+
+	var instanceDir string
+	var lock lockgate.LockHandle
+	switch opts.Sharing {
+	case SharingShared, SharingLocked:
+		instanceDir = filepath.Join(cacheDir, sharedInstanceName)
+
+		_, handle, err := werf.AcquireHostLock(ctx, instanceLockName(instanceDir), lockgate.AcquireOptions{Shared: opts.Sharing == SharingShared})
+		if err != nil {
+			return nil, fmt.Errorf("unable to lock cache mount %q: %s", opts.Id, err)
+		}
+
+		lock = handle
+	case SharingPrivate:
+		for ind := 0; ; ind++ {
+			dir := filepath.Join(cacheDir, privateInstancePrefix+strconv.Itoa(ind))
+
+			acquired, handle, err := werf.AcquireHostLock(ctx, instanceLockName(dir), lockgate.AcquireOptions{NonBlocking: true})
+			if err != nil {
+				return nil, fmt.Errorf("unable to lock cache mount %q: %s", opts.Id, err)
+			}
+
+			if acquired {
+				instanceDir = dir
+				lock = handle
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown cache mount %q sharing %q", opts.Id, opts.Sharing)
+	}
+
+	m := &CacheMount{
+		HostPath:    filepath.Join(instanceDir, instanceDataDir),
+		instanceDir: instanceDir,
+		lock:        lock,
+	}
+
+	if err := os.MkdirAll(m.HostPath, os.ModePerm); err != nil {
+		_ = werf.ReleaseHostLock(lock)
+		return nil, fmt.Errorf("unable to create cache mount dir %s: %s", m.HostPath, err)
+	}
+
+	if err := m.touch(); err != nil {
+		_ = werf.ReleaseHostLock(lock)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Release updates the last usage time of the instance, which is used by the LRU cleanup, and unlocks it
+func (m *CacheMount) Release() error {
+	touchErr := m.touch()
+
+	if err := werf.ReleaseHostLock(m.lock); err != nil {
+		return fmt.Errorf("unable to unlock cache mount %s: %s", m.instanceDir, err)
+	}
+
+	return touchErr
+}
+
+func (m *CacheMount) touch() error {
+	path := filepath.Join(m.instanceDir, instanceLastUsedAt)
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0o644); err != nil {
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func scopeDirName(scope, imageName string) (string, error) {
+	switch scope {
+	case ScopeProject:
+		return ScopeProject, nil
+	case ScopeImage:
+		if imageName == "" {
+			return "image--", nil
+		}
+		return fmt.Sprintf("image-%s", slug.LimitedSlug(imageName, slug.DefaultSlugMaxSize)), nil
+	default:
+		return "", fmt.Errorf("unknown cache mount scope %q", scope)
+	}
+}
+
+func instanceLockName(instanceDir string) string {
+	return fmt.Sprintf("cache_mount.%s", strings.TrimPrefix(instanceDir, werf.GetLocalCacheDir()))
+}
@@ -0,0 +1,142 @@
+package cache_mount
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+func initTestWerf(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cache-mount-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	if err := werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func acquireTestCacheMount(t *testing.T, opts AcquireOptions) *CacheMount {
+	m, err := Acquire(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func releaseTestCacheMount(t *testing.T, m *CacheMount) {
+	if err := m.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquire_Shared(t *testing.T) {
+	initTestWerf(t)
+
+	opts := AcquireOptions{ProjectName: "project", ImageName: "app", Id: "go", Sharing: SharingShared, Scope: ScopeImage}
+	m1 := acquireTestCacheMount(t, opts)
+	m2 := acquireTestCacheMount(t, opts)
+
+	expectedHostPath := filepath.Join(GetCacheMountsDir(), "project", "image-app", "go", sharedInstanceName, instanceDataDir)
+	for _, m := range []*CacheMount{m1, m2} {
+		if m.HostPath != expectedHostPath {
+			t.Errorf("expected host path %s, got %s", expectedHostPath, m.HostPath)
+		}
+	}
+
+	if info, err := os.Stat(expectedHostPath); err != nil || !info.IsDir() {
+		t.Errorf("expected host path %s to be created: %v", expectedHostPath, err)
+	}
+
+	releaseTestCacheMount(t, m1)
+	releaseTestCacheMount(t, m2)
+}
+
+func TestAcquire_Private(t *testing.T) {
+	initTestWerf(t)
+
+	opts := AcquireOptions{ProjectName: "project", Id: "go", Sharing: SharingPrivate, Scope: ScopeProject}
+	cacheDir := filepath.Join(GetCacheMountsDir(), "project", ScopeProject, "go")
+
+	m1 := acquireTestCacheMount(t, opts)
+	m2 := acquireTestCacheMount(t, opts)
+
+	if expected := filepath.Join(cacheDir, "private-0", instanceDataDir); m1.HostPath != expected {
+		t.Errorf("expected host path %s, got %s", expected, m1.HostPath)
+	}
+	if expected := filepath.Join(cacheDir, "private-1", instanceDataDir); m2.HostPath != expected {
+		t.Errorf("expected busy instance to be skipped and host path %s to be used, got %s", expected, m2.HostPath)
+	}
+
+	releaseTestCacheMount(t, m1)
+
+	m3 := acquireTestCacheMount(t, opts)
+	if m3.HostPath != m1.HostPath {
+		t.Errorf("expected released instance %s to be reused, got %s", m1.HostPath, m3.HostPath)
+	}
+
+	releaseTestCacheMount(t, m2)
+	releaseTestCacheMount(t, m3)
+}
+
+func TestAcquire_Locked(t *testing.T) {
+	initTestWerf(t)
+
+	opts := AcquireOptions{ProjectName: "project", Id: "go", Sharing: SharingLocked, Scope: ScopeProject}
+	m := acquireTestCacheMount(t, opts)
+
+	acquired, lock, err := werf.AcquireHostLock(context.Background(), instanceLockName(m.instanceDir), lockgate.AcquireOptions{NonBlocking: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acquired {
+		_ = werf.ReleaseHostLock(lock)
+		t.Fatalf("expected locked cache mount %s to be held exclusively", m.instanceDir)
+	}
+
+	releaseTestCacheMount(t, m)
+
+	if _, err := os.Stat(filepath.Join(m.instanceDir, instanceLastUsedAt)); err != nil {
+		t.Errorf("expected last usage time to be recorded: %s", err)
+	}
+}
+
+func TestAcquire_InvalidOptions(t *testing.T) {
+	initTestWerf(t)
+
+	tests := []struct {
+		name        string
+		opts        AcquireOptions
+		expectedErr string
+	}{
+		{
+			name:        "unknown sharing",
+			opts:        AcquireOptions{ProjectName: "project", Id: "go", Sharing: "unknown", Scope: ScopeProject},
+			expectedErr: `unknown cache mount "go" sharing "unknown"`,
+		},
+		{
+			name:        "unknown scope",
+			opts:        AcquireOptions{ProjectName: "project", Id: "go", Sharing: SharingShared, Scope: "unknown"},
+			expectedErr: `unknown cache mount scope "unknown"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Acquire(context.Background(), tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package cache_mount
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/werf/kubedog/pkg/utils"
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/volumeutils"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type cacheMountInstance struct {
+	Dir        string
+	LastUsedAt time.Time
+	Size       uint64
+}
+
+func getBytesToFree(vu volumeutils.VolumeUsage, targetVolumeUsagePercentage float64) uint64 {
+	allowedVolumeUsageToFree := vu.Percentage - targetVolumeUsagePercentage
+	return uint64((float64(vu.TotalBytes) / 100.0) * allowedVolumeUsageToFree)
+}
+
+// RunGC removes least recently used cache mounts instances until the volume usage of the local cache dir reaches the target level,
+// instances used by running builds are skipped
+func RunGC(ctx context.Context, allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage float64, dryRun bool) error {
+	vu, err := volumeutils.GetVolumeUsageByPath(ctx, werf.GetLocalCacheDir())
+	if err != nil {
+		return fmt.Errorf("error getting volume usage by path %q: %s", werf.GetLocalCacheDir(), err)
+	}
+
+	targetVolumeUsagePercentage := allowedVolumeUsagePercentage - allowedVolumeUsageMarginPercentage
+	bytesToFree := getBytesToFree(vu, targetVolumeUsagePercentage)
+
+	if vu.Percentage <= allowedVolumeUsagePercentage {
+		logboek.Context(ctx).Default().LogBlock("Cache mounts storage check").Do(func() {
+			logboek.Context(ctx).Default().LogF("Werf cache mounts dir: %s\n", GetCacheMountsDir())
+			logboek.Context(ctx).Default().LogF("Volume usage: %s / %s\n", humanize.Bytes(vu.UsedBytes), humanize.Bytes(vu.TotalBytes))
+			logboek.Context(ctx).Default().LogF("Allowed volume usage percentage: %s <= %s — %s\n", utils.GreenF("%0.2f%%", vu.Percentage), utils.BlueF("%0.2f%%", allowedVolumeUsagePercentage), utils.GreenF("OK"))
+		})
+
+		return nil
+	}
+
+	logboek.Context(ctx).Default().LogBlock("Cache mounts storage check").Do(func() {
+		logboek.Context(ctx).Default().LogF("Werf cache mounts dir: %s\n", GetCacheMountsDir())
+		logboek.Context(ctx).Default().LogF("Volume usage: %s / %s\n", humanize.Bytes(vu.UsedBytes), humanize.Bytes(vu.TotalBytes))
+		logboek.Context(ctx).Default().LogF("Allowed percentage level exceeded: %s > %s — %s\n", utils.RedF("%0.2f%%", vu.Percentage), utils.YellowF("%0.2f%%", allowedVolumeUsagePercentage), utils.RedF("HIGH VOLUME USAGE"))
+		logboek.Context(ctx).Default().LogF("Target percentage level after cleanup: %0.2f%% - %0.2f%% (margin) = %s\n", allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage, utils.BlueF("%0.2f%%", targetVolumeUsagePercentage))
+		logboek.Context(ctx).Default().LogF("Needed to free: %s\n", utils.RedF("%s", humanize.Bytes(bytesToFree)))
+	})
+
+	instances, err := getCacheMountsInstances()
+	if err != nil {
+		return err
+	}
+
+	freedBytes, err := removeLeastRecentlyUsedInstances(ctx, instances, bytesToFree, dryRun)
+	if err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogF("Freed by cache mounts: %s\n", humanize.Bytes(freedBytes))
+
+	return nil
+}
+
+// removeLeastRecentlyUsedInstances removes instances starting from the least recently used one until bytesToFree are freed
+func removeLeastRecentlyUsedInstances(ctx context.Context, instances []*cacheMountInstance, bytesToFree uint64, dryRun bool) (uint64, error) {
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].LastUsedAt.Before(instances[j].LastUsedAt)
+	})
+
+	var freedBytes uint64
+	for _, instance := range instances {
+		if freedBytes >= bytesToFree {
+			break
+		}
+
+		removed, err := removeInstance(ctx, instance, dryRun)
+		if err != nil {
+			return freedBytes, err
+		}
+
+		if removed {
+			freedBytes += instance.Size
+		}
+	}
+
+	return freedBytes, nil
+}
+
+func removeInstance(ctx context.Context, instance *cacheMountInstance, dryRun bool) (bool, error) {
+	acquired, lock, err := werf.AcquireHostLock(ctx, instanceLockName(instance.Dir), lockgate.AcquireOptions{NonBlocking: true})
+	if err != nil {
+		return false, fmt.Errorf("unable to lock cache mount %s: %s", instance.Dir, err)
+	}
+
+	if !acquired {
+		logboek.Context(ctx).Default().LogF("Skip cache mount %s: used by the running build\n", instance.Dir)
+		return false, nil
+	}
+	defer werf.ReleaseHostLock(lock)
+
+	logboek.Context(ctx).Default().LogF("Removing cache mount %s (%s, last used at %s)\n", instance.Dir, humanize.Bytes(instance.Size), instance.LastUsedAt.Format(time.RFC3339))
+	if dryRun {
+		return true, nil
+	}
+
+	if err := removeInstanceDir(ctx, instance.Dir); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: unable to remove cache mount %s: %s\n", instance.Dir, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// removeInstanceDir falls back to the linux container when the files written by the build containers as root
+// cannot be removed by the user running werf
+func removeInstanceDir(ctx context.Context, dir string) error {
+	err := os.RemoveAll(dir)
+	if err == nil || runtime.GOOS == "windows" {
+		return err
+	}
+
+	if err := util.RemoveHostDirsWithLinuxContainer(ctx, GetCacheMountsDir(), []string{dir}); err != nil {
+		return fmt.Errorf("unable to remove %s with linux container: %s", dir, err)
+	}
+
+	return nil
+}
+
+// getCacheMountsInstances lists instances by the path CACHE_MOUNTS_DIR/PROJECT/SCOPE/ID/INSTANCE
+func getCacheMountsInstances() ([]*cacheMountInstance, error) {
+	dirs, err := filepath.Glob(filepath.Join(GetCacheMountsDir(), "*", "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list cache mounts in %s: %s", GetCacheMountsDir(), err)
+	}
+
+	var res []*cacheMountInstance
+	for _, dir := range dirs {
+		instance := &cacheMountInstance{Dir: dir}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("error accessing %s: %s", dir, err)
+		}
+		instance.LastUsedAt = info.ModTime()
+
+		if data, err := ioutil.ReadFile(filepath.Join(dir, instanceLastUsedAt)); err == nil {
+			if timestamp, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+				instance.LastUsedAt = time.Unix(timestamp, 0)
+			}
+		}
+
+		if err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if info.Mode().IsRegular() {
+				instance.Size += uint64(info.Size())
+			}
+
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("unable to calculate size of %s: %s", dir, err)
+		}
+
+		res = append(res, instance)
+	}
+
+	return res, nil
+}
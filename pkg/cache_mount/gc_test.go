@@ -0,0 +1,135 @@
+package cache_mount
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func createTestInstance(t *testing.T, id string, lastUsedAt time.Time, size int) string {
+	dir := filepath.Join(GetCacheMountsDir(), "project", ScopeProject, id, sharedInstanceName)
+	if err := os.MkdirAll(filepath.Join(dir, instanceDataDir), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, instanceDataDir, "file"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, instanceLastUsedAt), []byte(strconv.FormatInt(lastUsedAt.Unix(), 10)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestRemoveLeastRecentlyUsedInstances(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		bytesToFree     uint64
+		busy            []string
+		expectedRemoved []string
+	}{
+		{
+			name:            "least recently used instance first",
+			bytesToFree:     100,
+			expectedRemoved: []string{"oldest"},
+		},
+		{
+			name:            "until enough bytes freed",
+			bytesToFree:     150,
+			expectedRemoved: []string{"oldest", "old"},
+		},
+		{
+			name:            "busy instance skipped",
+			bytesToFree:     100,
+			busy:            []string{"oldest"},
+			expectedRemoved: []string{"old"},
+		},
+		{
+			name:        "nothing to free",
+			bytesToFree: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTestWerf(t)
+
+			// Busy instances are locked before the last usage time is set, because Acquire updates it
+			for _, id := range tt.busy {
+				m := acquireTestCacheMount(t, AcquireOptions{ProjectName: "project", Id: id, Sharing: SharingLocked, Scope: ScopeProject})
+				defer releaseTestCacheMount(t, m)
+			}
+
+			dirs := map[string]string{
+				"recent": createTestInstance(t, "recent", now, 400),
+				"oldest": createTestInstance(t, "oldest", now.Add(-2*time.Hour), 100),
+				"old":    createTestInstance(t, "old", now.Add(-time.Hour), 200),
+			}
+
+			instances, err := getCacheMountsInstances()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sizes := map[string]uint64{}
+			for _, instance := range instances {
+				sizes[instance.Dir] = instance.Size
+			}
+
+			freedBytes, err := removeLeastRecentlyUsedInstances(context.Background(), instances, tt.bytesToFree, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedRemoved := map[string]bool{}
+			var expectedFreedBytes uint64
+			for _, id := range tt.expectedRemoved {
+				expectedRemoved[id] = true
+				expectedFreedBytes += sizes[dirs[id]]
+			}
+
+			if freedBytes != expectedFreedBytes {
+				t.Errorf("expected %d freed bytes, got %d", expectedFreedBytes, freedBytes)
+			}
+
+			for id, dir := range dirs {
+				_, err := os.Stat(dir)
+				if removed := os.IsNotExist(err); removed != expectedRemoved[id] {
+					t.Errorf("expected instance %q removed=%v, got removed=%v", id, expectedRemoved[id], removed)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveLeastRecentlyUsedInstances_DryRun(t *testing.T) {
+	initTestWerf(t)
+
+	dir := createTestInstance(t, "oldest", time.Now().Add(-time.Hour), 100)
+
+	instances, err := getCacheMountsInstances()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	freedBytes, err := removeLeastRecentlyUsedInstances(context.Background(), instances, 100, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 1 || freedBytes != instances[0].Size {
+		t.Errorf("expected size of the only instance to be freed, got %d", freedBytes)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("expected instance %s to be kept in dry run: %s", dir, err)
+	}
+}
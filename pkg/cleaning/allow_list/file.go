@@ -0,0 +1,120 @@
+package allow_list
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	FileFormatText = "text"
+	FileFormatJson = "json"
+)
+
+// File is the JSON allow-list file, which contains the images used in Kubernetes clusters unreachable by cleanup
+type File struct {
+	Images []string `json:"images"`
+}
+
+// ReadFile reads the images from the allow-list file in any supported format:
+// the JSON object with the images field, the JSON array or one image per line (empty lines and lines starting with # are ignored)
+func ReadFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read allow-list file %s: %s", path, err)
+	}
+
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte("{")):
+		file := &File{}
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal allow-list file %s: %s", path, err)
+		}
+
+		return file.Images, nil
+	case bytes.HasPrefix(data, []byte("[")):
+		var images []string
+		if err := json.Unmarshal(data, &images); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal allow-list file %s: %s", path, err)
+		}
+
+		return images, nil
+	default:
+		var images []string
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			images = append(images, line)
+		}
+
+		return images, nil
+	}
+}
+
+// RenderFile returns the sorted unique images in the allow-list file format
+func RenderFile(images []string, format string) ([]byte, error) {
+	uniqImages := map[string]bool{}
+	for _, image := range images {
+		uniqImages[image] = true
+	}
+
+	result := []string{}
+	for image := range uniqImages {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+
+	switch format {
+	case FileFormatJson:
+		data, err := json.MarshalIndent(&File{Images: result}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal allow-list file: %s", err)
+		}
+
+		return append(data, '\n'), nil
+	case FileFormatText:
+		var b bytes.Buffer
+		for _, image := range result {
+			b.WriteString(image + "\n")
+		}
+
+		return b.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown allow-list file format %q: expected %s or %s", format, FileFormatText, FileFormatJson)
+	}
+}
+
+// ParseKubernetesResource parses the resource in the form RESOURCE.VERSION[.GROUP]=JSONPATH,
+// e.g. rollouts.v1alpha1.argoproj.io={.spec.template.spec.containers[*].image}
+func ParseKubernetesResource(value string) (*KubernetesResource, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid kubernetes resource %q: RESOURCE.VERSION[.GROUP]=JSONPATH expected", value)
+	}
+
+	gvrParts := strings.SplitN(parts[0], ".", 3)
+	if len(gvrParts) < 2 || gvrParts[0] == "" || gvrParts[1] == "" {
+		return nil, fmt.Errorf("invalid kubernetes resource %q: RESOURCE.VERSION[.GROUP]=JSONPATH expected", value)
+	}
+
+	gvr := schema.GroupVersionResource{Resource: gvrParts[0], Version: gvrParts[1]}
+	if len(gvrParts) == 3 {
+		gvr.Group = gvrParts[2]
+	}
+
+	if err := jsonpath.New(parts[1]).Parse(parts[1]); err != nil {
+		return nil, fmt.Errorf("invalid kubernetes resource %q: invalid JSONPATH: %s", value, err)
+	}
+
+	return &KubernetesResource{GroupVersionResource: gvr, ImagePaths: []string{parts[1]}}, nil
+}
@@ -0,0 +1,225 @@
+package allow_list
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		expectedImages []string
+		expectedErr    string
+	}{
+		{
+			name:           "object",
+			content:        `{"images": ["registry.example.com/app:1", "registry.example.com/app:2"]}`,
+			expectedImages: []string{"registry.example.com/app:1", "registry.example.com/app:2"},
+		},
+		{
+			name:           "array",
+			content:        "\n[\"registry.example.com/app:1\", \"registry.example.com/app:2\"]\n",
+			expectedImages: []string{"registry.example.com/app:1", "registry.example.com/app:2"},
+		},
+		{
+			name:           "text",
+			content:        "registry.example.com/app:1\n  registry.example.com/app:2  \n",
+			expectedImages: []string{"registry.example.com/app:1", "registry.example.com/app:2"},
+		},
+		{
+			name:           "text with comments and empty lines",
+			content:        "# production cluster\nregistry.example.com/app:1\n\n  # staging cluster\nregistry.example.com/app:2\n",
+			expectedImages: []string{"registry.example.com/app:1", "registry.example.com/app:2"},
+		},
+		{
+			name:    "empty",
+			content: "\n\n",
+		},
+		{
+			name:        "invalid object",
+			content:     `{"images": "registry.example.com/app:1"}`,
+			expectedErr: "unable to unmarshal allow-list file",
+		},
+		{
+			name:        "invalid array",
+			content:     `["registry.example.com/app:1",]`,
+			expectedErr: "unable to unmarshal allow-list file",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "werf-allow-list-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for ind, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strconv.Itoa(ind))
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			images, err := ReadFile(path)
+			if tt.expectedErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil", tt.expectedErr)
+				} else if !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %q", tt.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(images, tt.expectedImages) {
+				t.Fatalf("expected images %v, got %v", tt.expectedImages, images)
+			}
+		})
+	}
+
+	t.Run("nonexistent file", func(t *testing.T) {
+		if _, err := ReadFile(filepath.Join(dir, "nonexistent")); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+}
+
+func TestRenderFile(t *testing.T) {
+	images := []string{"registry.example.com/app:2", "registry.example.com/app:1", "registry.example.com/app:2"}
+
+	tests := []struct {
+		format       string
+		expectedData string
+		expectedErr  string
+	}{
+		{
+			format:       FileFormatText,
+			expectedData: "registry.example.com/app:1\nregistry.example.com/app:2\n",
+		},
+		{
+			format:       FileFormatJson,
+			expectedData: "{\n  \"images\": [\n    \"registry.example.com/app:1\",\n    \"registry.example.com/app:2\"\n  ]\n}\n",
+		},
+		{
+			format:      "yaml",
+			expectedErr: `unknown allow-list file format "yaml"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := RenderFile(images, tt.format)
+			if tt.expectedErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil", tt.expectedErr)
+				} else if !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %q", tt.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if string(data) != tt.expectedData {
+				t.Fatalf("expected data %q, got %q", tt.expectedData, string(data))
+			}
+		})
+	}
+
+	t.Run("rendered file is read back", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "werf-allow-list-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		for _, format := range []string{FileFormatText, FileFormatJson} {
+			data, err := RenderFile(images, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(dir, format)
+			if err := ioutil.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			readImages, err := ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := []string{"registry.example.com/app:1", "registry.example.com/app:2"}; !reflect.DeepEqual(readImages, expected) {
+				t.Fatalf("%s: expected images %v, got %v", format, expected, readImages)
+			}
+		}
+	})
+}
+
+func TestParseKubernetesResource(t *testing.T) {
+	tests := []struct {
+		value            string
+		expectedResource *KubernetesResource
+		expectedErr      bool
+	}{
+		{
+			value: "rollouts.v1alpha1.argoproj.io={.spec.template.spec.containers[*].image}",
+			expectedResource: &KubernetesResource{
+				GroupVersionResource: schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"},
+				ImagePaths:           []string{"{.spec.template.spec.containers[*].image}"},
+			},
+		},
+		{
+			value: "services.v1.serving.knative.dev={.spec.template.spec.containers[*].image}",
+			expectedResource: &KubernetesResource{
+				GroupVersionResource: schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"},
+				ImagePaths:           []string{"{.spec.template.spec.containers[*].image}"},
+			},
+		},
+		{
+			value: "pods.v1={.spec.containers[?(@.name==\"app\")].image}",
+			expectedResource: &KubernetesResource{
+				GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+				ImagePaths:           []string{"{.spec.containers[?(@.name==\"app\")].image}"},
+			},
+		},
+		{value: "rollouts.v1alpha1.argoproj.io", expectedErr: true},
+		{value: "rollouts.v1alpha1.argoproj.io=", expectedErr: true},
+		{value: "rollouts={.spec.image}", expectedErr: true},
+		{value: ".v1={.spec.image}", expectedErr: true},
+		{value: "rollouts.v1alpha1.argoproj.io={.spec.containers[*.image}", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			resource, err := ParseKubernetesResource(tt.value)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("expected error, got resource %v", resource)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(resource, tt.expectedResource) {
+				t.Fatalf("expected resource %+v, got %+v", tt.expectedResource, resource)
+			}
+		})
+	}
+}
@@ -5,10 +5,11 @@ import (
 	"fmt"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
-func DeployedDockerImages(kubernetesClient kubernetes.Interface, kubernetesNamespace string) ([]string, error) {
+func DeployedDockerImages(kubernetesClient kubernetes.Interface, kubernetesDynamicClient dynamic.Interface, kubernetesNamespace string, extraResources []*KubernetesResource) ([]string, error) {
 	var deployedDockerImages []string
 
 	images, err := getPodsImages(kubernetesClient, kubernetesNamespace)
@@ -67,6 +68,15 @@ func DeployedDockerImages(kubernetesClient kubernetes.Interface, kubernetesNames
 
 	deployedDockerImages = append(deployedDockerImages, images...)
 
+	for _, resource := range extraResources {
+		images, err = getKubernetesResourceImages(kubernetesClient, kubernetesDynamicClient, kubernetesNamespace, resource)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get %s images: %s", resource, err)
+		}
+
+		deployedDockerImages = append(deployedDockerImages, images...)
+	}
+
 	return deployedDockerImages, nil
 }
 
@@ -0,0 +1,105 @@
+package allow_list
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/werf/logboek"
+)
+
+// KubernetesResource is a resource of any kind (e.g. custom resource), which references images by the fields selected with JSONPath templates
+type KubernetesResource struct {
+	GroupVersionResource schema.GroupVersionResource
+	ImagePaths           []string
+}
+
+func (r *KubernetesResource) String() string {
+	return r.GroupVersionResource.String()
+}
+
+func getKubernetesResourceImages(kubernetesClient kubernetes.Interface, kubernetesDynamicClient dynamic.Interface, kubernetesNamespace string, resource *KubernetesResource) ([]string, error) {
+	var imagePaths []*jsonpath.JSONPath
+	for _, imagePath := range resource.ImagePaths {
+		j := jsonpath.New(imagePath).AllowMissingKeys(true)
+		if err := j.Parse(imagePath); err != nil {
+			return nil, fmt.Errorf("invalid image path %q: %s", imagePath, err)
+		}
+
+		imagePaths = append(imagePaths, j)
+	}
+
+	apiResource, err := getKubernetesAPIResource(kubernetesClient, resource.GroupVersionResource)
+	if err != nil {
+		return nil, err
+	}
+
+	// the resource is not served by the cluster (e.g. the CRD is not installed), a typo in the resource should not go unnoticed though
+	if apiResource == nil {
+		logboek.Warn().LogF("WARNING: resource %s is not served by the cluster, skipping\n", resource)
+		return nil, nil
+	}
+
+	var resourceClient dynamic.ResourceInterface
+	if apiResource.Namespaced {
+		resourceClient = kubernetesDynamicClient.Resource(resource.GroupVersionResource).Namespace(kubernetesNamespace)
+	} else {
+		resourceClient = kubernetesDynamicClient.Resource(resource.GroupVersionResource)
+	}
+
+	list, err := resourceClient.List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	for _, item := range list.Items {
+		for ind, imagePath := range imagePaths {
+			results, err := imagePath.FindResults(item.Object)
+			if err != nil {
+				return nil, fmt.Errorf("unable to find results by image path %q: %s", resource.ImagePaths[ind], err)
+			}
+
+			for _, values := range results {
+				for _, value := range values {
+					if value.Kind() == reflect.Interface {
+						value = value.Elem()
+					}
+
+					if value.Kind() == reflect.String && value.String() != "" {
+						images = append(images, value.String())
+					}
+				}
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// getKubernetesAPIResource returns nil if the resource is confirmed absent through the discovery
+func getKubernetesAPIResource(kubernetesClient kubernetes.Interface, gvr schema.GroupVersionResource) (*metav1.APIResource, error) {
+	list, err := kubernetesClient.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("unable to discover resources for %s: %s", gvr.GroupVersion(), err)
+	}
+
+	for _, apiResource := range list.APIResources {
+		if apiResource.Name == gvr.Resource {
+			return &apiResource, nil
+		}
+	}
+
+	return nil, nil
+}
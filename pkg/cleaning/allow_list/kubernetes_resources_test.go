@@ -0,0 +1,170 @@
+package allow_list
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	rolloutsGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	clusterGVR  = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "clusterapps"}
+	unservedGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rolouts"}
+)
+
+type fakeDiscoveryErrClientset struct {
+	*fake.Clientset
+
+	err error
+}
+
+func (c fakeDiscoveryErrClientset) Discovery() discovery.DiscoveryInterface {
+	return fakeDiscoveryErr{FakeDiscovery: c.Clientset.Discovery().(*fakediscovery.FakeDiscovery), err: c.err}
+}
+
+type fakeDiscoveryErr struct {
+	*fakediscovery.FakeDiscovery
+
+	err error
+}
+
+func (d fakeDiscoveryErr) ServerResourcesForGroupVersion(string) (*metav1.APIResourceList, error) {
+	return nil, d.err
+}
+
+func newTestObject(gvr schema.GroupVersionResource, kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion(gvr.GroupVersion().String())
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+
+	return obj
+}
+
+func newTestClients(objects ...runtime.Object) (*fake.Clientset, *fakedynamic.FakeDynamicClient) {
+	kubernetesClient := fake.NewSimpleClientset()
+	kubernetesClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: rolloutsGVR.GroupVersion().String(),
+			APIResources: []metav1.APIResource{{Name: rolloutsGVR.Resource, Kind: "Rollout", Namespaced: true}},
+		},
+		{
+			GroupVersion: clusterGVR.GroupVersion().String(),
+			APIResources: []metav1.APIResource{{Name: clusterGVR.Resource, Kind: "ClusterApp", Namespaced: false}},
+		},
+	}
+
+	kubernetesDynamicClient := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		rolloutsGVR: "RolloutList",
+		clusterGVR:  "ClusterAppList",
+	}, objects...)
+
+	return kubernetesClient, kubernetesDynamicClient
+}
+
+func TestGetKubernetesResourceImages(t *testing.T) {
+	containersSpec := func(images ...string) map[string]interface{} {
+		var containers []interface{}
+		for _, image := range images {
+			containers = append(containers, map[string]interface{}{"name": image, "image": image})
+		}
+
+		return map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{"containers": containers}}}
+	}
+
+	kubernetesClient, kubernetesDynamicClient := newTestClients(
+		newTestObject(rolloutsGVR, "Rollout", "default", "app", containersSpec("app:1", "sidecar:1")),
+		newTestObject(rolloutsGVR, "Rollout", "other", "other", containersSpec("other:1")),
+		newTestObject(rolloutsGVR, "Rollout", "default", "empty", map[string]interface{}{}),
+		newTestObject(clusterGVR, "ClusterApp", "", "cluster", map[string]interface{}{"image": "cluster:1", "initImage": "init:1"}),
+	)
+
+	tests := []struct {
+		name           string
+		namespace      string
+		discoveryErr   error
+		resource       *KubernetesResource
+		expectedImages []string
+		expectedErr    string
+	}{
+		{
+			name:           "all namespaces",
+			resource:       &KubernetesResource{GroupVersionResource: rolloutsGVR, ImagePaths: []string{"{.spec.template.spec.containers[*].image}"}},
+			expectedImages: []string{"app:1", "other:1", "sidecar:1"},
+		},
+		{
+			name:           "namespace restriction",
+			namespace:      "default",
+			resource:       &KubernetesResource{GroupVersionResource: rolloutsGVR, ImagePaths: []string{"{.spec.template.spec.containers[*].image}"}},
+			expectedImages: []string{"app:1", "sidecar:1"},
+		},
+		{
+			name:           "cluster-scoped resource ignores namespace restriction",
+			namespace:      "default",
+			resource:       &KubernetesResource{GroupVersionResource: clusterGVR, ImagePaths: []string{"{.spec.image}", "{.spec.initImage}", "{.spec.missing}"}},
+			expectedImages: []string{"cluster:1", "init:1"},
+		},
+		{
+			name:     "resource not served by the cluster",
+			resource: &KubernetesResource{GroupVersionResource: unservedGVR, ImagePaths: []string{"{.spec.image}"}},
+		},
+		{
+			name:         "group version not served by the cluster",
+			discoveryErr: apierrors.NewNotFound(schema.GroupResource{Group: rolloutsGVR.Group}, rolloutsGVR.Version),
+			resource:     &KubernetesResource{GroupVersionResource: rolloutsGVR, ImagePaths: []string{"{.spec.template.spec.containers[*].image}"}},
+		},
+		{
+			name:         "discovery failure",
+			discoveryErr: errors.New("connection refused"),
+			resource:     &KubernetesResource{GroupVersionResource: rolloutsGVR, ImagePaths: []string{"{.spec.template.spec.containers[*].image}"}},
+			expectedErr:  "unable to discover resources for argoproj.io/v1alpha1: connection refused",
+		},
+		{
+			name:        "invalid image path",
+			resource:    &KubernetesResource{GroupVersionResource: rolloutsGVR, ImagePaths: []string{"{.spec.image"}},
+			expectedErr: "invalid image path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var client kubernetes.Interface = kubernetesClient
+			if tt.discoveryErr != nil {
+				client = fakeDiscoveryErrClientset{Clientset: kubernetesClient, err: tt.discoveryErr}
+			}
+
+			images, err := getKubernetesResourceImages(client, kubernetesDynamicClient, tt.namespace, tt.resource)
+			if tt.expectedErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil", tt.expectedErr)
+				} else if !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, got %q", tt.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			sort.Strings(images)
+			if !reflect.DeepEqual(images, tt.expectedImages) {
+				t.Fatalf("expected images %v, got %v", tt.expectedImages, images)
+			}
+		})
+	}
+}
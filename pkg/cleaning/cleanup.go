@@ -3,15 +3,20 @@ package cleaning
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/go-git/go-git/v5"
 	"github.com/gookit/color"
 	"github.com/rodaine/table"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 
 	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/kubedog/pkg/utils"
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/cleaning/allow_list"
@@ -30,10 +35,14 @@ type CleanupOptions struct {
 	LocalGit                                GitRepo
 	KubernetesContextClients                []*kube.ContextClient
 	KubernetesNamespaceRestrictionByContext map[string]string
+	KubernetesDynamicClientByContext        map[string]dynamic.Interface
 	WithoutKube                             bool
+	AllowListFiles                          []string
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
 	DryRun                                  bool
+	PlanFile                                string
+	ApplyPlanFile                           string
 }
 
 func Cleanup(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options CleanupOptions) error {
@@ -41,18 +50,46 @@ func Cleanup(ctx context.Context, projectName string, storageManager *manager.St
 }
 
 func newCleanupManager(projectName string, storageManager *manager.StorageManager, options CleanupOptions) *cleanupManager {
-	return &cleanupManager{
+	m := &cleanupManager{
 		ProjectName:                             projectName,
 		StorageManager:                          storageManager,
 		ImageNameList:                           options.ImageNameList,
-		DryRun:                                  options.DryRun,
+		DryRun:                                  options.DryRun || options.PlanFile != "",
 		LocalGit:                                options.LocalGit,
 		KubernetesContextClients:                options.KubernetesContextClients,
 		KubernetesNamespaceRestrictionByContext: options.KubernetesNamespaceRestrictionByContext,
+		KubernetesDynamicClientByContext:        options.KubernetesDynamicClientByContext,
 		WithoutKube:                             options.WithoutKube,
+		AllowListFiles:                          options.AllowListFiles,
 		GitHistoryBasedCleanupOptions:           options.GitHistoryBasedCleanupOptions,
 		KeepStagesBuiltWithinLastNHours:         options.KeepStagesBuiltWithinLastNHours,
+		PlanFile:                                options.PlanFile,
+		ApplyPlanFile:                           options.ApplyPlanFile,
 	}
+
+	// nothing is deleted when the plan is created, the deletions are recorded into the plan instead
+	if options.PlanFile != "" {
+		m.plan = &CleanupPlan{
+			ProjectName: projectName,
+			Repo:        storageManager.StagesStorage.String(),
+			CreatedAt:   time.Now(),
+			Strategy:    options.GitHistoryBasedCleanupOptions.GetStrategy(),
+		}
+
+		if m.plan.Strategy == config.MetaCleanupStrategyAge {
+			m.plan.Policies = append(m.plan.Policies, fmt.Sprintf("stagesPerImage={%s}", options.GitHistoryBasedCleanupOptions.StagesPerImage.String()))
+		} else {
+			for _, policy := range options.GitHistoryBasedCleanupOptions.KeepPolicies {
+				m.plan.Policies = append(m.plan.Policies, policy.String())
+			}
+		}
+
+		if options.GitHistoryBasedCleanupOptions.SizeQuota != nil {
+			m.plan.Policies = append(m.plan.Policies, fmt.Sprintf("sizeQuota=%s", options.GitHistoryBasedCleanupOptions.SizeQuotaString()))
+		}
+	}
+
+	return m
 }
 
 type cleanupManager struct {
@@ -66,6 +103,13 @@ type cleanupManager struct {
 
 	checksumSourceImageIDs       map[string][]string
 	nonexistentImportMetadataIDs []string
+	invalidImportMetadataIDs     []string
+	importSourceIDSourceImageID  map[string]string
+
+	fetchedDeployedDockerImagesNames []string
+	keptByPoliciesStageIDs           map[string]bool
+
+	plan *CleanupPlan
 
 	ProjectName                             string
 	StorageManager                          *manager.StorageManager
@@ -73,10 +117,14 @@ type cleanupManager struct {
 	LocalGit                                GitRepo
 	KubernetesContextClients                []*kube.ContextClient
 	KubernetesNamespaceRestrictionByContext map[string]string
+	KubernetesDynamicClientByContext        map[string]dynamic.Interface
 	WithoutKube                             bool
+	AllowListFiles                          []string
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
 	DryRun                                  bool
+	PlanFile                                string
+	ApplyPlanFile                           string
 }
 
 type GitRepo interface {
@@ -161,6 +209,13 @@ func (m *cleanupManager) initImagesMetadata(ctx context.Context) error {
 				continue
 			}
 
+			// the age-based cleanup does not use the git history, so commits are not checked
+			if m.GitHistoryBasedCleanupOptions.GetStrategy() == config.MetaCleanupStrategyAge {
+				m.imageNameStageIDCommitList[imageName][stageID] = stageIDCommitList
+				m.imageNameStageIDCommitListToCleanup[imageName][stageID] = stageIDCommitList
+				continue
+			}
+
 			var commitList, nonexistentCommitList []string
 			for _, commit := range stageIDCommitList {
 				exist, err := m.LocalGit.IsCommitExists(ctx, commit)
@@ -219,14 +274,32 @@ func (m *cleanupManager) deleteImageMetadataFromCache(imageName string, stageIDC
 }
 
 func (m *cleanupManager) run(ctx context.Context) error {
+	if m.ApplyPlanFile != "" {
+		return m.applyPlan(ctx)
+	}
+
 	if err := logboek.Context(ctx).LogProcess("Fetching manifests and metadata").DoError(func() error {
 		return m.init(ctx)
 	}); err != nil {
 		return err
 	}
 
-	if m.LocalGit != nil {
-		if !m.WithoutKube {
+	if m.GitHistoryBasedCleanupOptions.GetStrategy() == config.MetaCleanupStrategyAge {
+		if m.isUsedImagesScanEnabled() {
+			if err := logboek.Context(ctx).LogProcess("Skipping tags that are being used in Kubernetes").DoError(func() error {
+				return m.skipStageIDsThatAreUsedInKubernetes(ctx)
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := logboek.Context(ctx).LogProcess("Age-based cleanup").DoError(func() error {
+			return m.ageBasedCleanup(ctx)
+		}); err != nil {
+			return err
+		}
+	} else if m.LocalGit != nil {
+		if m.isUsedImagesScanEnabled() {
 			if err := logboek.Context(ctx).LogProcess("Skipping tags that are being used in Kubernetes").DoError(func() error {
 				return m.skipStageIDsThatAreUsedInKubernetes(ctx)
 			}); err != nil {
@@ -250,6 +323,22 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		return err
 	}
 
+	if m.GitHistoryBasedCleanupOptions.SizeQuota != nil {
+		if err := logboek.Context(ctx).LogProcess("Size quota-based cleanup").DoError(func() error {
+			return m.sizeQuotaBasedCleanup(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.plan != nil {
+		if err := m.plan.write(m.PlanFile); err != nil {
+			return err
+		}
+
+		logboek.Context(ctx).Default().LogFHighlight("Cleanup plan saved into %s\n", m.PlanFile)
+	}
+
 	return nil
 }
 
@@ -283,12 +372,51 @@ func (m *cleanupManager) skipStageIDsThatAreUsedInKubernetes(ctx context.Context
 	return nil
 }
 
+// isUsedImagesScanEnabled returns true if the images used in Kubernetes are got from the reachable clusters or allow-list files
+func (m *cleanupManager) isUsedImagesScanEnabled() bool {
+	return !m.WithoutKube || len(m.AllowListFiles) != 0
+}
+
 func (m *cleanupManager) deployedDockerImagesNames(ctx context.Context) ([]string, error) {
-	var deployedDockerImagesNames []string
+	// the images are fetched once and reused by the size quota-based cleanup
+	if m.fetchedDeployedDockerImagesNames != nil {
+		return m.fetchedDeployedDockerImagesNames, nil
+	}
+
+	deployedDockerImagesNames := []string{}
+	for _, allowListFile := range m.AllowListFiles {
+		if err := logboek.Context(ctx).LogProcessInline("Reading used docker images (allow-list file %s)", allowListFile).
+			DoError(func() error {
+				allowListDockerImagesNames, err := allow_list.ReadFile(allowListFile)
+				if err != nil {
+					return err
+				}
+
+				deployedDockerImagesNames = append(deployedDockerImagesNames, allowListDockerImagesNames...)
+
+				return nil
+			}); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.WithoutKube {
+		m.fetchedDeployedDockerImagesNames = deployedDockerImagesNames
+		return deployedDockerImagesNames, nil
+	}
+
+	var extraResources []*allow_list.KubernetesResource
+	for _, resource := range m.GitHistoryBasedCleanupOptions.KubernetesResources {
+		extraResources = append(extraResources, &allow_list.KubernetesResource{
+			GroupVersionResource: schema.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource},
+			ImagePaths:           resource.ImagePaths,
+		})
+	}
+
 	for _, contextClient := range m.KubernetesContextClients {
 		if err := logboek.Context(ctx).LogProcessInline("Getting deployed docker images (context %s)", contextClient.ContextName).
 			DoError(func() error {
-				kubernetesClientDeployedDockerImagesNames, err := allow_list.DeployedDockerImages(contextClient.Client, m.KubernetesNamespaceRestrictionByContext[contextClient.ContextName])
+				kubernetesClientDeployedDockerImagesNames, err := allow_list.DeployedDockerImages(contextClient.Client, m.KubernetesDynamicClientByContext[contextClient.ContextName], m.KubernetesNamespaceRestrictionByContext[contextClient.ContextName], extraResources)
 				if err != nil {
 					return fmt.Errorf("cannot get deployed imagesStageList: %s", err)
 				}
@@ -301,6 +429,8 @@ func (m *cleanupManager) deployedDockerImagesNames(ctx context.Context) ([]strin
 		}
 	}
 
+	m.fetchedDeployedDockerImagesNames = deployedDockerImagesNames
+
 	return deployedDockerImagesNames, nil
 }
 
@@ -355,7 +485,70 @@ func (m *cleanupManager) gitHistoryBasedCleanup(ctx context.Context) error {
 			}
 
 			if err := logboek.Context(ctx).LogProcess("Cleaning image metadata").DoError(func() error {
-				return m.cleanupImageMetadata(ctx, imageName, hitStageIDCommitList, stageIDToUnlink)
+				return m.cleanupImageMetadata(ctx, imageName, hitStageIDCommitList, stageIDToUnlink, m.notReachedByKeepPoliciesReason())
+			}); err != nil {
+				return err
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := m.cleanupNonexistentImageMetadata(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *cleanupManager) notReachedByKeepPoliciesReason() string {
+	var policies []string
+	for _, policy := range m.GitHistoryBasedCleanupOptions.KeepPolicies {
+		policies = append(policies, fmt.Sprintf("{%s}", policy.String()))
+	}
+
+	return policiesReason(CleanupPlanReasonNotReachedByKeepPolicies, policies)
+}
+
+func (m *cleanupManager) ageBasedCleanup(ctx context.Context) error {
+	stagesPerImage := m.GitHistoryBasedCleanupOptions.StagesPerImage
+	logboek.Context(ctx).Default().LogFDetails("Keeping stages per image: %s\n", stagesPerImage.String())
+	logboek.Context(ctx).LogOptionalLn()
+
+	for imageName, stageIDCommitList := range m.imageNameStageIDCommitListToCleanup {
+		if err := logboek.Context(ctx).LogProcess(logging.ImageLogProcessName(imageName, false)).DoError(func() error {
+			if logboek.Context(ctx).Streams().Width() > 90 {
+				m.printStageIDCommitListTable(ctx, imageName)
+			}
+
+			var stageIDs []string
+			for stageID := range stageIDCommitList {
+				stageIDs = append(stageIDs, stageID)
+			}
+
+			sort.SliceStable(stageIDs, func(i, j int) bool {
+				return m.mustGetStage(stageIDs[i]).Info.GetCreatedAt().After(m.mustGetStage(stageIDs[j]).Info.GetCreatedAt())
+			})
+
+			var keptStageIDs, stageIDsToUnlink []string
+			keptStageIDCommitList := map[string][]string{}
+			for ind, stageID := range stageIDs {
+				if stagesPerImage.IsStageKept(ind, m.mustGetStage(stageID).Info.GetCreatedAt()) {
+					keptStageIDs = append(keptStageIDs, stageID)
+					keptStageIDCommitList[stageID] = stageIDCommitList[stageID]
+				} else {
+					stageIDsToUnlink = append(stageIDsToUnlink, stageID)
+				}
+			}
+
+			if len(keptStageIDs) != 0 {
+				m.handleSavedStageIDs(ctx, keptStageIDs)
+			}
+
+			if err := logboek.Context(ctx).LogProcess("Cleaning image metadata").DoError(func() error {
+				return m.cleanupImageMetadata(ctx, imageName, keptStageIDCommitList, stageIDsToUnlink, policiesReason(CleanupPlanReasonNotKeptByAge, []string{fmt.Sprintf("stagesPerImage={%s}", stagesPerImage.String())}))
 			}); err != nil {
 				return err
 			}
@@ -418,6 +611,14 @@ func (m *cleanupManager) printStageIDCommitListTable(ctx context.Context, imageN
 }
 
 func (m *cleanupManager) handleSavedStageIDs(ctx context.Context, savedStageIDs []string) {
+	if m.keptByPoliciesStageIDs == nil {
+		m.keptByPoliciesStageIDs = map[string]bool{}
+	}
+
+	for _, stageID := range savedStageIDs {
+		m.keptByPoliciesStageIDs[stageID] = true
+	}
+
 	logboek.Context(ctx).Default().LogBlock("Saved tags").Do(func() {
 		for _, stageID := range savedStageIDs {
 			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageID)
@@ -426,7 +627,11 @@ func (m *cleanupManager) handleSavedStageIDs(ctx context.Context, savedStageIDs
 	})
 }
 
-func (m *cleanupManager) deleteStages(ctx context.Context, stages []*image.StageDescription) error {
+func (m *cleanupManager) deleteStages(ctx context.Context, stages []*image.StageDescription, reason string) error {
+	if m.plan != nil {
+		m.plan.addStages(stages, reason)
+	}
+
 	deleteStageOptions := manager.ForEachDeleteStageOptions{
 		DeleteImageOptions: storage.DeleteImageOptions{
 			RmiForce: false,
@@ -467,7 +672,7 @@ func deleteStages(ctx context.Context, storageManager *manager.StorageManager, d
 	})
 }
 
-func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName string, hitStageIDCommitList map[string][]string, stageIDsToUnlink []string) error {
+func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName string, hitStageIDCommitList map[string][]string, stageIDsToUnlink []string, reason string) error {
 	stageIDCommitList := m.imageNameStageIDCommitListToCleanup[imageName]
 	nonexistentStageIDCommitList := m.imageNameNonexistentStageIDCommitList[imageName]
 	stageIDNonexistentCommitList := m.imageNameStageIDNonexistentCommitList[imageName]
@@ -497,7 +702,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 		if len(stageIDCommitListToDelete) != 0 {
 			if err := logboek.Context(ctx).Info().LogProcess("Cleaning up metadata").DoError(func() error {
-				return m.deleteImageMetadata(ctx, imageName, stageIDCommitListToDelete, true)
+				return m.deleteImageMetadata(ctx, imageName, stageIDCommitListToDelete, true, reason)
 			}); err != nil {
 				return err
 			}
@@ -506,7 +711,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 	if len(nonexistentStageIDCommitList) != 0 {
 		if err := logboek.Context(ctx).Info().LogProcess("Deleting metadata for nonexistent stageIDs").DoError(func() error {
-			return m.deleteImageMetadata(ctx, imageName, nonexistentStageIDCommitList, false)
+			return m.deleteImageMetadata(ctx, imageName, nonexistentStageIDCommitList, false, CleanupPlanReasonNonexistentStage)
 		}); err != nil {
 			return err
 		}
@@ -514,7 +719,7 @@ func (m *cleanupManager) cleanupImageMetadata(ctx context.Context, imageName str
 
 	if len(stageIDNonexistentCommitList) != 0 {
 		if err := logboek.Context(ctx).Info().LogProcess("Deleting metadata for nonexistent commits").DoError(func() error {
-			return m.deleteImageMetadata(ctx, imageName, stageIDNonexistentCommitList, false)
+			return m.deleteImageMetadata(ctx, imageName, stageIDNonexistentCommitList, false, CleanupPlanReasonNonexistentCommit)
 		}); err != nil {
 			return err
 		}
@@ -530,7 +735,7 @@ func (m *cleanupManager) cleanupNonexistentImageMetadata(ctx context.Context) er
 
 	return logboek.Context(ctx).Default().LogProcess("Deleting metadata for nonexistent images").DoError(func() error {
 		for imageName, stageIDCommitList := range m.nonexistentImageNameStageIDCommitList {
-			if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, false); err != nil {
+			if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, false, CleanupPlanReasonNotManagedImage); err != nil {
 				return err
 			}
 		}
@@ -539,7 +744,11 @@ func (m *cleanupManager) cleanupNonexistentImageMetadata(ctx context.Context) er
 	})
 }
 
-func (m *cleanupManager) deleteImageMetadata(ctx context.Context, imageName string, stageIDCommitList map[string][]string, updateCache bool) error {
+func (m *cleanupManager) deleteImageMetadata(ctx context.Context, imageName string, stageIDCommitList map[string][]string, updateCache bool, reason string) error {
+	if m.plan != nil {
+		m.plan.addImageMetadata(imageName, stageIDCommitList, reason)
+	}
+
 	if err := deleteImageMetadata(ctx, m.ProjectName, m.StorageManager, imageName, stageIDCommitList, m.DryRun); err != nil {
 		return err
 	}
@@ -626,15 +835,17 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 
 	if len(stagesToDelete) != 0 {
 		if err := logboek.Context(ctx).Default().LogProcess("Deleting stages tags").DoError(func() error {
-			return m.deleteStages(ctx, stagesToDelete)
+			return m.deleteStages(ctx, stagesToDelete, m.unusedStageReason())
 		}); err != nil {
 			return err
 		}
+
+		m.stages = excludeStages(m.stages, stagesToDelete...)
 	}
 
 	if len(m.nonexistentImportMetadataIDs) != 0 {
 		if err := logboek.Context(ctx).Default().LogProcess("Cleaning imports metadata").DoError(func() error {
-			return m.deleteImportsMetadata(ctx, m.nonexistentImportMetadataIDs)
+			return m.deleteImportsMetadata(ctx, m.nonexistentImportMetadataIDs, CleanupPlanReasonNonexistentImportSource)
 		}); err != nil {
 			return err
 		}
@@ -643,8 +854,349 @@ func (m *cleanupManager) cleanupUnusedStages(ctx context.Context) error {
 	return nil
 }
 
+func (m *cleanupManager) unusedStageReason() string {
+	reason := "not related to kept images and images used in Kubernetes"
+	if m.KeepStagesBuiltWithinLastNHours != 0 {
+		reason += fmt.Sprintf(", built more than %d hours ago", m.KeepStagesBuiltWithinLastNHours)
+	}
+
+	return reason
+}
+
+// sizeQuotaBasedCleanup deletes the least recently used stages until the total size of the stages fits the size quota,
+// the stages kept by the cleanup policies, used in Kubernetes or built within the last N hours are never deleted along with their relatives
+func (m *cleanupManager) sizeQuotaBasedCleanup(ctx context.Context) error {
+	sizeQuota := *m.GitHistoryBasedCleanupOptions.SizeQuota
+
+	graph := m.newStagesGraph(m.stages)
+	layers := newStagesLayers(graph, m.stages)
+
+	totalSize := layers.totalSize()
+	if totalSize <= sizeQuota {
+		logboek.Context(ctx).Default().LogF("Stages size: %s <= %s (size quota) — %s\n", humanize.IBytes(totalSize), humanize.IBytes(sizeQuota), utils.GreenF("OK"))
+		return nil
+	}
+
+	bytesToFree := totalSize - sizeQuota
+	logboek.Context(ctx).Default().LogF("Stages size: %s > %s (size quota) — %s\n", utils.RedF("%s", humanize.IBytes(totalSize)), utils.YellowF("%s", humanize.IBytes(sizeQuota)), utils.RedF("QUOTA EXCEEDED"))
+	logboek.Context(ctx).Default().LogF("Needed to free: %s\n", utils.RedF("%s", humanize.IBytes(bytesToFree)))
+	logboek.Context(ctx).LogOptionalLn()
+
+	stages, err := m.stagesNotProtectedFromSizeQuotaBasedCleanup(ctx, graph)
+	if err != nil {
+		return err
+	}
+
+	stageLastUsedAt := graph.stagesLastUsedAt()
+	sort.SliceStable(stages, func(i, j int) bool {
+		iLastUsedAt := stageLastUsedAt[stages[i]]
+		jLastUsedAt := stageLastUsedAt[stages[j]]
+		if iLastUsedAt.Equal(jLastUsedAt) {
+			// the stage is deleted before its ancestors, import sources and platform stages
+			iCreatedAt := stages[i].Info.GetCreatedAt()
+			jCreatedAt := stages[j].Info.GetCreatedAt()
+			if iCreatedAt.Equal(jCreatedAt) {
+				// the manifest list is created at the same time as its latest built platform stage
+				return graph.isDependency(stages[i], stages[j])
+			}
+
+			return iCreatedAt.After(jCreatedAt)
+		}
+
+		return iLastUsedAt.Before(jLastUsedAt)
+	})
+
+	var freedBytes uint64
+	var stagesToDelete []*image.StageDescription
+	for _, stage := range stages {
+		if freedBytes >= bytesToFree {
+			break
+		}
+
+		// the layers shared with the remaining stages are not reclaimed
+		stageFreedBytes := layers.release(stage)
+		freedBytes += stageFreedBytes
+		stagesToDelete = append(stagesToDelete, stage)
+
+		logboek.Context(ctx).Info().LogF("Selected stage %s (%s reclaimed, last used at %s)\n", stage.Info.Tag, humanize.IBytes(stageFreedBytes), stageLastUsedAt[stage].Format(time.RFC3339))
+	}
+
+	if freedBytes < bytesToFree {
+		logboek.Context(ctx).Warn().LogF("WARNING: Size quota cannot be reached: the rest %s are taken by the stages kept by the cleanup policies, used in Kubernetes or built recently and their relatives\n", humanize.IBytes(bytesToFree-freedBytes))
+		logboek.Context(ctx).LogOptionalLn()
+	}
+
+	if len(stagesToDelete) == 0 {
+		return nil
+	}
+
+	reason := policiesReason(CleanupPlanReasonSizeQuotaExceeded, []string{fmt.Sprintf("sizeQuota=%s", m.GitHistoryBasedCleanupOptions.SizeQuotaString())})
+
+	if err := logboek.Context(ctx).Info().LogProcess("Cleaning image metadata").DoError(func() error {
+		for imageName, stageIDCommitList := range m.imageNameStageIDCommitList {
+			stageIDCommitListToDelete := map[string][]string{}
+			for _, stage := range stagesToDelete {
+				if commitList, ok := stageIDCommitList[stage.Info.Tag]; ok {
+					stageIDCommitListToDelete[stage.Info.Tag] = commitList
+				}
+			}
+
+			if len(stageIDCommitListToDelete) != 0 {
+				if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitListToDelete, true, reason); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Deleting stages tags").DoError(func() error {
+		return m.deleteStages(ctx, stagesToDelete, reason)
+	}); err != nil {
+		return err
+	}
+
+	m.stages = excludeStages(m.stages, stagesToDelete...)
+
+	logboek.Context(ctx).Default().LogF("Reclaimed by size quota-based cleanup: %s\n", utils.GreenF("%d (~ %s)", len(stagesToDelete), humanize.IBytes(freedBytes)))
+
+	return nil
+}
+
+func (m *cleanupManager) stagesNotProtectedFromSizeQuotaBasedCleanup(ctx context.Context, graph *stagesGraph) ([]*image.StageDescription, error) {
+	deployedDockerImages := map[string]bool{}
+	if m.isUsedImagesScanEnabled() {
+		deployedDockerImagesNames, err := m.deployedDockerImagesNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deployedDockerImageName := range deployedDockerImagesNames {
+			deployedDockerImages[deployedDockerImageName] = true
+		}
+	}
+
+	var protectedStages []*image.StageDescription
+	for _, stage := range m.stages {
+		isKeptByPolicies := m.keptByPoliciesStageIDs[stage.Info.Tag]
+		isDeployed := deployedDockerImages[fmt.Sprintf("%s:%s", m.StorageManager.StagesStorage.String(), stage.Info.Tag)]
+		isBuiltRecently := m.KeepStagesBuiltWithinLastNHours != 0 && time.Since(stage.Info.GetCreatedAt()).Hours() <= float64(m.KeepStagesBuiltWithinLastNHours)
+		if isKeptByPolicies || isDeployed || isBuiltRecently {
+			protectedStages = append(protectedStages, stage)
+		}
+	}
+
+	return excludeStages(m.stages, graph.stagesAndDependencies(protectedStages)...), nil
+}
+
+// stagesGraph links the stages with their parents, import sources and, for manifest lists, platform stages
+type stagesGraph struct {
+	stages       []*image.StageDescription
+	dependencies map[*image.StageDescription][]*image.StageDescription
+	dependents   map[*image.StageDescription][]*image.StageDescription
+}
+
+func (m *cleanupManager) newStagesGraph(stages []*image.StageDescription) *stagesGraph {
+	stageByImageID := map[string]*image.StageDescription{}
+	for _, stage := range stages {
+		stageByImageID[stage.Info.ID] = stage
+	}
+
+	graph := &stagesGraph{
+		stages:       stages,
+		dependencies: map[*image.StageDescription][]*image.StageDescription{},
+		dependents:   map[*image.StageDescription][]*image.StageDescription{},
+	}
+
+	addDependency := func(stage *image.StageDescription, imageID string) {
+		dependency, ok := stageByImageID[imageID]
+		if !ok || dependency == stage {
+			return
+		}
+
+		graph.dependencies[stage] = append(graph.dependencies[stage], dependency)
+		graph.dependents[dependency] = append(graph.dependents[dependency], stage)
+	}
+
+	for _, stage := range stages {
+		addDependency(stage, stage.Info.ParentID)
+
+		for _, platformImageID := range stage.Info.PlatformImageIDs {
+			addDependency(stage, platformImageID)
+		}
+
+		for label, checksum := range stage.Info.Labels {
+			if strings.HasPrefix(label, image.WerfImportChecksumLabelPrefix) {
+				for _, sourceImageID := range m.checksumSourceImageIDs[checksum] {
+					addDependency(stage, sourceImageID)
+				}
+			}
+		}
+	}
+
+	return graph
+}
+
+func (g *stagesGraph) isDependency(stage, dependency *image.StageDescription) bool {
+	for _, stageDependency := range g.dependencies[stage] {
+		if stageDependency == dependency {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stagesAndDependencies returns the stages along with their ancestors, import sources and platform stages
+func (g *stagesGraph) stagesAndDependencies(stages []*image.StageDescription) []*image.StageDescription {
+	var result []*image.StageDescription
+	visited := map[*image.StageDescription]bool{}
+
+	queue := append([]*image.StageDescription{}, stages...)
+	for len(queue) != 0 {
+		stage := queue[0]
+		queue = queue[1:]
+
+		if visited[stage] {
+			continue
+		}
+		visited[stage] = true
+
+		result = append(result, stage)
+		queue = append(queue, g.dependencies[stage]...)
+	}
+
+	return result
+}
+
+// stagesLastUsedAt returns the time the stage was last used at:
+// the stage is used when the stage itself or any stage based on it (or importing files from it) is built
+func (g *stagesGraph) stagesLastUsedAt() map[*image.StageDescription]time.Time {
+	stageLastUsedAt := map[*image.StageDescription]time.Time{}
+
+	var lastUsedAt func(stage *image.StageDescription) time.Time
+	lastUsedAt = func(stage *image.StageDescription) time.Time {
+		if result, ok := stageLastUsedAt[stage]; ok {
+			return result
+		}
+
+		// set before visiting the dependents to stop on the cycles
+		result := stage.Info.GetCreatedAt()
+		stageLastUsedAt[stage] = result
+
+		for _, dependent := range g.dependents[stage] {
+			if dependentLastUsedAt := lastUsedAt(dependent); dependentLastUsedAt.After(result) {
+				result = dependentLastUsedAt
+			}
+		}
+
+		stageLastUsedAt[stage] = result
+		return result
+	}
+
+	for _, stage := range g.stages {
+		lastUsedAt(stage)
+	}
+
+	return stageLastUsedAt
+}
+
+// stagesLayers counts the layers shared by the stages once,
+// the layers are reclaimed only when no remaining stage references them
+type stagesLayers struct {
+	stageLayers map[*image.StageDescription][]image.LayerInfo
+	layerSizes  map[string]int64
+	layerUsages map[string]int
+}
+
+func newStagesLayers(graph *stagesGraph, stages []*image.StageDescription) *stagesLayers {
+	l := &stagesLayers{
+		stageLayers: map[*image.StageDescription][]image.LayerInfo{},
+		layerSizes:  map[string]int64{},
+		layerUsages: map[string]int{},
+	}
+
+	for _, stage := range stages {
+		layers := stage.Info.Layers
+		if len(layers) == 0 {
+			layers = []image.LayerInfo{{Digest: stage.Info.ID, Size: stageOwnSize(graph, stage)}}
+		}
+
+		seen := map[string]bool{}
+		for _, layer := range layers {
+			if seen[layer.Digest] {
+				continue
+			}
+			seen[layer.Digest] = true
+
+			l.stageLayers[stage] = append(l.stageLayers[stage], layer)
+			l.layerSizes[layer.Digest] = layer.Size
+			l.layerUsages[layer.Digest]++
+		}
+	}
+
+	return l
+}
+
+// stageOwnSize is used when the layers of the stage are unknown, the size of the stage includes the size of its parent
+func stageOwnSize(graph *stagesGraph, stage *image.StageDescription) int64 {
+	for _, dependency := range graph.dependencies[stage] {
+		if dependency.Info.ID == stage.Info.ParentID && dependency.Info.Size <= stage.Info.Size {
+			return stage.Info.Size - dependency.Info.Size
+		}
+	}
+
+	return stage.Info.Size
+}
+
+func (l *stagesLayers) totalSize() uint64 {
+	var totalSize uint64
+	for digest, size := range l.layerSizes {
+		if l.layerUsages[digest] != 0 {
+			totalSize += uint64(size)
+		}
+	}
+
+	return totalSize
+}
+
+// release returns the size of the stage layers that are not referenced by the remaining stages
+func (l *stagesLayers) release(stage *image.StageDescription) uint64 {
+	var freedBytes uint64
+	for _, layer := range l.stageLayers[stage] {
+		l.layerUsages[layer.Digest]--
+		if l.layerUsages[layer.Digest] == 0 {
+			freedBytes += uint64(layer.Size)
+		}
+	}
+	delete(l.stageLayers, stage)
+
+	return freedBytes
+}
+
 func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
+	if err := m.fetchImportsMetadata(ctx); err != nil {
+		return err
+	}
+
+	for _, metadataID := range m.invalidImportMetadataIDs {
+		if err := logboek.Context(ctx).Warn().LogProcess("Deleting invalid import metadata %s", metadataID).
+			DoError(func() error {
+				return m.deleteImportsMetadata(ctx, []string{metadataID}, CleanupPlanReasonInvalidImportMetadata)
+			}); err != nil {
+			return fmt.Errorf("unable to delete import metadata %s: %s", metadataID, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *cleanupManager) fetchImportsMetadata(ctx context.Context) error {
 	m.checksumSourceImageIDs = map[string][]string{}
+	m.importSourceIDSourceImageID = map[string]string{}
 
 	importMetadataIDs, err := m.StorageManager.StagesStorage.GetImportMetadataIDs(ctx, m.ProjectName)
 	if err != nil {
@@ -657,14 +1209,11 @@ func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
 			return err
 		}
 
-		if metadata == nil {
-			if err := logboek.Context(ctx).Warn().LogProcess("Deleting invalid import metadata %s", metadataID).
-				DoError(func() error {
-					return m.deleteImportsMetadata(ctx, []string{metadataID})
-				}); err != nil {
-				return fmt.Errorf("unable to delete import metadata %s: %s", metadataID, err)
-			}
+		mutex.Lock()
+		defer mutex.Unlock()
 
+		if metadata == nil {
+			m.invalidImportMetadataIDs = append(m.invalidImportMetadataIDs, metadataID)
 			return nil
 		}
 
@@ -672,8 +1221,7 @@ func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
 		sourceImageID := metadata.SourceImageID
 		checksum := metadata.Checksum
 
-		mutex.Lock()
-		defer mutex.Unlock()
+		m.importSourceIDSourceImageID[importSourceID] = sourceImageID
 
 		stage := findStageByImageID(m.stages, sourceImageID)
 		if stage != nil {
@@ -691,7 +1239,11 @@ func (m *cleanupManager) initImportsMetadata(ctx context.Context) error {
 	})
 }
 
-func (m *cleanupManager) deleteImportsMetadata(ctx context.Context, importMetadataIDs []string) error {
+func (m *cleanupManager) deleteImportsMetadata(ctx context.Context, importMetadataIDs []string, reason string) error {
+	if m.plan != nil {
+		m.plan.addImportMetadata(importMetadataIDs, reason)
+	}
+
 	return deleteImportsMetadata(ctx, m.ProjectName, m.StorageManager, importMetadataIDs, m.DryRun)
 }
 
@@ -753,6 +1305,12 @@ func (m *cleanupManager) excludeStageAndRelativesByStage(stages []*image.StageDe
 		}
 	}
 
+	for _, platformImageID := range stage.Info.PlatformImageIDs {
+		var excludedPlatformStages []*image.StageDescription
+		stages, excludedPlatformStages = m.excludeStageAndRelativesByImageID(stages, platformImageID)
+		excludedStages = append(excludedStages, excludedPlatformStages...)
+	}
+
 	for label, checksum := range stage.Info.Labels {
 		if strings.HasPrefix(label, image.WerfImportChecksumLabelPrefix) {
 			sourceImageIDs, ok := m.checksumSourceImageIDs[checksum]
@@ -0,0 +1,374 @@
+package cleaning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+)
+
+const (
+	CleanupPlanReasonNotReachedByKeepPolicies = "not reachable by any keep policy"
+	CleanupPlanReasonNotKeptByAge             = "not kept by age-based policy"
+	CleanupPlanReasonNonexistentStage         = "stage does not exist"
+	CleanupPlanReasonNonexistentCommit        = "commit does not exist in local git repository"
+	CleanupPlanReasonNotManagedImage          = "image is neither defined in werf.yaml nor managed"
+	CleanupPlanReasonInvalidImportMetadata    = "invalid import metadata"
+	CleanupPlanReasonNonexistentImportSource  = "import source stage does not exist"
+	CleanupPlanReasonSizeQuotaExceeded        = "least recently used stage exceeding size quota"
+)
+
+// CleanupPlan is the list of stages, image metadata and import metadata, which werf cleanup would delete.
+// The plan is written by the cleanup with the plan file and executed later by the cleanup with the apply plan option
+type CleanupPlan struct {
+	ProjectName    string                       `json:"projectName"`
+	Repo           string                       `json:"repo"`
+	CreatedAt      time.Time                    `json:"createdAt"`
+	Strategy       string                       `json:"strategy"`
+	Policies       []string                     `json:"policies,omitempty"`
+	Stages         []*CleanupPlanStage          `json:"stages"`
+	ImageMetadata  []*CleanupPlanImageMetadata  `json:"imageMetadata"`
+	ImportMetadata []*CleanupPlanImportMetadata `json:"importMetadata"`
+}
+
+type CleanupPlanStage struct {
+	Tag     string `json:"tag"`
+	ImageID string `json:"imageID"`
+	Reason  string `json:"reason"`
+}
+
+type CleanupPlanImageMetadata struct {
+	ImageName string   `json:"imageName"`
+	StageID   string   `json:"stageID"`
+	Commits   []string `json:"commits"`
+	Reason    string   `json:"reason"`
+}
+
+type CleanupPlanImportMetadata struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// policiesReason adds the policies, which the deleted item is not kept by, to the reason
+func policiesReason(reason string, policies []string) string {
+	if len(policies) == 0 {
+		return reason
+	}
+
+	return fmt.Sprintf("%s (%s)", reason, strings.Join(policies, ", "))
+}
+
+func (p *CleanupPlan) addStages(stages []*image.StageDescription, reason string) {
+	for _, stage := range stages {
+		p.Stages = append(p.Stages, &CleanupPlanStage{Tag: stage.Info.Tag, ImageID: stage.Info.ID, Reason: reason})
+	}
+}
+
+func (p *CleanupPlan) addImageMetadata(imageName string, stageIDCommitList map[string][]string, reason string) {
+	for stageID, commitList := range stageIDCommitList {
+		if len(commitList) == 0 {
+			continue
+		}
+
+		p.ImageMetadata = append(p.ImageMetadata, &CleanupPlanImageMetadata{ImageName: imageName, StageID: stageID, Commits: commitList, Reason: reason})
+	}
+}
+
+func (p *CleanupPlan) addImportMetadata(importMetadataIDs []string, reason string) {
+	for _, importMetadataID := range importMetadataIDs {
+		p.ImportMetadata = append(p.ImportMetadata, &CleanupPlanImportMetadata{ID: importMetadataID, Reason: reason})
+	}
+}
+
+func (p *CleanupPlan) sort() {
+	sort.SliceStable(p.Stages, func(i, j int) bool {
+		return p.Stages[i].Tag < p.Stages[j].Tag
+	})
+
+	sort.SliceStable(p.ImageMetadata, func(i, j int) bool {
+		if p.ImageMetadata[i].ImageName != p.ImageMetadata[j].ImageName {
+			return p.ImageMetadata[i].ImageName < p.ImageMetadata[j].ImageName
+		}
+
+		return p.ImageMetadata[i].StageID < p.ImageMetadata[j].StageID
+	})
+
+	sort.SliceStable(p.ImportMetadata, func(i, j int) bool {
+		return p.ImportMetadata[i].ID < p.ImportMetadata[j].ID
+	})
+}
+
+func (p *CleanupPlan) write(path string) error {
+	p.sort()
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal cleanup plan: %s", err)
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write cleanup plan %s: %s", path, err)
+	}
+
+	return nil
+}
+
+func readCleanupPlan(path string) (*CleanupPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cleanup plan %s: %s", path, err)
+	}
+
+	plan := &CleanupPlan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal cleanup plan %s: %s", path, err)
+	}
+
+	return plan, nil
+}
+
+func (m *cleanupManager) applyPlan(ctx context.Context) error {
+	plan, err := readCleanupPlan(m.ApplyPlanFile)
+	if err != nil {
+		return err
+	}
+
+	repo := m.StorageManager.StagesStorage.String()
+	if plan.ProjectName != m.ProjectName || plan.Repo != repo {
+		return fmt.Errorf("cleanup plan %s was created for project %q and repo %q, but project %q and repo %q are used", m.ApplyPlanFile, plan.ProjectName, plan.Repo, m.ProjectName, repo)
+	}
+
+	var imageNameStageIDCommitList map[string]map[string][]string
+	if err := logboek.Context(ctx).LogProcess("Fetching manifests and metadata").DoError(func() error {
+		if err := m.initStages(ctx); err != nil {
+			return err
+		}
+
+		imageMetadataByImageName, imageMetadataByNotManagedImageName, err := m.StorageManager.StagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, m.ProjectName, m.ImageNameList)
+		if err != nil {
+			return err
+		}
+
+		imageNameStageIDCommitList = imageMetadataByImageName
+		for imageName, stageIDCommitList := range imageMetadataByNotManagedImageName {
+			imageNameStageIDCommitList[imageName] = stageIDCommitList
+		}
+
+		return m.fetchImportsMetadata(ctx)
+	}); err != nil {
+		return err
+	}
+
+	var deployedDockerImagesNames []string
+	if m.isUsedImagesScanEnabled() {
+		if err := logboek.Context(ctx).LogProcess("Fetching images that are being used in Kubernetes").DoError(func() error {
+			deployedDockerImagesNames, err = m.deployedDockerImagesNames(ctx)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := logboek.Context(ctx).LogProcess("Validating cleanup plan").DoError(func() error {
+		return m.validatePlan(plan, imageNameStageIDCommitList, deployedDockerImagesNames)
+	}); err != nil {
+		return err
+	}
+
+	return m.executePlan(ctx, plan, imageNameStageIDCommitList)
+}
+
+// validatePlan checks that nothing new references the planned stages since the plan has been created:
+// the planned stages should not be related to the stages of the image metadata, which is not planned for deletion,
+// to the stages, which are used in Kubernetes, and to the stages, which are not planned for deletion (e.g. built after the plan).
+// The plan created before the period of kept recently built stages is refused, because the planned stages could be used since then
+func (m *cleanupManager) validatePlan(plan *CleanupPlan, imageNameStageIDCommitList map[string]map[string][]string, deployedDockerImagesNames []string) error {
+	if plan.CreatedAt.IsZero() {
+		return fmt.Errorf("cleanup plan %s has no creation time, create a new plan", m.ApplyPlanFile)
+	} else if m.KeepStagesBuiltWithinLastNHours != 0 && time.Since(plan.CreatedAt).Hours() > float64(m.KeepStagesBuiltWithinLastNHours) {
+		return fmt.Errorf("cleanup plan %s created at %s is outdated, the plan should be applied within %d hours (--keep-stages-built-within-last-n-hours), create a new plan", m.ApplyPlanFile, plan.CreatedAt.Format(time.RFC3339), m.KeepStagesBuiltWithinLastNHours)
+	}
+
+	plannedStages := map[string]bool{}
+	for _, stage := range plan.Stages {
+		plannedStages[stage.Tag] = true
+	}
+
+	plannedImageNameStageIDCommits := map[string]map[string]map[string]bool{}
+	for _, metadata := range plan.ImageMetadata {
+		if _, ok := plannedImageNameStageIDCommits[metadata.ImageName]; !ok {
+			plannedImageNameStageIDCommits[metadata.ImageName] = map[string]map[string]bool{}
+		}
+
+		if _, ok := plannedImageNameStageIDCommits[metadata.ImageName][metadata.StageID]; !ok {
+			plannedImageNameStageIDCommits[metadata.ImageName][metadata.StageID] = map[string]bool{}
+		}
+
+		for _, commit := range metadata.Commits {
+			plannedImageNameStageIDCommits[metadata.ImageName][metadata.StageID][commit] = true
+		}
+	}
+
+	deployedDockerImages := map[string]bool{}
+	for _, deployedDockerImageName := range deployedDockerImagesNames {
+		deployedDockerImages[deployedDockerImageName] = true
+	}
+
+	var conflicts []string
+	keptStageReasons := map[string]string{}
+	for _, stage := range m.stages {
+		if deployedDockerImages[fmt.Sprintf("%s:%s", m.StorageManager.StagesStorage.String(), stage.Info.Tag)] {
+			keptStageReasons[stage.Info.Tag] = "it is used in Kubernetes"
+		}
+	}
+
+	for imageName, stageIDCommitList := range imageNameStageIDCommitList {
+		for stageID, commitList := range stageIDCommitList {
+			if !m.isStageExist(stageID) {
+				continue
+			}
+
+			if _, ok := keptStageReasons[stageID]; ok {
+				if len(plannedImageNameStageIDCommits[imageName][stageID]) != 0 {
+					conflicts = append(conflicts, fmt.Sprintf("image %s metadata for stage %s: the stage is used in Kubernetes", imageName, stageID))
+				}
+
+				continue
+			}
+
+			for _, commit := range commitList {
+				if !plannedImageNameStageIDCommits[imageName][stageID][commit] {
+					keptStageReasons[stageID] = fmt.Sprintf("image %s metadata for commit %s is not planned for deletion", imageName, commit)
+					break
+				}
+			}
+		}
+	}
+
+	var keptStageIDs []string
+	for stageID := range keptStageReasons {
+		keptStageIDs = append(keptStageIDs, stageID)
+	}
+	sort.Strings(keptStageIDs)
+
+	stages := m.stages
+	for _, stageID := range keptStageIDs {
+		var excludedStages []*image.StageDescription
+		stages, excludedStages = m.excludeStageAndRelativesByImageID(stages, m.mustGetStage(stageID).Info.ID)
+
+		for _, stage := range excludedStages {
+			if plannedStages[stage.Info.Tag] {
+				conflicts = append(conflicts, fmt.Sprintf("stage %s: the stage is related to stage %s, %s", stage.Info.Tag, stageID, keptStageReasons[stageID]))
+			}
+		}
+	}
+
+	for _, stage := range m.stages {
+		if plannedStages[stage.Info.Tag] {
+			continue
+		}
+
+		var excludedStages []*image.StageDescription
+		stages, excludedStages = m.excludeStageAndRelativesByStage(stages, stage)
+
+		for _, excludedStage := range excludedStages {
+			if plannedStages[excludedStage.Info.Tag] {
+				conflicts = append(conflicts, fmt.Sprintf("stage %s: the stage is an ancestor, import source or platform stage of stage %s, which is not planned for deletion", excludedStage.Info.Tag, stage.Info.Tag))
+			}
+		}
+	}
+
+	for _, importMetadata := range plan.ImportMetadata {
+		sourceImageID, ok := m.importSourceIDSourceImageID[importMetadata.ID]
+		if !ok {
+			continue
+		}
+
+		if stage := findStageByImageID(m.stages, sourceImageID); stage != nil && !plannedStages[stage.Info.Tag] {
+			conflicts = append(conflicts, fmt.Sprintf("import metadata %s: the source stage %s exists and is not planned for deletion", importMetadata.ID, stage.Info.Tag))
+		}
+	}
+
+	if len(conflicts) != 0 {
+		return fmt.Errorf("cleanup plan %s is outdated, create a new plan:\n%s", m.ApplyPlanFile, strings.Join(conflicts, "\n"))
+	}
+
+	return nil
+}
+
+// executePlan deletes the planned items, which still exist
+func (m *cleanupManager) executePlan(ctx context.Context, plan *CleanupPlan, imageNameStageIDCommitList map[string]map[string][]string) error {
+	imageNameStageIDCommitListToDelete := map[string]map[string][]string{}
+	for _, metadata := range plan.ImageMetadata {
+		var commitList []string
+		for _, commit := range metadata.Commits {
+			if util.IsStringsContainValue(imageNameStageIDCommitList[metadata.ImageName][metadata.StageID], commit) {
+				commitList = append(commitList, commit)
+			}
+		}
+
+		if len(commitList) == 0 {
+			continue
+		}
+
+		if _, ok := imageNameStageIDCommitListToDelete[metadata.ImageName]; !ok {
+			imageNameStageIDCommitListToDelete[metadata.ImageName] = map[string][]string{}
+		}
+
+		imageNameStageIDCommitListToDelete[metadata.ImageName][metadata.StageID] = commitList
+	}
+
+	if len(imageNameStageIDCommitListToDelete) != 0 {
+		if err := logboek.Context(ctx).Default().LogProcess("Deleting image metadata").DoError(func() error {
+			for imageName, stageIDCommitList := range imageNameStageIDCommitListToDelete {
+				if err := m.deleteImageMetadata(ctx, imageName, stageIDCommitList, false, ""); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	var stagesToDelete []*image.StageDescription
+	for _, stage := range plan.Stages {
+		if stageDesc := m.getStage(stage.Tag); stageDesc != nil {
+			stagesToDelete = append(stagesToDelete, stageDesc)
+		}
+	}
+
+	if len(stagesToDelete) != 0 {
+		if err := logboek.Context(ctx).Default().LogProcess("Deleting stages tags").DoError(func() error {
+			return m.deleteStages(ctx, stagesToDelete, "")
+		}); err != nil {
+			return err
+		}
+	}
+
+	var importMetadataIDsToDelete []string
+	for _, importMetadata := range plan.ImportMetadata {
+		if _, ok := m.importSourceIDSourceImageID[importMetadata.ID]; ok || util.IsStringsContainValue(m.invalidImportMetadataIDs, importMetadata.ID) {
+			importMetadataIDsToDelete = append(importMetadataIDsToDelete, importMetadata.ID)
+		}
+	}
+
+	if len(importMetadataIDsToDelete) != 0 {
+		if err := logboek.Context(ctx).Default().LogProcess("Cleaning imports metadata").DoError(func() error {
+			return m.deleteImportsMetadata(ctx, importMetadataIDsToDelete, "")
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
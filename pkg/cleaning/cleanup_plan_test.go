@@ -0,0 +1,237 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/storage/manager"
+)
+
+const testRepo = "registry.example.com/project"
+
+type fakeStagesStorage struct {
+	storage.StagesStorage
+}
+
+func (s fakeStagesStorage) String() string {
+	return testRepo
+}
+
+func newTestStage(digest string, uniqueID int64, parentID string, labels map[string]string) *image.StageDescription {
+	stageID := &image.StageID{Digest: digest, UniqueID: uniqueID}
+	return &image.StageDescription{
+		StageID: stageID,
+		Info:    &image.Info{Tag: stageID.String(), ID: "sha256:" + digest, ParentID: parentID, Labels: labels},
+	}
+}
+
+func newTestManifestListStage(digest string, uniqueID int64, platformStages ...*image.StageDescription) *image.StageDescription {
+	stage := newTestStage(digest, uniqueID, "", nil)
+	for _, platformStage := range platformStages {
+		stage.Info.PlatformImageIDs = append(stage.Info.PlatformImageIDs, platformStage.Info.ID)
+	}
+
+	return stage
+}
+
+func newTestCleanupManager(stages ...*image.StageDescription) *cleanupManager {
+	return &cleanupManager{
+		stages:                      stages,
+		checksumSourceImageIDs:      map[string][]string{},
+		importSourceIDSourceImageID: map[string]string{},
+		ProjectName:                 "project",
+		StorageManager:              &manager.StorageManager{StagesStorageManager: &manager.StagesStorageManager{StagesStorage: fakeStagesStorage{}}},
+		ApplyPlanFile:               "plan.json",
+	}
+}
+
+func newTestPlan(createdAt time.Time, stages ...*image.StageDescription) *CleanupPlan {
+	plan := &CleanupPlan{ProjectName: "project", Repo: testRepo, CreatedAt: createdAt}
+	plan.addStages(stages, "reason")
+
+	return plan
+}
+
+func TestCleanupManager_ValidatePlan(t *testing.T) {
+	now := time.Now()
+
+	base := newTestStage("base", 1, "", nil)
+	app := newTestStage("app", 2, base.Info.ID, nil)
+	source := newTestStage("source", 3, "", nil)
+	importer := newTestStage("importer", 4, "", map[string]string{image.WerfImportChecksumLabelPrefix + "import": "checksum"})
+	old := newTestStage("old", 5, "", nil)
+	amd64 := newTestStage("amd64", 6, "", nil)
+	arm64 := newTestStage("arm64", 7, "", nil)
+	list := newTestManifestListStage("list", 8, amd64, arm64)
+
+	oldMetadata := map[string]map[string][]string{"app": {old.Info.Tag: {"commit"}}}
+
+	withOldMetadata := func(plan *CleanupPlan) *CleanupPlan {
+		plan.addImageMetadata("app", map[string][]string{old.Info.Tag: {"commit"}}, "reason")
+		return plan
+	}
+
+	withImportMetadata := func(plan *CleanupPlan) *CleanupPlan {
+		plan.addImportMetadata([]string{"import"}, "reason")
+		return plan
+	}
+
+	tests := []struct {
+		name                       string
+		plan                       *CleanupPlan
+		imageNameStageIDCommitList map[string]map[string][]string
+		deployedDockerImagesNames  []string
+		expectedErr                string
+	}{
+		{
+			name:                       "nothing new references planned stages",
+			plan:                       withOldMetadata(newTestPlan(now, old)),
+			imageNameStageIDCommitList: oldMetadata,
+		},
+		{
+			name: "planned stage with planned descendant",
+			plan: newTestPlan(now, base, app),
+		},
+		{
+			name:        "plan without creation time",
+			plan:        newTestPlan(time.Time{}, old),
+			expectedErr: "has no creation time",
+		},
+		{
+			name:        "stale plan",
+			plan:        newTestPlan(now.Add(-3*time.Hour), old),
+			expectedErr: "is outdated, the plan should be applied within 2 hours",
+		},
+		{
+			name:                      "planned stage is used in Kubernetes",
+			plan:                      newTestPlan(now, old),
+			deployedDockerImagesNames: []string{testRepo + ":" + old.Info.Tag},
+			expectedErr:               "the stage is related to stage old-5, it is used in Kubernetes",
+		},
+		{
+			name:                       "planned metadata of stage used in Kubernetes",
+			plan:                       withOldMetadata(newTestPlan(now)),
+			imageNameStageIDCommitList: oldMetadata,
+			deployedDockerImagesNames:  []string{testRepo + ":" + old.Info.Tag},
+			expectedErr:                "image app metadata for stage old-5: the stage is used in Kubernetes",
+		},
+		{
+			name:                       "planned stage has unplanned metadata",
+			plan:                       newTestPlan(now, old),
+			imageNameStageIDCommitList: oldMetadata,
+			expectedErr:                "image app metadata for commit commit is not planned for deletion",
+		},
+		{
+			name:        "new stage is built on top of planned stage",
+			plan:        newTestPlan(now, base),
+			expectedErr: "stage base-1: the stage is an ancestor, import source or platform stage of stage app-2",
+		},
+		{
+			name:        "new stage imports from planned stage",
+			plan:        newTestPlan(now, source),
+			expectedErr: "stage source-3: the stage is an ancestor, import source or platform stage of stage importer-4",
+		},
+		{
+			name: "planned manifest list with planned platform stages",
+			plan: newTestPlan(now, list, amd64, arm64),
+		},
+		{
+			name:        "manifest list references planned platform stage",
+			plan:        newTestPlan(now, arm64),
+			expectedErr: "stage arm64-7: the stage is an ancestor, import source or platform stage of stage list-8",
+		},
+		{
+			name:        "import source of planned import metadata is not planned",
+			plan:        withImportMetadata(newTestPlan(now)),
+			expectedErr: "import metadata import: the source stage source-3 exists and is not planned for deletion",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestCleanupManager(base, app, source, importer, old, amd64, arm64, list)
+			m.KeepStagesBuiltWithinLastNHours = 2
+			m.checksumSourceImageIDs["checksum"] = []string{source.Info.ID}
+			m.importSourceIDSourceImageID["import"] = source.Info.ID
+
+			err := m.validatePlan(tt.plan, tt.imageNameStageIDCommitList, tt.deployedDockerImagesNames)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("expected error %q, got nil", tt.expectedErr)
+			} else if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Fatalf("expected error %q, got %q", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCleanupManager_ExecutePlan(t *testing.T) {
+	old := newTestStage("old", 1, "", nil)
+	kept := newTestStage("kept", 2, "", nil)
+	deleted := newTestStage("deleted", 3, "", nil)
+
+	plan := newTestPlan(time.Now(), old, deleted)
+	plan.addImageMetadata("app", map[string][]string{old.Info.Tag: {"commit", "deleted-commit"}, deleted.Info.Tag: {"commit"}}, "reason")
+	plan.addImportMetadata([]string{"import", "invalid-import", "deleted-import"}, "reason")
+
+	m := newTestCleanupManager(old, kept)
+	m.DryRun = true
+	m.importSourceIDSourceImageID["import"] = old.Info.ID
+	m.invalidImportMetadataIDs = []string{"invalid-import"}
+
+	// the deletions are recorded instead of being performed
+	m.plan = &CleanupPlan{}
+
+	imageNameStageIDCommitList := map[string]map[string][]string{"app": {old.Info.Tag: {"commit"}, kept.Info.Tag: {"commit"}}}
+	if err := m.executePlan(context.Background(), plan, imageNameStageIDCommitList); err != nil {
+		t.Fatal(err)
+	}
+
+	var deletedStages []string
+	for _, stage := range m.plan.Stages {
+		deletedStages = append(deletedStages, stage.Tag)
+	}
+	if expected := []string{old.Info.Tag}; !reflect.DeepEqual(deletedStages, expected) {
+		t.Errorf("expected deleted stages %v, got %v", expected, deletedStages)
+	}
+
+	deletedImageMetadata := map[string][]string{}
+	for _, metadata := range m.plan.ImageMetadata {
+		deletedImageMetadata[metadata.ImageName+"/"+metadata.StageID] = metadata.Commits
+	}
+	if expected := map[string][]string{"app/" + old.Info.Tag: {"commit"}}; !reflect.DeepEqual(deletedImageMetadata, expected) {
+		t.Errorf("expected deleted image metadata %v, got %v", expected, deletedImageMetadata)
+	}
+
+	var deletedImportMetadata []string
+	for _, metadata := range m.plan.ImportMetadata {
+		deletedImportMetadata = append(deletedImportMetadata, metadata.ID)
+	}
+	sort.Strings(deletedImportMetadata)
+	if expected := []string{"import", "invalid-import"}; !reflect.DeepEqual(deletedImportMetadata, expected) {
+		t.Errorf("expected deleted import metadata %v, got %v", expected, deletedImportMetadata)
+	}
+}
+
+func TestPoliciesReason(t *testing.T) {
+	if reason := policiesReason(CleanupPlanReasonNotKeptByAge, nil); reason != CleanupPlanReasonNotKeptByAge {
+		t.Errorf("expected reason %q, got %q", CleanupPlanReasonNotKeptByAge, reason)
+	}
+
+	expected := CleanupPlanReasonNotReachedByKeepPolicies + " ({references={branch=/.*/}}, {references={tag=/.*/}})"
+	if reason := policiesReason(CleanupPlanReasonNotReachedByKeepPolicies, []string{"{references={branch=/.*/}}", "{references={tag=/.*/}}"}); reason != expected {
+		t.Errorf("expected reason %q, got %q", expected, reason)
+	}
+}
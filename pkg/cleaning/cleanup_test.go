@@ -0,0 +1,351 @@
+package cleaning
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func newTestSizedStage(digest string, uniqueID int64, parentID string, createdAt time.Time, layers ...image.LayerInfo) *image.StageDescription {
+	stage := newTestStage(digest, uniqueID, parentID, nil)
+	stage.Info.CreatedAtUnixNano = createdAt.UnixNano()
+	stage.Info.Layers = layers
+	for _, layer := range layers {
+		stage.Info.Size += layer.Size
+	}
+
+	return stage
+}
+
+func testStagesTags(stages []*image.StageDescription) []string {
+	var tags []string
+	for _, stage := range stages {
+		tags = append(tags, stage.Info.Tag)
+	}
+
+	return tags
+}
+
+func TestStagesLayers(t *testing.T) {
+	now := time.Now()
+
+	t.Run("shared layers", func(t *testing.T) {
+		base := newTestSizedStage("base", 1, "", now, image.LayerInfo{Digest: "l1", Size: 100})
+		app := newTestSizedStage("app", 2, base.Info.ID, now, image.LayerInfo{Digest: "l1", Size: 100}, image.LayerInfo{Digest: "l2", Size: 50})
+		other := newTestSizedStage("other", 3, "", now, image.LayerInfo{Digest: "l1", Size: 100}, image.LayerInfo{Digest: "l3", Size: 30}, image.LayerInfo{Digest: "l3", Size: 30})
+
+		m := newTestCleanupManager(base, app, other)
+		layers := newStagesLayers(m.newStagesGraph(m.stages), m.stages)
+
+		if totalSize := layers.totalSize(); totalSize != 180 {
+			t.Fatalf("expected total size 180, got %d", totalSize)
+		}
+
+		for _, tt := range []struct {
+			stage              *image.StageDescription
+			expectedFreedBytes uint64
+			expectedTotalSize  uint64
+		}{
+			{stage: app, expectedFreedBytes: 50, expectedTotalSize: 130},
+			{stage: base, expectedFreedBytes: 0, expectedTotalSize: 130},
+			{stage: other, expectedFreedBytes: 130, expectedTotalSize: 0},
+		} {
+			if freedBytes := layers.release(tt.stage); freedBytes != tt.expectedFreedBytes {
+				t.Errorf("%s: expected freed bytes %d, got %d", tt.stage.Info.Tag, tt.expectedFreedBytes, freedBytes)
+			}
+
+			if totalSize := layers.totalSize(); totalSize != tt.expectedTotalSize {
+				t.Errorf("%s: expected total size %d, got %d", tt.stage.Info.Tag, tt.expectedTotalSize, totalSize)
+			}
+		}
+	})
+
+	t.Run("unknown layers", func(t *testing.T) {
+		base := newTestStage("base", 1, "", nil)
+		base.Info.Size = 100
+		app := newTestStage("app", 2, base.Info.ID, nil)
+		app.Info.Size = 150
+		other := newTestStage("other", 3, "", nil)
+		other.Info.Size = 30
+
+		m := newTestCleanupManager(base, app, other)
+		layers := newStagesLayers(m.newStagesGraph(m.stages), m.stages)
+
+		if totalSize := layers.totalSize(); totalSize != 180 {
+			t.Fatalf("expected total size 180, got %d", totalSize)
+		}
+
+		if freedBytes := layers.release(app); freedBytes != 50 {
+			t.Errorf("expected freed bytes 50, got %d", freedBytes)
+		}
+	})
+}
+
+func TestStagesGraph_StagesLastUsedAt(t *testing.T) {
+	now := time.Now()
+
+	base := newTestSizedStage("base", 1, "", now.Add(-5*time.Hour))
+	app := newTestSizedStage("app", 2, base.Info.ID, now.Add(-3*time.Hour))
+	source := newTestSizedStage("source", 3, "", now.Add(-4*time.Hour))
+	importer := newTestSizedStage("importer", 4, app.Info.ID, now.Add(-time.Hour))
+	importer.Info.Labels = map[string]string{image.WerfImportChecksumLabelPrefix + "import": "checksum"}
+	lone := newTestSizedStage("lone", 5, "", now.Add(-2*time.Hour))
+
+	m := newTestCleanupManager(base, app, source, importer, lone)
+	m.checksumSourceImageIDs["checksum"] = []string{source.Info.ID}
+
+	stageLastUsedAt := m.newStagesGraph(m.stages).stagesLastUsedAt()
+
+	for _, tt := range []struct {
+		stage              *image.StageDescription
+		expectedLastUsedAt time.Time
+	}{
+		{stage: base, expectedLastUsedAt: importer.Info.GetCreatedAt()},
+		{stage: app, expectedLastUsedAt: importer.Info.GetCreatedAt()},
+		{stage: source, expectedLastUsedAt: importer.Info.GetCreatedAt()},
+		{stage: importer, expectedLastUsedAt: importer.Info.GetCreatedAt()},
+		{stage: lone, expectedLastUsedAt: lone.Info.GetCreatedAt()},
+	} {
+		if lastUsedAt := stageLastUsedAt[tt.stage]; !lastUsedAt.Equal(tt.expectedLastUsedAt) {
+			t.Errorf("%s: expected last used at %s, got %s", tt.stage.Info.Tag, tt.expectedLastUsedAt, lastUsedAt)
+		}
+	}
+}
+
+func TestCleanupManager_StagesNotProtectedFromSizeQuotaBasedCleanup(t *testing.T) {
+	now := time.Now()
+
+	base := newTestSizedStage("base", 1, "", now.Add(-10*time.Hour))
+	app := newTestSizedStage("app", 2, base.Info.ID, now.Add(-9*time.Hour))
+	source := newTestSizedStage("source", 3, "", now.Add(-8*time.Hour))
+	importer := newTestSizedStage("importer", 4, "", now.Add(-7*time.Hour))
+	importer.Info.Labels = map[string]string{image.WerfImportChecksumLabelPrefix + "import": "checksum"}
+	recent := newTestSizedStage("recent", 5, base.Info.ID, now.Add(-time.Hour))
+
+	tests := []struct {
+		name                      string
+		keptByPoliciesStageIDs    []string
+		deployedDockerImagesNames []string
+		keepStagesBuiltWithinLast uint64
+		expectedStages            []string
+	}{
+		{
+			name:           "nothing is protected",
+			expectedStages: []string{base.Info.Tag, app.Info.Tag, source.Info.Tag, importer.Info.Tag, recent.Info.Tag},
+		},
+		{
+			name:                   "stage kept by policies and its ancestors",
+			keptByPoliciesStageIDs: []string{app.Info.Tag},
+			expectedStages:         []string{source.Info.Tag, importer.Info.Tag, recent.Info.Tag},
+		},
+		{
+			name:                      "stage used in Kubernetes and its import sources",
+			deployedDockerImagesNames: []string{testRepo + ":" + importer.Info.Tag},
+			expectedStages:            []string{base.Info.Tag, app.Info.Tag, recent.Info.Tag},
+		},
+		{
+			name:                      "stage built recently and its ancestors",
+			keepStagesBuiltWithinLast: 2,
+			expectedStages:            []string{app.Info.Tag, source.Info.Tag, importer.Info.Tag},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestCleanupManager(base, app, source, importer, recent)
+			m.checksumSourceImageIDs["checksum"] = []string{source.Info.ID}
+			m.KeepStagesBuiltWithinLastNHours = tt.keepStagesBuiltWithinLast
+			m.fetchedDeployedDockerImagesNames = append([]string{}, tt.deployedDockerImagesNames...)
+			m.handleSavedStageIDs(context.Background(), tt.keptByPoliciesStageIDs)
+
+			stages, err := m.stagesNotProtectedFromSizeQuotaBasedCleanup(context.Background(), m.newStagesGraph(m.stages))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tags := testStagesTags(stages); !reflect.DeepEqual(tags, tt.expectedStages) {
+				t.Errorf("expected stages %v, got %v", tt.expectedStages, tags)
+			}
+		})
+	}
+}
+
+func TestCleanupManager_SizeQuotaBasedCleanup(t *testing.T) {
+	now := time.Now()
+
+	// base <- app <- app2 are used by the latest build, old and older are not used since they were built
+	base := newTestSizedStage("base", 1, "", now.Add(-10*time.Hour), image.LayerInfo{Digest: "base", Size: 100})
+	app := newTestSizedStage("app", 2, base.Info.ID, now.Add(-9*time.Hour), image.LayerInfo{Digest: "base", Size: 100}, image.LayerInfo{Digest: "app", Size: 20})
+	app2 := newTestSizedStage("app2", 3, app.Info.ID, now.Add(-time.Hour), image.LayerInfo{Digest: "base", Size: 100}, image.LayerInfo{Digest: "app", Size: 20}, image.LayerInfo{Digest: "app2", Size: 10})
+	old := newTestSizedStage("old", 4, base.Info.ID, now.Add(-5*time.Hour), image.LayerInfo{Digest: "base", Size: 100}, image.LayerInfo{Digest: "old", Size: 40})
+	older := newTestSizedStage("older", 5, "", now.Add(-8*time.Hour), image.LayerInfo{Digest: "older", Size: 60})
+
+	tests := []struct {
+		name                   string
+		sizeQuota              uint64
+		keptByPoliciesStageIDs []string
+		expectedDeletedStages  []string
+	}{
+		{
+			name:      "quota is not exceeded",
+			sizeQuota: 230,
+		},
+		{
+			name:                  "least recently used stage",
+			sizeQuota:             200,
+			expectedDeletedStages: []string{older.Info.Tag},
+		},
+		{
+			name:                  "least recently used stages until the quota is reached",
+			sizeQuota:             130,
+			expectedDeletedStages: []string{older.Info.Tag, old.Info.Tag},
+		},
+		{
+			name:                  "descendants are deleted before ancestors",
+			sizeQuota:             0,
+			expectedDeletedStages: []string{older.Info.Tag, old.Info.Tag, app2.Info.Tag, app.Info.Tag, base.Info.Tag},
+		},
+		{
+			name:                   "quota cannot be reached due to stages kept by policies",
+			sizeQuota:              0,
+			keptByPoliciesStageIDs: []string{app2.Info.Tag},
+			expectedDeletedStages:  []string{older.Info.Tag, old.Info.Tag},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizeQuota := tt.sizeQuota
+
+			m := newTestCleanupManager(base, app, app2, old, older)
+			m.DryRun = true
+			m.WithoutKube = true
+			m.GitHistoryBasedCleanupOptions.SizeQuota = &sizeQuota
+			m.imageNameStageIDCommitList = map[string]map[string][]string{"app": {app2.Info.Tag: {"commit"}, old.Info.Tag: {"old-commit"}}}
+			m.handleSavedStageIDs(context.Background(), tt.keptByPoliciesStageIDs)
+
+			// the deletions are recorded instead of being performed
+			m.plan = &CleanupPlan{}
+
+			if err := m.sizeQuotaBasedCleanup(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var deletedStages []string
+			for _, stage := range m.plan.Stages {
+				deletedStages = append(deletedStages, stage.Tag)
+			}
+			if !reflect.DeepEqual(deletedStages, tt.expectedDeletedStages) {
+				t.Errorf("expected deleted stages %v, got %v", tt.expectedDeletedStages, deletedStages)
+			}
+
+			var deletedImageMetadata []string
+			for _, metadata := range m.plan.ImageMetadata {
+				deletedImageMetadata = append(deletedImageMetadata, metadata.StageID)
+			}
+			sort.Strings(deletedImageMetadata)
+
+			var expectedDeletedImageMetadata []string
+			for _, stageID := range tt.expectedDeletedStages {
+				if stageID == app2.Info.Tag || stageID == old.Info.Tag {
+					expectedDeletedImageMetadata = append(expectedDeletedImageMetadata, stageID)
+				}
+			}
+			sort.Strings(expectedDeletedImageMetadata)
+
+			if !reflect.DeepEqual(deletedImageMetadata, expectedDeletedImageMetadata) {
+				t.Errorf("expected deleted image metadata %v, got %v", expectedDeletedImageMetadata, deletedImageMetadata)
+			}
+
+			if expected := 5 - len(tt.expectedDeletedStages); len(m.stages) != expected {
+				t.Errorf("expected %d remaining stages, got %d", expected, len(m.stages))
+			}
+		})
+	}
+}
+
+func TestCleanupManager_ExcludeStageAndRelativesOfManifestList(t *testing.T) {
+	base := newTestStage("base", 1, "", nil)
+	amd64 := newTestStage("amd64", 2, base.Info.ID, nil)
+	arm64 := newTestStage("arm64", 3, "", nil)
+	list := newTestManifestListStage("list", 4, amd64, arm64)
+	other := newTestStage("other", 5, "", nil)
+
+	m := newTestCleanupManager(base, amd64, arm64, list, other)
+
+	// the manifest list kept by the image metadata or used in Kubernetes keeps its platform stages
+	stages, excludedStages := m.excludeStageAndRelativesByImageID(m.stages, list.Info.ID)
+
+	if tags, expected := testStagesTags(excludedStages), []string{list.Info.Tag, amd64.Info.Tag, base.Info.Tag, arm64.Info.Tag}; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected excluded stages %v, got %v", expected, tags)
+	}
+
+	if tags, expected := testStagesTags(stages), []string{other.Info.Tag}; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected remaining stages %v, got %v", expected, tags)
+	}
+}
+
+func TestCleanupManager_SizeQuotaBasedCleanupOfManifestList(t *testing.T) {
+	now := time.Now()
+
+	amd64 := newTestSizedStage("amd64", 1, "", now.Add(-2*time.Hour), image.LayerInfo{Digest: "amd64", Size: 100})
+	arm64 := newTestSizedStage("arm64", 2, "", now.Add(-time.Hour), image.LayerInfo{Digest: "arm64", Size: 100})
+	list := newTestManifestListStage("list", 3, amd64, arm64)
+	list.Info.CreatedAtUnixNano = arm64.Info.CreatedAtUnixNano
+	list.Info.Layers = append(append([]image.LayerInfo{}, amd64.Info.Layers...), arm64.Info.Layers...)
+	list.Info.Size = amd64.Info.Size + arm64.Info.Size
+
+	tests := []struct {
+		name                   string
+		sizeQuota              uint64
+		keptByPoliciesStageIDs []string
+		expectedDeletedStages  []string
+	}{
+		{
+			name:                  "manifest list is deleted before its platform stages",
+			sizeQuota:             100,
+			expectedDeletedStages: []string{list.Info.Tag, arm64.Info.Tag},
+		},
+		{
+			name:                  "manifest list and all its platform stages",
+			sizeQuota:             0,
+			expectedDeletedStages: []string{list.Info.Tag, arm64.Info.Tag, amd64.Info.Tag},
+		},
+		{
+			name:                   "platform stages of manifest list kept by policies",
+			sizeQuota:              0,
+			keptByPoliciesStageIDs: []string{list.Info.Tag},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizeQuota := tt.sizeQuota
+
+			m := newTestCleanupManager(amd64, arm64, list)
+			m.DryRun = true
+			m.WithoutKube = true
+			m.GitHistoryBasedCleanupOptions.SizeQuota = &sizeQuota
+			m.handleSavedStageIDs(context.Background(), tt.keptByPoliciesStageIDs)
+
+			// the deletions are recorded instead of being performed
+			m.plan = &CleanupPlan{}
+
+			if err := m.sizeQuotaBasedCleanup(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var deletedStages []string
+			for _, stage := range m.plan.Stages {
+				deletedStages = append(deletedStages, stage.Tag)
+			}
+			if !reflect.DeepEqual(deletedStages, tt.expectedDeletedStages) {
+				t.Errorf("expected deleted stages %v, got %v", tt.expectedDeletedStages, deletedStages)
+			}
+		})
+	}
+}
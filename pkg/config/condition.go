@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"path"
+
+	"gopkg.in/yaml.v2"
+)
+
+// conditionContext is used to evaluate conditions of shell commands and ansible tasks
+type conditionContext struct {
+	Env       string
+	ImageName string
+}
+
+type rawCondition struct {
+	Env   interface{} `yaml:"env,omitempty"`
+	Image interface{} `yaml:"image,omitempty"`
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+// condition is met when the werf environment matches one of env patterns and the image name matches one of image patterns,
+// the omitted field matches any value
+type condition struct {
+	Env   []string
+	Image []string
+}
+
+func newCondition(value interface{}, configSection interface{}, d *doc) (*condition, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("invalid condition: %s", err), configSection, d)
+	}
+
+	raw := &rawCondition{}
+	if err := yaml.UnmarshalStrict(data, raw); err != nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("invalid condition `%v`: `env: PATTERN || [ PATTERN, ... ]` and `image: PATTERN || [ PATTERN, ... ]` expected!", value), configSection, d)
+	}
+
+	if err := checkOverflow(raw.UnsupportedAttributes, configSection, d); err != nil {
+		return nil, err
+	}
+
+	c := &condition{}
+	if c.Env, err = InterfaceToStringArray(raw.Env, configSection, d); err != nil {
+		return nil, err
+	}
+
+	if c.Image, err = InterfaceToStringArray(raw.Image, configSection, d); err != nil {
+		return nil, err
+	}
+
+	if len(c.Env) == 0 && len(c.Image) == 0 {
+		return nil, newDetailedConfigError("empty condition: `env: PATTERN || [ PATTERN, ... ]` or `image: PATTERN || [ PATTERN, ... ]` required!", configSection, d)
+	}
+
+	for _, pattern := range append(append([]string{}, c.Env...), c.Image...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid condition pattern %q: %s", pattern, err), configSection, d)
+		}
+	}
+
+	return c, nil
+}
+
+func (c *condition) match(ctx conditionContext) bool {
+	return matchConditionPatterns(c.Env, ctx.Env) && matchConditionPatterns(c.Image, ctx.ImageName)
+}
+
+func matchConditionPatterns(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+type conditionEntry struct {
+	condition string
+	env       string
+	imageName string
+	expected  bool
+}
+
+var _ = DescribeTable("matching condition", func(e conditionEntry) {
+	var value interface{}
+	Ω(yaml.Unmarshal([]byte(e.condition), &value)).Should(Succeed())
+
+	c, err := newCondition(value, nil, &doc{Content: []byte(e.condition)})
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(c.match(conditionContext{Env: e.env, ImageName: e.imageName})).Should(Equal(e.expected))
+},
+	Entry("env matches", conditionEntry{"env: production\n", "production", "app", true}),
+	Entry("env does not match", conditionEntry{"env: production\n", "staging", "app", false}),
+	Entry("env matches one of patterns", conditionEntry{"env: [production, review-*]\n", "review-42", "app", true}),
+	Entry("env is not set", conditionEntry{"env: production\n", "", "app", false}),
+	Entry("image matches", conditionEntry{"image: [backend, worker]\n", "", "worker", true}),
+	Entry("env and image match", conditionEntry{"env: production\nimage: backend\n", "production", "backend", true}),
+	Entry("env matches and image does not", conditionEntry{"env: production\nimage: backend\n", "production", "frontend", false}))
+
+var _ = DescribeTable("parsing condition errors", func(condition string) {
+	var value interface{}
+	Ω(yaml.Unmarshal([]byte(condition), &value)).Should(Succeed())
+
+	_, err := newCondition(value, nil, &doc{Content: []byte(condition)})
+	Ω(err).Should(HaveOccurred())
+},
+	Entry("empty condition", "{}\n"),
+	Entry("unknown field", "environment: production\n"),
+	Entry("invalid pattern", "env: \"[\"\n"))
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	DependencyImportImageName   = "ImageName"
+	DependencyImportImageRepo   = "ImageRepo"
+	DependencyImportImageTag    = "ImageTag"
+	DependencyImportImageID     = "ImageID"
+	DependencyImportImageDigest = "ImageDigest"
+)
+
+var dependencyTargetRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Dependency makes the image to be built after the dependency image and injects properties of the built dependency image
+// into build args of the Dockerfile image or environment of the Stapel image
+type Dependency struct {
+	ImageName string
+	Before    string
+	After     string
+	Imports   []*DependencyImport
+
+	raw *rawDependency
+}
+
+type DependencyImport struct {
+	Type           string
+	TargetBuildArg string
+	TargetEnv      string
+
+	raw *rawDependencyImport
+}
+
+func (c *Dependency) validate(isDockerfileImage bool) error {
+	if c.ImageName == "" {
+		return newDetailedConfigError("image name `image: NAME` required for dependency!", c.raw, c.raw.doc)
+	}
+
+	if isDockerfileImage {
+		if c.Before != "" || c.After != "" {
+			return newDetailedConfigError("`before` and `after` are not supported for dependency of Dockerfile image: the dependency is used by the whole Dockerfile build!", c.raw, c.raw.doc)
+		}
+	} else {
+		if c.Before != "" && c.After != "" {
+			return newDetailedConfigError("specify only one stage using `before: install|setup` or `after: install|setup` for dependency!", c.raw, c.raw.doc)
+		} else if c.Before == "" && c.After == "" {
+			return newDetailedConfigError("stage is not specified with `before: install|setup` or `after: install|setup` for dependency!", c.raw, c.raw.doc)
+		} else if c.Before != "" && checkInvalidRelation(c.Before) {
+			return newDetailedConfigError(fmt.Sprintf("invalid stage `before: %s` for dependency: expected install or setup!", c.Before), c.raw, c.raw.doc)
+		} else if c.After != "" && checkInvalidRelation(c.After) {
+			return newDetailedConfigError(fmt.Sprintf("invalid stage `after: %s` for dependency: expected install or setup!", c.After), c.raw, c.raw.doc)
+		}
+	}
+
+	for _, imp := range c.Imports {
+		if err := imp.validate(isDockerfileImage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *DependencyImport) validate(isDockerfileImage bool) error {
+	switch c.Type {
+	case DependencyImportImageName, DependencyImportImageRepo, DependencyImportImageTag, DependencyImportImageID, DependencyImportImageDigest:
+	default:
+		return newDetailedConfigError(fmt.Sprintf("invalid `type: %s` for dependency import: expected %s, %s, %s, %s or %s!", c.Type, DependencyImportImageName, DependencyImportImageRepo, DependencyImportImageTag, DependencyImportImageID, DependencyImportImageDigest), c.raw, c.raw.rawDependency.doc)
+	}
+
+	var target, targetDirective string
+	if isDockerfileImage {
+		if c.TargetEnv != "" {
+			return newDetailedConfigError("`targetEnv` is not supported for dependency import of Dockerfile image: use `targetBuildArg: NAME`!", c.raw, c.raw.rawDependency.doc)
+		}
+
+		target, targetDirective = c.TargetBuildArg, "targetBuildArg"
+	} else {
+		if c.TargetBuildArg != "" {
+			return newDetailedConfigError("`targetBuildArg` is not supported for dependency import of Stapel image: use `targetEnv: NAME`!", c.raw, c.raw.rawDependency.doc)
+		}
+
+		target, targetDirective = c.TargetEnv, "targetEnv"
+	}
+
+	if target == "" {
+		return newDetailedConfigError(fmt.Sprintf("`%s: NAME` required for dependency import!", targetDirective), c.raw, c.raw.rawDependency.doc)
+	} else if !dependencyTargetRegexp.MatchString(target) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `%s: %s` for dependency import: letters, digits and underscores are allowed, the first character cannot be a digit!", targetDirective, target), c.raw, c.raw.rawDependency.doc)
+	}
+
+	return nil
+}
+
+// validateDependencies checks the dependencies of the image: the dependency should be an image defined in werf.yaml
+// and each build arg or env should be set by the only dependency import
+func validateDependencies(c *WerfConfig, imageName string, dependencies []*Dependency) error {
+	targets := map[string]bool{}
+	for _, dependency := range dependencies {
+		if dependency.ImageName == imageName {
+			return newDetailedConfigError("cannot use own image name as dependency!", dependency.raw, dependency.raw.doc)
+		}
+
+		if c.GetImage(dependency.ImageName) == nil {
+			if c.GetArtifact(dependency.ImageName) != nil {
+				return newDetailedConfigError(fmt.Sprintf("artifact `%s` cannot be used as dependency: use `import` directive to get files of the artifact!", dependency.ImageName), dependency.raw, dependency.raw.doc)
+			}
+
+			return newDetailedConfigError(fmt.Sprintf("no such image `%s`!", dependency.ImageName), dependency.raw, dependency.raw.doc)
+		}
+
+		for _, imp := range dependency.Imports {
+			target := imp.TargetBuildArg + imp.TargetEnv
+			if targets[target] {
+				return newDetailedConfigError(fmt.Sprintf("`%s` is already set by another dependency import!", target), imp.raw, dependency.raw.doc)
+			}
+			targets[target] = true
+		}
+	}
+
+	return nil
+}
@@ -16,6 +16,9 @@ type ImageFromDockerfile struct {
 	AddHost        []string
 	Network        string
 	SSH            string
+	Staged         bool
+	Platform       []string
+	Dependencies   []*Dependency
 
 	raw *rawImageFromDockerfile
 }
@@ -29,6 +32,10 @@ func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.In
 		return newDetailedConfigError("`contextAddFile: [PATH, ...]|PATH` each path should be relative to context!", nil, c.raw.doc)
 	}
 
+	if c.Staged && len(c.Dependencies) != 0 {
+		return newDetailedConfigError("`dependencies` are not supported for the staged Dockerfile image!", nil, c.raw.doc)
+	}
+
 	if len(c.ContextAddFile) != 0 {
 		for _, contextAddFile := range c.ContextAddFile {
 			if err := giterminismManager.Inspector().InspectConfigDockerfileContextAddFile(filepath.Join(c.Context, contextAddFile)); err != nil {
@@ -43,3 +50,7 @@ func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.In
 func (c *ImageFromDockerfile) GetName() string {
 	return c.Name
 }
+
+func (c *ImageFromDockerfile) GetPlatforms() []string {
+	return c.Platform
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ImageGraphNodeImage           = "image"
+	ImageGraphNodeDockerfileImage = "dockerfileImage"
+	ImageGraphNodeArtifact        = "artifact"
+
+	ImageGraphEdgeFromImage    = "fromImage"
+	ImageGraphEdgeFromArtifact = "fromArtifact"
+	ImageGraphEdgeImport       = "import"
+	ImageGraphEdgeDependency   = "dependency"
+)
+
+// ImageGraph is the dependency graph of images and artifacts, each edge goes from the image to its dependency
+type ImageGraph struct {
+	Nodes []*ImageGraphNode `json:"nodes"`
+	Edges []*ImageGraphEdge `json:"edges"`
+	// Sets are groups of images in the build order, images of the same set do not depend on each other and are built in parallel
+	Sets [][]string `json:"sets"`
+}
+
+type ImageGraphNode struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	image ImageInterface
+}
+
+type ImageGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+	// Stage of the image, which uses the dependency
+	Stage string `json:"stage"`
+	// DependencyStage is the stage of the dependency, which is used, the last stage is used when empty
+	DependencyStage string `json:"dependencyStage,omitempty"`
+
+	dependency ImageInterface
+}
+
+func (g *ImageGraph) GetNode(name string) *ImageGraphNode {
+	for _, node := range g.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+
+	return nil
+}
+
+func (g *ImageGraph) GetNodeEdges(name string) []*ImageGraphEdge {
+	var edges []*ImageGraphEdge
+	for _, edge := range g.Edges {
+		if edge.From == name {
+			edges = append(edges, edge)
+		}
+	}
+
+	return edges
+}
+
+// GetImageGraph returns the graph of the images and all their dependencies, the infinite loop in dependencies is an error
+func (c *WerfConfig) GetImageGraph(images []ImageInterface) (*ImageGraph, error) {
+	graph := &ImageGraph{}
+
+	queue := append([]ImageInterface{}, images...)
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if graph.GetNode(current.GetName()) != nil {
+			continue
+		}
+
+		graph.Nodes = append(graph.Nodes, &ImageGraphNode{Name: current.GetName(), Type: imageGraphNodeType(current), image: current})
+
+		for _, edge := range c.getImageGraphEdges(current) {
+			graph.Edges = append(graph.Edges, edge)
+			queue = append(queue, edge.dependency)
+		}
+	}
+
+	if err := graph.validateInfiniteLoop(); err != nil {
+		return nil, err
+	}
+
+	graph.Sets = graph.getSets()
+
+	return graph, nil
+}
+
+func (c *WerfConfig) getImageGraphEdges(interf ImageInterface) (edges []*ImageGraphEdge) {
+	addEdge := func(dependency ImageInterface, edgeType, stage, dependencyStage string) {
+		for _, edge := range edges {
+			if edge.To == dependency.GetName() && edge.Type == edgeType && edge.Stage == stage && edge.DependencyStage == dependencyStage {
+				return
+			}
+		}
+
+		edges = append(edges, &ImageGraphEdge{
+			From:            interf.GetName(),
+			To:              dependency.GetName(),
+			Type:            edgeType,
+			Stage:           stage,
+			DependencyStage: dependencyStage,
+			dependency:      dependency,
+		})
+	}
+
+	switch i := interf.(type) {
+	case *ImageFromDockerfile:
+		for _, dependency := range i.Dependencies {
+			addEdge(c.GetImage(dependency.ImageName), ImageGraphEdgeDependency, "dockerfile", "")
+		}
+	case StapelImageInterface:
+		if i.ImageBaseConfig().FromImageName != "" {
+			addEdge(c.GetImage(i.ImageBaseConfig().FromImageName), ImageGraphEdgeFromImage, "from", "")
+		}
+
+		if i.ImageBaseConfig().FromArtifactName != "" {
+			addEdge(c.GetArtifact(i.ImageBaseConfig().FromArtifactName), ImageGraphEdgeFromArtifact, "from", "")
+		}
+
+		for _, imp := range i.imports() {
+			stage := importsStageName(imp.Before, imp.After)
+			if imp.ImageName != "" {
+				addEdge(c.GetImage(imp.ImageName), ImageGraphEdgeImport, stage, imp.Stage)
+			} else if imp.ArtifactName != "" {
+				addEdge(c.GetArtifact(imp.ArtifactName), ImageGraphEdgeImport, stage, imp.Stage)
+			}
+		}
+
+		// properties of dependencies are set by the imports stages
+		for _, dependency := range i.ImageBaseConfig().Dependencies {
+			addEdge(c.GetImage(dependency.ImageName), ImageGraphEdgeDependency, importsStageName(dependency.Before, dependency.After), "")
+		}
+	}
+
+	return edges
+}
+
+func importsStageName(before, after string) string {
+	if before != "" {
+		return "importsBefore" + strings.Title(before)
+	}
+
+	return "importsAfter" + strings.Title(after)
+}
+
+func (g *ImageGraph) validateInfiniteLoop() error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := map[string]int{}
+	var path []string
+	var pathEdges []*ImageGraphEdge
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, edge := range g.GetNodeEdges(name) {
+			switch state[edge.To] {
+			case visiting:
+				for ind := range path {
+					if path[ind] == edge.To {
+						loop := append(append([]*ImageGraphEdge{}, pathEdges[ind:]...), edge)
+						return fmt.Errorf("infinite loop detected in images dependencies: %s", g.formatLoop(loop))
+					}
+				}
+			case visited:
+				continue
+			}
+
+			pathEdges = append(pathEdges, edge)
+			if err := visit(edge.To); err != nil {
+				return err
+			}
+			pathEdges = pathEdges[:len(pathEdges)-1]
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, node := range g.Nodes {
+		if state[node.Name] == 0 {
+			if err := visit(node.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatLoop formats the loop as `image "a" -[fromImage]-> artifact "b" -[import to importsAfterInstall]-> image "a"`
+func (g *ImageGraph) formatLoop(loop []*ImageGraphEdge) string {
+	formatNode := func(name string) string {
+		return fmt.Sprintf("%s %q", g.GetNode(name).Type, name)
+	}
+
+	parts := []string{formatNode(loop[0].From)}
+	for _, edge := range loop {
+		if edge.Type == ImageGraphEdgeImport || edge.Type == ImageGraphEdgeDependency {
+			parts = append(parts, fmt.Sprintf("-[%s to %s]->", edge.Type, edge.Stage), formatNode(edge.To))
+		} else {
+			parts = append(parts, fmt.Sprintf("-[%s]->", edge.Type), formatNode(edge.To))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// getSets groups images by levels: the image gets into the first set after the sets of all its dependencies
+func (g *ImageGraph) getSets() [][]string {
+	sets := [][]string{}
+	isHandled := map[string]bool{}
+
+	for len(isHandled) != len(g.Nodes) {
+		var set []string
+
+	outerLoop:
+		for _, node := range g.Nodes {
+			if isHandled[node.Name] {
+				continue
+			}
+
+			for _, edge := range g.GetNodeEdges(node.Name) {
+				if !isHandled[edge.To] {
+					continue outerLoop
+				}
+			}
+
+			set = append(set, node.Name)
+		}
+
+		for _, name := range set {
+			isHandled[name] = true
+		}
+
+		sets = append(sets, set)
+	}
+
+	return sets
+}
+
+func imageGraphNodeType(interf ImageInterface) string {
+	switch i := interf.(type) {
+	case StapelImageInterface:
+		if i.IsArtifact() {
+			return ImageGraphNodeArtifact
+		}
+		return ImageGraphNodeImage
+	default:
+		return ImageGraphNodeDockerfileImage
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("image graph", func() {
+	newImage := func(name, fromImage string, imports ...*Import) *StapelImage {
+		return &StapelImage{StapelImageBase: &StapelImageBase{Name: name, FromImageName: fromImage, Import: imports}}
+	}
+
+	newArtifact := func(name, fromImage string, imports ...*Import) *StapelImageArtifact {
+		return &StapelImageArtifact{StapelImageBase: &StapelImageBase{Name: name, FromImageName: fromImage, Import: imports}}
+	}
+
+	It("groups images by sets in the build order", func() {
+		werfConfig := &WerfConfig{
+			StapelImages: []*StapelImage{
+				newImage("app", "base", &Import{ArtifactName: "build", After: "install", Stage: "setup"}),
+				newImage("base", ""),
+				newImage("tools", ""),
+			},
+			Artifacts: []*StapelImageArtifact{newArtifact("build", "base")},
+		}
+
+		graph, err := werfConfig.GetImageGraph(werfConfig.GetAllImages())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(graph.Sets).Should(Equal([][]string{{"base", "tools"}, {"build"}, {"app"}}))
+		Ω(graph.GetNodeEdges("app")).Should(HaveLen(2))
+		Ω(graph.GetNodeEdges("app")[1].Stage).Should(Equal("importsAfterInstall"))
+		Ω(graph.GetNodeEdges("app")[1].DependencyStage).Should(Equal("setup"))
+	})
+
+	It("adds dependency edges", func() {
+		werfConfig := &WerfConfig{
+			StapelImages: []*StapelImage{newImage("base", "")},
+			ImagesFromDockerfile: []*ImageFromDockerfile{
+				{Name: "app", Dependencies: []*Dependency{{ImageName: "base"}}},
+			},
+		}
+
+		graph, err := werfConfig.GetImageGraph(werfConfig.GetAllImages())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(graph.Sets).Should(Equal([][]string{{"base"}, {"app"}}))
+		Ω(graph.GetNodeEdges("app")).Should(HaveLen(1))
+		Ω(graph.GetNodeEdges("app")[0].Type).Should(Equal(ImageGraphEdgeDependency))
+		Ω(graph.GetNodeEdges("app")[0].Stage).Should(Equal("dockerfile"))
+	})
+
+	It("returns image dependencies by graph edges", func() {
+		werfConfig := &WerfConfig{
+			StapelImages: []*StapelImage{
+				newImage("app", "base",
+					&Import{ArtifactName: "build", After: "install"},
+					&Import{ArtifactName: "build", Before: "setup"},
+				),
+				newImage("base", ""),
+			},
+			Artifacts: []*StapelImageArtifact{newArtifact("build", "")},
+		}
+
+		var names []string
+		for _, dep := range werfConfig.GetImageDependencies(werfConfig.GetImage("app")) {
+			names = append(names, dep.GetName())
+		}
+		Ω(names).Should(Equal([]string{"base", "build"}))
+		Ω(werfConfig.GetImageDependencies(werfConfig.GetImage("base"))).Should(BeEmpty())
+	})
+
+	It("reports infinite loop", func() {
+		werfConfig := &WerfConfig{
+			StapelImages: []*StapelImage{newImage("app", "", &Import{ArtifactName: "build", Before: "setup"})},
+			Artifacts:    []*StapelImageArtifact{newArtifact("build", "app")},
+		}
+
+		_, err := werfConfig.GetImageGraph(werfConfig.GetAllImages())
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(Equal(`infinite loop detected in images dependencies: image "app" -[import to importsBeforeSetup]-> artifact "build" -[fromImage]-> image "app"`))
+	})
+})
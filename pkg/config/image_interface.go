@@ -2,4 +2,6 @@ package config
 
 type ImageInterface interface {
 	GetName() string
+	// GetPlatforms returns target platforms of the image, the image is built for the host platform when the list is empty
+	GetPlatforms() []string
 }
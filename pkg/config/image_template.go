@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	imageTemplateDirective = "template"
+	extendsDirective       = "extends"
+)
+
+// concatenatedTemplateSections are lists, which are appended to the inherited ones instead of replacing them
+var concatenatedTemplateSections = []string{"git", "mount", "secrets", "import", "dependencies", "contextAddFile", "addHost"}
+
+// imageTemplate is an abstract image section, which is not built but could be extended by images and other templates
+type imageTemplate struct {
+	Name string
+	Raw  map[string]interface{}
+
+	doc *doc
+}
+
+func isImageTemplateDoc(h map[string]interface{}) bool {
+	_, ok := h[imageTemplateDirective]
+	return ok
+}
+
+func newImageTemplate(raw map[string]interface{}, d *doc) (*imageTemplate, error) {
+	name, ok := raw[imageTemplateDirective].(string)
+	if !ok || name == "" {
+		return nil, newDetailedConfigError("template name required: `template: NAME`!", nil, d)
+	}
+
+	if isImageDoc(raw) {
+		return nil, newDetailedConfigError("template section cannot define `image` or `artifact`: templates are not built, use `extends: NAME` in the image section instead!", nil, d)
+	}
+
+	return &imageTemplate{Name: name, Raw: raw, doc: d}, nil
+}
+
+type imageTemplateResolver struct {
+	templates map[string]*imageTemplate
+	resolved  map[string]map[string]interface{}
+	resolving []string
+}
+
+func newImageTemplateResolver(templates []*imageTemplate) (*imageTemplateResolver, error) {
+	r := &imageTemplateResolver{
+		templates: map[string]*imageTemplate{},
+		resolved:  map[string]map[string]interface{}{},
+	}
+
+	for _, t := range templates {
+		if existing, ok := r.templates[t.Name]; ok {
+			return nil, newConfigError(fmt.Sprintf("conflict between templates names!\n\n%s%s\n", dumpConfigDoc(existing.doc), dumpConfigDoc(t.doc)))
+		}
+		r.templates[t.Name] = t
+	}
+
+	return r, nil
+}
+
+// resolveImageDoc merges templates into the image section with the extends directive.
+// The resulting section is returned with the new doc, which content is the resolved section and line is the line of the original section.
+func (r *imageTemplateResolver) resolveImageDoc(raw map[string]interface{}, d *doc) (map[string]interface{}, *doc, error) {
+	if _, ok := raw[extendsDirective]; !ok {
+		return raw, d, nil
+	}
+
+	names, err := getExtendsTemplatesNames(raw, d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := r.extend(raw, d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := yaml.Marshal(res)
+	if err != nil {
+		return nil, nil, newYamlUnmarshalError(err, d)
+	}
+
+	return res, &doc{
+		Content:        content,
+		Line:           d.Line,
+		RenderFilePath: fmt.Sprintf("%s (section on line %d extended with templates: %s)", d.RenderFilePath, d.Line+1, strings.Join(names, ", ")),
+	}, nil
+}
+
+func (r *imageTemplateResolver) extend(raw map[string]interface{}, d *doc) (map[string]interface{}, error) {
+	names, err := getExtendsTemplatesNames(raw, d)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]interface{}{}
+	for _, name := range names {
+		templateRaw, err := r.resolveTemplate(name, d)
+		if err != nil {
+			return nil, err
+		}
+
+		res = mergeTemplateSection(res, templateRaw)
+	}
+
+	res = mergeTemplateSection(res, raw)
+	delete(res, imageTemplateDirective)
+	delete(res, extendsDirective)
+
+	return res, nil
+}
+
+func (r *imageTemplateResolver) resolveTemplate(name string, d *doc) (map[string]interface{}, error) {
+	if res, ok := r.resolved[name]; ok {
+		return res, nil
+	}
+
+	for ind, resolvingName := range r.resolving {
+		if resolvingName == name {
+			loop := append(append([]string{}, r.resolving[ind:]...), name)
+			return nil, newDetailedConfigError(fmt.Sprintf("infinite loop detected in templates extends: %s!", strings.Join(loop, " -> ")), nil, d)
+		}
+	}
+
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, newDetailedConfigError(fmt.Sprintf("template %q is not defined!", name), nil, d)
+	}
+
+	r.resolving = append(r.resolving, name)
+	res, err := r.extend(t.Raw, t.doc)
+	r.resolving = r.resolving[:len(r.resolving)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	r.resolved[name] = res
+
+	return res, nil
+}
+
+func getExtendsTemplatesNames(raw map[string]interface{}, d *doc) ([]string, error) {
+	switch value := raw[extendsDirective].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		return InterfaceToStringArray(value, nil, d)
+	default:
+		return nil, newDetailedConfigError("`extends: NAME || [ NAME, ... ]` template name or list of templates names required!", nil, d)
+	}
+}
+
+// mergeTemplateSection returns the new section, where mappings are merged recursively with the override values priority,
+// concatenatedTemplateSections are appended to the base ones and other values are replaced
+func mergeTemplateSection(base, override map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, value := range base {
+		res[key] = value
+	}
+
+	for key, value := range override {
+		baseValue, exist := res[key]
+		switch {
+		case !exist:
+			res[key] = value
+		case isConcatenatedTemplateSection(key):
+			var list []interface{}
+			list = append(list, templateSectionToList(baseValue)...)
+			res[key] = append(list, templateSectionToList(value)...)
+		default:
+			res[key] = mergeTemplateValue(baseValue, value)
+		}
+	}
+
+	return res
+}
+
+func mergeTemplateValue(base, override interface{}) interface{} {
+	baseMap, baseOk := base.(map[interface{}]interface{})
+	overrideMap, overrideOk := override.(map[interface{}]interface{})
+	if !baseOk || !overrideOk {
+		return override
+	}
+
+	res := map[interface{}]interface{}{}
+	for key, value := range baseMap {
+		res[key] = value
+	}
+
+	for key, value := range overrideMap {
+		res[key] = mergeTemplateValue(res[key], value)
+	}
+
+	return res
+}
+
+func isConcatenatedTemplateSection(key string) bool {
+	for _, section := range concatenatedTemplateSections {
+		if section == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func templateSectionToList(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+type imageTemplateEntry struct {
+	templates []string
+	image     string
+	expected  string
+}
+
+var _ = DescribeTable("resolving image templates", func(e imageTemplateEntry) {
+	var templates []*imageTemplate
+	for _, templateContent := range e.templates {
+		t, err := newImageTemplate(unmarshalTestSection(templateContent), &doc{Content: []byte(templateContent)})
+		Ω(err).ShouldNot(HaveOccurred())
+		templates = append(templates, t)
+	}
+
+	resolver, err := newImageTemplateResolver(templates)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	res, _, err := resolver.resolveImageDoc(unmarshalTestSection(e.image), &doc{Content: []byte(e.image)})
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(res).Should(Equal(unmarshalTestSection(e.expected)))
+},
+	Entry("without extends", imageTemplateEntry{
+		[]string{"template: base\nfrom: alpine\n"},
+		"image: app\nfrom: ubuntu\n",
+		"image: app\nfrom: ubuntu\n",
+	}),
+	Entry("scalars are replaced", imageTemplateEntry{
+		[]string{"template: base\nfrom: alpine\nfromLatest: true\n"},
+		"image: app\nextends: base\nfrom: ubuntu\n",
+		"image: app\nfrom: ubuntu\nfromLatest: true\n",
+	}),
+	Entry("mappings are merged and lists inside them are replaced", imageTemplateEntry{
+		[]string{"template: base\nshell:\n  install: [a]\n  setup: [b]\ndocker:\n  ENV:\n    A: a\n"},
+		"image: app\nextends: base\nshell:\n  install: [c]\ndocker:\n  ENV:\n    B: b\n",
+		"image: app\nshell:\n  install: [c]\n  setup: [b]\ndocker:\n  ENV:\n    A: a\n    B: b\n",
+	}),
+	Entry("git, mount and import are concatenated", imageTemplateEntry{
+		[]string{"template: base\ngit:\n- add: /a\n"},
+		"image: app\nextends: base\ngit:\n- add: /b\n",
+		"image: app\ngit:\n- add: /a\n- add: /b\n",
+	}),
+	Entry("templates are applied in order", imageTemplateEntry{
+		[]string{"template: base\nfrom: alpine\n", "template: go\nextends: base\nfrom: golang\n", "template: cache\nfromCacheVersion: 1\n"},
+		"image: app\nextends: [go, cache]\n",
+		"image: app\nfrom: golang\nfromCacheVersion: 1\n",
+	}))
+
+var _ = DescribeTable("resolving image templates errors", func(templatesContents []string, image string, expectedError string) {
+	var templates []*imageTemplate
+	for _, templateContent := range templatesContents {
+		t, err := newImageTemplate(unmarshalTestSection(templateContent), &doc{Content: []byte(templateContent)})
+		Ω(err).ShouldNot(HaveOccurred())
+		templates = append(templates, t)
+	}
+
+	resolver, err := newImageTemplateResolver(templates)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	_, _, err = resolver.resolveImageDoc(unmarshalTestSection(image), &doc{Content: []byte(image)})
+	Ω(err).Should(HaveOccurred())
+	Ω(err.Error()).Should(ContainSubstring(expectedError))
+},
+	Entry("not defined template", []string{}, "image: app\nextends: base\n", `template "base" is not defined!`),
+	Entry("infinite loop", []string{"template: a\nextends: b\n", "template: b\nextends: a\n"}, "image: app\nextends: a\n", "infinite loop detected in templates extends: a -> b -> a!"))
+
+var _ = Describe("resolved image doc", func() {
+	It("keeps the line of the original section", func() {
+		template := "template: base\nfrom: alpine\n"
+		t, err := newImageTemplate(unmarshalTestSection(template), &doc{Content: []byte(template), Line: 2, RenderFilePath: "werf.yaml"})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		resolver, err := newImageTemplateResolver([]*imageTemplate{t})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		image := "image: app\nextends: base\n"
+		_, d, err := resolver.resolveImageDoc(unmarshalTestSection(image), &doc{Content: []byte(image), Line: 10, RenderFilePath: "werf.yaml"})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(d.Line).Should(Equal(10))
+		Ω(d.RenderFilePath).Should(Equal("werf.yaml (section on line 11 extended with templates: base)"))
+		Ω(dumpConfigDoc(d)).Should(ContainSubstring("    11  "))
+	})
+})
+
+func unmarshalTestSection(content string) map[string]interface{} {
+	var res map[string]interface{}
+	Ω(yaml.UnmarshalStrict([]byte(content), &res)).Should(Succeed())
+	return res
+}
@@ -6,4 +6,5 @@ type Meta struct {
 	Deploy        MetaDeploy
 	Cleanup       MetaCleanup
 	GitWorktree   MetaGitWorktree
+	Include       []MetaInclude
 }
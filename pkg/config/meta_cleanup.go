@@ -5,10 +5,79 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	MetaCleanupStrategyGitHistory = "gitHistory"
+	MetaCleanupStrategyAge        = "age"
 )
 
 type MetaCleanup struct {
-	KeepPolicies []*MetaCleanupKeepPolicy
+	Strategy            *string
+	KeepPolicies        []*MetaCleanupKeepPolicy
+	StagesPerImage      MetaCleanupStagesPerImage
+	KubernetesResources []*MetaCleanupKubernetesResource
+	SizeQuota           *uint64
+}
+
+func (obj MetaCleanup) GetStrategy() string {
+	if obj.Strategy != nil {
+		return *obj.Strategy
+	} else {
+		return MetaCleanupStrategyGitHistory
+	}
+}
+
+func (obj MetaCleanup) SizeQuotaString() string {
+	if obj.SizeQuota == nil {
+		return ""
+	}
+
+	return humanize.IBytes(*obj.SizeQuota)
+}
+
+// MetaCleanupStagesPerImage selects the stages of the image to keep by the age-based cleanup:
+// the last n built stages and the stages built within the period are kept
+type MetaCleanupStagesPerImage struct {
+	Last *int
+	In   *time.Duration
+}
+
+func (c *MetaCleanupStagesPerImage) IsStageKept(ind int, createdAt time.Time) bool {
+	if c.Last != nil && ind < *c.Last {
+		return true
+	}
+
+	if c.In != nil && time.Since(createdAt) <= *c.In {
+		return true
+	}
+
+	return false
+}
+
+func (c *MetaCleanupStagesPerImage) String() string {
+	var parts []string
+
+	if c.In != nil {
+		parts = append(parts, fmt.Sprintf("in=%s", c.In.String()))
+	}
+
+	if c.Last != nil {
+		parts = append(parts, fmt.Sprintf("last=%d", *c.Last))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// MetaCleanupKubernetesResource is the resource, which is scanned for images used in Kubernetes in addition
+// to the built-in workload kinds, the images are selected by JSONPath templates (e.g. custom resources of operators)
+type MetaCleanupKubernetesResource struct {
+	Group      string
+	Version    string
+	Resource   string
+	ImagePaths []string
 }
 
 type MetaCleanupKeepPolicy struct {
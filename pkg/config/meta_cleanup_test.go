@@ -0,0 +1,77 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+type stagesPerImageEntry struct {
+	last     *int
+	in       *time.Duration
+	ind      int
+	age      time.Duration
+	expected bool
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+var _ = DescribeTable("stages per image", func(e stagesPerImageEntry) {
+	stagesPerImage := MetaCleanupStagesPerImage{Last: e.last, In: e.in}
+	Ω(stagesPerImage.IsStageKept(e.ind, time.Now().Add(-e.age))).Should(Equal(e.expected))
+},
+	Entry("within last", stagesPerImageEntry{last: intPtr(2), ind: 1, age: 48 * time.Hour, expected: true}),
+	Entry("out of last", stagesPerImageEntry{last: intPtr(2), ind: 2, expected: false}),
+	Entry("within period", stagesPerImageEntry{in: durationPtr(24 * time.Hour), ind: 10, age: time.Hour, expected: true}),
+	Entry("out of period", stagesPerImageEntry{in: durationPtr(24 * time.Hour), ind: 0, age: 48 * time.Hour, expected: false}),
+	Entry("out of last, but within period", stagesPerImageEntry{last: intPtr(1), in: durationPtr(24 * time.Hour), ind: 3, age: time.Hour, expected: true}),
+	Entry("within last, but out of period", stagesPerImageEntry{last: intPtr(1), in: durationPtr(24 * time.Hour), ind: 0, age: 48 * time.Hour, expected: true}),
+	Entry("no last stages with period", stagesPerImageEntry{last: intPtr(0), in: durationPtr(24 * time.Hour), ind: 0, age: 48 * time.Hour, expected: false}))
+
+func unmarshalTestMetaCleanupSection(content string, section interface{}) error {
+	parentStack = util.NewStack()
+	parentStack.Push(&rawMetaCleanup{rawMeta: &rawMeta{doc: &doc{Content: []byte(content)}}})
+	defer parentStack.Pop()
+
+	return yaml.UnmarshalStrict([]byte(content), section)
+}
+
+var _ = DescribeTable("stages per image validation", func(content string, expectedErr bool) {
+	err := unmarshalTestMetaCleanupSection(content, &rawMetaCleanupStagesPerImage{})
+	if expectedErr {
+		Ω(err).Should(HaveOccurred())
+	} else {
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+},
+	Entry("last", "last: 1\n", false),
+	Entry("period", "in: 168h\n", false),
+	Entry("no last stages with period", "last: 0\nin: 168h\n", false),
+	Entry("nothing is kept", "last: 0\n", true),
+	Entry("negative last", "last: -1\nin: 168h\n", true),
+	Entry("neither last nor period", "{}\n", true))
+
+var _ = DescribeTable("kubernetes resource validation", func(content string, expectedErr bool) {
+	err := unmarshalTestMetaCleanupSection(content, &rawMetaCleanupKubernetesResource{})
+	if expectedErr {
+		Ω(err).Should(HaveOccurred())
+	} else {
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+},
+	Entry("custom resource", "group: argoproj.io\nversion: v1alpha1\nresource: rollouts\nimagePaths:\n- \"{.spec.template.spec.containers[*].image}\"\n", false),
+	Entry("core resource", "version: v1\nresource: pods\nimagePaths:\n- \"{.spec.containers[*].image}\"\n", false),
+	Entry("no image paths", "version: v1\nresource: pods\n", true),
+	Entry("not a template", "version: v1\nresource: pods\nimagePaths:\n- .spec.image\n", true),
+	Entry("unclosed bracket", "version: v1\nresource: pods\nimagePaths:\n- \"{.spec.containers[*.image}\"\n", true),
+	Entry("unterminated string", "version: v1\nresource: pods\nimagePaths:\n- \"{.metadata.annotations['image}\"\n", true))
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// MetaInclude is the werf config file included from the project directory (the path could be a glob)
+// or from the git repository
+type MetaInclude struct {
+	Path   string
+	Git    string
+	Branch string
+	Tag    string
+	Commit string
+}
+
+// readFiles calls includeFunc for each included file with the source, which describes the file for the user
+func (obj MetaInclude) readFiles(ctx context.Context, giterminismManager giterminism_manager.Interface, includeFunc func(source string, data []byte) error) error {
+	if obj.Git == "" {
+		return giterminismManager.FileReader().ReadConfigIncludeFiles(ctx, obj.Path, func(relPath string, data []byte, err error) error {
+			if err != nil {
+				return err
+			}
+
+			return includeFunc(relPath, data)
+		})
+	}
+
+	commit, data, err := obj.readGitFile(ctx, giterminismManager)
+	if err != nil {
+		return fmt.Errorf("unable to read werf config include %q from git repo %s: %s", obj.Path, obj.Git, err)
+	}
+
+	return includeFunc(fmt.Sprintf("%s@%s:%s", obj.Git, commit, obj.Path), data)
+}
+
+func (obj MetaInclude) readGitFile(ctx context.Context, giterminismManager giterminism_manager.Interface) (string, []byte, error) {
+	switch {
+	case obj.Branch != "":
+		if err := giterminismManager.Inspector().InspectConfigIncludeGitBranch(); err != nil {
+			return "", nil, err
+		}
+	case obj.Tag != "":
+		if err := giterminismManager.Inspector().InspectConfigIncludeGitTag(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	remoteGitRepo, err := git_repo.OpenRemoteRepo(getRepositoryID(obj.Git), obj.Git)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Refreshing %s repository", remoteGitRepo.GetName())).
+		DoError(func() error {
+			return remoteGitRepo.CloneAndFetch(ctx)
+		}); err != nil {
+		return "", nil, err
+	}
+
+	var commit string
+	switch {
+	case obj.Commit != "":
+		commit = obj.Commit
+	case obj.Tag != "":
+		commit, err = remoteGitRepo.TagCommit(ctx, obj.Tag)
+	default:
+		commit, err = remoteGitRepo.LatestBranchCommit(ctx, obj.Branch)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := remoteGitRepo.ReadCommitFile(ctx, commit, obj.Path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return commit, data, nil
+}
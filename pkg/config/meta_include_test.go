@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"text/template"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+var errTestGiterminism = errors.New("not allowed by giterminism")
+
+type fakeIncludeFileReader struct {
+	giterminism_manager.FileReader
+
+	files map[string]string
+}
+
+func (r fakeIncludeFileReader) ReadConfigIncludeFiles(_ context.Context, glob string, includeFunc func(relPath string, data []byte, err error) error) error {
+	var relPaths []string
+	for relPath := range r.files {
+		if matched, err := path.Match(glob, relPath); err != nil {
+			return err
+		} else if matched {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	sort.Strings(relPaths)
+
+	for _, relPath := range relPaths {
+		if err := includeFunc(relPath, []byte(r.files[relPath]), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type fakeIncludeInspector struct {
+	giterminism_manager.Inspector
+}
+
+func (i fakeIncludeInspector) InspectConfigIncludeGitBranch() error {
+	return errTestGiterminism
+}
+
+func (i fakeIncludeInspector) InspectConfigIncludeGitTag() error {
+	return errTestGiterminism
+}
+
+type fakeIncludeGiterminismManager struct {
+	giterminism_manager.Interface
+
+	fileReader fakeIncludeFileReader
+}
+
+func (m fakeIncludeGiterminismManager) FileReader() giterminism_manager.FileReader {
+	return m.fileReader
+}
+
+func (m fakeIncludeGiterminismManager) Inspector() giterminism_manager.Inspector {
+	return fakeIncludeInspector{}
+}
+
+var _ = Describe("werf config includes", func() {
+	const meta = "configVersion: 1\nproject: test\n"
+
+	renderWithLocalIncludeRelPaths := func(config string, files map[string]string) (string, []string, error) {
+		tmpl := template.New("werfConfig")
+		Ω(addTemplate(tmpl, ".werf/_base.tmpl", `{{ define "base" }}from: alpine:{{ .Env }}{{ end }}`)).Should(Succeed())
+
+		templateData := map[string]interface{}{"Env": "production"}
+		giterminismManager := fakeIncludeGiterminismManager{fileReader: fakeIncludeFileReader{files: files}}
+
+		return renderWerfConfigIncludes(context.Background(), tmpl, templateData, giterminismManager, config, "werf.yaml")
+	}
+
+	render := func(config string, files map[string]string) (string, error) {
+		res, _, err := renderWithLocalIncludeRelPaths(config, files)
+		return res, err
+	}
+
+	It("keeps the config without includes", func() {
+		config := meta + "---\nimage: app\nfrom: alpine\n"
+
+		res, err := render(config, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(res).Should(Equal(config))
+	})
+
+	It("renders the included files with the werf config templates and appends them in order", func() {
+		config := meta + "include:\n- path: services/*/werf.yaml\n---\nimage: app\nfrom: alpine"
+
+		res, err := render(config, map[string]string{
+			"services/backend/werf.yaml":  "image: backend\n{{ template \"base\" . }}\n",
+			"services/frontend/werf.yaml": "image: frontend\nfrom: node\n",
+			"other/werf.yaml":             "image: other\nfrom: alpine\n",
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(res).Should(Equal(config + "\n" +
+			"---\n# include: services/backend/werf.yaml\nimage: backend\nfrom: alpine:production\n" +
+			"---\n# include: services/frontend/werf.yaml\nimage: frontend\nfrom: node\n"))
+	})
+
+	It("returns the paths of the files included from the project directory", func() {
+		config := meta + "include:\n- path: services/*/werf.yaml\n- path: shared.yaml\n---\nimage: app\nfrom: alpine\n"
+
+		_, localIncludeRelPaths, err := renderWithLocalIncludeRelPaths(config, map[string]string{
+			"services/backend/werf.yaml":  "image: backend\nfrom: alpine\n",
+			"services/frontend/werf.yaml": "image: frontend\nfrom: node\n",
+			"shared.yaml":                 "image: shared\nfrom: alpine\n",
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(localIncludeRelPaths).Should(Equal([]string{"services/backend/werf.yaml", "services/frontend/werf.yaml", "shared.yaml"}))
+	})
+
+	It("fails if the included file defines the meta config section", func() {
+		_, err := render(meta+"include:\n- path: shared.yaml\n", map[string]string{"shared.yaml": meta})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("meta config section cannot be defined in werf config include shared.yaml"))
+	})
+
+	It("fails if the included file cannot be rendered", func() {
+		_, err := render(meta+"include:\n- path: shared.yaml\n", map[string]string{"shared.yaml": "image: {{ template \"unknown\" }}\n"})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("unable to render werf config include shared.yaml"))
+	})
+
+	It("inspects the git tag by giterminism", func() {
+		_, err := render(meta+"include:\n- git: https://example.com/shared.git\n  tag: v1.0.0\n  path: werf.yaml\n", nil)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(errTestGiterminism.Error()))
+	})
+
+	It("inspects the git branch by giterminism", func() {
+		_, err := render(meta+"include:\n- git: https://example.com/shared.git\n  branch: main\n  path: werf.yaml\n", nil)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(errTestGiterminism.Error()))
+	})
+
+	It("fails if the commit is not the full commit hash", func() {
+		_, err := render(meta+"include:\n- git: https://example.com/shared.git\n  commit: 5d14fa0\n  path: werf.yaml\n", nil)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("the full 40-character commit hash expected"))
+	})
+})
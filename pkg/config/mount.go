@@ -2,18 +2,41 @@ package config
 
 import (
 	"fmt"
+	"path"
+	"regexp"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/slug"
 )
 
+const (
+	CacheMountSharingShared  = "shared"
+	CacheMountSharingLocked  = "locked"
+	CacheMountSharingPrivate = "private"
+
+	CacheMountScopeProject = "project"
+	CacheMountScopeImage   = "image"
+)
+
+var cacheMountIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
 type Mount struct {
 	To   string
 	From string
 	Type string
 
+	// Id, Sharing and Scope are defined only for the cache mount
+	Id      string
+	Sharing string
+	Scope   string
+
 	raw *rawMount
 }
 
+func defaultCacheMountId(to string) string {
+	return slug.LimitedSlug(path.Clean(path.Join("/", to))[1:], slug.DefaultSlugMaxSize)
+}
+
 func (c *Mount) validate(giterminismManager giterminism_manager.Interface) error {
 	var err error
 	if c.raw.FromPath != "" {
@@ -30,14 +53,40 @@ func (c *Mount) validate(giterminismManager giterminism_manager.Interface) error
 		return newDetailedConfigError(fmt.Sprintf("cannot use `from: %s` and `fromPath: %s` at the same time for mount!", c.raw.From, c.raw.FromPath), c, c.raw.rawStapelImage.doc)
 	}
 
+	if c.Type != "cache" && (c.raw.Id != "" || c.raw.Sharing != "" || c.raw.Scope != "") {
+		return newDetailedConfigError("`id`, `sharing` and `scope` can be used only with `from: cache` for mount!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
 	if c.To == "" || !isAbsolutePath(c.To) {
 		return newDetailedConfigError("`to: PATH` absolute path required for mount!", c.raw, c.raw.rawStapelImage.doc)
 	} else if c.Type == "custom_dir" {
 		if c.From == "" {
 			return newDetailedConfigError("`fromPath: PATH` absolute or relative path required for mount!", c.raw, c.raw.rawStapelImage.doc)
 		}
+	} else if c.Type == "cache" {
+		return c.validateCache()
 	} else if c.Type != "tmp_dir" && c.Type != "build_dir" {
-		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir` or `build_dir`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir`, `build_dir` or `cache`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
 	}
 	return nil
 }
+
+func (c *Mount) validateCache() error {
+	if !cacheMountIdRegexp.MatchString(c.Id) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `id: %s` for cache mount: alphanumeric characters, dots, dashes and underscores are allowed!", c.Id), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	switch c.Sharing {
+	case CacheMountSharingShared, CacheMountSharingLocked, CacheMountSharingPrivate:
+	default:
+		return newDetailedConfigError(fmt.Sprintf("invalid `sharing: %s` for cache mount: expected `shared`, `locked` or `private`!", c.Sharing), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	switch c.Scope {
+	case CacheMountScopeProject, CacheMountScopeImage:
+	default:
+		return newDetailedConfigError(fmt.Sprintf("invalid `scope: %s` for cache mount: expected `project` or `image`!", c.Scope), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	return nil
+}
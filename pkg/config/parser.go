@@ -8,9 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/go-git/go-git/v5/plumbing/transport"
@@ -37,7 +39,7 @@ func RenderWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfCo
 	}
 
 	if len(imagesToProcess) == 0 {
-		werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
+		werfConfigRenderContent, _, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
 		if err != nil {
 			return err
 		}
@@ -67,7 +69,7 @@ func RenderWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfCo
 }
 
 func GetWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) (*WerfConfig, error) {
-	werfConfigRenderContent, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
+	werfConfigRenderContent, localIncludeRelPaths, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +93,56 @@ func GetWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfi
 		return nil, err
 	}
 
+	werfConfig, err := getWerfConfigFromDocs(ctx, docs, giterminismManager, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+	werfConfig.LocalIncludeRelPaths = localIncludeRelPaths
+
+	return werfConfig, nil
+}
+
+// ValidateWerfConfig validates all config sections with the werf.yaml schema and returns all found errors.
+// If there are no schema errors, the config is parsed and the parsing error is returned.
+func ValidateWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, opts WerfConfigOptions) ([]*ValidationError, error) {
+	werfConfigRenderContent, _, err := renderWerfConfigYaml(ctx, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath, giterminismManager, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	werfConfigRenderPath, err := tmp_manager.CreateWerfConfigRender(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.LogRenderedFilePath {
+		logboek.Context(ctx).LogF("Using werf config render file: %s\n", werfConfigRenderPath)
+	}
+
+	err = writeWerfConfigRender(werfConfigRenderContent, werfConfigRenderPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write rendered config to %s: %s", werfConfigRenderPath, err)
+	}
+
+	docs, err := splitByDocs(werfConfigRenderContent, werfConfigRenderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	validationErrors, err := validateDocsWithSchema(docs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(validationErrors) != 0 {
+		return validationErrors, nil
+	}
+
+	_, err = getWerfConfigFromDocs(ctx, docs, giterminismManager, opts.Env)
+	return nil, err
+}
+
+func getWerfConfigFromDocs(ctx context.Context, docs []*doc, giterminismManager giterminism_manager.Interface, env string) (*WerfConfig, error) {
 	meta, rawStapelImages, rawImagesFromDockerfile, err := splitByMetaAndRawImages(docs)
 	if err != nil {
 		return nil, err
@@ -118,7 +170,7 @@ func GetWerfConfig(ctx context.Context, customWerfConfigRelPath, customWerfConfi
 		return nil, fmt.Errorf(format, defaultProjectName)
 	}
 
-	werfConfig, err := prepareWerfConfig(giterminismManager, rawStapelImages, rawImagesFromDockerfile, meta)
+	werfConfig, err := prepareWerfConfig(giterminismManager, rawStapelImages, rawImagesFromDockerfile, meta, env)
 	if err != nil {
 		return nil, err
 	}
@@ -185,16 +237,16 @@ func splitByDocs(werfConfigRenderContent string, werfConfigRenderPath string) ([
 	return docs, nil
 }
 
-func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, env string) (string, error) {
+func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWerfConfigTemplatesDirRelPath string, giterminismManager giterminism_manager.Interface, env string) (string, []string, error) {
 	tmpl := template.New("werfConfig")
 	tmpl.Funcs(funcMap(tmpl, giterminismManager))
 
 	if err := parseWerfConfigTemplatesDir(ctx, tmpl, giterminismManager, customWerfConfigTemplatesDirRelPath); err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	if err := parseWerfConfig(ctx, tmpl, giterminismManager, customWerfConfigRelPath); err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	templateData := make(map[string]interface{})
@@ -202,11 +254,93 @@ func renderWerfConfigYaml(ctx context.Context, customWerfConfigRelPath, customWe
 		ctx:                ctx,
 		giterminismManager: giterminismManager,
 	}
+	templateData["Git"] = gitInfo{
+		ctx:                ctx,
+		giterminismManager: giterminismManager,
+	}
 	templateData["Env"] = env
 
 	config, err := executeTemplate(tmpl, "werfConfig", templateData)
+	if err != nil {
+		return "", nil, err
+	}
 
-	return config, err
+	werfConfigRelPath := customWerfConfigRelPath
+	if werfConfigRelPath == "" {
+		werfConfigRelPath = "werf.yaml"
+	}
+
+	return renderWerfConfigIncludes(ctx, tmpl, templateData, giterminismManager, config, werfConfigRelPath)
+}
+
+// renderWerfConfigIncludes renders the files included by the meta config section and appends their sections to the werf config,
+// the paths of the files included from the project directory are returned
+func renderWerfConfigIncludes(ctx context.Context, tmpl *template.Template, templateData map[string]interface{}, giterminismManager giterminism_manager.Interface, config, werfConfigRelPath string) (string, []string, error) {
+	docs, err := splitByDocs(config, werfConfigRelPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var meta *Meta
+	for _, doc := range docs {
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(doc.Content, &raw); err != nil {
+			return "", nil, newYamlUnmarshalError(err, doc)
+		}
+
+		if isMetaDoc(raw) {
+			parentStack = util.NewStack()
+			rawMeta := &rawMeta{doc: doc}
+			if err := yaml.UnmarshalStrict(doc.Content, &rawMeta); err != nil {
+				return "", nil, newYamlUnmarshalError(err, doc)
+			}
+
+			meta = rawMeta.toMeta()
+			break
+		}
+	}
+
+	if meta == nil || len(meta.Include) == 0 {
+		return config, nil, nil
+	}
+
+	result := config
+	var localIncludeRelPaths []string
+	for _, include := range meta.Include {
+		if err := include.readFiles(ctx, giterminismManager, func(source string, data []byte) error {
+			if include.Git == "" {
+				localIncludeRelPaths = append(localIncludeRelPaths, source)
+			}
+
+			templateName := fmt.Sprintf("include:%s", source)
+			if err := addTemplate(tmpl, templateName, string(data)); err != nil {
+				return fmt.Errorf("unable to parse werf config include %s: %s", source, err)
+			}
+
+			content, err := executeTemplate(tmpl, templateName, templateData)
+			if err != nil {
+				return fmt.Errorf("unable to render werf config include %s: %s", source, err)
+			}
+
+			for _, docContent := range splitContent([]byte(content)) {
+				var raw map[string]interface{}
+				if err := yaml.Unmarshal(docContent, &raw); err == nil && isMetaDoc(raw) {
+					return fmt.Errorf("meta config section cannot be defined in werf config include %s", source)
+				}
+			}
+
+			if !strings.HasSuffix(result, "\n") {
+				result += "\n"
+			}
+			result += fmt.Sprintf("---\n# include: %s\n%s", source, content)
+
+			return nil
+		}); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return result, localIncludeRelPaths, nil
 }
 
 func parseWerfConfig(ctx context.Context, tmpl *template.Template, giterminismManager giterminism_manager.Interface, relWerfConfigPath string) error {
@@ -340,6 +474,86 @@ func (f files) Glob(pattern string) map[string]interface{} {
 	}
 }
 
+func (f files) Exists(relPath string) bool {
+	if exist, err := f.giterminismManager.FileReader().ConfigGoTemplateFilesExists(f.ctx, relPath); err != nil {
+		panic(err.Error())
+	} else {
+		return exist
+	}
+}
+
+// Lines returns the lines of the file without the trailing line break
+func (f files) Lines(relPath string) []string {
+	content := strings.TrimSuffix(f.Get(relPath), "\n")
+	if content == "" {
+		return []string{}
+	}
+
+	return strings.Split(content, "\n")
+}
+
+// Sha256Sum returns the checksum of the paths and contents of the files matched by the glob
+func (f files) Sha256Sum(pattern string) string {
+	res, err := f.doGlob(f.ctx, pattern)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	var paths []string
+	for path := range res {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var args []string
+	for _, path := range paths {
+		args = append(args, path, res[path].(string))
+	}
+
+	return util.Sha256Hash(args...)
+}
+
+// gitInfo provides the metadata of the current commit of the project git repository
+type gitInfo struct {
+	ctx                context.Context
+	giterminismManager giterminism_manager.Interface
+}
+
+func (g gitInfo) Commit() string {
+	return g.giterminismManager.HeadCommit()
+}
+
+func (g gitInfo) CommitDate() (time.Time, error) {
+	return g.giterminismManager.LocalGitRepo().CommitTime(g.ctx, g.giterminismManager.HeadCommit())
+}
+
+// Tag returns the first tag of the current commit in alphabetical order or an empty string
+func (g gitInfo) Tag() (string, error) {
+	if err := g.giterminismManager.Inspector().InspectConfigGoTemplateRenderingGitReferences(); err != nil {
+		return "", err
+	}
+
+	tags, err := g.giterminismManager.LocalGitRepo().CommitTags(g.ctx, g.giterminismManager.HeadCommit())
+	if err != nil {
+		return "", err
+	}
+
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	return tags[0], nil
+}
+
+// Branch returns the checked out branch or an empty string if HEAD is detached
+func (g gitInfo) Branch() (string, error) {
+	if err := g.giterminismManager.Inspector().InspectConfigGoTemplateRenderingGitReferences(); err != nil {
+		return "", err
+	}
+
+	return g.giterminismManager.LocalGitRepo().CurrentBranch(g.ctx)
+}
+
 func splitContent(content []byte) (docsContents [][]byte) {
 	const (
 		stateLineBegin   = "stateLineBegin"
@@ -457,7 +671,7 @@ func emptyDocContent(content []byte) bool {
 	return true
 }
 
-func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImages []*rawStapelImage, rawImagesFromDockerfile []*rawImageFromDockerfile, meta *Meta) (*WerfConfig, error) {
+func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImages []*rawStapelImage, rawImagesFromDockerfile []*rawImageFromDockerfile, meta *Meta, env string) (*WerfConfig, error) {
 	var stapelImages []*StapelImage
 	var imagesFromDockerfile []*ImageFromDockerfile
 	var artifacts []*StapelImageArtifact
@@ -472,13 +686,13 @@ func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImag
 
 	for _, rawImage := range rawImages {
 		if rawImage.stapelImageType() == "images" {
-			if sameImages, err := rawImage.toStapelImageDirectives(giterminismManager); err != nil {
+			if sameImages, err := rawImage.toStapelImageDirectives(giterminismManager, env); err != nil {
 				return nil, err
 			} else {
 				stapelImages = append(stapelImages, sameImages...)
 			}
 		} else {
-			if imageArtifact, err := rawImage.toStapelImageArtifactDirectives(giterminismManager); err != nil {
+			if imageArtifact, err := rawImage.toStapelImageArtifactDirectives(giterminismManager, env); err != nil {
 				return nil, err
 			} else {
 				artifacts = append(artifacts, imageArtifact)
@@ -505,6 +719,10 @@ func prepareWerfConfig(giterminismManager giterminism_manager.Interface, rawImag
 		return nil, err
 	}
 
+	if err := werfConfig.validateImagesDependencies(); err != nil {
+		return nil, err
+	}
+
 	if err := werfConfig.exportsAutoExcluding(); err != nil {
 		return nil, err
 	}
@@ -522,6 +740,10 @@ func splitByMetaAndRawImages(docs []*doc) (*Meta, []*rawStapelImage, []*rawImage
 	var resultMeta *Meta
 
 	parentStack = util.NewStack()
+
+	var imageTemplates []*imageTemplate
+	var imagesDocs []*doc
+	var imagesRaws []map[string]interface{}
 	for _, doc := range docs {
 		var raw map[string]interface{}
 		err := yaml.UnmarshalStrict(doc.Content, &raw)
@@ -541,7 +763,32 @@ func splitByMetaAndRawImages(docs []*doc) (*Meta, []*rawStapelImage, []*rawImage
 			}
 
 			resultMeta = rawMeta.toMeta()
-		} else if isImageFromDockerfileDoc(raw) {
+		} else if isImageTemplateDoc(raw) {
+			imageTemplateSection, err := newImageTemplate(raw, doc)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			imageTemplates = append(imageTemplates, imageTemplateSection)
+		} else {
+			imagesDocs = append(imagesDocs, doc)
+			imagesRaws = append(imagesRaws, raw)
+		}
+	}
+
+	// templates could be defined after the images, which extend them
+	imageTemplateResolver, err := newImageTemplateResolver(imageTemplates)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for ind := range imagesDocs {
+		raw, doc, err := imageTemplateResolver.resolveImageDoc(imagesRaws[ind], imagesDocs[ind])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if isImageFromDockerfileDoc(raw) {
 			imageFromDockerfile := &rawImageFromDockerfile{doc: doc}
 			err := yaml.UnmarshalStrict(doc.Content, &imageFromDockerfile)
 			if err != nil {
@@ -558,7 +805,7 @@ func splitByMetaAndRawImages(docs []*doc) (*Meta, []*rawStapelImage, []*rawImage
 
 			rawStapelImages = append(rawStapelImages, image)
 		} else {
-			return nil, nil, nil, newYamlUnmarshalError(errors.New("cannot recognize type of config section (part of YAML stream separated by three hyphens, https://yaml.org/spec/1.2/spec.html#id2800132):\n * 'configVersion' required for meta config section;\n * 'image' required for the image config sections;\n * 'artifact' required for the artifact config sections;"), doc)
+			return nil, nil, nil, newYamlUnmarshalError(errors.New("cannot recognize type of config section (part of YAML stream separated by three hyphens, https://yaml.org/spec/1.2/spec.html#id2800132):\n * 'configVersion' required for meta config section;\n * 'image' required for the image config sections;\n * 'artifact' required for the artifact config sections;\n * 'template' required for the template config sections;"), doc)
 		}
 	}
 
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+type fakeGoTemplateFileReader struct {
+	giterminism_manager.FileReader
+
+	files map[string]string
+}
+
+func (r fakeGoTemplateFileReader) ConfigGoTemplateFilesGet(_ context.Context, relPath string) ([]byte, error) {
+	content, ok := r.files[relPath]
+	if !ok {
+		return nil, fmt.Errorf("{{ .Files.Get %q }}: file not found", relPath)
+	}
+
+	return []byte(content), nil
+}
+
+func (r fakeGoTemplateFileReader) ConfigGoTemplateFilesGlob(_ context.Context, pattern string) (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	for relPath, content := range r.files {
+		if matched, err := path.Match(pattern, relPath); err != nil {
+			return nil, err
+		} else if matched {
+			res[relPath] = content
+		}
+	}
+
+	return res, nil
+}
+
+type fakeGoTemplateGiterminismManager struct {
+	giterminism_manager.Interface
+
+	fileReader fakeGoTemplateFileReader
+}
+
+func (m fakeGoTemplateGiterminismManager) FileReader() giterminism_manager.FileReader {
+	return m.fileReader
+}
+
+var _ = Describe(".Files", func() {
+	newFiles := func(filesContents map[string]string) files {
+		return files{
+			ctx:                context.Background(),
+			giterminismManager: fakeGoTemplateGiterminismManager{fileReader: fakeGoTemplateFileReader{files: filesContents}},
+		}
+	}
+
+	DescribeTable("Lines", func(content string, expected []string) {
+		Ω(newFiles(map[string]string{"file": content}).Lines("file")).Should(Equal(expected))
+	},
+		Entry("empty file", "", []string{}),
+		Entry("single line without line break", "a", []string{"a"}),
+		Entry("trailing line break", "a\nb\n", []string{"a", "b"}),
+		Entry("empty lines are kept", "a\n\nb\n\n", []string{"a", "", "b", ""}))
+
+	It("Lines panics if the file cannot be read", func() {
+		Ω(func() { newFiles(nil).Lines("file") }).Should(Panic())
+	})
+
+	It("Sha256Sum depends on the paths and contents of the matched files only", func() {
+		sum := newFiles(map[string]string{"a.txt": "a", "b.txt": "b", "c.md": "c"}).Sha256Sum("*.txt")
+
+		Ω(newFiles(map[string]string{"a.txt": "a", "b.txt": "b", "c.md": "changed"}).Sha256Sum("*.txt")).Should(Equal(sum))
+		Ω(newFiles(map[string]string{"a.txt": "a", "b.txt": "changed"}).Sha256Sum("*.txt")).ShouldNot(Equal(sum))
+		Ω(newFiles(map[string]string{"a.txt": "a", "d.txt": "b"}).Sha256Sum("*.txt")).ShouldNot(Equal(sum))
+		Ω(newFiles(map[string]string{"a.txt": "ab", "b.txt": ""}).Sha256Sum("*.txt")).ShouldNot(Equal(sum))
+	})
+
+	It("Sha256Sum panics if no files matched", func() {
+		Ω(func() { newFiles(map[string]string{"c.md": "c"}).Sha256Sum("*.txt") }).Should(Panic())
+	})
+})
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var platformRegexp = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+
+// parsePlatforms returns the list of platforms in the os/arch[/variant] format from `platform: PLATFORM || [ PLATFORM, ... ]`
+func parsePlatforms(value interface{}, configSection interface{}, d *doc) ([]string, error) {
+	platforms, err := InterfaceToStringArray(value, configSection, d)
+	if err != nil {
+		return nil, err
+	}
+
+	exist := map[string]bool{}
+	for _, platform := range platforms {
+		if !platformRegexp.MatchString(platform) {
+			return nil, newDetailedConfigError(fmt.Sprintf("invalid platform %q: `platform: OS/ARCH[/VARIANT] || [ OS/ARCH[/VARIANT], ... ]` expected (e.g. linux/amd64, linux/arm64 or linux/arm/v7)!", platform), configSection, d)
+		}
+
+		if exist[platform] {
+			return nil, newDetailedConfigError(fmt.Sprintf("duplicate platform %q!", platform), configSection, d)
+		}
+		exist[platform] = true
+	}
+
+	return platforms, nil
+}
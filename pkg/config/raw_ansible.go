@@ -36,7 +36,7 @@ func (c *rawAnsible) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
-func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
+func (c *rawAnsible) toDirective(conditionCtx conditionContext) (ansible *Ansible, err error) {
 	ansible = &Ansible{}
 
 	ansible.CacheVersion = c.CacheVersion
@@ -46,33 +46,33 @@ func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
 	ansible.SetupCacheVersion = c.SetupCacheVersion
 
 	for ind := range c.BeforeInstall {
-		if ansibleTask, err := c.BeforeInstall[ind].toDirective(); err != nil {
+		if ansibleTask, err := c.BeforeInstall[ind].toDirective(conditionCtx); err != nil {
 			return nil, err
-		} else {
+		} else if ansibleTask != nil {
 			ansible.BeforeInstall = append(ansible.BeforeInstall, ansibleTask)
 		}
 	}
 
 	for ind := range c.Install {
-		if ansibleTask, err := c.Install[ind].toDirective(); err != nil {
+		if ansibleTask, err := c.Install[ind].toDirective(conditionCtx); err != nil {
 			return nil, err
-		} else {
+		} else if ansibleTask != nil {
 			ansible.Install = append(ansible.Install, ansibleTask)
 		}
 	}
 
 	for ind := range c.BeforeSetup {
-		if ansibleTask, err := c.BeforeSetup[ind].toDirective(); err != nil {
+		if ansibleTask, err := c.BeforeSetup[ind].toDirective(conditionCtx); err != nil {
 			return nil, err
-		} else {
+		} else if ansibleTask != nil {
 			ansible.BeforeSetup = append(ansible.BeforeSetup, ansibleTask)
 		}
 	}
 
 	for ind := range c.Setup {
-		if ansibleTask, err := c.Setup[ind].toDirective(); err != nil {
+		if ansibleTask, err := c.Setup[ind].toDirective(conditionCtx); err != nil {
 			return nil, err
-		} else {
+		} else if ansibleTask != nil {
 			ansible.Setup = append(ansible.Setup, ansibleTask)
 		}
 	}
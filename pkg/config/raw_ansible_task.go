@@ -12,9 +12,13 @@ type rawAnsibleTask struct {
 	Always []rawAnsibleTask       `yaml:"always,omitempty"`
 	Fields map[string]interface{} `yaml:",inline"`
 
+	onlyIf *condition
+
 	rawAnsible *rawAnsible `yaml:"-"` // parent
 }
 
+const ansibleTaskOnlyIfField = "onlyIf"
+
 func (c *rawAnsibleTask) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if parent, ok := parentStack.Peek().(*rawAnsible); ok {
 		c.rawAnsible = parent
@@ -30,6 +34,15 @@ func (c *rawAnsibleTask) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 
+	// onlyIf is evaluated by werf and is not passed to ansible
+	if value, ok := c.Fields[ansibleTaskOnlyIfField]; ok {
+		delete(c.Fields, ansibleTaskOnlyIfField)
+
+		if c.onlyIf, err = newCondition(value, c, c.rawAnsible.rawImage.doc); err != nil {
+			return err
+		}
+	}
+
 	if !c.blockDefined() {
 		check := false
 		for _, supportedModule := range supportedModules() {
@@ -151,10 +164,49 @@ func supportedModules() []string {
 	return modules
 }
 
-func (c *rawAnsibleTask) toDirective() (*AnsibleTask, error) {
+// filterByConditions returns the task, which nested tasks conditions are met, or nil if the task condition is not met
+func (c *rawAnsibleTask) filterByConditions(conditionCtx conditionContext) *rawAnsibleTask {
+	if c.onlyIf != nil && !c.onlyIf.match(conditionCtx) {
+		return nil
+	}
+
+	if !c.blockDefined() {
+		return c
+	}
+
+	res := *c
+	res.Block = filterAnsibleTasksByConditions(c.Block, conditionCtx)
+	res.Rescue = filterAnsibleTasksByConditions(c.Rescue, conditionCtx)
+	res.Always = filterAnsibleTasksByConditions(c.Always, conditionCtx)
+
+	if !res.blockDefined() {
+		return nil
+	}
+
+	return &res
+}
+
+func filterAnsibleTasksByConditions(tasks []rawAnsibleTask, conditionCtx conditionContext) []rawAnsibleTask {
+	var res []rawAnsibleTask
+	for ind := range tasks {
+		if task := tasks[ind].filterByConditions(conditionCtx); task != nil {
+			res = append(res, *task)
+		}
+	}
+
+	return res
+}
+
+// toDirective returns nil if the task condition is not met
+func (c *rawAnsibleTask) toDirective(conditionCtx conditionContext) (*AnsibleTask, error) {
+	task := c.filterByConditions(conditionCtx)
+	if task == nil {
+		return nil, nil
+	}
+
 	ansibleTask := &AnsibleTask{}
 
-	marshal, err := yaml.Marshal(c)
+	marshal, err := yaml.Marshal(task)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+type ansibleTasksConditionsEntry struct {
+	tasks    string
+	env      string
+	expected string
+}
+
+func unmarshalTestAnsibleTasks(tasks string) []rawAnsibleTask {
+	d := &doc{Content: []byte(tasks)}
+
+	parentStack = util.NewStack()
+	parentStack.Push(&rawStapelImage{doc: d})
+	defer parentStack.Pop()
+
+	ansible := &rawAnsible{}
+	Ω(yaml.UnmarshalStrict([]byte("install:\n"+tasks), ansible)).Should(Succeed())
+
+	return ansible.Install
+}
+
+var _ = DescribeTable("filtering ansible tasks by conditions", func(e ansibleTasksConditionsEntry) {
+	tasks := filterAnsibleTasksByConditions(unmarshalTestAnsibleTasks(e.tasks), conditionContext{Env: e.env, ImageName: "app"})
+
+	data, err := yaml.Marshal(tasks)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	var res, expected []map[string]interface{}
+	Ω(yaml.Unmarshal(data, &res)).Should(Succeed())
+	Ω(yaml.Unmarshal([]byte(e.expected), &expected)).Should(Succeed())
+	Ω(res).Should(Equal(expected))
+},
+	Entry("tasks without conditions", ansibleTasksConditionsEntry{
+		"- shell: make\n- shell: make install\n",
+		"production",
+		"- shell: make\n- shell: make install\n",
+	}),
+	Entry("onlyIf is not passed to ansible", ansibleTasksConditionsEntry{
+		"- shell: make\n  onlyIf: {env: production}\n",
+		"production",
+		"- shell: make\n",
+	}),
+	Entry("task, which condition is not met, is skipped", ansibleTasksConditionsEntry{
+		"- shell: make debug\n  onlyIf: {env: development}\n- shell: make release\n  onlyIf: {env: production}\n",
+		"production",
+		"- shell: make release\n",
+	}),
+	Entry("nested tasks are filtered", ansibleTasksConditionsEntry{
+		"- block:\n  - shell: make debug\n    onlyIf: {env: development}\n  - shell: make release\n  rescue:\n  - shell: make clean\n    onlyIf: {env: development}\n",
+		"production",
+		"- block:\n  - shell: make release\n",
+	}),
+	Entry("block, which nested tasks are all skipped, is skipped", ansibleTasksConditionsEntry{
+		"- block:\n  - shell: make debug\n    onlyIf: {env: development}\n- shell: make install\n",
+		"production",
+		"- shell: make install\n",
+	}),
+	Entry("block, which condition is not met, is skipped with nested tasks", ansibleTasksConditionsEntry{
+		"- block:\n  - shell: make release\n  onlyIf: {image: backend}\n- shell: make install\n",
+		"production",
+		"- shell: make install\n",
+	}))
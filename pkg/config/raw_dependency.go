@@ -0,0 +1,86 @@
+package config
+
+type rawDependency struct {
+	Image      string                 `yaml:"image,omitempty"`
+	Before     string                 `yaml:"before,omitempty"`
+	After      string                 `yaml:"after,omitempty"`
+	RawImports []*rawDependencyImport `yaml:"imports,omitempty"`
+
+	doc *doc `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawDependencyImport struct {
+	Type           string `yaml:"type,omitempty"`
+	TargetBuildArg string `yaml:"targetBuildArg,omitempty"`
+	TargetEnv      string `yaml:"targetEnv,omitempty"`
+
+	rawDependency *rawDependency `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawDependency) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawStapelImage:
+		c.doc = parent.doc
+	case *rawImageFromDockerfile:
+		c.doc = parent.doc
+	}
+
+	parentStack.Push(c)
+	type plain rawDependency
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawDependency) toDirective(isDockerfileImage bool) (*Dependency, error) {
+	dependency := &Dependency{
+		ImageName: c.Image,
+		Before:    c.Before,
+		After:     c.After,
+		raw:       c,
+	}
+
+	for _, rawImport := range c.RawImports {
+		dependency.Imports = append(dependency.Imports, &DependencyImport{
+			Type:           rawImport.Type,
+			TargetBuildArg: rawImport.TargetBuildArg,
+			TargetEnv:      rawImport.TargetEnv,
+			raw:            rawImport,
+		})
+	}
+
+	if err := dependency.validate(isDockerfileImage); err != nil {
+		return nil, err
+	}
+
+	return dependency, nil
+}
+
+func (c *rawDependencyImport) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawDependency); ok {
+		c.rawDependency = parent
+	}
+
+	type plain rawDependencyImport
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawDependency.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
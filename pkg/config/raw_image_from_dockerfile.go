@@ -7,15 +7,18 @@ import (
 )
 
 type rawImageFromDockerfile struct {
-	Images         []string               `yaml:"-"`
-	Dockerfile     string                 `yaml:"dockerfile,omitempty"`
-	Context        string                 `yaml:"context,omitempty"`
-	ContextAddFile interface{}            `yaml:"contextAddFile,omitempty"`
-	Target         string                 `yaml:"target,omitempty"`
-	Args           map[string]interface{} `yaml:"args,omitempty"`
-	AddHost        interface{}            `yaml:"addHost,omitempty"`
-	Network        string                 `yaml:"network,omitempty"`
-	SSH            string                 `yaml:"ssh,omitempty"`
+	Images          []string               `yaml:"-"`
+	Dockerfile      string                 `yaml:"dockerfile,omitempty"`
+	Context         string                 `yaml:"context,omitempty"`
+	ContextAddFile  interface{}            `yaml:"contextAddFile,omitempty"`
+	Target          string                 `yaml:"target,omitempty"`
+	Args            map[string]interface{} `yaml:"args,omitempty"`
+	AddHost         interface{}            `yaml:"addHost,omitempty"`
+	Network         string                 `yaml:"network,omitempty"`
+	SSH             string                 `yaml:"ssh,omitempty"`
+	Staged          bool                   `yaml:"staged,omitempty"`
+	Platform        interface{}            `yaml:"platform,omitempty"`
+	RawDependencies []*rawDependency       `yaml:"dependencies,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -100,6 +103,19 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(giterminismManag
 
 	image.Network = c.Network
 	image.SSH = c.SSH
+	image.Staged = c.Staged
+
+	if image.Platform, err = parsePlatforms(c.Platform, nil, c.doc); err != nil {
+		return nil, err
+	}
+
+	for _, rawDependency := range c.RawDependencies {
+		if dependency, err := rawDependency.toDirective(true); err != nil {
+			return nil, err
+		} else {
+			image.Dependencies = append(image.Dependencies, dependency)
+		}
+	}
 
 	image.raw = c
 
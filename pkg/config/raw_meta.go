@@ -13,6 +13,7 @@ type rawMeta struct {
 	Deploy             *rawMetaDeploy      `yaml:"deploy,omitempty"`
 	Cleanup            *rawMetaCleanup     `yaml:"cleanup,omitempty"`
 	GitWorktree        *rawMetaGitWorktree `yaml:"gitWorktree,omitempty"`
+	Include            []*rawMetaInclude   `yaml:"include,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -75,5 +76,9 @@ func (c *rawMeta) toMeta() *Meta {
 		meta.GitWorktree = c.GitWorktree.toMetaGitWorktree()
 	}
 
+	for _, include := range c.Include {
+		meta.Include = append(meta.Include, include.toMetaInclude())
+	}
+
 	return meta
 }
@@ -5,10 +5,19 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/dustin/go-humanize"
+	"k8s.io/client-go/util/jsonpath"
 )
 
 type rawMetaCleanup struct {
-	KeepPolicies []*rawMetaCleanupKeepPolicy `yaml:"keepPolicies,omitempty"`
+	Strategy            *string                             `yaml:"strategy,omitempty"`
+	KeepPolicies        []*rawMetaCleanupKeepPolicy         `yaml:"keepPolicies,omitempty"`
+	StagesPerImage      *rawMetaCleanupStagesPerImage       `yaml:"stagesPerImage,omitempty"`
+	KubernetesResources []*rawMetaCleanupKubernetesResource `yaml:"kubernetesResources,omitempty"`
+	SizeQuota           *string                             `yaml:"sizeQuota,omitempty"`
+
+	SizeQuotaBytes *uint64 `yaml:"-"`
 
 	rawMeta               *rawMeta
 	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
@@ -36,6 +45,24 @@ type rawMetaCleanupKeepPolicyReferences struct {
 
 type rawMetaCleanupKeepPolicyImagesPerReference rawMetaCleanupKeepPolicyReferencesLimit
 
+type rawMetaCleanupKubernetesResource struct {
+	Group      string   `yaml:"group,omitempty"`
+	Version    string   `yaml:"version,omitempty"`
+	Resource   string   `yaml:"resource,omitempty"`
+	ImagePaths []string `yaml:"imagePaths,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMetaCleanupStagesPerImage struct {
+	Last *int           `yaml:"last,omitempty"`
+	In   *time.Duration `yaml:"in,omitempty"`
+
+	rawMetaCleanup        *rawMetaCleanup
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
 type rawMetaCleanupKeepPolicyReferencesLimit struct {
 	Last     *int           `yaml:"last,omitempty"`
 	In       *time.Duration `yaml:"in,omitempty"`
@@ -62,6 +89,33 @@ func (c *rawMetaCleanup) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 
+	if c.Strategy != nil {
+		switch *c.Strategy {
+		case MetaCleanupStrategyGitHistory, MetaCleanupStrategyAge:
+		default:
+			return newDetailedConfigError(fmt.Sprintf("unsupported value %q for `strategy: gitHistory|age`!", *c.Strategy), c, c.rawMeta.doc)
+		}
+	}
+
+	if c.Strategy != nil && *c.Strategy == MetaCleanupStrategyAge {
+		if len(c.KeepPolicies) != 0 {
+			return newDetailedConfigError("`keepPolicies` cannot be used with `strategy: age`: the git history is not used by the age-based cleanup!", c, c.rawMeta.doc)
+		} else if c.StagesPerImage == nil {
+			return newDetailedConfigError("`stagesPerImage` required for `strategy: age`!", c, c.rawMeta.doc)
+		}
+	} else if c.StagesPerImage != nil {
+		return newDetailedConfigError("`stagesPerImage` can be used only with `strategy: age`!", c, c.rawMeta.doc)
+	}
+
+	if c.SizeQuota != nil {
+		sizeQuotaBytes, err := humanize.ParseBytes(*c.SizeQuota)
+		if err != nil || sizeQuotaBytes == 0 {
+			return newDetailedConfigError(fmt.Sprintf("invalid value %q for `sizeQuota: size string`: expected positive size (e.g. 50GiB)!", *c.SizeQuota), c, c.rawMeta.doc)
+		}
+
+		c.SizeQuotaBytes = &sizeQuotaBytes
+	}
+
 	return nil
 }
 
@@ -160,6 +214,70 @@ func (c *rawMetaCleanupKeepPolicyReferencesLimit) UnmarshalYAML(unmarshal func(i
 	return nil
 }
 
+func (c *rawMetaCleanupStagesPerImage) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupStagesPerImage
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Last == nil && c.In == nil {
+		return newDetailedConfigError("`last: int` or `in: duration string` required for cleanup stagesPerImage!", c, c.rawMetaCleanup.rawMeta.doc)
+	} else if c.Last != nil && *c.Last < 0 {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %d for `last: int`: expected non-negative number!", *c.Last), c, c.rawMetaCleanup.rawMeta.doc)
+	} else if c.Last != nil && *c.Last < 1 && c.In == nil {
+		return newDetailedConfigError(fmt.Sprintf("invalid value %d for `last: int`: expected positive number, all stages of each image would be deleted (`last: 0` can be used only with `in: duration string`)!", *c.Last), c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaCleanupKubernetesResource) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMetaCleanup); ok {
+		c.rawMetaCleanup = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaCleanupKubernetesResource
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMetaCleanup.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Version == "" || c.Resource == "" {
+		return newDetailedConfigError("version `version: string` and resource `resource: string` required for cleanup kubernetes resource!", c, c.rawMetaCleanup.rawMeta.doc)
+	} else if len(c.ImagePaths) == 0 {
+		return newDetailedConfigError("image paths `imagePaths: [JSONPATH, ...]` required for cleanup kubernetes resource!", c, c.rawMetaCleanup.rawMeta.doc)
+	}
+
+	for _, imagePath := range c.ImagePaths {
+		if !strings.HasPrefix(imagePath, "{") || !strings.HasSuffix(imagePath, "}") {
+			return newDetailedConfigError(fmt.Sprintf("invalid image path %q: JSONPath template `{.spec.image}` expected!", imagePath), c, c.rawMetaCleanup.rawMeta.doc)
+		}
+
+		if err := jsonpath.New(imagePath).Parse(imagePath); err != nil {
+			return newDetailedConfigError(fmt.Sprintf("invalid image path %q: %s!", imagePath, err), c, c.rawMetaCleanup.rawMeta.doc)
+		}
+	}
+
+	return nil
+}
+
 func (c *rawMetaCleanupKeepPolicyReferences) processRegexpString(name, configValue string) (*regexp.Regexp, error) {
 	var value string
 	if strings.HasPrefix(configValue, "/") && strings.HasSuffix(configValue, "/") {
@@ -180,11 +298,21 @@ func (c *rawMetaCleanupKeepPolicyReferences) processRegexpString(name, configVal
 
 func (c *rawMetaCleanup) toMetaCleanup() MetaCleanup {
 	metaCleanup := MetaCleanup{}
+	metaCleanup.Strategy = c.Strategy
+	metaCleanup.SizeQuota = c.SizeQuotaBytes
+
+	if c.StagesPerImage != nil {
+		metaCleanup.StagesPerImage = c.StagesPerImage.toMetaCleanupStagesPerImage()
+	}
 
 	for _, policy := range c.KeepPolicies {
 		metaCleanup.KeepPolicies = append(metaCleanup.KeepPolicies, policy.toMetaCleanupKeepPolicy())
 	}
 
+	for _, resource := range c.KubernetesResources {
+		metaCleanup.KubernetesResources = append(metaCleanup.KubernetesResources, resource.toMetaCleanupKubernetesResource())
+	}
+
 	return metaCleanup
 }
 
@@ -245,3 +373,21 @@ func (c *rawMetaCleanupKeepPolicyImagesPerReference) toMetaCleanupKeepPolicyImag
 
 	return limit
 }
+
+func (c *rawMetaCleanupStagesPerImage) toMetaCleanupStagesPerImage() MetaCleanupStagesPerImage {
+	stagesPerImage := MetaCleanupStagesPerImage{}
+	stagesPerImage.Last = c.Last
+	stagesPerImage.In = c.In
+
+	return stagesPerImage
+}
+
+func (c *rawMetaCleanupKubernetesResource) toMetaCleanupKubernetesResource() *MetaCleanupKubernetesResource {
+	resource := &MetaCleanupKubernetesResource{}
+	resource.Group = c.Group
+	resource.Version = c.Version
+	resource.Resource = c.Resource
+	resource.ImagePaths = c.ImagePaths
+
+	return resource
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+var includeCommitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+type rawMetaInclude struct {
+	Path   string `yaml:"path,omitempty"`
+	Git    string `yaml:"git,omitempty"`
+	Branch string `yaml:"branch,omitempty"`
+	Tag    string `yaml:"tag,omitempty"`
+	Commit string `yaml:"commit,omitempty"`
+
+	rawMeta *rawMeta
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawMetaInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawMeta); ok {
+		c.rawMeta = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawMetaInclude
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawMeta.doc); err != nil {
+		return err
+	}
+
+	if c.Path == "" {
+		return newDetailedConfigError("`path: PATH` required for include!", c, c.rawMeta.doc)
+	} else if filepath.IsAbs(c.Path) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `path: %s` for include: the path should be relative to the project directory or the git repository root!", c.Path), c, c.rawMeta.doc)
+	}
+
+	if c.Git == "" {
+		if c.Branch != "" || c.Tag != "" || c.Commit != "" {
+			return newDetailedConfigError("`branch`, `tag` and `commit` are supported only for include from the git repository: specify `git: URL`!", c, c.rawMeta.doc)
+		}
+	} else if !oneOrNone([]bool{c.Branch != "", c.Tag != "", c.Commit != ""}) || (c.Branch == "" && c.Tag == "" && c.Commit == "") {
+		return newDetailedConfigError("specify only one of `branch: BRANCH`, `tag: TAG` or `commit: COMMIT` for include from the git repository!", c, c.rawMeta.doc)
+	} else if c.Commit != "" && !includeCommitRegexp.MatchString(c.Commit) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `commit: %s` for include: the full 40-character commit hash expected!", c.Commit), c, c.rawMeta.doc)
+	}
+
+	return nil
+}
+
+func (c *rawMetaInclude) toMetaInclude() MetaInclude {
+	obj := MetaInclude{}
+	obj.Path = c.Path
+	obj.Git = c.Git
+	obj.Branch = c.Branch
+	obj.Tag = c.Tag
+	obj.Commit = c.Commit
+	return obj
+}
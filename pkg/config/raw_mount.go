@@ -6,6 +6,9 @@ type rawMount struct {
 	To       string `yaml:"to,omitempty"`
 	From     string `yaml:"from,omitempty"`
 	FromPath string `yaml:"fromPath,omitempty"`
+	Id       string `yaml:"id,omitempty"`
+	Sharing  string `yaml:"sharing,omitempty"`
+	Scope    string `yaml:"scope,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -40,6 +43,23 @@ func (c *rawMount) toDirective(giterminismManager giterminism_manager.Interface)
 		mount.Type = c.From
 	}
 
+	if mount.Type == "cache" {
+		mount.Id = c.Id
+		if mount.Id == "" {
+			mount.Id = defaultCacheMountId(c.To)
+		}
+
+		mount.Sharing = c.Sharing
+		if mount.Sharing == "" {
+			mount.Sharing = CacheMountSharingShared
+		}
+
+		mount.Scope = c.Scope
+		if mount.Scope == "" {
+			mount.Scope = CacheMountScopeProject
+		}
+	}
+
 	mount.raw = c
 
 	if err := c.validateDirective(giterminismManager, mount); err != nil {
@@ -0,0 +1,52 @@
+package config
+
+import "github.com/werf/werf/pkg/giterminism_manager"
+
+type rawSecret struct {
+	Id  string `yaml:"id,omitempty"`
+	Env string `yaml:"env,omitempty"`
+	Src string `yaml:"src,omitempty"`
+	To  string `yaml:"to,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.rawStapelImage = parent
+	}
+
+	type plain rawSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawSecret) toDirective(giterminismManager giterminism_manager.Interface) (secret *Secret, err error) {
+	secret = &Secret{}
+	secret.Id = c.Id
+	secret.Env = c.Env
+	secret.Src = c.Src
+
+	if c.To == "" {
+		secret.To = defaultSecretMountpoint(c.Id)
+	} else {
+		secret.To = c.To
+	}
+
+	secret.raw = c
+
+	if err := secret.validate(giterminismManager); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
 type rawShell struct {
 	BeforeInstall             interface{} `yaml:"beforeInstall,omitempty"`
 	Install                   interface{} `yaml:"install,omitempty"`
@@ -33,7 +39,7 @@ func (c *rawShell) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
-func (c *rawShell) toDirective() (shell *Shell, err error) {
+func (c *rawShell) toDirective(conditionCtx conditionContext) (shell *Shell, err error) {
 	shell = &Shell{}
 	shell.CacheVersion = c.CacheVersion
 	shell.BeforeInstallCacheVersion = c.BeforeInstallCacheVersion
@@ -41,25 +47,25 @@ func (c *rawShell) toDirective() (shell *Shell, err error) {
 	shell.BeforeSetupCacheVersion = c.BeforeSetupCacheVersion
 	shell.SetupCacheVersion = c.SetupCacheVersion
 
-	if beforeInstall, err := InterfaceToStringArray(c.BeforeInstall, c, c.rawStapelImage.doc); err != nil {
+	if beforeInstall, err := c.toStageCommands(c.BeforeInstall, conditionCtx); err != nil {
 		return nil, err
 	} else {
 		shell.BeforeInstall = beforeInstall
 	}
 
-	if install, err := InterfaceToStringArray(c.Install, c, c.rawStapelImage.doc); err != nil {
+	if install, err := c.toStageCommands(c.Install, conditionCtx); err != nil {
 		return nil, err
 	} else {
 		shell.Install = install
 	}
 
-	if beforeSetup, err := InterfaceToStringArray(c.BeforeSetup, c, c.rawStapelImage.doc); err != nil {
+	if beforeSetup, err := c.toStageCommands(c.BeforeSetup, conditionCtx); err != nil {
 		return nil, err
 	} else {
 		shell.BeforeSetup = beforeSetup
 	}
 
-	if setup, err := InterfaceToStringArray(c.Setup, c, c.rawStapelImage.doc); err != nil {
+	if setup, err := c.toStageCommands(c.Setup, conditionCtx); err != nil {
 		return nil, err
 	} else {
 		shell.Setup = setup
@@ -74,6 +80,83 @@ func (c *rawShell) toDirective() (shell *Shell, err error) {
 	return shell, nil
 }
 
+// toStageCommands returns the stage commands, which conditions are met.
+// The stage is defined by a command, a commands group `{run: COMMANDS, when: CONDITION}` or an array of them.
+func (c *rawShell) toStageCommands(value interface{}, conditionCtx conditionContext) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{v}, nil
+	case map[interface{}]interface{}:
+		return c.toCommandsGroupCommands(v, conditionCtx)
+	case []interface{}:
+		var commands []string
+		for _, item := range v {
+			switch itemValue := item.(type) {
+			case string:
+				commands = append(commands, itemValue)
+			case map[interface{}]interface{}:
+				groupCommands, err := c.toCommandsGroupCommands(itemValue, conditionCtx)
+				if err != nil {
+					return nil, err
+				}
+				commands = append(commands, groupCommands...)
+			default:
+				return nil, newDetailedConfigError(fmt.Sprintf("single string, commands group `{run: COMMANDS, when: CONDITION}` or array of them expected, got `%v`!", value), c, c.rawStapelImage.doc)
+			}
+		}
+		return commands, nil
+	default:
+		return nil, newDetailedConfigError(fmt.Sprintf("single string, commands group `{run: COMMANDS, when: CONDITION}` or array of them expected, got `%v`!", value), c, c.rawStapelImage.doc)
+	}
+}
+
+type rawShellCommandsGroup struct {
+	Run  interface{} `yaml:"run,omitempty"`
+	When interface{} `yaml:"when,omitempty"`
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawShell) toCommandsGroupCommands(value map[interface{}]interface{}, conditionCtx conditionContext) ([]string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("invalid commands group: %s", err), c, c.rawStapelImage.doc)
+	}
+
+	group := &rawShellCommandsGroup{}
+	if err := yaml.UnmarshalStrict(data, group); err != nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("invalid commands group `%v`: `{run: COMMANDS, when: CONDITION}` expected!", value), c, c.rawStapelImage.doc)
+	}
+
+	if err := checkOverflow(group.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return nil, err
+	}
+
+	if group.Run == nil {
+		return nil, newDetailedConfigError(fmt.Sprintf("`run: COMMAND || [ COMMAND, ... ]` required for commands group `%v`!", value), c, c.rawStapelImage.doc)
+	}
+
+	commands, err := InterfaceToStringArray(group.Run, c, c.rawStapelImage.doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.When != nil {
+		cond, err := newCondition(group.When, c, c.rawStapelImage.doc)
+		if err != nil {
+			return nil, err
+		}
+
+		if !cond.match(conditionCtx) {
+			return nil, nil
+		}
+	}
+
+	return commands, nil
+}
+
 func (c *rawShell) validateDirective(shell *Shell) error {
 	if err := shell.validate(); err != nil {
 		return err
@@ -0,0 +1,51 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+type shellStageCommandsEntry struct {
+	stage    string
+	env      string
+	expected []string
+}
+
+func newTestRawShell(content string) *rawShell {
+	return &rawShell{rawStapelImage: &rawStapelImage{doc: &doc{Content: []byte(content)}}}
+}
+
+var _ = DescribeTable("shell stage commands", func(e shellStageCommandsEntry) {
+	var value interface{}
+	Ω(yaml.Unmarshal([]byte(e.stage), &value)).Should(Succeed())
+
+	commands, err := newTestRawShell(e.stage).toStageCommands(value, conditionContext{Env: e.env, ImageName: "app"})
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(commands).Should(Equal(e.expected))
+},
+	Entry("empty stage", shellStageCommandsEntry{"", "production", []string{}}),
+	Entry("single command", shellStageCommandsEntry{"make\n", "production", []string{"make"}}),
+	Entry("commands", shellStageCommandsEntry{"[make, make install]\n", "production", []string{"make", "make install"}}),
+	Entry("commands group without condition", shellStageCommandsEntry{"run: [make, make install]\n", "production", []string{"make", "make install"}}),
+	Entry("commands group, which condition is met", shellStageCommandsEntry{"run: make\nwhen:\n  env: production\n", "production", []string{"make"}}),
+	Entry("commands group, which condition is not met", shellStageCommandsEntry{"run: make\nwhen:\n  env: production\n", "staging", nil}),
+	Entry("commands and groups are concatenated in order", shellStageCommandsEntry{
+		"- apt-get update\n- run: [make debug]\n  when: {env: development}\n- run: [make release]\n  when: {env: production}\n- make install\n",
+		"production",
+		[]string{"apt-get update", "make release", "make install"},
+	}),
+	Entry("commands group is matched by image", shellStageCommandsEntry{"- run: make\n  when: {image: [backend, app]}\n", "", []string{"make"}}))
+
+var _ = DescribeTable("shell stage commands errors", func(stage string) {
+	var value interface{}
+	Ω(yaml.Unmarshal([]byte(stage), &value)).Should(Succeed())
+
+	_, err := newTestRawShell(stage).toStageCommands(value, conditionContext{Env: "production", ImageName: "app"})
+	Ω(err).Should(HaveOccurred())
+},
+	Entry("number", "42\n"),
+	Entry("nested array", "[[make]]\n"),
+	Entry("commands group without run", "when: {env: production}\n"),
+	Entry("commands group with unknown field", "run: make\nif: {env: production}\n"),
+	Entry("commands group with invalid condition", "run: make\nwhen: {environment: production}\n"))
@@ -7,19 +7,22 @@ import (
 )
 
 type rawStapelImage struct {
-	Images           []string     `yaml:"-"`
-	Artifact         string       `yaml:"artifact,omitempty"`
-	From             string       `yaml:"from,omitempty"`
-	FromLatest       bool         `yaml:"fromLatest,omitempty"`
-	FromCacheVersion string       `yaml:"fromCacheVersion,omitempty"`
-	FromImage        string       `yaml:"fromImage,omitempty"`
-	FromArtifact     string       `yaml:"fromArtifact,omitempty"`
-	RawGit           []*rawGit    `yaml:"git,omitempty"`
-	RawShell         *rawShell    `yaml:"shell,omitempty"`
-	RawAnsible       *rawAnsible  `yaml:"ansible,omitempty"`
-	RawMount         []*rawMount  `yaml:"mount,omitempty"`
-	RawDocker        *rawDocker   `yaml:"docker,omitempty"`
-	RawImport        []*rawImport `yaml:"import,omitempty"`
+	Images           []string         `yaml:"-"`
+	Artifact         string           `yaml:"artifact,omitempty"`
+	From             string           `yaml:"from,omitempty"`
+	FromLatest       bool             `yaml:"fromLatest,omitempty"`
+	FromCacheVersion string           `yaml:"fromCacheVersion,omitempty"`
+	FromImage        string           `yaml:"fromImage,omitempty"`
+	FromArtifact     string           `yaml:"fromArtifact,omitempty"`
+	RawGit           []*rawGit        `yaml:"git,omitempty"`
+	RawShell         *rawShell        `yaml:"shell,omitempty"`
+	RawAnsible       *rawAnsible      `yaml:"ansible,omitempty"`
+	RawMount         []*rawMount      `yaml:"mount,omitempty"`
+	RawSecrets       []*rawSecret     `yaml:"secrets,omitempty"`
+	RawDocker        *rawDocker       `yaml:"docker,omitempty"`
+	RawImport        []*rawImport     `yaml:"import,omitempty"`
+	RawDependencies  []*rawDependency `yaml:"dependencies,omitempty"`
+	Platform         interface{}      `yaml:"platform,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -97,9 +100,9 @@ func (c *rawStapelImage) stapelImageType() string {
 	return ""
 }
 
-func (c *rawStapelImage) toStapelImageDirectives(giterminismManager giterminism_manager.Interface) (images []*StapelImage, err error) {
+func (c *rawStapelImage) toStapelImageDirectives(giterminismManager giterminism_manager.Interface, env string) (images []*StapelImage, err error) {
 	for _, imageName := range c.Images {
-		if image, err := c.toStapelImageDirective(giterminismManager, imageName); err != nil {
+		if image, err := c.toStapelImageDirective(giterminismManager, env, imageName); err != nil {
 			return nil, err
 		} else {
 			images = append(images, image)
@@ -109,11 +112,11 @@ func (c *rawStapelImage) toStapelImageDirectives(giterminismManager giterminism_
 	return images, nil
 }
 
-func (c *rawStapelImage) toStapelImageArtifactDirectives(giterminismManager giterminism_manager.Interface) (*StapelImageArtifact, error) {
+func (c *rawStapelImage) toStapelImageArtifactDirectives(giterminismManager giterminism_manager.Interface, env string) (*StapelImageArtifact, error) {
 	imageArtifact := &StapelImageArtifact{}
 
 	var err error
-	if imageArtifact.StapelImageBase, err = c.toStapelImageBaseDirective(giterminismManager, c.Artifact); err != nil {
+	if imageArtifact.StapelImageBase, err = c.toStapelImageBaseDirective(giterminismManager, env, c.Artifact); err != nil {
 		return nil, err
 	}
 
@@ -124,10 +127,10 @@ func (c *rawStapelImage) toStapelImageArtifactDirectives(giterminismManager gite
 	return imageArtifact, nil
 }
 
-func (c *rawStapelImage) toStapelImageDirective(giterminismManager giterminism_manager.Interface, name string) (*StapelImage, error) {
+func (c *rawStapelImage) toStapelImageDirective(giterminismManager giterminism_manager.Interface, env, name string) (*StapelImage, error) {
 	image := &StapelImage{}
 
-	if imageBase, err := c.toStapelImageBaseDirective(giterminismManager, name); err != nil {
+	if imageBase, err := c.toStapelImageBaseDirective(giterminismManager, env, name); err != nil {
 		return nil, err
 	} else {
 		image.StapelImageBase = imageBase
@@ -141,6 +144,12 @@ func (c *rawStapelImage) toStapelImageDirective(giterminismManager giterminism_m
 		}
 	}
 
+	if platforms, err := parsePlatforms(c.Platform, nil, c.doc); err != nil {
+		return nil, err
+	} else {
+		image.Platform = platforms
+	}
+
 	if err := c.validateStapelImageDirective(image); err != nil {
 		return nil, err
 	}
@@ -195,6 +204,10 @@ func (c *rawStapelImage) validateStapelImageArtifactDirective(imageArtifact *Sta
 		return newDetailedConfigError("`docker` section is not supported for artifact!", nil, c.doc)
 	}
 
+	if c.Platform != nil {
+		return newDetailedConfigError("`platform` is not supported for artifact: artifacts are built for platforms of images which use them!", nil, c.doc)
+	}
+
 	if err := imageArtifact.validate(); err != nil {
 		return err
 	}
@@ -202,7 +215,7 @@ func (c *rawStapelImage) validateStapelImageArtifactDirective(imageArtifact *Sta
 	return nil
 }
 
-func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager giterminism_manager.Interface, name string) (imageBase *StapelImageBase, err error) {
+func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager giterminism_manager.Interface, env, name string) (imageBase *StapelImageBase, err error) {
 	if imageBase, err = c.toBaseStapelImageBaseDirective(giterminismManager, name); err != nil {
 		return nil, err
 	}
@@ -230,7 +243,7 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 	}
 
 	if c.RawShell != nil {
-		if shell, err := c.RawShell.toDirective(); err != nil {
+		if shell, err := c.RawShell.toDirective(conditionContext{Env: env, ImageName: name}); err != nil {
 			return nil, err
 		} else {
 			imageBase.Shell = shell
@@ -238,7 +251,7 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 	}
 
 	if c.RawAnsible != nil {
-		if ansible, err := c.RawAnsible.toDirective(); err != nil {
+		if ansible, err := c.RawAnsible.toDirective(conditionContext{Env: env, ImageName: name}); err != nil {
 			return nil, err
 		} else {
 			imageBase.Ansible = ansible
@@ -253,6 +266,14 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 		}
 	}
 
+	for _, rawDependency := range c.RawDependencies {
+		if dependency, err := rawDependency.toDirective(false); err != nil {
+			return nil, err
+		} else {
+			imageBase.Dependencies = append(imageBase.Dependencies, dependency)
+		}
+	}
+
 	if err := c.validateStapelImageBaseDirective(giterminismManager, imageBase); err != nil {
 		return nil, err
 	}
@@ -280,6 +301,14 @@ func (c *rawStapelImage) toBaseStapelImageBaseDirective(giterminismManager giter
 		}
 	}
 
+	for _, secret := range c.RawSecrets {
+		if imageSecret, err := secret.toDirective(giterminismManager); err != nil {
+			return nil, err
+		} else {
+			imageBase.Secrets = append(imageBase.Secrets, imageSecret)
+		}
+	}
+
 	imageBase.Git = &GitManager{}
 
 	imageBase.raw = c
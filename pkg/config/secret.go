@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+var secretIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Secret is mounted into the build container of user stages from env or file, it is not part of stages digests and images
+type Secret struct {
+	Id  string
+	Env string
+	Src string
+	To  string
+
+	raw *rawSecret
+}
+
+func defaultSecretMountpoint(id string) string {
+	return path.Join("/run/secrets", id)
+}
+
+func (c *Secret) validate(giterminismManager giterminism_manager.Interface) error {
+	if !secretIdRegexp.MatchString(c.Id) {
+		return newDetailedConfigError(fmt.Sprintf("invalid `id: %s` for secret: alphanumeric characters, dots, dashes and underscores are allowed!", c.Id), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Env == "" && c.Src == "" {
+		return newDetailedConfigError("`env: ENV_NAME` or `src: PATH` required for secret!", c.raw, c.raw.rawStapelImage.doc)
+	} else if c.Env != "" && c.Src != "" {
+		return newDetailedConfigError(fmt.Sprintf("cannot use `env: %s` and `src: %s` at the same time for secret!", c.Env, c.Src), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Src != "" {
+		if err := giterminismManager.Inspector().InspectConfigStapelSecretSrc(c.Src); err != nil {
+			return newDetailedConfigError(err.Error(), c.raw, c.raw.rawStapelImage.doc)
+		}
+	}
+
+	if !isAbsolutePath(c.To) {
+		return newDetailedConfigError("`to: PATH` absolute path required for secret!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	return nil
+}
@@ -20,7 +20,10 @@ type StapelImageBase struct {
 	Shell            *Shell
 	Ansible          *Ansible
 	Mount            []*Mount
+	Secrets          []*Secret
 	Import           []*Import
+	Dependencies     []*Dependency
+	Platform         []string
 
 	raw *rawStapelImage
 }
@@ -29,6 +32,10 @@ func (c *StapelImageBase) GetName() string {
 	return c.Name
 }
 
+func (c *StapelImageBase) GetPlatforms() []string {
+	return c.Platform
+}
+
 func (c *StapelImageBase) imports() []*Import {
 	return c.Import
 }
@@ -98,6 +105,19 @@ func (c *StapelImageBase) validate(giterminismManager giterminism_manager.Interf
 		mountByTo[mount.To] = true
 	}
 
+	secretById := map[string]bool{}
+	for _, secret := range c.Secrets {
+		if secretById[secret.Id] {
+			return newDetailedConfigError(fmt.Sprintf("conflict between secrets: duplicate `id: %s`!", secret.Id), nil, c.raw.doc)
+		}
+		secretById[secret.Id] = true
+
+		if mountByTo[secret.To] {
+			return newDetailedConfigError(fmt.Sprintf("conflict between secrets and mounts: duplicate `to: %s`!", secret.To), nil, c.raw.doc)
+		}
+		mountByTo[secret.To] = true
+	}
+
 	if !oneOrNone([]bool{c.From != "", c.raw.FromImage != "", c.raw.FromArtifact != ""}) {
 		return newDetailedConfigError("conflict between `from`, `fromImage` and `fromArtifact` directives!", nil, c.raw.doc)
 	}
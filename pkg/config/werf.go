@@ -1,9 +1,7 @@
 package config
 
 import (
-	"errors"
 	"fmt"
-	"strings"
 )
 
 type WerfConfig struct {
@@ -11,6 +9,9 @@ type WerfConfig struct {
 	StapelImages         []*StapelImage
 	ImagesFromDockerfile []*ImageFromDockerfile
 	Artifacts            []*StapelImageArtifact
+
+	// LocalIncludeRelPaths are the paths of the files included from the project directory by the meta config section
+	LocalIncludeRelPaths []string
 }
 
 func (c *WerfConfig) HasImageOrArtifact(imageName string) bool {
@@ -163,28 +164,40 @@ func (c *WerfConfig) validateImagesNames() error {
 }
 
 func (c *WerfConfig) associateImportsArtifacts() error {
-	var relatedImageImages []ImageInterface
-	var artifactImports []*Import
+	var imports []*Import
 
 	for _, image := range c.StapelImages {
-		relatedImageImages = append(relatedImageImages, c.relatedImageImages(image)...)
+		imports = append(imports, image.Import...)
 	}
 
 	for _, artifact := range c.Artifacts {
-		relatedImageImages = append(relatedImageImages, c.relatedImageImages(artifact)...)
+		imports = append(imports, artifact.Import...)
 	}
 
-	for _, relatedImageInterface := range relatedImageImages {
-		switch relatedImageInterface.(type) {
-		case *StapelImage:
-			artifactImports = append(artifactImports, relatedImageInterface.(*StapelImage).Import...)
-		case *StapelImageArtifact:
-			artifactImports = append(artifactImports, relatedImageInterface.(*StapelImageArtifact).Import...)
+	for _, imp := range imports {
+		if err := c.validateImportImage(imp); err != nil {
+			return err
 		}
 	}
 
-	for _, artifactImport := range artifactImports {
-		if err := c.validateImportImage(artifactImport); err != nil {
+	return nil
+}
+
+func (c *WerfConfig) validateImagesDependencies() error {
+	for _, image := range c.StapelImages {
+		if err := validateDependencies(c, image.Name, image.Dependencies); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range c.ImagesFromDockerfile {
+		if err := validateDependencies(c, image.Name, image.Dependencies); err != nil {
+			return err
+		}
+	}
+
+	for _, artifact := range c.Artifacts {
+		if err := validateDependencies(c, artifact.Name, artifact.Dependencies); err != nil {
 			return err
 		}
 	}
@@ -195,16 +208,7 @@ func (c *WerfConfig) associateImportsArtifacts() error {
 func (c *WerfConfig) validateImportImage(i *Import) error {
 	if i.ImageName != "" {
 		if interf := c.GetImage(i.ImageName); interf == nil {
-			var imageName string
-
-			switch image := interf.(type) {
-			case *StapelImage:
-				imageName = image.Name
-			case *ImageFromDockerfile:
-				imageName = image.Name
-			}
-
-			return newDetailedConfigError(fmt.Sprintf("no such image `%s`!", imageName), i.raw, i.raw.rawStapelImage.doc)
+			return newDetailedConfigError(fmt.Sprintf("no such image `%s`!", i.ImageName), i.raw, i.raw.rawStapelImage.doc)
 		}
 	} else if i.ArtifactName != "" {
 		if imageArtifact := c.GetArtifact(i.ArtifactName); imageArtifact == nil {
@@ -258,183 +262,44 @@ func (c *WerfConfig) validateImageFrom(i *StapelImageBase) error {
 }
 
 func (c *WerfConfig) validateInfiniteLoopBetweenRelatedImages() error {
-	var imageAndArtifactNames []string
-
-	for _, image := range c.StapelImages {
-		imageAndArtifactNames = append(imageAndArtifactNames, image.Name)
-	}
-
+	images := c.GetAllImages()
 	for _, artifact := range c.Artifacts {
-		imageAndArtifactNames = append(imageAndArtifactNames, artifact.Name)
-	}
-
-	for _, imageOrArtifactName := range imageAndArtifactNames {
-		if err, errImagesStack := c.validateImageInfiniteLoop(imageOrArtifactName, []string{}); err != nil {
-			return fmt.Errorf("%s: %s", err, strings.Join(errImagesStack, " -> "))
-		}
+		images = append(images, artifact)
 	}
 
-	return nil
+	_, err := c.GetImageGraph(images)
+	return err
 }
 
-func (c *WerfConfig) ImagesWithDependenciesBySets(images []ImageInterface) (sets [][]ImageInterface) {
-	sets = [][]ImageInterface{}
-	isDepChecked := map[ImageInterface]bool{}
-	imageDepsToHandle := c.imageDependenciesInOrder(images)
-
-	for len(imageDepsToHandle) != 0 {
-		var currentDeps []ImageInterface
-
-	outerLoop:
-		for image, deps := range imageDepsToHandle {
-			for _, dep := range deps {
-				_, ok := isDepChecked[dep]
-				if !ok {
-					continue outerLoop
-				}
-			}
-
-			currentDeps = append(currentDeps, image)
-		}
-
-		for _, dep := range currentDeps {
-			isDepChecked[dep] = true
-			delete(imageDepsToHandle, dep)
-		}
-
-		sets = append(sets, currentDeps)
+// ImagesWithDependenciesBySets returns the images and all their dependencies grouped by sets of the image graph in the build order
+func (c *WerfConfig) ImagesWithDependenciesBySets(images []ImageInterface) ([][]ImageInterface, error) {
+	graph, err := c.GetImageGraph(images)
+	if err != nil {
+		return nil, err
 	}
 
-	return sets
-}
-
-func (c *WerfConfig) imageDependenciesInOrder(images []ImageInterface) (imageDeps map[ImageInterface][]ImageInterface) {
-	imageDeps = map[ImageInterface][]ImageInterface{}
-	stack := images
-
-	for len(stack) != 0 {
-		current := stack[0]
-		stack = stack[1:]
-
-		imageDeps[current] = c.imageDependencies(current)
-
-	outerLoop:
-		for _, dep := range imageDeps[current] {
-			for key, _ := range imageDeps {
-				if key == dep {
-					continue outerLoop
-				}
-			}
-
-			stack = append(stack, dep)
-		}
-	}
-
-	return imageDeps
-}
-
-func (c *WerfConfig) imageDependencies(interf ImageInterface) (deps []ImageInterface) {
-	switch i := interf.(type) {
-	case StapelImageInterface:
-		if i.ImageBaseConfig().FromImageName != "" {
-			deps = append(deps, c.GetImage(i.ImageBaseConfig().FromImageName))
-		}
-
-		if i.ImageBaseConfig().FromArtifactName != "" {
-			deps = append(deps, c.GetArtifact(i.ImageBaseConfig().FromArtifactName))
-		}
-
-		for _, imp := range i.imports() {
-			if imp.ImageName != "" {
-				deps = append(deps, c.GetImage(imp.ImageName))
-			} else if imp.ArtifactName != "" {
-				deps = append(deps, c.GetArtifact(imp.ArtifactName))
-			}
-		}
-	case *ImageFromDockerfile:
-	}
-
-	return deps
-}
-
-func (c *WerfConfig) relatedImageImages(interf ImageInterface) (images []ImageInterface) {
-	images = append(images, interf)
-	switch i := interf.(type) {
-	case StapelImageInterface:
-		if i.ImageBaseConfig().FromImageName != "" {
-			images = append(images, c.relatedImageImages(c.GetImage(i.ImageBaseConfig().FromImageName))...)
+	var sets [][]ImageInterface
+	for _, setNames := range graph.Sets {
+		var set []ImageInterface
+		for _, name := range setNames {
+			set = append(set, graph.GetNode(name).image)
 		}
 
-		if i.ImageBaseConfig().FromArtifactName != "" {
-			images = append(images, c.relatedImageImages(c.GetArtifact(i.ImageBaseConfig().FromArtifactName))...)
-		}
-	case *ImageFromDockerfile:
+		sets = append(sets, set)
 	}
 
-	return
+	return sets, nil
 }
 
-func (c *WerfConfig) validateImageInfiniteLoop(imageOrArtifactName string, imageNameStack []string) (error, []string) {
-	for _, stackImageName := range imageNameStack {
-		if stackImageName == imageOrArtifactName {
-			return errors.New("infinite loop detected"), []string{imageOrArtifactName}
-		}
-	}
-	imageNameStack = append(imageNameStack, imageOrArtifactName)
-
-	var image StapelImageInterface
-	interf := c.GetImage(imageOrArtifactName)
-	if interf != nil {
-		switch i := interf.(type) {
-		case *ImageFromDockerfile:
-			return nil, imageNameStack
-		case *StapelImage:
-			image = i
-		}
-	} else {
-		image = c.GetArtifact(imageOrArtifactName)
-		if image == nil {
-			panic("runtime error")
-		}
-	}
-
-	imageBaseConfig := image.ImageBaseConfig()
-	if imageBaseConfig == nil {
-		panic("runtime error")
-	}
-
-	if imageBaseConfig.FromImageName != "" {
-		if err, errImagesStack := c.validateImageInfiniteLoop(imageBaseConfig.FromImageName, imageNameStack); err != nil {
-			return err, append([]string{imageOrArtifactName}, errImagesStack...)
-		}
-	}
-
-	if imageBaseConfig.FromArtifactName != "" {
-		if err, errImagesStack := c.validateImageInfiniteLoop(imageBaseConfig.FromArtifactName, imageNameStack); err != nil {
-			return err, append([]string{imageOrArtifactName}, errImagesStack...)
-		}
-	}
-
-	for _, imp := range image.imports() {
-		if imp.ImageName != "" {
-			var importImageName string
-
-			switch i := c.GetImage(imp.ImageName).(type) {
-			case *ImageFromDockerfile:
-				importImageName = i.Name
-			case *StapelImage:
-				importImageName = i.Name
-			}
-
-			if err, errImagesStack := c.validateImageInfiniteLoop(importImageName, imageNameStack); err != nil {
-				return err, append([]string{imageOrArtifactName}, errImagesStack...)
-			}
-		} else if imp.ArtifactName != "" {
-			if err, errImagesStack := c.validateImageInfiniteLoop(imp.ArtifactName, imageNameStack); err != nil {
-				return err, append([]string{imageOrArtifactName}, errImagesStack...)
-			}
+// GetImageDependencies returns images and artifacts, which the image is based on, imports files from or depends on
+func (c *WerfConfig) GetImageDependencies(interf ImageInterface) (deps []ImageInterface) {
+	added := map[string]bool{}
+	for _, edge := range c.getImageGraphEdges(interf) {
+		if !added[edge.To] {
+			added[edge.To] = true
+			deps = append(deps, edge.dependency)
 		}
 	}
 
-	return nil, nil
+	return deps
 }
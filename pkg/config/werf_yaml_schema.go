@@ -0,0 +1,1142 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	yaml_v3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+// TODO: use embedded file werf_yaml_schema.json instead
+const werfYamlSchemaJson = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "werf.yaml",
+  "description": "Config section of werf.yaml (part of YAML stream separated by three hyphens): meta, stapel image or artifact, image from dockerfile, image template",
+  "type": "object",
+  "if": {
+    "required": ["configVersion"]
+  },
+  "then": {
+    "$ref": "#/definitions/meta"
+  },
+  "else": {
+    "if": {
+      "required": ["template"]
+    },
+    "then": {
+      "$ref": "#/definitions/imageTemplate"
+    },
+    "else": {
+      "if": {
+        "required": ["dockerfile"]
+      },
+      "then": {
+        "$ref": "#/definitions/imageFromDockerfile"
+      },
+      "else": {
+        "if": {
+          "required": ["extends"]
+        },
+        "then": {
+          "$ref": "#/definitions/extendingImage"
+        },
+        "else": {
+          "$ref": "#/definitions/stapelImage"
+        }
+      }
+    }
+  },
+  "definitions": {
+    "scalar": {
+      "type": ["string", "number", "boolean"]
+    },
+    "stringOrStringArray": {
+      "type": ["string", "array"],
+      "items": {
+        "type": "string"
+      }
+    },
+    "imageName": {
+      "description": "Image name, list of image names or null for the single unnamed image",
+      "type": ["string", "array", "null"],
+      "items": {
+        "type": "string"
+      }
+    },
+    "extends": {
+      "description": "Template name or list of templates names",
+      "$ref": "#/definitions/stringOrStringArray"
+    },
+    "meta": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["configVersion", "project"],
+      "properties": {
+        "configVersion": {
+          "type": "integer",
+          "enum": [1]
+        },
+        "project": {
+          "type": "string",
+          "minLength": 1
+        },
+        "deploy": {
+          "$ref": "#/definitions/metaDeploy"
+        },
+        "cleanup": {
+          "$ref": "#/definitions/metaCleanup"
+        },
+        "gitWorktree": {
+          "$ref": "#/definitions/metaGitWorktree"
+        },
+        "include": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/metaInclude"
+          }
+        }
+      }
+    },
+    "metaDeploy": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "helmChartDir": {
+          "type": "string"
+        },
+        "helmRelease": {
+          "type": "string"
+        },
+        "helmReleaseSlug": {
+          "type": "boolean"
+        },
+        "namespace": {
+          "type": "string"
+        },
+        "namespaceSlug": {
+          "type": "boolean"
+        }
+      }
+    },
+    "metaCleanup": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "strategy": {
+          "type": "string",
+          "enum": ["gitHistory", "age"]
+        },
+        "keepPolicies": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/metaCleanupKeepPolicy"
+          }
+        },
+        "stagesPerImage": {
+          "$ref": "#/definitions/metaCleanupStagesPerImage"
+        },
+        "kubernetesResources": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/metaCleanupKubernetesResource"
+          }
+        },
+        "sizeQuota": {
+          "description": "Size, e.g. 50GiB",
+          "type": "string",
+          "pattern": "^[0-9]+(\\.[0-9]+)?\\s?([kKmMgGtTpPeE]i?)?[bB]?$"
+        }
+      }
+    },
+    "metaCleanupKeepPolicy": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "references": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "tag": {
+              "type": "string"
+            },
+            "branch": {
+              "type": "string"
+            },
+            "limit": {
+              "$ref": "#/definitions/metaCleanupKeepPolicyLimit"
+            }
+          }
+        },
+        "imagesPerReference": {
+          "$ref": "#/definitions/metaCleanupKeepPolicyLimit"
+        }
+      }
+    },
+    "metaCleanupKeepPolicyLimit": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "last": {
+          "type": "integer"
+        },
+        "in": {
+          "description": "Duration, e.g. 12h",
+          "type": ["string", "integer"]
+        },
+        "operator": {
+          "type": "string",
+          "enum": ["And", "Or"]
+        }
+      }
+    },
+    "metaCleanupStagesPerImage": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "last": {
+          "type": "integer",
+          "minimum": 0
+        },
+        "in": {
+          "description": "Duration, e.g. 12h",
+          "type": ["string", "integer"]
+        }
+      }
+    },
+    "metaCleanupKubernetesResource": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["version", "resource", "imagePaths"],
+      "properties": {
+        "group": {
+          "type": "string"
+        },
+        "version": {
+          "type": "string"
+        },
+        "resource": {
+          "type": "string"
+        },
+        "imagePaths": {
+          "type": "array",
+          "minItems": 1,
+          "items": {
+            "type": "string",
+            "pattern": "^\\{.*\\}$"
+          }
+        }
+      }
+    },
+    "metaGitWorktree": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "forceShallowClone": {
+          "type": "boolean"
+        },
+        "allowUnshallow": {
+          "type": "boolean"
+        },
+        "allowFetchOriginBranchesAndTags": {
+          "type": "boolean"
+        }
+      }
+    },
+    "metaInclude": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["path"],
+      "properties": {
+        "path": {
+          "description": "The path of the included file relative to the project directory (could be a glob) or to the git repository root",
+          "type": "string"
+        },
+        "git": {
+          "description": "The url of the git repository to include the file from",
+          "type": "string"
+        },
+        "branch": {
+          "type": "string"
+        },
+        "tag": {
+          "type": "string"
+        },
+        "commit": {
+          "type": "string"
+        }
+      }
+    },
+    "stapelImage": {
+      "type": "object",
+      "additionalProperties": false,
+      "anyOf": [
+        {
+          "required": ["image"]
+        },
+        {
+          "required": ["artifact"]
+        }
+      ],
+      "properties": {
+        "image": {
+          "$ref": "#/definitions/imageName"
+        },
+        "artifact": {
+          "type": "string",
+          "minLength": 1
+        },
+        "from": {
+          "type": "string"
+        },
+        "fromLatest": {
+          "type": "boolean"
+        },
+        "fromCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "fromImage": {
+          "type": "string"
+        },
+        "fromArtifact": {
+          "type": "string"
+        },
+        "git": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/git"
+          }
+        },
+        "shell": {
+          "$ref": "#/definitions/shell"
+        },
+        "ansible": {
+          "$ref": "#/definitions/ansible"
+        },
+        "mount": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/mount"
+          }
+        },
+        "secrets": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/secret"
+          }
+        },
+        "docker": {
+          "$ref": "#/definitions/docker"
+        },
+        "import": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/import"
+          }
+        },
+        "dependencies": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/dependency"
+          }
+        },
+        "platform": {
+          "$ref": "#/definitions/platform"
+        }
+      }
+    },
+    "git": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "url": {
+          "type": "string"
+        },
+        "branch": {
+          "type": "string"
+        },
+        "tag": {
+          "type": "string"
+        },
+        "commit": {
+          "type": "string"
+        },
+        "add": {
+          "type": "string"
+        },
+        "to": {
+          "type": "string"
+        },
+        "includePaths": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "excludePaths": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "owner": {
+          "type": "string"
+        },
+        "group": {
+          "type": "string"
+        },
+        "stageDependencies": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "install": {
+              "$ref": "#/definitions/stringOrStringArray"
+            },
+            "beforeSetup": {
+              "$ref": "#/definitions/stringOrStringArray"
+            },
+            "setup": {
+              "$ref": "#/definitions/stringOrStringArray"
+            }
+          }
+        }
+      }
+    },
+    "shell": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "beforeInstall": {
+          "$ref": "#/definitions/shellCommands"
+        },
+        "install": {
+          "$ref": "#/definitions/shellCommands"
+        },
+        "beforeSetup": {
+          "$ref": "#/definitions/shellCommands"
+        },
+        "setup": {
+          "$ref": "#/definitions/shellCommands"
+        },
+        "cacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "beforeInstallCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "installCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "beforeSetupCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "setupCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        }
+      }
+    },
+    "shellCommands": {
+      "description": "Command, conditional commands group or list of them",
+      "anyOf": [
+        {
+          "type": "string"
+        },
+        {
+          "$ref": "#/definitions/shellCommandsGroup"
+        },
+        {
+          "type": "array",
+          "items": {
+            "anyOf": [
+              {
+                "type": "string"
+              },
+              {
+                "$ref": "#/definitions/shellCommandsGroup"
+              }
+            ]
+          }
+        }
+      ]
+    },
+    "shellCommandsGroup": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["run"],
+      "properties": {
+        "run": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "when": {
+          "$ref": "#/definitions/condition"
+        }
+      }
+    },
+    "ansible": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "beforeInstall": {
+          "$ref": "#/definitions/ansibleTasks"
+        },
+        "install": {
+          "$ref": "#/definitions/ansibleTasks"
+        },
+        "beforeSetup": {
+          "$ref": "#/definitions/ansibleTasks"
+        },
+        "setup": {
+          "$ref": "#/definitions/ansibleTasks"
+        },
+        "cacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "beforeInstallCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "installCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "beforeSetupCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "setupCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        }
+      }
+    },
+    "ansibleTasks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "block": {
+            "$ref": "#/definitions/ansibleTasks"
+          },
+          "rescue": {
+            "$ref": "#/definitions/ansibleTasks"
+          },
+          "always": {
+            "$ref": "#/definitions/ansibleTasks"
+          },
+          "onlyIf": {
+            "$ref": "#/definitions/condition"
+          }
+        }
+      }
+    },
+    "condition": {
+      "description": "Condition is met when the werf environment and the image name match one of the glob patterns",
+      "type": "object",
+      "additionalProperties": false,
+      "minProperties": 1,
+      "properties": {
+        "env": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "image": {
+          "$ref": "#/definitions/stringOrStringArray"
+        }
+      }
+    },
+    "mount": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["to"],
+      "properties": {
+        "to": {
+          "type": "string"
+        },
+        "from": {
+          "type": "string",
+          "enum": ["tmp_dir", "build_dir", "cache"]
+        },
+        "fromPath": {
+          "type": "string"
+        },
+        "id": {
+          "type": "string",
+          "pattern": "^[a-zA-Z0-9][a-zA-Z0-9_.-]*$"
+        },
+        "sharing": {
+          "type": "string",
+          "enum": ["shared", "locked", "private"]
+        },
+        "scope": {
+          "type": "string",
+          "enum": ["project", "image"]
+        }
+      }
+    },
+    "secret": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["id"],
+      "oneOf": [
+        {
+          "required": ["env"]
+        },
+        {
+          "required": ["src"]
+        }
+      ],
+      "properties": {
+        "id": {
+          "type": "string",
+          "pattern": "^[a-zA-Z0-9][a-zA-Z0-9_.-]*$"
+        },
+        "env": {
+          "type": "string"
+        },
+        "src": {
+          "type": "string"
+        },
+        "to": {
+          "type": "string"
+        }
+      }
+    },
+    "docker": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "VOLUME": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "EXPOSE": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "ENV": {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/scalar"
+          }
+        },
+        "LABEL": {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/scalar"
+          }
+        },
+        "CMD": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "WORKDIR": {
+          "type": "string"
+        },
+        "USER": {
+          "$ref": "#/definitions/scalar"
+        },
+        "ENTRYPOINT": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "HEALTHCHECK": {
+          "type": "string"
+        }
+      }
+    },
+    "import": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["add"],
+      "oneOf": [
+        {
+          "required": ["image"]
+        },
+        {
+          "required": ["artifact"]
+        }
+      ],
+      "properties": {
+        "image": {
+          "type": "string"
+        },
+        "artifact": {
+          "type": "string"
+        },
+        "before": {
+          "type": "string",
+          "enum": ["install", "setup"]
+        },
+        "after": {
+          "type": "string",
+          "enum": ["install", "setup"]
+        },
+        "stage": {
+          "type": "string",
+          "enum": ["beforeInstall", "install", "beforeSetup", "setup"]
+        },
+        "add": {
+          "type": "string"
+        },
+        "to": {
+          "type": "string"
+        },
+        "includePaths": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "excludePaths": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "owner": {
+          "type": "string"
+        },
+        "group": {
+          "type": "string"
+        }
+      }
+    },
+    "dependency": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["image"],
+      "properties": {
+        "image": {
+          "description": "The name of the image defined in werf.yaml, which should be built before the current image",
+          "type": "string"
+        },
+        "before": {
+          "description": "The stage of the Stapel image, before which the dependency is used",
+          "type": "string",
+          "enum": ["install", "setup"]
+        },
+        "after": {
+          "description": "The stage of the Stapel image, after which the dependency is used",
+          "type": "string",
+          "enum": ["install", "setup"]
+        },
+        "imports": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "additionalProperties": false,
+            "required": ["type"],
+            "properties": {
+              "type": {
+                "description": "The property of the built dependency image",
+                "type": "string",
+                "enum": ["ImageName", "ImageRepo", "ImageTag", "ImageID", "ImageDigest"]
+              },
+              "targetBuildArg": {
+                "description": "The build arg of the Dockerfile image, which is set to the property value",
+                "type": "string",
+                "pattern": "^[a-zA-Z_][a-zA-Z0-9_]*$"
+              },
+              "targetEnv": {
+                "description": "The environment variable of the Stapel image, which is set to the property value",
+                "type": "string",
+                "pattern": "^[a-zA-Z_][a-zA-Z0-9_]*$"
+              }
+            }
+          }
+        }
+      }
+    },
+    "platform": {
+      "description": "Target platform in the OS/ARCH[/VARIANT] format or list of platforms, the image is built for each platform and published as the manifest list",
+      "type": ["string", "array"],
+      "pattern": "^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$",
+      "items": {
+        "type": "string",
+        "pattern": "^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$"
+      },
+      "uniqueItems": true
+    },
+    "imageFromDockerfile": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["image"],
+      "properties": {
+        "image": {
+          "$ref": "#/definitions/imageName"
+        },
+        "dockerfile": {
+          "type": "string"
+        },
+        "context": {
+          "type": "string"
+        },
+        "contextAddFile": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "target": {
+          "type": "string"
+        },
+        "args": {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/scalar"
+          }
+        },
+        "addHost": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "network": {
+          "type": "string"
+        },
+        "ssh": {
+          "type": "string"
+        },
+        "staged": {
+          "type": "boolean"
+        },
+        "platform": {
+          "$ref": "#/definitions/platform"
+        },
+        "dependencies": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/dependency"
+          }
+        },
+        "extends": {
+          "$ref": "#/definitions/extends"
+        }
+      }
+    },
+    "imageTemplate": {
+      "description": "Abstract image section, which is not built and could be extended by images and other templates",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["template"],
+      "properties": {
+        "template": {
+          "type": "string",
+          "minLength": 1
+        },
+        "from": {
+          "type": "string"
+        },
+        "fromLatest": {
+          "type": "boolean"
+        },
+        "fromCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "fromImage": {
+          "type": "string"
+        },
+        "fromArtifact": {
+          "type": "string"
+        },
+        "git": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/git"
+          }
+        },
+        "shell": {
+          "$ref": "#/definitions/shell"
+        },
+        "ansible": {
+          "$ref": "#/definitions/ansible"
+        },
+        "mount": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/mount"
+          }
+        },
+        "secrets": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/secret"
+          }
+        },
+        "docker": {
+          "$ref": "#/definitions/docker"
+        },
+        "import": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/import"
+          }
+        },
+        "dependencies": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/dependency"
+          }
+        },
+        "platform": {
+          "$ref": "#/definitions/platform"
+        },
+        "dockerfile": {
+          "type": "string"
+        },
+        "context": {
+          "type": "string"
+        },
+        "contextAddFile": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "target": {
+          "type": "string"
+        },
+        "args": {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/scalar"
+          }
+        },
+        "addHost": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "network": {
+          "type": "string"
+        },
+        "ssh": {
+          "type": "string"
+        },
+        "staged": {
+          "type": "boolean"
+        },
+        "extends": {
+          "$ref": "#/definitions/extends"
+        }
+      }
+    },
+    "extendingImage": {
+      "description": "Stapel image, artifact or image from dockerfile, which extends templates",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["extends"],
+      "anyOf": [
+        {
+          "required": ["image"]
+        },
+        {
+          "required": ["artifact"]
+        }
+      ],
+      "properties": {
+        "image": {
+          "$ref": "#/definitions/imageName"
+        },
+        "artifact": {
+          "type": "string",
+          "minLength": 1
+        },
+        "from": {
+          "type": "string"
+        },
+        "fromLatest": {
+          "type": "boolean"
+        },
+        "fromCacheVersion": {
+          "$ref": "#/definitions/scalar"
+        },
+        "fromImage": {
+          "type": "string"
+        },
+        "fromArtifact": {
+          "type": "string"
+        },
+        "git": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/git"
+          }
+        },
+        "shell": {
+          "$ref": "#/definitions/shell"
+        },
+        "ansible": {
+          "$ref": "#/definitions/ansible"
+        },
+        "mount": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/mount"
+          }
+        },
+        "secrets": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/secret"
+          }
+        },
+        "docker": {
+          "$ref": "#/definitions/docker"
+        },
+        "import": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/import"
+          }
+        },
+        "dependencies": {
+          "type": "array",
+          "items": {
+            "$ref": "#/definitions/dependency"
+          }
+        },
+        "platform": {
+          "$ref": "#/definitions/platform"
+        },
+        "dockerfile": {
+          "type": "string"
+        },
+        "context": {
+          "type": "string"
+        },
+        "contextAddFile": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "target": {
+          "type": "string"
+        },
+        "args": {
+          "type": "object",
+          "additionalProperties": {
+            "$ref": "#/definitions/scalar"
+          }
+        },
+        "addHost": {
+          "$ref": "#/definitions/stringOrStringArray"
+        },
+        "network": {
+          "type": "string"
+        },
+        "ssh": {
+          "type": "string"
+        },
+        "staged": {
+          "type": "boolean"
+        },
+        "extends": {
+          "$ref": "#/definitions/extends"
+        }
+      }
+    }
+  }
+}
+`
+
+// WerfYamlSchema returns JSON Schema of werf.yaml config sections, which could be used by editors
+func WerfYamlSchema() string {
+	return werfYamlSchemaJson
+}
+
+type ValidationError struct {
+	FilePath string
+	Line     int
+	Column   int
+	Field    string
+	Message  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.FilePath, e.Line, e.Column, e.Field, e.Message)
+}
+
+// validateDocsWithSchema returns errors of all docs, positions are lines of the rendered config file
+func validateDocsWithSchema(docs []*doc) ([]*ValidationError, error) {
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(werfYamlSchemaJson))
+	if err != nil {
+		panic(fmt.Sprint("unexpected error: ", err))
+	}
+
+	var validationErrors []*ValidationError
+	for _, d := range docs {
+		docValidationErrors, err := validateDocWithSchema(schema, d)
+		if err != nil {
+			return nil, err
+		}
+
+		validationErrors = append(validationErrors, docValidationErrors...)
+	}
+
+	return validationErrors, nil
+}
+
+func validateDocWithSchema(schema *gojsonschema.Schema, d *doc) ([]*ValidationError, error) {
+	data, err := yaml.YAMLToJSON(d.Content)
+	if err != nil {
+		return nil, newYamlUnmarshalError(err, d)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return nil, newYamlUnmarshalError(err, d)
+	}
+
+	var node yaml_v3.Node
+	if err := yaml_v3.Unmarshal(d.Content, &node); err != nil {
+		return nil, newYamlUnmarshalError(err, d)
+	}
+
+	var validationErrors []*ValidationError
+	for _, resultError := range result.Errors() {
+		switch resultError.Type() {
+		case "condition_then", "condition_else":
+			// errors of the selected config section schema are reported separately
+			continue
+		}
+
+		// keys could contain dots (e.g. LABEL names), so the path is split by the unprintable delimiter
+		path := strings.Split(resultError.Context().String("\x00"), "\x00")[1:]
+		field := strings.Join(path, ".")
+		if property, ok := resultError.Details()["property"].(string); ok && resultError.Type() == "additional_property_not_allowed" {
+			path = append(path, property)
+			field = strings.Join(path, ".")
+		}
+
+		if field == "" {
+			field = "(root)"
+		}
+
+		line, column := findYamlNodePosition(&node, path)
+		validationErrors = append(validationErrors, &ValidationError{
+			FilePath: d.RenderFilePath,
+			Line:     d.Line + line,
+			Column:   column,
+			Field:    field,
+			Message:  resultError.Description(),
+		})
+	}
+
+	sort.SliceStable(validationErrors, func(i, j int) bool {
+		if validationErrors[i].Line != validationErrors[j].Line {
+			return validationErrors[i].Line < validationErrors[j].Line
+		}
+		return validationErrors[i].Column < validationErrors[j].Column
+	})
+
+	return validationErrors, nil
+}
+
+// findYamlNodePosition returns the position of the deepest found key or item of the path
+func findYamlNodePosition(node *yaml_v3.Node, path []string) (int, int) {
+	if node.Kind == yaml_v3.DocumentNode && len(node.Content) != 0 {
+		node = node.Content[0]
+	}
+
+	line, column := node.Line, node.Column
+	for _, part := range path {
+		if node.Kind == yaml_v3.AliasNode {
+			node = node.Alias
+		}
+
+		var next *yaml_v3.Node
+		switch node.Kind {
+		case yaml_v3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part {
+					line, column = node.Content[i].Line, node.Content[i].Column
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml_v3.SequenceNode:
+			if ind, err := strconv.Atoi(part); err == nil && ind < len(node.Content) {
+				next = node.Content[ind]
+				line, column = next.Line, next.Column
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		node = next
+	}
+
+	return line, column
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type schemaEntry struct {
+	content        string
+	expectedErrors []string
+}
+
+var _ = DescribeTable("validating werf.yaml section with schema", func(e schemaEntry) {
+	validationErrors, err := validateDocsWithSchema([]*doc{{Line: 10, Content: []byte(e.content), RenderFilePath: "werf.yaml"}})
+	Ω(err).ShouldNot(HaveOccurred())
+
+	var errors []string
+	for _, validationError := range validationErrors {
+		errors = append(errors, validationError.Error())
+	}
+	Ω(errors).Should(Equal(e.expectedErrors))
+},
+	Entry("valid meta", schemaEntry{
+		"configVersion: 1\nproject: app\n",
+		nil,
+	}),
+	Entry("meta with unknown field", schemaEntry{
+		"configVersion: 1\nproject: app\ndeploy:\n  helmRelese: app\n",
+		[]string{"werf.yaml:14:3: deploy.helmRelese: Additional property helmRelese is not allowed"},
+	}),
+	Entry("meta with includes", schemaEntry{
+		"configVersion: 1\nproject: app\ninclude:\n- path: services/*/werf.yaml\n- git: https://github.com/company/werf-shared.git\n  commit: 5d14fa0\n  path: werf.yaml\n- git: https://github.com/company/werf-shared.git\n",
+		[]string{"werf.yaml:18:3: include.2: path is required"},
+	}),
+	Entry("meta with age-based cleanup", schemaEntry{
+		"configVersion: 1\nproject: app\ncleanup:\n  strategy: age\n  stagesPerImage:\n    last: -1\n    in: 168h\n",
+		[]string{"werf.yaml:16:5: cleanup.stagesPerImage.last: Must be greater than or equal to 0"},
+	}),
+	Entry("meta with cleanup kubernetes resources", schemaEntry{
+		"configVersion: 1\nproject: app\ncleanup:\n  kubernetesResources:\n  - group: argoproj.io\n    version: v1alpha1\n    resource: rollouts\n    imagePaths:\n    - \"{.spec.template.spec.containers[*].image}\"\n  - version: v1\n    resource: services\n    imagePaths: [.spec.image]\n",
+		[]string{"werf.yaml:22:18: cleanup.kubernetesResources.1.imagePaths.0: Does not match pattern '^\\{.*\\}$'"},
+	}),
+	Entry("meta with cleanup size quota", schemaEntry{
+		"configVersion: 1\nproject: app\ncleanup:\n  sizeQuota: 50 gigabytes\n",
+		[]string{"werf.yaml:14:3: cleanup.sizeQuota: Does not match pattern '^[0-9]+(\\.[0-9]+)?\\s?([kKmMgGtTpPeE]i?)?[bB]?$'"},
+	}),
+	Entry("stapel image with several errors", schemaEntry{
+		"image: app\nfrom: alpine\nshell:\n  instal: [a]\nmount:\n- to: /x\n  from: bad\n",
+		[]string{
+			"werf.yaml:14:3: shell.instal: Additional property instal is not allowed",
+			"werf.yaml:17:3: mount.0.from: mount.0.from must be one of the following: \"tmp_dir\", \"build_dir\", \"cache\"",
+		},
+	}),
+	Entry("stapel image with cache mount", schemaEntry{
+		"image: app\nfrom: alpine\nmount:\n- from: cache\n  to: /root/.npm\n- from: cache\n  id: go-mod\n  to: /root/go/pkg/mod\n  sharing: exclusive\n  scope: image\n",
+		[]string{"werf.yaml:19:3: mount.1.sharing: mount.1.sharing must be one of the following: \"shared\", \"locked\", \"private\""},
+	}),
+	Entry("stapel image with platforms", schemaEntry{
+		"image: app\nfrom: alpine\nplatform: [linux/amd64, linux/arm64]\n",
+		nil,
+	}),
+	Entry("dockerfile image with invalid platform", schemaEntry{
+		"image: app\ndockerfile: Dockerfile\nplatform: arm64\n",
+		[]string{"werf.yaml:13:1: platform: Does not match pattern '^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$'"},
+	}),
+	Entry("dockerfile image with dependency", schemaEntry{
+		"image: app\ndockerfile: Dockerfile\ndependencies:\n- image: base\n  imports:\n  - type: ImageName\n    targetBuildArg: BASE_IMAGE\n  - type: ImageSize\n    targetBuildArg: 1_SIZE\n",
+		[]string{
+			"werf.yaml:18:5: dependencies.0.imports.1.type: dependencies.0.imports.1.type must be one of the following: \"ImageName\", \"ImageRepo\", \"ImageTag\", \"ImageID\", \"ImageDigest\"",
+			"werf.yaml:19:5: dependencies.0.imports.1.targetBuildArg: Does not match pattern '^[a-zA-Z_][a-zA-Z0-9_]*$'",
+		},
+	}),
+	Entry("dockerfile image with wrong type", schemaEntry{
+		"image: app\ndockerfile: Dockerfile\ntarget: 1\n",
+		[]string{"werf.yaml:13:1: target: Invalid type. Expected: string, given: integer"},
+	}))
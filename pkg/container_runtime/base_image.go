@@ -14,13 +14,13 @@ type baseImage struct {
 	inspect   *types.ImageInspect
 	stageDesc *image.StageDescription
 
-	LocalDockerServerRuntime *LocalDockerServerRuntime
+	ContainerRuntime ContainerRuntime
 }
 
-func newBaseImage(name string, localDockerServerRuntime *LocalDockerServerRuntime) *baseImage {
+func newBaseImage(name string, containerRuntime ContainerRuntime) *baseImage {
 	img := &baseImage{}
 	img.name = name
-	img.LocalDockerServerRuntime = localDockerServerRuntime
+	img.ContainerRuntime = containerRuntime
 	return img
 }
 
@@ -33,7 +33,7 @@ func (i *baseImage) SetName(name string) {
 }
 
 func (i *baseImage) MustResetInspect(ctx context.Context) error {
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.Name()); err != nil {
+	if inspect, err := getImageInspect(ctx, i.ContainerRuntime, i.Name()); err != nil {
 		return fmt.Errorf("unable to get inspect for image %s: %s", i.Name(), err)
 	} else {
 		i.SetInspect(inspect)
@@ -4,8 +4,8 @@ type buildImage struct {
 	*baseImage
 }
 
-func newBuildImage(id string, localDockerServerRuntime *LocalDockerServerRuntime) *buildImage {
+func newBuildImage(id string, containerRuntime ContainerRuntime) *buildImage {
 	image := &buildImage{}
-	image.baseImage = newBaseImage(id, localDockerServerRuntime)
+	image.baseImage = newBaseImage(id, containerRuntime)
 	return image
 }
@@ -0,0 +1,146 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/buildah"
+)
+
+// BuildahRuntime builds and stores images using buildah and does not require docker daemon
+type BuildahRuntime struct{}
+
+func NewBuildahRuntime() *BuildahRuntime {
+	return &BuildahRuntime{}
+}
+
+// GetImageInspect returns docker-compatible inspect of the image from the buildah local storage
+func (runtime *BuildahRuntime) GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error) {
+	inspect, err := buildah.Inspect(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if inspect == nil {
+		return nil, nil
+	}
+
+	return &types.ImageInspect{
+		ID:      inspect.FromImageID,
+		Parent:  inspect.Docker.Parent,
+		Created: inspect.Docker.Created.Format(time.RFC3339Nano),
+		Size:    inspect.Docker.Size,
+		Config: &container.Config{
+			Labels:     inspect.Docker.Config.Labels,
+			Env:        inspect.Docker.Config.Env,
+			Cmd:        strslice.StrSlice(inspect.Docker.Config.Cmd),
+			Entrypoint: strslice.StrSlice(inspect.Docker.Config.Entrypoint),
+			WorkingDir: inspect.Docker.Config.WorkingDir,
+			User:       inspect.Docker.Config.User,
+			Image:      inspect.Docker.Config.Image,
+			OnBuild:    inspect.Docker.Config.OnBuild,
+		},
+	}, nil
+}
+
+func (runtime *BuildahRuntime) PullImage(ctx context.Context, ref string) error {
+	if err := buildah.Pull(ctx, ref); err != nil {
+		return fmt.Errorf("unable to pull image %s: %s", ref, err)
+	}
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RefreshImageObject(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return err
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+	return nil
+}
+
+func (runtime *BuildahRuntime) PullImageFromRegistry(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := dockerImage.Image.Pull(ctx); err != nil {
+		return fmt.Errorf("unable to export image %s: %s", dockerImage.Image.Name(), err)
+	}
+
+	if inspect, err := runtime.GetImageInspect(ctx, dockerImage.Image.Name()); err != nil {
+		return fmt.Errorf("unable to get inspect of image %s: %s", dockerImage.Image.Name(), err)
+	} else {
+		dockerImage.Image.SetInspect(inspect)
+	}
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RenameImage(ctx context.Context, img Image, newImageName string, removeOldName bool) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Tagging image %s by name %s", dockerImage.Image.Name(), newImageName)).DoError(func() error {
+		if err := buildah.Tag(ctx, dockerImage.Image.Name(), newImageName); err != nil {
+			return fmt.Errorf("unable to tag image %s by name %s: %s", dockerImage.Image.Name(), newImageName, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if removeOldName {
+		if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Removing old image tag %s", dockerImage.Image.Name())).DoError(func() error {
+			return buildah.Rmi(ctx, dockerImage.Image.Name())
+		}); err != nil {
+			return err
+		}
+	}
+
+	dockerImage.Image.SetName(newImageName)
+
+	return nil
+}
+
+func (runtime *BuildahRuntime) RemoveImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Removing image tag %s", dockerImage.Image.Name())).DoError(func() error {
+		return buildah.Rmi(ctx, dockerImage.Image.Name())
+	})
+}
+
+func (runtime *BuildahRuntime) PushImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	return logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Pushing %s", dockerImage.Image.Name())).DoError(func() error {
+		return dockerImage.Image.Push(ctx)
+	})
+}
+
+func (runtime *BuildahRuntime) PushBuiltImage(ctx context.Context, img Image) error {
+	dockerImage := img.(*DockerImage)
+
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Tagging built image by name %s", dockerImage.Image.Name())).DoError(func() error {
+		if err := dockerImage.Image.TagBuiltImage(ctx); err != nil {
+			return fmt.Errorf("unable to tag built image by name %s: %s", dockerImage.Image.Name(), err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return runtime.PushImage(ctx, img)
+}
+
+func (runtime *BuildahRuntime) String() string {
+	return "buildah"
+}
@@ -20,6 +20,14 @@ type ContainerRuntime interface {
 	String() string
 }
 
+// LocalImagesRuntime is implemented by runtimes which keep built and pulled images in the local storage
+type LocalImagesRuntime interface {
+	ContainerRuntime
+
+	GetImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
+	PullImage(ctx context.Context, ref string) error
+}
+
 type LocalDockerServerRuntime struct{}
 
 // GetImageInspect only available for LocalDockerServerRuntime
@@ -172,3 +180,11 @@ type LocalHostRuntime struct {
 func (runtime *LocalHostRuntime) String() string {
 	return "localhost"
 }
+
+func getImageInspect(ctx context.Context, containerRuntime ContainerRuntime, ref string) (*types.ImageInspect, error) {
+	runtime, ok := containerRuntime.(LocalImagesRuntime)
+	if !ok {
+		return nil, fmt.Errorf("container runtime %s does not store images locally", containerRuntime.String())
+	}
+	return runtime.GetImageInspect(ctx, ref)
+}
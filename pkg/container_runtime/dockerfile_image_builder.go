@@ -3,23 +3,30 @@ package container_runtime
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
 
+	"github.com/werf/werf/pkg/buildah"
 	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
 )
 
 type DockerfileImageBuilder struct {
+	ContainerRuntime ContainerRuntime
+
 	temporalId      string
 	isBuilt         bool
 	buildArgs       []string
 	filePathToStdin string
 }
 
-func NewDockerfileImageBuilder() *DockerfileImageBuilder {
-	return &DockerfileImageBuilder{temporalId: uuid.New().String()}
+func NewDockerfileImageBuilder(containerRuntime ContainerRuntime) *DockerfileImageBuilder {
+	return &DockerfileImageBuilder{ContainerRuntime: containerRuntime, temporalId: uuid.New().String()}
 }
 
 func (b *DockerfileImageBuilder) GetBuiltId() string {
@@ -38,6 +45,10 @@ func (b *DockerfileImageBuilder) SetFilePathToStdin(path string) {
 }
 
 func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
+	if _, ok := b.ContainerRuntime.(*BuildahRuntime); ok {
+		return b.buildWithBuildah(ctx)
+	}
+
 	buildArgs := append(b.buildArgs, fmt.Sprintf("--tag=%s", b.temporalId))
 
 	if b.filePathToStdin != "" {
@@ -71,7 +82,44 @@ func (b *DockerfileImageBuilder) Build(ctx context.Context) error {
 	return nil
 }
 
+func (b *DockerfileImageBuilder) buildWithBuildah(ctx context.Context) error {
+	contextDir, err := ioutil.TempDir(werf.GetTmpDir(), "buildah-context-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(contextDir)
+
+	if b.filePathToStdin != "" {
+		if err := util.ExtractArchive(b.filePathToStdin, contextDir); err != nil {
+			return fmt.Errorf("unable to extract build context %s: %s", b.filePathToStdin, err)
+		}
+	}
+
+	// docker build arguments which are passed by the dockerfile stage are compatible with buildah bud
+	buildArgs := append(b.buildArgs, fmt.Sprintf("--tag=%s", b.temporalId))
+	for ind, arg := range buildArgs {
+		if strings.HasPrefix(arg, "--file=") {
+			buildArgs[ind] = fmt.Sprintf("--file=%s", filepath.Join(contextDir, strings.TrimPrefix(arg, "--file=")))
+		}
+	}
+
+	if err := buildah.Bud(ctx, contextDir, buildArgs...); err != nil {
+		return err
+	}
+
+	b.isBuilt = true
+
+	return nil
+}
+
 func (b *DockerfileImageBuilder) Cleanup(ctx context.Context) error {
+	if _, ok := b.ContainerRuntime.(*BuildahRuntime); ok {
+		if err := buildah.Rmi(ctx, b.temporalId); err != nil {
+			return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", b.temporalId, err)
+		}
+		return nil
+	}
+
 	if err := docker.CliRmi(ctx, b.temporalId, "--force"); err != nil {
 		return fmt.Errorf("unable to remove temporal dockerfile image %q: %s", b.temporalId, err)
 	}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/buildah"
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
 )
@@ -23,16 +24,27 @@ type StageImage struct {
 	container              *StageImageContainer
 	buildImage             *buildImage
 	dockerfileImageBuilder *DockerfileImageBuilder
+	platform               string
 }
 
-func NewStageImage(fromImage *StageImage, name string, localDockerServerRuntime *LocalDockerServerRuntime) *StageImage {
+func NewStageImage(fromImage *StageImage, name string, containerRuntime ContainerRuntime) *StageImage {
 	stage := &StageImage{}
-	stage.baseImage = newBaseImage(name, localDockerServerRuntime)
+	stage.baseImage = newBaseImage(name, containerRuntime)
 	stage.fromImage = fromImage
 	stage.container = newStageImageContainer(stage)
 	return stage
 }
 
+// SetPlatform sets the target platform (os/arch[/variant]) of the image, which is used to pull, run and build the image,
+// the host platform is used by default
+func (i *StageImage) SetPlatform(platform string) {
+	i.platform = platform
+}
+
+func (i *StageImage) GetPlatform() string {
+	return i.platform
+}
+
 func (i *StageImage) Inspect() *types.ImageInspect {
 	return i.inspect
 }
@@ -54,7 +66,15 @@ func (i *StageImage) GetID() string {
 }
 
 func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
-	if i.dockerfileImageBuilder != nil {
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		if options.IntrospectBeforeError || options.IntrospectAfterError {
+			return fmt.Errorf("introspection is not supported by %s container runtime", i.ContainerRuntime.String())
+		}
+
+		if err := i.buildWithBuildah(ctx); err != nil {
+			return err
+		}
+	} else if i.dockerfileImageBuilder != nil {
 		if err := i.dockerfileImageBuilder.Build(ctx); err != nil {
 			return err
 		}
@@ -120,7 +140,7 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 		}
 	}
 
-	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
+	if inspect, err := getImageInspect(ctx, i.ContainerRuntime, i.MustGetBuiltId()); err != nil {
 		return err
 	} else {
 		i.SetInspect(inspect)
@@ -133,13 +153,49 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 	return nil
 }
 
+func (i *StageImage) buildWithBuildah(ctx context.Context) error {
+	if i.dockerfileImageBuilder != nil {
+		return i.dockerfileImageBuilder.Build(ctx)
+	}
+
+	containerLockName := ContainerLockName(i.container.Name())
+	if _, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
+		return fmt.Errorf("failed to lock %s: %s", containerLockName, err)
+	} else {
+		defer werf.ReleaseHostLock(lock)
+	}
+
+	if len(i.container.prepareAllRunCommands()) != 0 {
+		logboek.Context(ctx).Debug().LogF("Decoded command:\n%s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
+	}
+
+	if err := i.container.runWithBuildah(ctx); err != nil {
+		if rmErr := i.container.rmWithBuildah(ctx); rmErr != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to remove buildah container %s: %s\n", i.container.Name(), rmErr)
+		}
+		return err
+	}
+
+	if err := i.Commit(ctx); err != nil {
+		return err
+	}
+
+	return i.container.rmWithBuildah(ctx)
+}
+
 func (i *StageImage) Commit(ctx context.Context) error {
-	builtId, err := i.container.commit(ctx)
+	var builtId string
+	var err error
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		builtId, err = i.container.commitWithBuildah(ctx)
+	} else {
+		builtId, err = i.container.commit(ctx)
+	}
 	if err != nil {
 		return err
 	}
 
-	i.buildImage = newBuildImage(builtId, i.LocalDockerServerRuntime)
+	i.buildImage = newBuildImage(builtId, i.ContainerRuntime)
 
 	return nil
 }
@@ -195,15 +251,31 @@ func (i *StageImage) GetBuiltId() string {
 }
 
 func (i *StageImage) TagBuiltImage(ctx context.Context) error {
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		return buildah.Tag(ctx, i.MustGetBuiltId(), i.name)
+	}
 	return docker.CliTag(ctx, i.MustGetBuiltId(), i.name)
 }
 
 func (i *StageImage) Tag(ctx context.Context, name string) error {
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		return buildah.Tag(ctx, i.GetID(), name)
+	}
 	return docker.CliTag(ctx, i.GetID(), name)
 }
 
 func (i *StageImage) Pull(ctx context.Context) error {
-	if err := docker.CliPullWithRetries(ctx, i.name); err != nil {
+	var args []string
+	if i.platform != "" {
+		args = append(args, fmt.Sprintf("--platform=%s", i.platform))
+	}
+	args = append(args, i.name)
+
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		if err := buildah.Pull(ctx, args...); err != nil {
+			return err
+		}
+	} else if err := docker.CliPullWithRetries(ctx, args...); err != nil {
 		return err
 	}
 
@@ -213,12 +285,19 @@ func (i *StageImage) Pull(ctx context.Context) error {
 }
 
 func (i *StageImage) Push(ctx context.Context) error {
+	if _, ok := i.ContainerRuntime.(*BuildahRuntime); ok {
+		return buildah.Push(ctx, i.name)
+	}
 	return docker.CliPushWithRetries(ctx, i.name)
 }
 
 func (i *StageImage) DockerfileImageBuilder() *DockerfileImageBuilder {
 	if i.dockerfileImageBuilder == nil {
-		i.dockerfileImageBuilder = NewDockerfileImageBuilder()
+		i.dockerfileImageBuilder = NewDockerfileImageBuilder(i.ContainerRuntime)
+
+		if i.platform != "" {
+			i.dockerfileImageBuilder.AppendBuildArgs(fmt.Sprintf("--platform=%s", i.platform))
+		}
 	}
 	return i.dockerfileImageBuilder
 }
@@ -71,6 +71,7 @@ func (c *StageImageContainer) ServiceCommitChangeOptions() ContainerOptions {
 func (c *StageImageContainer) prepareRunArgs(ctx context.Context) ([]string, error) {
 	var args []string
 	args = append(args, fmt.Sprintf("--name=%s", c.name))
+	args = append(args, c.platformArgs()...)
 
 	runOptions, err := c.prepareRunOptions(ctx)
 	if err != nil {
@@ -169,11 +170,22 @@ func (c *StageImageContainer) prepareIntrospectArgsBase(ctx context.Context) ([]
 	}
 
 	args = append(args, []string{"-ti", "--rm"}...)
+	args = append(args, c.platformArgs()...)
 	args = append(args, runArgs...)
 
 	return args, nil
 }
 
+// platformArgs returns docker run args to run the container for the target platform of the image,
+// emulation (binfmt_misc) is required on the host to run containers for a foreign platform
+func (c *StageImageContainer) platformArgs() []string {
+	if c.image.platform == "" {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("--platform=%s", c.image.platform)}
+}
+
 func (c *StageImageContainer) prepareRunOptions(ctx context.Context) (*StageImageContainerOptions, error) {
 	serviceRunOptions, err := c.prepareServiceRunOptions(ctx)
 	if err != nil {
@@ -0,0 +1,94 @@
+package container_runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/werf/werf/pkg/buildah"
+	"github.com/werf/werf/pkg/stapel"
+)
+
+func (c *StageImageContainer) runWithBuildah(ctx context.Context) error {
+	runOptions, err := c.prepareRunOptionsWithBuildah(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := buildah.From(ctx, c.image.fromImage.GetID(), c.name); err != nil {
+		return fmt.Errorf("unable to create buildah container %s: %s", c.name, err)
+	}
+
+	opts := buildah.RunOptions{
+		Volumes:    runOptions.Volume,
+		User:       runOptions.User,
+		WorkingDir: runOptions.Workdir,
+	}
+
+	if err := buildah.Run(ctx, c.name, opts, runOptions.Entrypoint, "-ec", c.prepareRunCommandWithEnv(runOptions.Env)); err != nil {
+		return fmt.Errorf("container run failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (c *StageImageContainer) prepareRunOptionsWithBuildah(ctx context.Context) (*StageImageContainerOptions, error) {
+	if len(c.runOptions.VolumesFrom) != 0 {
+		return nil, fmt.Errorf("volumes from containers %v are not supported by buildah container runtime", c.runOptions.VolumesFrom)
+	}
+
+	serviceRunOptions := newStageContainerOptions()
+	serviceRunOptions.Workdir = "/"
+	serviceRunOptions.Entrypoint = stapel.BashBinPath()
+	serviceRunOptions.User = "0:0"
+
+	stapelVolume, err := stapel.GetOrCreateBuildahVolume(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serviceRunOptions.Volume = []string{stapelVolume}
+
+	return serviceRunOptions.merge(c.runOptions), nil
+}
+
+// prepareRunCommandWithEnv exports run environment inside the command, because buildah run does not
+// have a way to pass environment which should not be committed into the resulting image
+func (c *StageImageContainer) prepareRunCommandWithEnv(env map[string]string) string {
+	var keys []string
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var commands []string
+	for _, key := range keys {
+		commands = append(commands, fmt.Sprintf("export %s=%s", key, shellQuote(env[key])))
+	}
+	commands = append(commands, c.prepareRunCommands()...)
+
+	return ShelloutPack(strings.Join(commands, " && "))
+}
+
+func (c *StageImageContainer) commitWithBuildah(ctx context.Context) (string, error) {
+	commitOptions := c.serviceCommitChangeOptions.merge(c.commitChangeOptions)
+
+	if err := buildah.Config(ctx, c.name, commitOptions.toBuildahConfigArgs()...); err != nil {
+		return "", fmt.Errorf("unable to configure buildah container %s: %s", c.name, err)
+	}
+
+	id, err := buildah.Commit(ctx, c.name)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (c *StageImageContainer) rmWithBuildah(ctx context.Context) error {
+	return buildah.Rm(ctx, c.name)
+}
+
+func shellQuote(value string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", `'"'"'`))
+}
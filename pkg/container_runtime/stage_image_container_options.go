@@ -277,3 +277,45 @@ func getEmptyEntrypointInstructionValue(ctx context.Context) (string, error) {
 
 	return "[\"\"]", nil
 }
+
+func (co *StageImageContainerOptions) toBuildahConfigArgs() []string {
+	var args []string
+
+	for _, volume := range co.Volume {
+		args = append(args, fmt.Sprintf("--volume=%s", volume))
+	}
+
+	for _, expose := range co.Expose {
+		args = append(args, fmt.Sprintf("--port=%s", expose))
+	}
+
+	for key, value := range co.Env {
+		args = append(args, fmt.Sprintf("--env=%s=%v", key, value))
+	}
+
+	for key, value := range co.Label {
+		args = append(args, fmt.Sprintf("--label=%s=%v", key, value))
+	}
+
+	if co.Workdir != "" {
+		args = append(args, fmt.Sprintf("--workingdir=%s", co.Workdir))
+	}
+
+	if co.User != "" {
+		args = append(args, fmt.Sprintf("--user=%s", co.User))
+	}
+
+	if co.Entrypoint != "" {
+		args = append(args, fmt.Sprintf("--entrypoint=%s", co.Entrypoint))
+	}
+
+	if co.Cmd != "" {
+		args = append(args, fmt.Sprintf("--cmd=%s", co.Cmd))
+	}
+
+	if co.HealthCheck != "" {
+		args = append(args, fmt.Sprintf("--healthcheck=%s", co.HealthCheck))
+	}
+
+	return args
+}
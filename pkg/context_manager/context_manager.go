@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 
@@ -97,3 +98,42 @@ func AddContextAddFileToContextArchive(ctx context.Context, originalArchivePath
 
 	return destinationArchivePath, nil
 }
+
+// AddFileToContextArchive creates a copy of the context archive with the additional file, the archive contains only the file if originalArchivePath is empty
+func AddFileToContextArchive(ctx context.Context, originalArchivePath string, tarEntryName string, data []byte) (string, error) {
+	destinationArchivePath := GetTmpArchivePath()
+
+	addFileFunc := func(tw *tar.Writer) error {
+		now := time.Now()
+		if err := tw.WriteHeader(&tar.Header{
+			Name:       tarEntryName,
+			Mode:       0644,
+			Size:       int64(len(data)),
+			ModTime:    now,
+			AccessTime: now,
+			ChangeTime: now,
+		}); err != nil {
+			return fmt.Errorf("unable to write tar header for file %s: %s", tarEntryName, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("unable to write file %s to archive %q: %s", tarEntryName, destinationArchivePath, err)
+		}
+
+		logboek.Context(ctx).Debug().LogF("Extra file was added: %q\n", tarEntryName)
+
+		return nil
+	}
+
+	var err error
+	if originalArchivePath == "" {
+		err = util.CreateArchive(destinationArchivePath, addFileFunc)
+	} else {
+		err = util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, []string{tarEntryName}, addFileFunc)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return destinationArchivePath, nil
+}
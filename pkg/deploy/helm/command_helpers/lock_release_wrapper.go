@@ -4,14 +4,17 @@ import (
 	"context"
 
 	"github.com/werf/werf/pkg/deploy/lock_manager"
+	"github.com/werf/werf/pkg/tracing"
 )
 
 func LockReleaseWrapper(ctx context.Context, releaseName string, lockManager *lock_manager.LockManager, cmdFunc func() error) error {
-	if lock, err := lockManager.LockRelease(ctx, releaseName); err != nil {
+	_, span := tracing.StartSpan(ctx, "helm.LockRelease", "helm.release", releaseName)
+	lock, err := lockManager.LockRelease(ctx, releaseName)
+	span.End(err)
+	if err != nil {
 		return err
-	} else {
-		defer lockManager.Unlock(lock)
 	}
+	defer lockManager.Unlock(lock)
 
 	return cmdFunc()
 }
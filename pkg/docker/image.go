@@ -224,3 +224,23 @@ func CliBuild_LiveOutputWithCustomIn(ctx context.Context, rc io.ReadCloser, args
 func CliBuild_LiveOutput(ctx context.Context, args ...string) error {
 	return doCliBuild(cli(ctx), args...)
 }
+
+func doCliSave(c command.Cli, args ...string) error {
+	return prepareCliCmd(image.NewSaveCommand(c), args...).Execute()
+}
+
+func CliSave(ctx context.Context, args ...string) error {
+	return callCliWithAutoOutput(ctx, func(c command.Cli) error {
+		return doCliSave(c, args...)
+	})
+}
+
+func doCliLoad(c command.Cli, args ...string) error {
+	return prepareCliCmd(image.NewLoadCommand(c), args...).Execute()
+}
+
+func CliLoad(ctx context.Context, args ...string) error {
+	return callCliWithAutoOutput(ctx, func(c command.Cli) error {
+		return doCliLoad(c, args...)
+	})
+}
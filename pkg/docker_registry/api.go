@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 
 	"github.com/werf/logboek"
 
@@ -84,58 +88,176 @@ func (api *api) GetRepoImageConfigFile(_ context.Context, reference string) (*v1
 }
 
 func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info, error) {
-	imageInfo, _, err := api.image(reference)
+	desc, err := api.descriptor(reference)
 	if err != nil {
 		return nil, err
 	}
 
-	digest, err := imageInfo.Digest()
+	parsedReference, err := name.NewTag(reference, api.parseReferenceOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
-	manifest, err := imageInfo.Manifest()
+	repoImage := &image.Info{
+		Name:       reference,
+		Repository: strings.Join([]string{parsedReference.RegistryStr(), parsedReference.RepositoryStr()}, "/"),
+		Tag:        parsedReference.TagStr(),
+		RepoDigest: desc.Digest.String(),
+	}
+
+	switch desc.MediaType {
+	case types.OCIImageIndex, types.DockerManifestList:
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest list %q: %v", reference, err)
+		}
+
+		if err := setRepoImageIndexInfo(repoImage, index); err != nil {
+			return nil, fmt.Errorf("reading manifest list %q: %v", reference, err)
+		}
+	default:
+		img, err := desc.Image()
+		if err != nil {
+			return nil, fmt.Errorf("reading image %q: %v", reference, err)
+		}
+
+		if err := setRepoImageInfo(repoImage, img); err != nil {
+			return nil, err
+		}
+	}
+
+	return repoImage, nil
+}
+
+func setRepoImageInfo(info *image.Info, img v1.Image) error {
+	manifest, err := img.Manifest()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	configFile, err := imageInfo.ConfigFile()
+	configFile, err := img.ConfigFile()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var totalSize int64
-	if layers, err := imageInfo.Layers(); err != nil {
-		return nil, err
-	} else {
-		for _, l := range layers {
-			if lSize, err := l.Size(); err != nil {
-				return nil, err
-			} else {
-				totalSize += lSize
-			}
+	layers, size, err := getImageLayers(img)
+	if err != nil {
+		return err
+	}
+
+	info.ID = manifest.Config.Digest.String()
+	info.ParentID = configFile.Config.Image
+	info.Labels = configFile.Config.Labels
+	info.Size = size
+	info.Layers = layers
+	info.SetCreatedAtUnix(configFile.Created.Unix())
+
+	return nil
+}
+
+// setRepoImageIndexInfo sets the info of the manifest list, which has no config:
+// the ID is the digest of the manifest list, the size is the total size of the images of the list,
+// the labels and the creation time are of the latest created image,
+// the IDs of the images of the list link the manifest list with the stages of the platforms
+func setRepoImageIndexInfo(info *image.Info, index v1.ImageIndex) error {
+	digest, err := index.Digest()
+	if err != nil {
+		return err
+	}
+	info.ID = digest.String()
+
+	var latestCreatedAt time.Time
+	if err := forEachIndexImage(index, func(img v1.Image) error {
+		configName, err := img.ConfigName()
+		if err != nil {
+			return err
 		}
+		info.PlatformImageIDs = append(info.PlatformImageIDs, configName.String())
+
+		configFile, err := img.ConfigFile()
+		if err != nil {
+			return err
+		}
+
+		layers, size, err := getImageLayers(img)
+		if err != nil {
+			return err
+		}
+		info.Size += size
+		info.Layers = append(info.Layers, layers...)
+
+		if info.Labels == nil || configFile.Created.After(latestCreatedAt) {
+			latestCreatedAt = configFile.Created.Time
+			info.Labels = configFile.Config.Labels
+		}
+
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	parsedReference, err := name.NewTag(reference, api.parseReferenceOptions()...)
+	info.SetCreatedAtUnix(latestCreatedAt.Unix())
+
+	return nil
+}
+
+func forEachIndexImage(index v1.ImageIndex, f func(img v1.Image) error) error {
+	indexManifest, err := index.IndexManifest()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	repoImage := &image.Info{
-		Name:       reference,
-		Repository: strings.Join([]string{parsedReference.RegistryStr(), parsedReference.RepositoryStr()}, "/"),
-		ID:         manifest.Config.Digest.String(),
-		Tag:        parsedReference.TagStr(),
-		RepoDigest: digest.String(),
-		ParentID:   configFile.Config.Image,
-		Labels:     configFile.Config.Labels,
-		Size:       totalSize,
+	for _, desc := range indexManifest.Manifests {
+		switch desc.MediaType {
+		case types.OCIImageIndex, types.DockerManifestList:
+			childIndex, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := forEachIndexImage(childIndex, f); err != nil {
+				return err
+			}
+		default:
+			img, err := index.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			if err := f(img); err != nil {
+				return err
+			}
+		}
 	}
 
-	repoImage.SetCreatedAtUnix(configFile.Created.Unix())
+	return nil
+}
 
-	return repoImage, nil
+// getImageLayers returns the layers of the image and their total size
+func getImageLayers(img v1.Image) ([]image.LayerInfo, int64, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var layersInfo []image.LayerInfo
+	var totalSize int64
+	for _, l := range layers {
+		lDigest, err := l.Digest()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		lSize, err := l.Size()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		layersInfo = append(layersInfo, image.LayerInfo{Digest: lDigest.String(), Size: lSize})
+		totalSize += lSize
+	}
+
+	return layersInfo, totalSize, nil
 }
 
 func (api *api) list(reference string) ([]string, error) {
@@ -202,6 +324,10 @@ func (api *api) pushImage(_ context.Context, reference string, opts *PushImageOp
 		return fmt.Errorf("parsing reference %q: %v", reference, err)
 	}
 
+	if opts != nil && len(opts.PlatformImages) != 0 {
+		return api.pushManifestList(ref, opts.PlatformImages)
+	}
+
 	labels := map[string]string{}
 	if opts != nil {
 		labels = opts.Labels
@@ -221,6 +347,78 @@ func (api *api) pushImage(_ context.Context, reference string, opts *PushImageOp
 	return nil
 }
 
+func (api *api) pushManifestList(ref name.Reference, platformImages map[string]string) error {
+	var platforms []string
+	for platform := range platformImages {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+
+	var addenda []mutate.IndexAddendum
+	for _, platform := range platforms {
+		p, err := parsePlatform(platform)
+		if err != nil {
+			return err
+		}
+
+		img, _, err := api.image(platformImages[platform])
+		if err != nil {
+			return err
+		}
+
+		addenda = append(addenda, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: p},
+		})
+	}
+
+	index := mutate.AppendManifests(empty.Index, addenda...)
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	err := remote.WriteIndex(ref, index, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return fmt.Errorf("write manifest list to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return nil
+}
+
+// parsePlatform parses the platform in the os/arch[/variant] format
+func parsePlatform(platform string) (*v1.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("unexpected platform format %q: os/arch[/variant] expected", platform)
+	}
+
+	p := &v1.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	return p, nil
+}
+
+func (api *api) descriptor(reference string) (*remote.Descriptor, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	http.DefaultTransport = oldDefaultTransport
+
+	if err != nil {
+		return nil, fmt.Errorf("reading image %q: %v", ref, err)
+	}
+
+	return desc, nil
+}
+
 func (api *api) image(reference string) (v1.Image, name.Reference, error) {
 	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
 	if err != nil {
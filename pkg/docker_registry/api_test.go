@@ -0,0 +1,165 @@
+package docker_registry
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		expected *v1.Platform
+	}{
+		{"linux/amd64", &v1.Platform{OS: "linux", Architecture: "amd64"}},
+		{"linux/arm/v7", &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{"linux", nil},
+		{"linux/arm/v7/extra", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			p, err := parsePlatform(tt.platform)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected error, got platform %#v", p)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(p, tt.expected) {
+				t.Fatalf("expected platform %#v, got %#v", tt.expected, p)
+			}
+		})
+	}
+}
+
+func newTestPlatformImage(t *testing.T, createdAt time.Time, labels map[string]string) v1.Image {
+	img, err := random.Image(1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = mutate.Config(img, v1.Config{Labels: labels})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = mutate.CreatedAt(img, v1.Time{Time: createdAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+func TestSetRepoImageIndexInfo(t *testing.T) {
+	createdAt := time.Unix(1600000000, 0)
+	amd64Image := newTestPlatformImage(t, createdAt, map[string]string{"platform": "linux/amd64"})
+	arm64Image := newTestPlatformImage(t, createdAt.Add(time.Hour), map[string]string{"platform": "linux/arm64"})
+
+	index := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: amd64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}}},
+		mutate.IndexAddendum{Add: arm64Image, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+	)
+
+	info := &image.Info{}
+	if err := setRepoImageIndexInfo(info, index); err != nil {
+		t.Fatal(err)
+	}
+
+	indexDigest, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != indexDigest.String() {
+		t.Errorf("expected manifest list digest %s as ID, got %s", indexDigest, info.ID)
+	}
+
+	var expectedSize int64
+	var expectedLayersCount int
+	for _, img := range []v1.Image{amd64Image, arm64Image} {
+		layers, size, err := getImageLayers(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedSize += size
+		expectedLayersCount += len(layers)
+	}
+	if info.Size != expectedSize {
+		t.Errorf("expected total size of the platforms images %d, got %d", expectedSize, info.Size)
+	}
+	if len(info.Layers) != expectedLayersCount {
+		t.Errorf("expected layers of the platforms images %d, got %d", expectedLayersCount, len(info.Layers))
+	}
+
+	if expected := createdAt.Add(time.Hour).Unix(); info.GetCreatedAt().Unix() != expected {
+		t.Errorf("expected creation time of the latest created image %d, got %d", expected, info.GetCreatedAt().Unix())
+	}
+
+	if info.Labels["platform"] != "linux/arm64" {
+		t.Errorf("expected labels of the latest created image, got %v", info.Labels)
+	}
+
+	var expectedPlatformImageIDs []string
+	for _, img := range []v1.Image{amd64Image, arm64Image} {
+		configName, err := img.ConfigName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedPlatformImageIDs = append(expectedPlatformImageIDs, configName.String())
+	}
+	if !reflect.DeepEqual(info.PlatformImageIDs, expectedPlatformImageIDs) {
+		t.Errorf("expected IDs of the platforms images %v, got %v", expectedPlatformImageIDs, info.PlatformImageIDs)
+	}
+}
+
+func TestSetRepoImageInfo(t *testing.T) {
+	createdAt := time.Unix(1600000000, 0)
+	img := newTestPlatformImage(t, createdAt, map[string]string{"label": "value"})
+
+	info := &image.Info{}
+	if err := setRepoImageInfo(info, img); err != nil {
+		t.Fatal(err)
+	}
+
+	configName, err := img.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != configName.String() {
+		t.Errorf("expected config digest %s as ID, got %s", configName, info.ID)
+	}
+
+	if info.GetCreatedAt().Unix() != createdAt.Unix() {
+		t.Errorf("expected creation time %d, got %d", createdAt.Unix(), info.GetCreatedAt().Unix())
+	}
+
+	if info.Labels["label"] != "value" {
+		t.Errorf("expected image labels, got %v", info.Labels)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expectedLayers []image.LayerInfo
+	for _, layerDesc := range manifest.Layers {
+		expectedLayers = append(expectedLayers, image.LayerInfo{Digest: layerDesc.Digest.String(), Size: layerDesc.Size})
+	}
+	if !reflect.DeepEqual(info.Layers, expectedLayers) {
+		t.Errorf("expected layers %v, got %v", expectedLayers, info.Layers)
+	}
+}
@@ -27,6 +27,9 @@ type DockerRegistry interface {
 
 type PushImageOptions struct {
 	Labels map[string]string
+	// PlatformImages are references of already pushed images by platforms (os/arch[/variant]),
+	// the manifest list of these images is pushed instead of the manifest-only image when set
+	PlatformImages map[string]string
 }
 
 type DockerRegistryOptions struct {
@@ -0,0 +1,452 @@
+package docker_registry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const ociLayoutRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// OciLayout implements DockerRegistry interface over the OCI image layout directory.
+// Image tags are stored as the ref name annotations of the index manifests, the repository part of the reference is ignored.
+type OciLayout struct {
+	LayoutPath string
+
+	// manifests are immutable, so the blobs referenced by the manifest are cached by the manifest digest
+	manifestBlobs      map[string][]string
+	manifestBlobsMutex sync.Mutex
+}
+
+func NewOciLayout(layoutPath string) *OciLayout {
+	return &OciLayout{LayoutPath: layoutPath, manifestBlobs: map[string][]string{}}
+}
+
+func (l *OciLayout) CreateRepo(ctx context.Context, _ string) error {
+	return l.withLock(ctx, func() error {
+		_, err := l.getOrCreateLayout()
+		return err
+	})
+}
+
+func (l *OciLayout) DeleteRepo(ctx context.Context, _ string) error {
+	return l.withLock(ctx, func() error {
+		return os.RemoveAll(l.LayoutPath)
+	})
+}
+
+func (l *OciLayout) Tags(ctx context.Context, _ string) ([]string, error) {
+	var tags []string
+	if err := l.withSharedLock(ctx, func() error {
+		descriptors, err := l.indexDescriptors()
+		if err != nil {
+			return err
+		}
+
+		for _, desc := range descriptors {
+			if tag, ok := desc.Annotations[ociLayoutRefNameAnnotation]; ok {
+				tags = append(tags, tag)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (l *OciLayout) IsRepoImageExists(ctx context.Context, reference string) (bool, error) {
+	if imgInfo, err := l.TryGetRepoImage(ctx, reference); err != nil {
+		return false, err
+	} else {
+		return imgInfo != nil, nil
+	}
+}
+
+func (l *OciLayout) TryGetRepoImage(ctx context.Context, reference string) (*image.Info, error) {
+	var info *image.Info
+	if err := l.withSharedLock(ctx, func() error {
+		img, err := l.image(reference)
+		if err != nil {
+			return err
+		}
+
+		if img == nil {
+			return nil
+		}
+
+		info, err = newImageInfoFromImage(reference, img)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (l *OciLayout) GetRepoImage(ctx context.Context, reference string) (*image.Info, error) {
+	if imgInfo, err := l.TryGetRepoImage(ctx, reference); err != nil {
+		return nil, err
+	} else if imgInfo == nil {
+		return nil, fmt.Errorf("image %s not found in the oci layout %s", reference, l.LayoutPath)
+	} else {
+		return imgInfo, nil
+	}
+}
+
+func (l *OciLayout) DeleteRepoImage(ctx context.Context, repoImage *image.Info) error {
+	return l.withLock(ctx, func() error {
+		path, err := l.getOrCreateLayout()
+		if err != nil {
+			return err
+		}
+
+		if err := path.RemoveDescriptors(tagMatcher(repoImage.Tag)); err != nil {
+			return fmt.Errorf("unable to remove %s from the oci layout %s: %s", repoImage.Name, l.LayoutPath, err)
+		}
+
+		return l.removeUnreferencedBlobs(ctx, path)
+	})
+}
+
+func (l *OciLayout) PushImage(ctx context.Context, reference string, opts *PushImageOptions) error {
+	if opts != nil && len(opts.PlatformImages) != 0 {
+		return fmt.Errorf("unable to push %s: manifest lists are not supported by the oci layout %s", reference, l.LayoutPath)
+	}
+
+	var labels map[string]string
+	if opts != nil {
+		labels = opts.Labels
+	}
+
+	return l.appendImage(ctx, reference, container_registry_extensions.NewManifestOnlyImage(labels))
+}
+
+// AppendImageFromArchive adds the image from the docker-archive tarball into the layout by the reference tag
+func (l *OciLayout) AppendImageFromArchive(ctx context.Context, reference, archivePath string) error {
+	tag, err := name.NewTag(reference)
+	if err != nil {
+		return fmt.Errorf("unable to parse reference %s: %s", reference, err)
+	}
+
+	img, err := tarball.ImageFromPath(archivePath, &tag)
+	if err != nil {
+		return fmt.Errorf("unable to read image %s from archive %s: %s", reference, archivePath, err)
+	}
+
+	return l.appendImage(ctx, reference, img)
+}
+
+// SaveImageToArchive writes the image from the layout into the docker-archive tarball, which can be loaded by the container runtime
+func (l *OciLayout) SaveImageToArchive(ctx context.Context, reference, archivePath string) error {
+	tag, err := name.NewTag(reference)
+	if err != nil {
+		return fmt.Errorf("unable to parse reference %s: %s", reference, err)
+	}
+
+	return l.withSharedLock(ctx, func() error {
+		img, err := l.image(reference)
+		if err != nil {
+			return err
+		}
+
+		if img == nil {
+			return fmt.Errorf("image %s not found in the oci layout %s", reference, l.LayoutPath)
+		}
+
+		if err := tarball.WriteToFile(archivePath, tag, img); err != nil {
+			return fmt.Errorf("unable to write image %s to archive %s: %s", reference, archivePath, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *OciLayout) String() string {
+	return l.LayoutPath
+}
+
+func (l *OciLayout) appendImage(ctx context.Context, reference string, img v1.Image) error {
+	_, tag := image.ParseRepositoryAndTag(reference)
+
+	return l.withLock(ctx, func() error {
+		path, err := l.getOrCreateLayout()
+		if err != nil {
+			return err
+		}
+
+		if err := path.RemoveDescriptors(tagMatcher(tag)); err != nil {
+			return fmt.Errorf("unable to remove previous %s from the oci layout %s: %s", reference, l.LayoutPath, err)
+		}
+
+		if err := path.AppendImage(img, layout.WithAnnotations(map[string]string{ociLayoutRefNameAnnotation: tag})); err != nil {
+			return fmt.Errorf("unable to append %s to the oci layout %s: %s", reference, l.LayoutPath, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *OciLayout) image(reference string) (v1.Image, error) {
+	_, tag := image.ParseRepositoryAndTag(reference)
+
+	index, err := l.rootIndex()
+	if err != nil || index == nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read oci layout %s index: %s", l.LayoutPath, err)
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		if desc.Annotations[ociLayoutRefNameAnnotation] != tag {
+			continue
+		}
+
+		switch desc.MediaType {
+		case types.OCIManifestSchema1, types.DockerManifestSchema2:
+		default:
+			return nil, fmt.Errorf("unable to get image %s from the oci layout %s: unsupported media type %q", reference, l.LayoutPath, desc.MediaType)
+		}
+
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get image %s from the oci layout %s: %s", reference, l.LayoutPath, err)
+		}
+
+		return img, nil
+	}
+
+	return nil, nil
+}
+
+func (l *OciLayout) indexDescriptors() ([]v1.Descriptor, error) {
+	index, err := l.rootIndex()
+	if err != nil || index == nil {
+		return nil, err
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read oci layout %s index: %s", l.LayoutPath, err)
+	}
+
+	return indexManifest.Manifests, nil
+}
+
+func (l *OciLayout) rootIndex() (v1.ImageIndex, error) {
+	if _, err := os.Stat(filepath.Join(l.LayoutPath, "index.json")); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	path, err := layout.FromPath(l.LayoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open oci layout %s: %s", l.LayoutPath, err)
+	}
+
+	index, err := path.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read oci layout %s index: %s", l.LayoutPath, err)
+	}
+
+	return index, nil
+}
+
+func (l *OciLayout) getOrCreateLayout() (layout.Path, error) {
+	if path, err := layout.FromPath(l.LayoutPath); err == nil {
+		return path, nil
+	}
+
+	path, err := layout.Write(l.LayoutPath, empty.Index)
+	if err != nil {
+		return "", fmt.Errorf("unable to create oci layout %s: %s", l.LayoutPath, err)
+	}
+
+	return path, nil
+}
+
+// removeUnreferencedBlobs removes blobs, which are not referenced by the index manifests, because layers could be shared between images
+func (l *OciLayout) removeUnreferencedBlobs(ctx context.Context, path layout.Path) error {
+	index, err := path.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("unable to read oci layout %s index: %s", l.LayoutPath, err)
+	}
+
+	referencedBlobs := map[string]bool{}
+	if err := l.collectIndexBlobs(index, referencedBlobs); err != nil {
+		return err
+	}
+
+	blobsDir := filepath.Join(l.LayoutPath, "blobs", "sha256")
+	blobs, err := ioutil.ReadDir(blobsDir)
+	if err != nil {
+		return fmt.Errorf("unable to read dir %s: %s", blobsDir, err)
+	}
+
+	for _, blob := range blobs {
+		if referencedBlobs[blob.Name()] {
+			continue
+		}
+
+		logboek.Context(ctx).Debug().LogF("-- OciLayout.removeUnreferencedBlobs removing blob %s\n", blob.Name())
+		if err := os.Remove(filepath.Join(blobsDir, blob.Name())); err != nil {
+			return fmt.Errorf("unable to remove blob %s: %s", blob.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// collectIndexBlobs walks the index recursively and collects the digests of all referenced manifests, configs and layers
+func (l *OciLayout) collectIndexBlobs(index v1.ImageIndex, blobs map[string]bool) error {
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	for _, desc := range indexManifest.Manifests {
+		blobs[desc.Digest.Hex] = true
+
+		switch desc.MediaType {
+		case types.OCIImageIndex, types.DockerManifestList:
+			childIndex, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("unable to get index %s: %s", desc.Digest, err)
+			}
+
+			if err := l.collectIndexBlobs(childIndex, blobs); err != nil {
+				return err
+			}
+		case types.OCIManifestSchema1, types.DockerManifestSchema2:
+			manifestBlobs, err := l.getManifestBlobs(index, desc.Digest)
+			if err != nil {
+				return err
+			}
+
+			for _, blob := range manifestBlobs {
+				blobs[blob] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *OciLayout) getManifestBlobs(index v1.ImageIndex, digest v1.Hash) ([]string, error) {
+	l.manifestBlobsMutex.Lock()
+	defer l.manifestBlobsMutex.Unlock()
+
+	if blobs, ok := l.manifestBlobs[digest.Hex]; ok {
+		return blobs, nil
+	}
+
+	img, err := index.Image(digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image %s: %s", digest, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image %s manifest: %s", digest, err)
+	}
+
+	blobs := []string{manifest.Config.Digest.Hex}
+	for _, layerDesc := range manifest.Layers {
+		blobs = append(blobs, layerDesc.Digest.Hex)
+	}
+
+	l.manifestBlobs[digest.Hex] = blobs
+
+	return blobs, nil
+}
+
+func (l *OciLayout) withLock(ctx context.Context, f func() error) error {
+	return werf.WithHostLock(ctx, l.lockName(), lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
+}
+
+func (l *OciLayout) withSharedLock(ctx context.Context, f func() error) error {
+	return werf.WithHostLock(ctx, l.lockName(), lockgate.AcquireOptions{Timeout: 600 * time.Second, Shared: true}, f)
+}
+
+// lockName is the same for the relative and absolute paths of the layout dir
+func (l *OciLayout) lockName() string {
+	layoutPath, err := filepath.Abs(l.LayoutPath)
+	if err != nil {
+		layoutPath = filepath.Clean(l.LayoutPath)
+	}
+
+	return fmt.Sprintf("oci_layout.%s", util.Sha256Hash(layoutPath))
+}
+
+func tagMatcher(tag string) func(desc v1.Descriptor) bool {
+	return func(desc v1.Descriptor) bool {
+		return desc.Annotations[ociLayoutRefNameAnnotation] == tag
+	}
+}
+
+func newImageInfoFromImage(reference string, img v1.Image) (*image.Info, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var layers []image.LayerInfo
+	var totalSize int64
+	for _, layerDesc := range manifest.Layers {
+		layers = append(layers, image.LayerInfo{Digest: layerDesc.Digest.String(), Size: layerDesc.Size})
+		totalSize += layerDesc.Size
+	}
+
+	repository, tag := image.ParseRepositoryAndTag(reference)
+
+	repoImage := &image.Info{
+		Name:       reference,
+		Repository: repository,
+		ID:         manifest.Config.Digest.String(),
+		Tag:        tag,
+		RepoDigest: digest.String(),
+		ParentID:   configFile.Config.Image,
+		Labels:     configFile.Config.Labels,
+		Size:       totalSize,
+		Layers:     layers,
+	}
+
+	repoImage.SetCreatedAtUnix(configFile.Created.Unix())
+
+	return repoImage, nil
+}
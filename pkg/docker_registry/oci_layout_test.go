@@ -0,0 +1,165 @@
+package docker_registry
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+
+	"github.com/werf/werf/pkg/docker_registry/container_registry_extensions"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestOciLayout_PushAndDeleteImage(t *testing.T) {
+	ctx := context.Background()
+	l := newTestOciLayout(t)
+
+	for _, tag := range []string{"tag-1", "tag-2"} {
+		if err := l.PushImage(ctx, "repo:"+tag, &PushImageOptions{Labels: map[string]string{"tag": tag}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkOciLayoutTags(t, l, []string{"tag-1", "tag-2"})
+
+	info1, err := l.GetRepoImage(ctx, "repo:tag-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info1.Tag != "tag-1" || info1.Labels["tag"] != "tag-1" {
+		t.Errorf("unexpected image info: tag=%s labels=%v", info1.Tag, info1.Labels)
+	}
+
+	info2, err := l.GetRepoImage(ctx, "repo:tag-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.DeleteRepoImage(ctx, info1); err != nil {
+		t.Fatal(err)
+	}
+
+	checkOciLayoutTags(t, l, []string{"tag-2"})
+
+	if info, err := l.TryGetRepoImage(ctx, "repo:tag-1"); err != nil {
+		t.Fatal(err)
+	} else if info != nil {
+		t.Errorf("expected deleted image not to be found")
+	}
+
+	checkOciLayoutBlob(t, l, info1.ID, false)
+	checkOciLayoutBlob(t, l, info2.ID, true)
+}
+
+func TestOciLayout_NestedIndex(t *testing.T) {
+	ctx := context.Background()
+	l := newTestOciLayout(t)
+
+	if err := l.PushImage(ctx, "repo:tag", &PushImageOptions{Labels: map[string]string{"tag": "tag"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	indexImage := container_registry_extensions.NewManifestOnlyImage(map[string]string{"tag": "list"})
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: indexImage})
+
+	path, err := layout.FromPath(l.LayoutPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := path.AppendIndex(index, layout.WithAnnotations(map[string]string{ociLayoutRefNameAnnotation: "list"})); err != nil {
+		t.Fatal(err)
+	}
+
+	checkOciLayoutTags(t, l, []string{"list", "tag"})
+
+	if _, err := l.TryGetRepoImage(ctx, "repo:list"); err == nil || !strings.Contains(err.Error(), "unsupported media type") {
+		t.Errorf("expected unsupported media type error, got %v", err)
+	}
+
+	info, err := l.GetRepoImage(ctx, "repo:tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.DeleteRepoImage(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+
+	checkOciLayoutTags(t, l, []string{"list"})
+
+	indexImageConfigDigest, err := indexImage.ConfigName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkOciLayoutBlob(t, l, info.ID, false)
+	checkOciLayoutBlob(t, l, indexImageConfigDigest.String(), true)
+}
+
+func TestOciLayout_LockName(t *testing.T) {
+	if NewOciLayout("/a_b").lockName() == NewOciLayout("/a/b").lockName() {
+		t.Errorf("expected different lock names for different layout paths")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if NewOciLayout("layout").lockName() != NewOciLayout(filepath.Join(wd, "layout", ".")).lockName() {
+		t.Errorf("expected the same lock name for the relative and absolute layout paths")
+	}
+}
+
+func newTestOciLayout(t *testing.T) *OciLayout {
+	tmpDir, err := ioutil.TempDir("", "oci-layout-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	if err := werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewOciLayout(filepath.Join(tmpDir, "layout"))
+	if err := l.CreateRepo(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+func checkOciLayoutTags(t *testing.T, l *OciLayout, expectedTags []string) {
+	tags, err := l.Tags(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(tags)
+	if strings.Join(tags, ",") != strings.Join(expectedTags, ",") {
+		t.Errorf("expected tags %v, got %v", expectedTags, tags)
+	}
+}
+
+func checkOciLayoutBlob(t *testing.T, l *OciLayout, digest string, expectedExist bool) {
+	hash, err := v1.NewHash(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(l.LayoutPath, "blobs", hash.Algorithm, hash.Hex))
+	if exist := err == nil; exist != expectedExist {
+		t.Errorf("expected blob %s existence to be %v, got %v", digest, expectedExist, exist)
+	}
+}
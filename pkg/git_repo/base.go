@@ -14,6 +14,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/true_git"
 	"github.com/werf/werf/pkg/true_git/ls_tree"
 	"github.com/werf/werf/pkg/werf"
@@ -137,6 +138,9 @@ func (repo *Base) getOrCreatePatch(ctx context.Context, repoPath, gitDir, repoID
 }
 
 func (repo *Base) CreatePatch(ctx context.Context, repoPath, gitDir, repoID, workTreeCacheDir string, opts PatchOptions) (patch Patch, err error) {
+	ctx, span := tracing.StartSpan(ctx, "git.CreatePatch", "git.repo", repo.Name)
+	defer func() { span.End(err) }()
+
 	logboek.Context(ctx).Debug().LogProcess("Creating patch").Do(func() {
 		logboek.Context(ctx).Debug().LogFDetails("repository: %s\noptions: %+v\n", repo.Name, opts)
 		logboek.Context(ctx).Debug().LogOptionalLn()
@@ -299,6 +303,9 @@ func (repo *Base) getOrCreateArchive(ctx context.Context, repoPath, gitDir, repo
 }
 
 func (repo *Base) CreateArchive(ctx context.Context, repoPath, gitDir, repoID, workTreeCacheDir string, opts ArchiveOptions) (archive Archive, err error) {
+	ctx, span := tracing.StartSpan(ctx, "git.CreateArchive", "git.repo", repo.Name)
+	defer func() { span.End(err) }()
+
 	logboek.Context(ctx).Debug().LogProcess("Creating archive").Do(func() {
 		logboek.Context(ctx).Debug().LogFDetails("repository: %s\noptions: %+v\n", repo.Name, opts)
 		logboek.Context(ctx).Debug().LogOptionalLn()
@@ -481,6 +488,9 @@ func (repo *Base) getOrCreateChecksum(ctx context.Context, repository *git.Repos
 }
 
 func (repo *Base) CreateChecksum(ctx context.Context, repository *git.Repository, opts ChecksumOptions) (checksum string, err error) {
+	ctx, span := tracing.StartSpan(ctx, "git.CreateChecksum", "git.repo", repo.Name)
+	defer func() { span.End(err) }()
+
 	logboek.Context(ctx).Debug().LogProcess("Creating checksum").Do(func() {
 		logboek.Context(ctx).Debug().LogFDetails("repository: %s\noptions: %+v\n", repo.Name, opts)
 		logboek.Context(ctx).Debug().LogOptionalLn()
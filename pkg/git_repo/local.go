@@ -6,10 +6,13 @@ import (
 	"os"
 	pathPkg "path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
 
 	"github.com/werf/logboek"
@@ -273,6 +276,87 @@ func (repo *Local) lsTreeResult(ctx context.Context, commit string, opts LsTreeO
 	return
 }
 
+// ResolveRevision returns the commit for the revision (commit, branch, tag, HEAD~N, etc.)
+func (repo *Local) ResolveRevision(_ context.Context, rev string) (commit string, err error) {
+	err = repo.withNonThreadSafeRepository(func(repository *git.Repository) error {
+		hash, err := repository.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return fmt.Errorf("unable to resolve revision %q: %s", rev, err)
+		}
+
+		commit = hash.String()
+		return nil
+	})
+
+	return
+}
+
+// CommitTime returns the committer date of the commit
+func (repo *Local) CommitTime(_ context.Context, commit string) (commitTime time.Time, err error) {
+	err = repo.withNonThreadSafeRepository(func(repository *git.Repository) error {
+		commitObj, err := repository.CommitObject(plumbing.NewHash(commit))
+		if err != nil {
+			return fmt.Errorf("bad commit %q: %s", commit, err)
+		}
+
+		commitTime = commitObj.Committer.When
+		return nil
+	})
+
+	return
+}
+
+// CommitTags returns the sorted tags pointing to the commit
+func (repo *Local) CommitTags(_ context.Context, commit string) (tags []string, err error) {
+	err = repo.withNonThreadSafeRepository(func(repository *git.Repository) error {
+		refs, err := repository.Tags()
+		if err != nil {
+			return err
+		}
+
+		return refs.ForEach(func(ref *plumbing.Reference) error {
+			targetCommit := ref.Hash().String()
+
+			obj, err := repository.TagObject(ref.Hash())
+			switch err {
+			case nil:
+				targetCommit = obj.Target.String()
+			case plumbing.ErrObjectNotFound:
+			default:
+				return err
+			}
+
+			if targetCommit == commit {
+				tags = append(tags, ref.Name().Short())
+			}
+
+			return nil
+		})
+	})
+
+	sort.Strings(tags)
+
+	return
+}
+
+// CurrentBranch returns the checked out branch or an empty string if HEAD is detached
+func (repo *Local) CurrentBranch(_ context.Context) (branch string, err error) {
+	err = repo.withNonThreadSafeRepository(func(repository *git.Repository) error {
+		ref, err := repository.Head()
+		if err != nil {
+			return fmt.Errorf("unable to get HEAD: %s", err)
+		}
+
+		if ref.Name().IsBranch() {
+			branch = ref.Name().Short()
+		}
+
+		return nil
+	})
+
+	return
+}
+
 func (repo *Local) IsCommitExists(ctx context.Context, commit string) (bool, error) {
 	return repo.isCommitExists(ctx, repo.WorkTreeDir, repo.GitDir, commit)
 }
@@ -0,0 +1,141 @@
+package git_repo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var testSignature = &object.Signature{Name: "werf", Email: "werf@example.com", When: time.Unix(1600000000, 0)}
+
+func newTestLocal(t *testing.T) (*Local, *git.Repository) {
+	workTreeDir, err := ioutil.TempDir("", "werf-git-repo-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(workTreeDir) })
+
+	repository, err := git.PlainInit(workTreeDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Local{
+		Base:                    NewBase("test"),
+		WorkTreeDir:             workTreeDir,
+		GitDir:                  filepath.Join(workTreeDir, git.GitDirName),
+		nonThreadSafeRepository: repository,
+	}, repository
+}
+
+func commitTestFile(t *testing.T, repository *git.Repository, workTreeDir, content string) plumbing.Hash {
+	if err := ioutil.WriteFile(filepath.Join(workTreeDir, "file"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worktree.Add("file"); err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := worktree.Commit(content, &git.CommitOptions{Author: testSignature})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+func TestLocal_CommitTags(t *testing.T) {
+	repo, repository := newTestLocal(t)
+
+	firstCommit := commitTestFile(t, repository, repo.WorkTreeDir, "first")
+	secondCommit := commitTestFile(t, repository, repo.WorkTreeDir, "second")
+	thirdCommit := commitTestFile(t, repository, repo.WorkTreeDir, "third")
+
+	if _, err := repository.CreateTag("v1.1.0", firstCommit, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.CreateTag("v1.0.0", firstCommit, &git.CreateTagOptions{Tagger: testSignature, Message: "v1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.CreateTag("v2.0.0", secondCommit, &git.CreateTagOptions{Tagger: testSignature, Message: "v2.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		commit       plumbing.Hash
+		expectedTags []string
+	}{
+		{"lightweight and annotated tags are sorted", firstCommit, []string{"v1.0.0", "v1.1.0"}},
+		{"annotated tag", secondCommit, []string{"v2.0.0"}},
+		{"commit without tags", thirdCommit, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := repo.CommitTags(context.Background(), tt.commit.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tags, tt.expectedTags) {
+				t.Fatalf("expected tags %v, got %v", tt.expectedTags, tags)
+			}
+		})
+	}
+}
+
+func TestLocal_CurrentBranch(t *testing.T) {
+	repo, repository := newTestLocal(t)
+
+	firstCommit := commitTestFile(t, repository, repo.WorkTreeDir, "first")
+	commitTestFile(t, repository, repo.WorkTreeDir, "second")
+
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		checkout       *git.CheckoutOptions
+		expectedBranch string
+	}{
+		{"default branch", nil, "master"},
+		{"new branch", &git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature/test"), Create: true}, "feature/test"},
+		{"detached HEAD", &git.CheckoutOptions{Hash: firstCommit}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.checkout != nil {
+				if err := worktree.Checkout(tt.checkout); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			branch, err := repo.CurrentBranch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if branch != tt.expectedBranch {
+				t.Fatalf("expected branch %q, got %q", tt.expectedBranch, branch)
+			}
+		})
+	}
+}
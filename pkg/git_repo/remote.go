@@ -329,6 +329,35 @@ func (repo *Remote) IsCommitExists(ctx context.Context, commit string) (bool, er
 	return repo.isCommitExists(ctx, repo.GetClonePath(), repo.GetClonePath(), commit)
 }
 
+func (repo *Remote) ReadCommitFile(_ context.Context, commit, path string) ([]byte, error) {
+	rawRepo, err := git.PlainOpenWithOptions(repo.GetClonePath(), &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo: %s", err)
+	}
+
+	commitHash, err := newHash(commit)
+	if err != nil {
+		return nil, fmt.Errorf("bad commit hash %q: %s", commit, err)
+	}
+
+	commitObj, err := rawRepo.CommitObject(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("bad commit %q: %s", commit, err)
+	}
+
+	file, err := commitObj.File(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file %q from commit %q: %s", path, commit, err)
+	}
+
+	content, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %q from commit %q: %s", path, commit, err)
+	}
+
+	return []byte(content), nil
+}
+
 func (repo *Remote) getRepoID() string {
 	return util.Sha256Hash(repo.getFilesystemRelativePathByEndpoint())
 }
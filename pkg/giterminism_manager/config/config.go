@@ -59,10 +59,26 @@ func (c Config) UncommittedConfigGoTemplateRenderingFilePathMatcher() path_match
 	return c.Config.GoTemplateRendering.UncommittedFilePathMatcher()
 }
 
+func (c Config) UncommittedConfigIncludeFilePathMatcher() path_matcher.PathMatcher {
+	return c.Config.Include.UncommittedFilePathMatcher()
+}
+
+func (c Config) IsConfigIncludeGitBranchAccepted() bool {
+	return c.Config.Include.AllowBranch
+}
+
+func (c Config) IsConfigIncludeGitTagAccepted() bool {
+	return c.Config.Include.AllowTag
+}
+
 func (c Config) IsConfigGoTemplateRenderingEnvNameAccepted(envName string) (bool, error) {
 	return c.Config.GoTemplateRendering.IsEnvNameAccepted(envName)
 }
 
+func (c Config) IsConfigGoTemplateRenderingGitReferencesAccepted() bool {
+	return c.Config.GoTemplateRendering.AllowGitReferences
+}
+
 func (c Config) IsConfigStapelFromLatestAccepted() bool {
 	return c.Config.Stapel.AllowFromLatest
 }
@@ -79,6 +95,10 @@ func (c Config) IsConfigStapelMountFromPathAccepted(fromPath string) bool {
 	return c.Config.Stapel.Mount.IsFromPathAccepted(fromPath)
 }
 
+func (c Config) IsConfigStapelSecretSrcAccepted(src string) bool {
+	return c.Config.Stapel.Secret.IsSrcAccepted(src)
+}
+
 func (c Config) IsConfigDockerfileContextAddFileAccepted(relPath string) bool {
 	return c.Config.Dockerfile.IsContextAddFileAccepted(relPath)
 }
@@ -98,6 +118,7 @@ func (c Config) UncommittedHelmFilePathMatcher() path_matcher.PathMatcher {
 type config struct {
 	AllowUncommitted          bool                `json:"allowUncommitted"`
 	AllowUncommittedTemplates []string            `json:"allowUncommittedTemplates"`
+	Include                   include             `json:"include"`
 	GoTemplateRendering       goTemplateRendering `json:"goTemplateRendering"`
 	Stapel                    stapel              `json:"stapel"`
 	Dockerfile                dockerfile          `json:"dockerfile"`
@@ -107,9 +128,20 @@ func (c config) UncommittedTemplateFilePathMatcher() path_matcher.PathMatcher {
 	return pathMatcher(c.AllowUncommittedTemplates)
 }
 
+type include struct {
+	AllowUncommitted []string `json:"allowUncommitted"`
+	AllowBranch      bool     `json:"allowBranch"`
+	AllowTag         bool     `json:"allowTag"`
+}
+
+func (i include) UncommittedFilePathMatcher() path_matcher.PathMatcher {
+	return pathMatcher(i.AllowUncommitted)
+}
+
 type goTemplateRendering struct {
 	AllowEnvVariables     []string `json:"allowEnvVariables"`
 	AllowUncommittedFiles []string `json:"allowUncommittedFiles"`
+	AllowGitReferences    bool     `json:"allowGitReferences"`
 }
 
 func (r goTemplateRendering) IsEnvNameAccepted(name string) (bool, error) {
@@ -145,9 +177,10 @@ func (r goTemplateRendering) UncommittedFilePathMatcher() path_matcher.PathMatch
 }
 
 type stapel struct {
-	AllowFromLatest bool  `json:"allowFromLatest"`
-	Git             git   `json:"git"`
-	Mount           mount `json:"mount"`
+	AllowFromLatest bool   `json:"allowFromLatest"`
+	Git             git    `json:"git"`
+	Mount           mount  `json:"mount"`
+	Secret          secret `json:"secret"`
 }
 
 type git struct {
@@ -163,6 +196,14 @@ func (m mount) IsFromPathAccepted(path string) bool {
 	return isPathMatched(m.AllowFromPaths, path)
 }
 
+type secret struct {
+	AllowSrcPaths []string `json:"allowSrcPaths"`
+}
+
+func (s secret) IsSrcAccepted(path string) bool {
+	return isPathMatched(s.AllowSrcPaths, path)
+}
+
 type dockerfile struct {
 	AllowUncommitted                  []string `json:"allowUncommitted"`
 	AllowUncommittedDockerignoreFiles []string `json:"allowUncommittedDockerignoreFiles"`
@@ -40,12 +40,26 @@ definitions:
         type: array
         items:
           type: string
+      include:
+        $ref: '#/definitions/ConfigInclude'
       goTemplateRendering:
         $ref: '#/definitions/ConfigGoTemplateRendering'
       stapel:
         $ref: '#/definitions/ConfigStapel'
       dockerfile:
         $ref: '#/definitions/ConfigDockerfile'
+  ConfigInclude:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowUncommitted:
+        type: array
+        items:
+          type: string
+      allowBranch:
+        type: boolean
+      allowTag:
+        type: boolean
   ConfigGoTemplateRendering:
     type: object
     additionalProperties: {}
@@ -58,6 +72,8 @@ definitions:
         type: array
         items:
           type: string
+      allowGitReferences:
+        type: boolean
   ConfigStapel:
     type: object
     additionalProperties: {}
@@ -68,6 +84,8 @@ definitions:
         $ref: '#/definitions/ConfigStapelGit'
       mount:
         $ref: '#/definitions/ConfigStapelMount'
+      secret:
+        $ref: '#/definitions/ConfigStapelSecret'
   ConfigStapelGit:
     type: object
     additionalProperties: {}
@@ -84,6 +102,14 @@ definitions:
         type: array
         items:
           type: string
+  ConfigStapelSecret:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowSrcPaths:
+        type: array
+        items:
+          type: string
   ConfigDockerfile:
     type: object
     additionalProperties: {}
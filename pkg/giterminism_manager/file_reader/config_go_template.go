@@ -38,3 +38,12 @@ func (r FileReader) ConfigGoTemplateFilesGet(ctx context.Context, relPath string
 
 	return data, nil
 }
+
+func (r FileReader) ConfigGoTemplateFilesExists(ctx context.Context, relPath string) (bool, error) {
+	exist, err := r.IsConfigurationFileExist(ctx, relPath, r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher().IsPathMatched)
+	if err != nil {
+		return false, fmt.Errorf("{{ .Files.Exists %q }}: %s", relPath, err)
+	}
+
+	return exist, nil
+}
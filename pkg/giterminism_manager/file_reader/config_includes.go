@@ -0,0 +1,55 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+)
+
+func (r FileReader) ReadConfigIncludeFiles(ctx context.Context, glob string, includeFunc func(relPath string, data []byte, err error) error) (err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadConfigIncludeFiles %q", glob).
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			err = r.readConfigIncludeFiles(ctx, glob, includeFunc)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("err: %q\n", err)
+			}
+		})
+
+	if err != nil {
+		return fmt.Errorf("unable to read werf config includes %q: %s", glob, err)
+	}
+
+	return nil
+}
+
+func (r FileReader) readConfigIncludeFiles(ctx context.Context, glob string, includeFunc func(relPath string, data []byte, err error) error) error {
+	var found bool
+	if err := r.WalkConfigurationFilesWithGlob(
+		ctx,
+		"",
+		glob,
+		r.giterminismConfig.UncommittedConfigIncludeFilePathMatcher(),
+		func(relativeToDirNotResolvedPath string, data []byte, err error) error {
+			found = true
+			return includeFunc(filepath.ToSlash(relativeToDirNotResolvedPath), data, err)
+		},
+	); err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("no matches found")
+	}
+
+	return nil
+}
@@ -23,6 +23,7 @@ func NewFileReader(sharedOptions sharedOptions) FileReader {
 type giterminismConfig interface {
 	IsUncommittedConfigAccepted() bool
 	UncommittedConfigTemplateFilePathMatcher() path_matcher.PathMatcher
+	UncommittedConfigIncludeFilePathMatcher() path_matcher.PathMatcher
 	UncommittedConfigGoTemplateRenderingFilePathMatcher() path_matcher.PathMatcher
 	IsUncommittedDockerfileAccepted(relPath string) bool
 	IsUncommittedDockerignoreAccepted(relPath string) bool
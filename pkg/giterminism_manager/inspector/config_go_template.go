@@ -20,3 +20,15 @@ func (i Inspector) InspectConfigGoTemplateRenderingEnv(ctx context.Context, envN
 
 The use of the function env complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the value of the environment variable affects the final digest of built images.`, envName))
 }
+
+func (i Inspector) InspectConfigGoTemplateRenderingGitReferences() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigGoTemplateRenderingGitReferencesAccepted() {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(`.Git.Branch and .Git.Tag not allowed by giterminism
+
+The same commit could be checked out by different branches and tagged later, so the use of the current branch and tags complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the values affect the final digest of built images.
+
+As an alternative, we recommend using .Git.Commit and .Git.CommitDate, which are defined by the current commit.`)
+}
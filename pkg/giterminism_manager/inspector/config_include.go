@@ -0,0 +1,25 @@
+package inspector
+
+func (i Inspector) InspectConfigIncludeGitBranch() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigIncludeGitBranchAccepted() {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(`include with git branch not allowed by giterminism
+
+The included file is read from the latest commit of the remote git branch, so a new commit in the branch changes the werf config without any changes in the project repository. Thus, the previous pipeline jobs cannot be retried with the same configuration, and the changes of the shared configuration may break the pipeline unexpectedly.
+
+As an alternative, we recommend using the commit to guarantee the application's controllable and predictable life cycle.`)
+}
+
+func (i Inspector) InspectConfigIncludeGitTag() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigIncludeGitTagAccepted() {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(`include with git tag not allowed by giterminism
+
+The tag of the remote git repository can be moved to another commit or recreated, so the same tag can change the werf config without any changes in the project repository. Thus, the previous pipeline jobs cannot be retried with the same configuration, and the changes of the shared configuration may break the pipeline unexpectedly.
+
+As an alternative, we recommend using the commit to guarantee the application's controllable and predictable life cycle.`)
+}
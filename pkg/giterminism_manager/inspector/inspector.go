@@ -19,11 +19,15 @@ func NewInspector(giterminismConfig giterminismConfig, fileReader fileReader, sh
 }
 
 type giterminismConfig interface {
+	IsConfigIncludeGitBranchAccepted() bool
+	IsConfigIncludeGitTagAccepted() bool
 	IsConfigGoTemplateRenderingEnvNameAccepted(envName string) (bool, error)
+	IsConfigGoTemplateRenderingGitReferencesAccepted() bool
 	IsConfigStapelFromLatestAccepted() bool
 	IsConfigStapelGitBranchAccepted() bool
 	IsConfigStapelMountBuildDirAccepted() bool
 	IsConfigStapelMountFromPathAccepted(fromPath string) bool
+	IsConfigStapelSecretSrcAccepted(src string) bool
 	IsConfigDockerfileContextAddFileAccepted(relPath string) bool
 }
 
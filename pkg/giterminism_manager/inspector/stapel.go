@@ -57,3 +57,17 @@ func (i Inspector) InspectConfigStapelMountFromPath(fromPath string) error {
 
 The use of the fromPath mount may lead to unpredictable behavior when used in parallel and potentially affect reproducibility and reliability. The data in the mounted directory has no effect on the final image digest, which can lead to invalid images and hard-to-trace issues.`, fromPath))
 }
+
+func (i Inspector) InspectConfigStapelSecretSrc(src string) error {
+	if i.sharedOptions.LooseGiterminism() {
+		return nil
+	}
+
+	if i.giterminismConfig.IsConfigStapelSecretSrcAccepted(src) {
+		return nil
+	}
+
+	return NewExternalDependencyFoundError(fmt.Sprintf(`"secrets { src: %s, ... }" not allowed by giterminism
+
+The use of the src secret makes the build depend on a file outside of the project git repository. The content of the file has no effect on the final image digest, which can lead to invalid images and hard-to-trace issues.`, src))
+}
@@ -26,8 +26,10 @@ type FileReader interface {
 	IsConfigExistAnywhere(ctx context.Context, relPath string) (bool, error)
 	ReadConfig(ctx context.Context, customRelPath string) ([]byte, error)
 	ReadConfigTemplateFiles(ctx context.Context, customRelDirPath string, tmplFunc func(templatePathInsideDir string, data []byte, err error) error) error
+	ReadConfigIncludeFiles(ctx context.Context, glob string, includeFunc func(relPath string, data []byte, err error) error) error
 	ConfigGoTemplateFilesGet(ctx context.Context, relPath string) ([]byte, error)
 	ConfigGoTemplateFilesGlob(ctx context.Context, pattern string) (map[string]interface{}, error)
+	ConfigGoTemplateFilesExists(ctx context.Context, relPath string) (bool, error)
 	ReadDockerfile(ctx context.Context, relPath string) ([]byte, error)
 	IsDockerignoreExistAnywhere(ctx context.Context, relPath string) (bool, error)
 	ReadDockerignore(ctx context.Context, relPath string) ([]byte, error)
@@ -42,11 +44,15 @@ type HelmChartExtender interface {
 }
 
 type Inspector interface {
+	InspectConfigIncludeGitBranch() error
+	InspectConfigIncludeGitTag() error
 	InspectConfigGoTemplateRenderingEnv(ctx context.Context, envName string) error
+	InspectConfigGoTemplateRenderingGitReferences() error
 	InspectConfigStapelFromLatest() error
 	InspectConfigStapelGitBranch() error
 	InspectConfigStapelMountBuildDir() error
 	InspectConfigStapelMountFromPath(fromPath string) error
+	InspectConfigStapelSecretSrc(src string) error
 	InspectConfigDockerfileContextAddFile(relPath string) error
 	InspectBuildContextFiles(ctx context.Context, matcher path_matcher.PathMatcher) error
 }
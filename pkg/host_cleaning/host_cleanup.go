@@ -7,6 +7,7 @@ import (
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/cache_mount"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/tmp_manager"
 	"github.com/werf/werf/pkg/werf"
@@ -65,6 +66,7 @@ func RunAutoHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 		logboek.Context(ctx).Default().LogLn()
 		logboek.Context(ctx).Default().LogFDetails("Werf tries to maintain host clean by deleting:\n")
 		logboek.Context(ctx).Default().LogFDetails(" - old unused files from werf caches (which are stored in the ~/.werf/local_cache);\n")
+		logboek.Context(ctx).Default().LogFDetails(" - least recently used cache mounts of stapel images (which are stored in the ~/.werf/local_cache/cache_mounts);\n")
 		logboek.Context(ctx).Default().LogFDetails(" - old temporary service files /tmp/werf-project-data-* and /tmp/werf-config-render-*;\n")
 		logboek.Context(ctx).Default().LogFDetails(" - least recently used werf images (only >= v1.2 werf images could be removed, note that werf <= v1.1 images will not be deleted by this auto cleanup);\n")
 		logboek.Context(ctx).Default().LogLn()
@@ -89,6 +91,15 @@ func RunHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 	allowedVolumeUsagePercentage := getAllowedVolumeUsagePercentage(options.AllowedVolumeUsagePercentage)
 	allowedVolumeUsageMarginPercentage := getAllowedVolumeUsageMarginPercentage(getAllowedVolumeUsagePercentage(options.AllowedVolumeUsagePercentage), options.AllowedVolumeUsageMarginPercentage)
 
+	if err := logboek.Context(ctx).Default().LogProcess("Running GC for cache mounts").DoError(func() error {
+		if err := cache_mount.RunGC(ctx, allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage, options.DryRun); err != nil {
+			return fmt.Errorf("cache mounts GC failed: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
 	if err := logboek.Context(ctx).Default().LogProcess("Running GC for git data").DoError(func() error {
 		if err := git_repo.RunGC(ctx, allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage); err != nil {
 			return fmt.Errorf("git repo GC failed: %s", err)
@@ -20,7 +20,17 @@ type Info struct {
 	ParentID          string            `json:"parentID"`
 	Labels            map[string]string `json:"labels"`
 	Size              int64             `json:"size"`
+	Layers            []LayerInfo       `json:"layers"`
 	CreatedAtUnixNano int64             `json:"createdAtUnixNano"`
+
+	// PlatformImageIDs are the IDs of the images of the manifest list, which has no parent
+	PlatformImageIDs []string `json:"platformImageIDs,omitempty"`
+}
+
+// LayerInfo is the layer of the image in the repo, the images built on top of each other share the layers
+type LayerInfo struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
 }
 
 func (info *Info) SetCreatedAtUnix(seconds int64) {
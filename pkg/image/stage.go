@@ -3,6 +3,7 @@ package image
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +16,21 @@ func (id StageID) String() string {
 	return fmt.Sprintf("%s-%d", id.Digest, id.UniqueID)
 }
 
+// ParseStageID parses the stage ID in the DIGEST-UNIQUEID format, which is returned by the StageID.String()
+func ParseStageID(stageID string) (*StageID, error) {
+	parts := strings.SplitN(stageID, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unexpected stage ID format %q", stageID)
+	}
+
+	uniqueID, err := ParseUniqueIDAsTimestamp(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse unique ID %q of stage ID %q: %s", parts[1], stageID, err)
+	}
+
+	return &StageID{Digest: parts[0], UniqueID: uniqueID}, nil
+}
+
 func (id StageID) UniqueIDAsTime() time.Time {
 	return time.Unix(id.UniqueID/1000, id.UniqueID%1000)
 }
@@ -0,0 +1,62 @@
+package stapel
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/buildah"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// GetOrCreateBuildahVolume returns volume spec to mount stapel tools into the buildah working container.
+// Buildah has no analogue of the docker --volumes-from option, so the stapel image root is mounted
+// on the host and stapel directory is bind-mounted into the working container read-only.
+func GetOrCreateBuildahVolume(ctx context.Context) (string, error) {
+	c := getContainer()
+
+	exist, err := buildah.ContainerExist(ctx, c.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if !exist {
+		err := werf.WithHostLock(ctx, fmt.Sprintf("stapel.buildah_container.%s", c.Name), lockgate.AcquireOptions{Timeout: time.Second * 600}, func() error {
+			return logboek.Context(ctx).LogProcess("Creating buildah container %s from image %s", c.Name, c.ImageName).DoError(func() error {
+				exist, err := buildah.ContainerExist(ctx, c.Name)
+				if err != nil {
+					return err
+				}
+
+				if exist {
+					return nil
+				}
+
+				if inspect, err := buildah.Inspect(ctx, c.ImageName); err != nil {
+					return err
+				} else if inspect == nil {
+					if err := buildah.Pull(ctx, c.ImageName); err != nil {
+						return err
+					}
+				}
+
+				return buildah.From(ctx, c.ImageName, c.Name)
+			})
+		})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	mountPoint, err := buildah.Mount(ctx, c.Name)
+	if err != nil {
+		return "", fmt.Errorf("unable to mount buildah container %s: %s", c.Name, err)
+	}
+
+	return fmt.Sprintf("%s:%s:ro", filepath.Join(mountPoint, c.Volume), c.Volume), nil
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// BuildRunnerLeaseTTL is the time after the last runner activity, when the runner is considered dead and its claims could be taken by other runners
+	BuildRunnerLeaseTTL = time.Minute
+	// BuildRunnerHeartbeatPeriod is the period the runner should renew its lease with
+	BuildRunnerHeartbeatPeriod = 10 * time.Second
+)
+
+// BuildCoordinator distributes images and stages between werf processes (runners), which build the same project at the same time.
+// Claims are held while the runner lease is renewed by any call, so claims of dead runners are released after BuildRunnerLeaseTTL.
+type BuildCoordinator interface {
+	// ClaimImage claims the first of the images, which is not claimed by another alive runner of the session, and returns its name or empty string if all images are claimed
+	ClaimImage(ctx context.Context, projectName, sessionID, runnerID string, imageNames []string) (string, error)
+	// ClaimStage claims the stage by digest, returns true if the stage is not claimed by another alive runner of the session and should be built by the runner
+	ClaimStage(ctx context.Context, projectName, sessionID, runnerID, digest string) (bool, error)
+	// Heartbeat renews the runner lease
+	Heartbeat(ctx context.Context, projectName, sessionID, runnerID string) error
+
+	String() string
+}
@@ -212,6 +212,10 @@ func (storage *LocalDockerServerStagesStorage) StoreImage(ctx context.Context, i
 	return storage.LocalDockerServerRuntime.TagImageByName(ctx, img)
 }
 
+func (storage *LocalDockerServerStagesStorage) StoreManifestList(_ context.Context, _, _ string, _ int64, _ map[string]*image.StageDescription) (*image.StageDescription, error) {
+	return nil, fmt.Errorf("manifest lists are not supported by %s stages storage: specify repo address", LocalStorageAddress)
+}
+
 func (storage *LocalDockerServerStagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
 	logboek.Context(ctx).Debug().LogF("-- LocalDockerServerStagesStorage.PutImageMetadata %s %s %s %s\n", projectName, imageName, commit, stageID)
 
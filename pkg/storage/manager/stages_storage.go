@@ -20,6 +20,7 @@ import (
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/storage"
 	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/tracing"
 	"github.com/werf/werf/pkg/util/parallel"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -160,7 +161,9 @@ func (m *StagesStorageManager) FetchStage(ctx context.Context, stg stage.Interfa
 			DoError(func() error {
 				logboek.Context(ctx).Info().LogF("Image name: %s\n", stg.GetImage().Name())
 
-				if err := m.StagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()}); err != nil {
+				if err := tracing.Do(ctx, "storage.FetchImage", func(ctx context.Context) error {
+					return m.StagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: stg.GetImage()})
+				}, "werf.storage", m.StagesStorage.String(), "werf.stage_image", stg.GetImage().Name()); err != nil {
 					return fmt.Errorf("unable to fetch stage %s image %s from storage %s: %s", stg.LogDetailedName(), stg.GetImage().Name(), m.StagesStorage.String(), err)
 				}
 
@@ -229,7 +232,10 @@ func (m *StagesStorageManager) AtomicStoreStagesByDigestToCache(ctx context.Cont
 		})
 }
 
-func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName, stageDigest string) ([]*image.StageDescription, error) {
+func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName, stageDigest string) (stages []*image.StageDescription, err error) {
+	ctx, span := tracing.StartSpan(ctx, "storage.GetStagesByDigest", "werf.storage", m.StagesStorage.String(), "werf.stage", stageName, "werf.stage_digest", stageDigest)
+	defer func() { span.End(err) }()
+
 	cacheExists, cacheStages, err := m.getStagesByDigestFromCache(ctx, stageName, stageDigest)
 	if err != nil {
 		return nil, err
@@ -245,13 +251,16 @@ func (m *StagesStorageManager) GetStagesByDigest(ctx context.Context, stageName,
 	return m.atomicGetStagesByDigestWithCacheReset(ctx, stageName, stageDigest)
 }
 
-func (m *StagesStorageManager) GetStagesByDigestFromStagesStorage(ctx context.Context, stageName, stageDigest string, stagesStorage storage.StagesStorage) ([]*image.StageDescription, error) {
+func (m *StagesStorageManager) GetStagesByDigestFromStagesStorage(ctx context.Context, stageName, stageDigest string, stagesStorage storage.StagesStorage) (stages []*image.StageDescription, err error) {
+	ctx, span := tracing.StartSpan(ctx, "storage.GetStagesByDigest", "werf.storage", stagesStorage.String(), "werf.stage", stageName, "werf.stage_digest", stageDigest)
+	defer func() { span.End(err) }()
+
 	stageIDs, err := m.getStagesIDsByDigestFromStagesStorage(ctx, stageName, stageDigest, stagesStorage)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get stages ids from %s by digest %s for stage %s: %s", stagesStorage.String(), stageDigest, stageName, err)
 	}
 
-	stages, err := m.getStagesDescriptions(ctx, stageIDs, stagesStorage)
+	stages, err = m.getStagesDescriptions(ctx, stageIDs, stagesStorage)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get stage descriptions by ids from %s: %s", stagesStorage.String(), err)
 	}
@@ -259,8 +268,11 @@ func (m *StagesStorageManager) GetStagesByDigestFromStagesStorage(ctx context.Co
 	return stages, nil
 }
 
-func (m *StagesStorageManager) CopySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) (*image.StageDescription, error) {
-	img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime.(*container_runtime.LocalDockerServerRuntime))
+func (m *StagesStorageManager) CopySuitableByDigestStage(ctx context.Context, stageDesc *image.StageDescription, sourceStagesStorage, destinationStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime) (_ *image.StageDescription, err error) {
+	ctx, span := tracing.StartSpan(ctx, "storage.CopyStage", "werf.stage_id", stageDesc.StageID.String(), "werf.storage.from", sourceStagesStorage.String(), "werf.storage.to", destinationStagesStorage.String())
+	defer func() { span.End(err) }()
+
+	img := container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime)
 
 	logboek.Context(ctx).Info().LogF("Fetching %s\n", img.Name())
 	if err := sourceStagesStorage.FetchImage(ctx, &container_runtime.DockerImage{Image: img}); err != nil {
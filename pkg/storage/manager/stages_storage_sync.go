@@ -0,0 +1,431 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/util/parallel"
+)
+
+type SyncStagesOptions struct {
+	// ImageNameList is the list of images which managed image records and image metadata should be synced.
+	// All managed images of the source stages storage are used if the list is empty.
+	ImageNameList []string
+	// OnlyImagesStages limits synced stages by the stages of the images from the ImageNameList (including parents and import sources)
+	OnlyImagesStages bool
+	// DigestList limits synced stages by the stages with the specified digests
+	DigestList []string
+	// MaxAge limits synced stages by the stages created within the specified period
+	MaxAge time.Duration
+	DryRun bool
+}
+
+// SyncStagesFromStagesStorage copies stages, import metadata, image metadata and managed images from the source stages storage into the primary one.
+// Records already existing in the primary stages storage are skipped, so the interrupted sync could be resumed by running it again.
+func (m *StagesStorageManager) SyncStagesFromStagesStorage(ctx context.Context, sourceStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, opts SyncStagesOptions) error {
+	imageNameList := opts.ImageNameList
+	if len(imageNameList) == 0 {
+		managedImages, err := sourceStagesStorage.GetManagedImages(ctx, m.ProjectName)
+		if err != nil {
+			return fmt.Errorf("unable to get managed images from %s: %s", sourceStagesStorage.String(), err)
+		}
+		imageNameList = managedImages
+	}
+
+	var sourceStages []*image.StageDescription
+	if err := logboek.Context(ctx).Default().LogProcess("Getting stages from %s", sourceStagesStorage.String()).DoError(func() error {
+		var err error
+		sourceStages, err = m.getStageDescriptionListFromStagesStorage(ctx, sourceStagesStorage)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	var importMetadataList []*storage.ImportMetadata
+	if err := logboek.Context(ctx).Default().LogProcess("Getting import metadata from %s", sourceStagesStorage.String()).DoError(func() error {
+		var err error
+		importMetadataList, err = m.getImportMetadataListFromStagesStorage(ctx, sourceStagesStorage)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	imageMetadataByImageName, _, err := sourceStagesStorage.GetAllAndGroupImageMetadataByImageName(ctx, m.ProjectName, imageNameList)
+	if err != nil {
+		return fmt.Errorf("unable to get image metadata from %s: %s", sourceStagesStorage.String(), err)
+	}
+
+	stages := sourceStages
+	if opts.OnlyImagesStages {
+		stages = selectImagesStages(sourceStages, importMetadataList, imageMetadataByImageName)
+	}
+	stages = filterStagesByDigestAndAge(stages, opts.DigestList, opts.MaxAge)
+
+	existingStageIDs, err := m.StagesStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get stages from %s: %s", m.StagesStorage.String(), err)
+	}
+
+	existingStages := map[string]bool{}
+	for _, stageID := range existingStageIDs {
+		existingStages[stageID.String()] = true
+	}
+
+	var stagesToSync []*image.StageDescription
+	for _, stageDesc := range stages {
+		if !existingStages[stageDesc.StageID.String()] {
+			stagesToSync = append(stagesToSync, stageDesc)
+		}
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Syncing stages (%d to copy, %d already exist)", len(stagesToSync), len(stages)-len(stagesToSync)).DoError(func() error {
+		return m.syncStages(ctx, sourceStagesStorage, containerRuntime, stagesToSync, opts.DryRun)
+	}); err != nil {
+		return err
+	}
+
+	syncedImageIDs := map[string]bool{}
+	syncedStageIDs := map[string]bool{}
+	for _, stageDesc := range stages {
+		syncedImageIDs[stageDesc.Info.ID] = true
+		syncedStageIDs[stageDesc.StageID.String()] = true
+	}
+	for stageID := range existingStages {
+		syncedStageIDs[stageID] = true
+	}
+
+	var importMetadataToSync []*storage.ImportMetadata
+	for _, metadata := range importMetadataList {
+		if syncedImageIDs[metadata.SourceImageID] {
+			importMetadataToSync = append(importMetadataToSync, metadata)
+		}
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Syncing import metadata").DoError(func() error {
+		return m.syncImportMetadata(ctx, importMetadataToSync, opts.DryRun)
+	}); err != nil {
+		return err
+	}
+
+	if err := logboek.Context(ctx).Default().LogProcess("Syncing image metadata").DoError(func() error {
+		return m.syncImageMetadata(ctx, imageMetadataByImageName, syncedStageIDs, opts.DryRun)
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Syncing managed images").DoError(func() error {
+		return m.syncManagedImages(ctx, sourceStagesStorage, imageNameList, opts.DryRun)
+	})
+}
+
+func (m *StagesStorageManager) syncStages(ctx context.Context, sourceStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, stages []*image.StageDescription, dryRun bool) error {
+	if dryRun {
+		for _, stageDesc := range stages {
+			logboek.Context(ctx).Default().LogFDetails("  stage: %s\n", stageDesc.Info.Name)
+		}
+		return nil
+	}
+
+	return parallel.DoTasks(ctx, len(stages), parallel.DoTasksOptions{
+		MaxNumberOfWorkers:         m.MaxNumberOfWorkers(),
+		InitDockerCLIForEachWorker: true,
+	}, func(ctx context.Context, taskId int) error {
+		stageDesc := stages[taskId]
+
+		if err := m.syncStage(ctx, sourceStagesStorage, containerRuntime, stageDesc); err != nil {
+			return fmt.Errorf("unable to copy stage %s: %s", stageDesc.StageID.String(), err)
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  stage: %s\n", stageDesc.Info.Name)
+
+		return nil
+	})
+}
+
+func (m *StagesStorageManager) syncStage(ctx context.Context, sourceStagesStorage storage.StagesStorage, containerRuntime container_runtime.ContainerRuntime, stageDesc *image.StageDescription) error {
+	// the stage could be stored concurrently by the build, so the existence is checked again under the same lock the build takes
+	if lock, err := m.StorageLockManager.LockStage(ctx, m.ProjectName, stageDesc.StageID.Digest); err != nil {
+		return fmt.Errorf("error locking stage %s: %s", stageDesc.StageID.String(), err)
+	} else {
+		defer m.StorageLockManager.Unlock(ctx, lock)
+	}
+
+	if existingStageDesc, err := m.StagesStorage.GetStageDescription(ctx, m.ProjectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID); err != nil {
+		return fmt.Errorf("unable to get stage %s from %s: %s", stageDesc.StageID.String(), m.StagesStorage.String(), err)
+	} else if existingStageDesc != nil {
+		logboek.Context(ctx).Info().LogF("Stage %s already exists in %s\n", stageDesc.StageID.String(), m.StagesStorage.String())
+		return nil
+	}
+
+	img := &container_runtime.DockerImage{Image: container_runtime.NewStageImage(nil, stageDesc.Info.Name, containerRuntime)}
+
+	logboek.Context(ctx).Info().LogF("Fetching %s\n", stageDesc.Info.Name)
+	if err := sourceStagesStorage.FetchImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to fetch %s from %s: %s", stageDesc.Info.Name, sourceStagesStorage.String(), err)
+	}
+
+	newImageName := m.StagesStorage.ConstructStageImageName(m.ProjectName, stageDesc.StageID.Digest, stageDesc.StageID.UniqueID)
+	logboek.Context(ctx).Info().LogF("Renaming image %s to %s\n", stageDesc.Info.Name, newImageName)
+	if err := containerRuntime.RenameImage(ctx, img, newImageName, sourceStagesStorage.Address() != storage.LocalStorageAddress); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Info().LogF("Storing %s\n", newImageName)
+	if err := m.StagesStorage.StoreImage(ctx, img); err != nil {
+		return fmt.Errorf("unable to store %s to %s: %s", newImageName, m.StagesStorage.String(), err)
+	}
+
+	if m.StagesStorage.Address() != storage.LocalStorageAddress {
+		if err := containerRuntime.RemoveImage(ctx, img); err != nil {
+			return fmt.Errorf("unable to remove local image %s: %s", newImageName, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *StagesStorageManager) syncImportMetadata(ctx context.Context, importMetadataList []*storage.ImportMetadata, dryRun bool) error {
+	return parallel.DoTasks(ctx, len(importMetadataList), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
+	}, func(ctx context.Context, taskId int) error {
+		metadata := importMetadataList[taskId]
+
+		if existingMetadata, err := m.StagesStorage.GetImportMetadata(ctx, m.ProjectName, metadata.ImportSourceID); err != nil {
+			return fmt.Errorf("unable to get import metadata %s from %s: %s", metadata.ImportSourceID, m.StagesStorage.String(), err)
+		} else if existingMetadata != nil {
+			return nil
+		}
+
+		if !dryRun {
+			if err := m.StagesStorage.PutImportMetadata(ctx, m.ProjectName, metadata); err != nil {
+				return fmt.Errorf("unable to put import metadata %s into %s: %s", metadata.ImportSourceID, m.StagesStorage.String(), err)
+			}
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  importMetadataID: %s\n", metadata.ImportSourceID)
+
+		return nil
+	})
+}
+
+type syncImageMetadataTask struct {
+	imageName string
+	stageID   string
+	commit    string
+}
+
+func (m *StagesStorageManager) syncImageMetadata(ctx context.Context, imageMetadataByImageName map[string]map[string][]string, syncedStageIDs map[string]bool, dryRun bool) error {
+	var tasks []syncImageMetadataTask
+	for imageName, stageIDCommitList := range imageMetadataByImageName {
+		for stageID, commitList := range stageIDCommitList {
+			if !syncedStageIDs[stageID] {
+				continue
+			}
+
+			for _, commit := range commitList {
+				tasks = append(tasks, syncImageMetadataTask{
+					imageName: imageName,
+					stageID:   stageID,
+					commit:    commit,
+				})
+			}
+		}
+	}
+
+	return parallel.DoTasks(ctx, len(tasks), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
+	}, func(ctx context.Context, taskId int) error {
+		task := tasks[taskId]
+
+		if exist, err := m.StagesStorage.IsImageMetadataExist(ctx, m.ProjectName, task.imageName, task.commit, task.stageID); err != nil {
+			return fmt.Errorf("unable to check image %q metadata existence in %s: %s", task.imageName, m.StagesStorage.String(), err)
+		} else if exist {
+			return nil
+		}
+
+		if !dryRun {
+			if err := m.StagesStorage.PutImageMetadata(ctx, m.ProjectName, task.imageName, task.commit, task.stageID); err != nil {
+				return fmt.Errorf("unable to put image %q metadata into %s: %s", task.imageName, m.StagesStorage.String(), err)
+			}
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  image: %s commit: %s stageID: %s\n", task.imageName, task.commit, task.stageID)
+
+		return nil
+	})
+}
+
+func (m *StagesStorageManager) syncManagedImages(ctx context.Context, sourceStagesStorage storage.StagesStorage, imageNameList []string, dryRun bool) error {
+	sourceManagedImages, err := sourceStagesStorage.GetManagedImages(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get managed images from %s: %s", sourceStagesStorage.String(), err)
+	}
+
+	existingManagedImages, err := m.StagesStorage.GetManagedImages(ctx, m.ProjectName)
+	if err != nil {
+		return fmt.Errorf("unable to get managed images from %s: %s", m.StagesStorage.String(), err)
+	}
+
+	var managedImages []string
+	for _, imageName := range imageNameList {
+		if util.IsStringsContainValue(sourceManagedImages, imageName) && !util.IsStringsContainValue(existingManagedImages, imageName) {
+			managedImages = append(managedImages, imageName)
+		}
+	}
+	sort.Strings(managedImages)
+
+	return parallel.DoTasks(ctx, len(managedImages), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
+	}, func(ctx context.Context, taskId int) error {
+		imageName := managedImages[taskId]
+
+		if !dryRun {
+			if err := m.StagesStorage.AddManagedImage(ctx, m.ProjectName, imageName); err != nil {
+				return fmt.Errorf("unable to add managed image %q into %s: %s", imageName, m.StagesStorage.String(), err)
+			}
+		}
+
+		logboek.Context(ctx).Default().LogFDetails("  managedImage: %s\n", imageName)
+
+		return nil
+	})
+}
+
+func (m *StagesStorageManager) getStageDescriptionListFromStagesStorage(ctx context.Context, stagesStorage storage.StagesStorage) ([]*image.StageDescription, error) {
+	stageIDs, err := stagesStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stages from %s: %s", stagesStorage.String(), err)
+	}
+
+	var mutex sync.Mutex
+	var stages []*image.StageDescription
+	if err := parallel.DoTasks(ctx, len(stageIDs), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
+	}, func(ctx context.Context, taskId int) error {
+		stageID := stageIDs[taskId]
+
+		if stageDesc, err := getStageDescription(ctx, m.ProjectName, stageID, stagesStorage, getStageDescriptionOptions{StageShouldExist: false, WithManifestCache: stagesStorage.Address() != storage.LocalStorageAddress}); err != nil {
+			return err
+		} else if stageDesc == nil {
+			logboek.Context(ctx).Warn().LogF("Ignoring stage %s: cannot get stage description from %s\n", stageID.String(), stagesStorage.String())
+		} else {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			stages = append(stages, stageDesc)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return stages, nil
+}
+
+func (m *StagesStorageManager) getImportMetadataListFromStagesStorage(ctx context.Context, stagesStorage storage.StagesStorage) ([]*storage.ImportMetadata, error) {
+	ids, err := stagesStorage.GetImportMetadataIDs(ctx, m.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get import metadata ids from %s: %s", stagesStorage.String(), err)
+	}
+
+	var mutex sync.Mutex
+	var importMetadataList []*storage.ImportMetadata
+	if err := parallel.DoTasks(ctx, len(ids), parallel.DoTasksOptions{
+		MaxNumberOfWorkers: m.MaxNumberOfWorkers(),
+	}, func(ctx context.Context, taskId int) error {
+		id := ids[taskId]
+
+		if metadata, err := stagesStorage.GetImportMetadata(ctx, m.ProjectName, id); err != nil {
+			return fmt.Errorf("unable to get import metadata %s from %s: %s", id, stagesStorage.String(), err)
+		} else if metadata != nil {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			importMetadataList = append(importMetadataList, metadata)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return importMetadataList, nil
+}
+
+// selectImagesStages returns the stages used by the image metadata records with all parent stages and import sources
+func selectImagesStages(stages []*image.StageDescription, importMetadataList []*storage.ImportMetadata, imageMetadataByImageName map[string]map[string][]string) []*image.StageDescription {
+	stageByStageID := map[string]*image.StageDescription{}
+	stageByImageID := map[string]*image.StageDescription{}
+	for _, stageDesc := range stages {
+		stageByStageID[stageDesc.StageID.String()] = stageDesc
+		stageByImageID[stageDesc.Info.ID] = stageDesc
+	}
+
+	checksumSourceImageIDs := map[string][]string{}
+	for _, metadata := range importMetadataList {
+		checksumSourceImageIDs[metadata.Checksum] = append(checksumSourceImageIDs[metadata.Checksum], metadata.SourceImageID)
+	}
+
+	selected := map[*image.StageDescription]bool{}
+	var selectStageAndRelatives func(stageDesc *image.StageDescription)
+	selectStageAndRelatives = func(stageDesc *image.StageDescription) {
+		for stageDesc != nil && !selected[stageDesc] {
+			selected[stageDesc] = true
+
+			for label, checksum := range stageDesc.Info.Labels {
+				if !strings.HasPrefix(label, image.WerfImportChecksumLabelPrefix) {
+					continue
+				}
+
+				for _, sourceImageID := range checksumSourceImageIDs[checksum] {
+					selectStageAndRelatives(stageByImageID[sourceImageID])
+				}
+			}
+
+			stageDesc = stageByImageID[stageDesc.Info.ParentID]
+		}
+	}
+
+	for _, stageIDCommitList := range imageMetadataByImageName {
+		for stageID := range stageIDCommitList {
+			selectStageAndRelatives(stageByStageID[stageID])
+		}
+	}
+
+	var res []*image.StageDescription
+	for _, stageDesc := range stages {
+		if selected[stageDesc] {
+			res = append(res, stageDesc)
+		}
+	}
+
+	return res
+}
+
+func filterStagesByDigestAndAge(stages []*image.StageDescription, digestList []string, maxAge time.Duration) []*image.StageDescription {
+	var res []*image.StageDescription
+	for _, stageDesc := range stages {
+		if len(digestList) != 0 && !util.IsStringsContainValue(digestList, stageDesc.StageID.Digest) {
+			continue
+		}
+
+		if maxAge != 0 && time.Since(stageDesc.Info.GetCreatedAt()) > maxAge {
+			continue
+		}
+
+		res = append(res, stageDesc)
+	}
+
+	return res
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+func newTestStageDescription(digest string, uniqueID int64, imageID, parentID string, createdAt time.Time, labels map[string]string) *image.StageDescription {
+	info := &image.Info{ID: imageID, ParentID: parentID, Labels: labels}
+	info.SetCreatedAtUnixNano(createdAt.UnixNano())
+
+	return &image.StageDescription{
+		StageID: &image.StageID{Digest: digest, UniqueID: uniqueID},
+		Info:    info,
+	}
+}
+
+func stageDescriptionsIDs(stages []*image.StageDescription) string {
+	var ids []string
+	for _, stageDesc := range stages {
+		ids = append(ids, stageDesc.StageID.String())
+	}
+	sort.Strings(ids)
+
+	return strings.Join(ids, ",")
+}
+
+func TestSelectImagesStages(t *testing.T) {
+	now := time.Now()
+
+	base := newTestStageDescription("base", 1, "sha256:base", "", now, nil)
+	source := newTestStageDescription("source", 2, "sha256:source", "", now, nil)
+	importer := newTestStageDescription("importer", 3, "sha256:importer", "sha256:base", now, map[string]string{image.WerfImportChecksumLabelPrefix + "id": "checksum"})
+	unrelated := newTestStageDescription("unrelated", 4, "sha256:unrelated", "", now, nil)
+	stages := []*image.StageDescription{base, source, importer, unrelated}
+
+	importMetadataList := []*storage.ImportMetadata{{ImportSourceID: "id", SourceImageID: "sha256:source", Checksum: "checksum"}}
+
+	tests := []struct {
+		name                     string
+		imageMetadataByImageName map[string]map[string][]string
+		expected                 []*image.StageDescription
+	}{
+		{
+			name:                     "no image metadata",
+			imageMetadataByImageName: map[string]map[string][]string{},
+		},
+		{
+			name:                     "stage with parent and import source",
+			imageMetadataByImageName: map[string]map[string][]string{"app": {importer.StageID.String(): {"commit"}}},
+			expected:                 []*image.StageDescription{base, source, importer},
+		},
+		{
+			name:                     "stage without relatives",
+			imageMetadataByImageName: map[string]map[string][]string{"app": {unrelated.StageID.String(): {"commit"}}},
+			expected:                 []*image.StageDescription{unrelated},
+		},
+		{
+			name:                     "nonexistent stage",
+			imageMetadataByImageName: map[string]map[string][]string{"app": {"nonexistent-5": {"commit"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectImagesStages(stages, importMetadataList, tt.imageMetadataByImageName)
+			if stageDescriptionsIDs(got) != stageDescriptionsIDs(tt.expected) {
+				t.Errorf("expected stages %q, got %q", stageDescriptionsIDs(tt.expected), stageDescriptionsIDs(got))
+			}
+		})
+	}
+}
+
+func TestFilterStagesByDigestAndAge(t *testing.T) {
+	now := time.Now()
+
+	fresh := newTestStageDescription("a", 1, "sha256:fresh", "", now, nil)
+	old := newTestStageDescription("a", 2, "sha256:old", "", now.Add(-48*time.Hour), nil)
+	other := newTestStageDescription("b", 3, "sha256:other", "", now, nil)
+	stages := []*image.StageDescription{fresh, old, other}
+
+	tests := []struct {
+		name       string
+		digestList []string
+		maxAge     time.Duration
+		expected   []*image.StageDescription
+	}{
+		{
+			name:     "no filters",
+			expected: stages,
+		},
+		{
+			name:       "by digest",
+			digestList: []string{"a"},
+			expected:   []*image.StageDescription{fresh, old},
+		},
+		{
+			name:     "by age",
+			maxAge:   24 * time.Hour,
+			expected: []*image.StageDescription{fresh, other},
+		},
+		{
+			name:       "by digest and age",
+			digestList: []string{"a"},
+			maxAge:     24 * time.Hour,
+			expected:   []*image.StageDescription{fresh},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterStagesByDigestAndAge(stages, tt.digestList, tt.maxAge)
+			if stageDescriptionsIDs(got) != stageDescriptionsIDs(tt.expected) {
+				t.Errorf("expected stages %q, got %q", stageDescriptionsIDs(tt.expected), stageDescriptionsIDs(got))
+			}
+		})
+	}
+}
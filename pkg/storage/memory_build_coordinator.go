@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// buildSessionTTL is the time after the last activity, when the session claims are dropped
+const buildSessionTTL = time.Hour
+
+// BuildCoordinatorStateStore persists sessions of the build coordinator by the project and session key
+type BuildCoordinatorStateStore interface {
+	GetSessions() (map[string]*BuildSession, error)
+	PutSession(key string, session *BuildSession) error
+	DeleteSession(key string) error
+
+	String() string
+}
+
+type MemoryBuildCoordinator struct {
+	mutex    sync.Mutex
+	sessions map[string]*BuildSession
+	store    BuildCoordinatorStateStore
+
+	now func() time.Time
+}
+
+type BuildSession struct {
+	// Claims contains runner ID by the claimed image name or stage digest
+	Claims map[string]string `json:"claims"`
+	// Runners contains the last activity time by runner ID
+	Runners        map[string]time.Time `json:"runners"`
+	LastActivityAt time.Time            `json:"lastActivityAt"`
+}
+
+func NewMemoryBuildCoordinator() *MemoryBuildCoordinator {
+	return &MemoryBuildCoordinator{sessions: map[string]*BuildSession{}, now: time.Now}
+}
+
+// NewMemoryBuildCoordinatorWithStateStore returns the coordinator, which restores sessions from the store and persists each change into the store
+func NewMemoryBuildCoordinatorWithStateStore(store BuildCoordinatorStateStore) (*MemoryBuildCoordinator, error) {
+	sessions, err := store.GetSessions()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get build sessions from %s: %s", store.String(), err)
+	}
+
+	coordinator := NewMemoryBuildCoordinator()
+	coordinator.store = store
+	for key, session := range sessions {
+		coordinator.sessions[key] = session
+	}
+
+	return coordinator, nil
+}
+
+func (coordinator *MemoryBuildCoordinator) String() string {
+	return "memory"
+}
+
+func (coordinator *MemoryBuildCoordinator) ClaimImage(_ context.Context, projectName, sessionID, runnerID string, imageNames []string) (string, error) {
+	coordinator.mutex.Lock()
+	defer coordinator.mutex.Unlock()
+
+	key, session, err := coordinator.getSessionAndRenewLease(projectName, sessionID, runnerID)
+	if err != nil {
+		return "", err
+	}
+
+	var claimedImageName string
+	for _, imageName := range imageNames {
+		if session.claim("image/"+imageName, runnerID, coordinator.now()) {
+			claimedImageName = imageName
+			break
+		}
+	}
+
+	return claimedImageName, coordinator.putSession(key, session)
+}
+
+func (coordinator *MemoryBuildCoordinator) ClaimStage(_ context.Context, projectName, sessionID, runnerID, digest string) (bool, error) {
+	coordinator.mutex.Lock()
+	defer coordinator.mutex.Unlock()
+
+	key, session, err := coordinator.getSessionAndRenewLease(projectName, sessionID, runnerID)
+	if err != nil {
+		return false, err
+	}
+
+	claimed := session.claim("stage/"+digest, runnerID, coordinator.now())
+
+	return claimed, coordinator.putSession(key, session)
+}
+
+func (coordinator *MemoryBuildCoordinator) Heartbeat(_ context.Context, projectName, sessionID, runnerID string) error {
+	coordinator.mutex.Lock()
+	defer coordinator.mutex.Unlock()
+
+	key, session, err := coordinator.getSessionAndRenewLease(projectName, sessionID, runnerID)
+	if err != nil {
+		return err
+	}
+
+	return coordinator.putSession(key, session)
+}
+
+func (coordinator *MemoryBuildCoordinator) getSessionAndRenewLease(projectName, sessionID, runnerID string) (string, *BuildSession, error) {
+	now := coordinator.now()
+	for key, session := range coordinator.sessions {
+		if now.Sub(session.LastActivityAt) > buildSessionTTL {
+			delete(coordinator.sessions, key)
+
+			if coordinator.store != nil {
+				if err := coordinator.store.DeleteSession(key); err != nil {
+					return "", nil, fmt.Errorf("unable to delete build session %s from %s: %s", key, coordinator.store.String(), err)
+				}
+			}
+		}
+	}
+
+	key := projectName + "/" + sessionID
+	session, ok := coordinator.sessions[key]
+	if !ok {
+		session = &BuildSession{Claims: map[string]string{}, Runners: map[string]time.Time{}}
+		coordinator.sessions[key] = session
+	}
+	session.LastActivityAt = now
+	session.Runners[runnerID] = now
+
+	return key, session, nil
+}
+
+func (coordinator *MemoryBuildCoordinator) putSession(key string, session *BuildSession) error {
+	if coordinator.store == nil {
+		return nil
+	}
+
+	if err := coordinator.store.PutSession(key, session); err != nil {
+		return fmt.Errorf("unable to put build session %s into %s: %s", key, coordinator.store.String(), err)
+	}
+
+	return nil
+}
+
+// claim takes the free claim, the claim of the runner itself or the claim of the dead runner
+func (session *BuildSession) claim(key, runnerID string, now time.Time) bool {
+	if claimRunnerID, ok := session.Claims[key]; ok && claimRunnerID != runnerID {
+		if now.Sub(session.Runners[claimRunnerID]) <= BuildRunnerLeaseTTL {
+			return false
+		}
+	}
+
+	session.Claims[key] = runnerID
+	return true
+}
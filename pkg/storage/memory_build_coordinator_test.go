@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryBuildCoordinator_ClaimImage(t *testing.T) {
+	ctx := context.Background()
+	coordinator := NewMemoryBuildCoordinator()
+	imageNames := []string{"backend", "frontend"}
+
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-1", imageNames, "backend")
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-2", imageNames, "frontend")
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-3", imageNames, "")
+	checkClaimedImage(t, coordinator, ctx, "other-session", "runner-1", imageNames, "backend")
+}
+
+func TestMemoryBuildCoordinator_ClaimStage(t *testing.T) {
+	ctx := context.Background()
+	coordinator := NewMemoryBuildCoordinator()
+
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest-1", true)
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest-1", true)
+	checkClaimedStage(t, coordinator, ctx, "runner-2", "digest-1", false)
+	checkClaimedStage(t, coordinator, ctx, "runner-2", "digest-2", true)
+}
+
+func TestMemoryBuildCoordinator_Lease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	coordinator := NewMemoryBuildCoordinator()
+	coordinator.now = func() time.Time { return now }
+
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-1", []string{"backend"}, "backend")
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest", true)
+	checkClaimedStage(t, coordinator, ctx, "runner-2", "digest", false)
+
+	// the lease is renewed by the heartbeat
+	now = now.Add(BuildRunnerLeaseTTL - time.Second)
+	if err := coordinator.Heartbeat(ctx, "project", "session", "runner-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(BuildRunnerLeaseTTL - time.Second)
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-2", []string{"backend"}, "")
+	checkClaimedStage(t, coordinator, ctx, "runner-2", "digest", false)
+
+	// claims of the dead runner are taken by other runners
+	now = now.Add(BuildRunnerLeaseTTL + time.Second)
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-2", []string{"backend"}, "backend")
+	checkClaimedStage(t, coordinator, ctx, "runner-2", "digest", true)
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest", false)
+
+	// inactive sessions are dropped
+	now = now.Add(buildSessionTTL + time.Second)
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest", true)
+	if len(coordinator.sessions) != 1 || len(coordinator.sessions["project/session"].Runners) != 1 {
+		t.Errorf("expected inactive session to be dropped, got %+v", coordinator.sessions)
+	}
+}
+
+func checkClaimedImage(t *testing.T, coordinator *MemoryBuildCoordinator, ctx context.Context, sessionID, runnerID string, imageNames []string, expected string) {
+	if imageName, err := coordinator.ClaimImage(ctx, "project", sessionID, runnerID, imageNames); err != nil {
+		t.Error(err)
+	} else if imageName != expected {
+		t.Errorf("expected runner %s of session %s to claim image %q, got %q", runnerID, sessionID, expected, imageName)
+	}
+}
+
+func checkClaimedStage(t *testing.T, coordinator *MemoryBuildCoordinator, ctx context.Context, runnerID, digest string, expected bool) {
+	if claimed, err := coordinator.ClaimStage(ctx, "project", "session", runnerID, digest); err != nil {
+		t.Error(err)
+	} else if claimed != expected {
+		t.Errorf("expected runner %s claim of stage %s to be %v, got %v", runnerID, digest, expected, claimed)
+	}
+}
+
+type testBuildCoordinatorStateStore struct {
+	sessions map[string]BuildSession
+}
+
+func (store *testBuildCoordinatorStateStore) GetSessions() (map[string]*BuildSession, error) {
+	res := map[string]*BuildSession{}
+	for key, session := range store.sessions {
+		session := session
+		res[key] = &session
+	}
+	return res, nil
+}
+
+func (store *testBuildCoordinatorStateStore) PutSession(key string, session *BuildSession) error {
+	claims := map[string]string{}
+	for k, v := range session.Claims {
+		claims[k] = v
+	}
+
+	runners := map[string]time.Time{}
+	for k, v := range session.Runners {
+		runners[k] = v
+	}
+
+	store.sessions[key] = BuildSession{Claims: claims, Runners: runners, LastActivityAt: session.LastActivityAt}
+	return nil
+}
+
+func (store *testBuildCoordinatorStateStore) DeleteSession(key string) error {
+	delete(store.sessions, key)
+	return nil
+}
+
+func (store *testBuildCoordinatorStateStore) String() string {
+	return "test"
+}
+
+func TestMemoryBuildCoordinator_StateStore(t *testing.T) {
+	ctx := context.Background()
+	store := &testBuildCoordinatorStateStore{sessions: map[string]BuildSession{}}
+
+	coordinator, err := NewMemoryBuildCoordinatorWithStateStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkClaimedImage(t, coordinator, ctx, "session", "runner-1", []string{"backend", "frontend"}, "backend")
+	checkClaimedStage(t, coordinator, ctx, "runner-1", "digest", true)
+
+	// claims survive the restart
+	restartedCoordinator, err := NewMemoryBuildCoordinatorWithStateStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkClaimedImage(t, restartedCoordinator, ctx, "session", "runner-2", []string{"backend", "frontend"}, "frontend")
+	checkClaimedStage(t, restartedCoordinator, ctx, "runner-2", "digest", false)
+
+	// expired sessions are deleted from the store
+	restartedCoordinator.now = func() time.Time { return time.Now().Add(buildSessionTTL + time.Minute) }
+	if _, err := restartedCoordinator.ClaimImage(ctx, "project", "other-session", "runner-1", []string{"backend"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.sessions["project/session"]; ok || len(store.sessions) != 1 {
+		t.Errorf("expected expired session to be deleted from the store, got %+v", store.sessions)
+	}
+}
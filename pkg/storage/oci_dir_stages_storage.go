@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/buildah"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	OciDirStorageAddressPrefix = "oci-dir://"
+
+	// OciDirStage_RepoFormat is a local repository name used by container runtime for images of the oci-dir stages storage
+	OciDirStage_RepoFormat = "werf-oci-dir/%s"
+)
+
+func IsOciDirStorageAddress(address string) bool {
+	return strings.HasPrefix(address, OciDirStorageAddressPrefix)
+}
+
+// OciDirStagesStorage stores stages and service records in the OCI image layout directory.
+// Stages, managed images, image and import metadata are stored by the same tags as in the RepoStagesStorage,
+// so the directory could be moved between environments and used as the primary or secondary stages storage.
+type OciDirStagesStorage struct {
+	*RepoStagesStorage
+
+	StorageAddress string
+	OciLayout      *docker_registry.OciLayout
+}
+
+func NewOciDirStagesStorage(address string, containerRuntime container_runtime.ContainerRuntime) (*OciDirStagesStorage, error) {
+	layoutPath := strings.TrimPrefix(address, OciDirStorageAddressPrefix)
+	if layoutPath == "" {
+		return nil, fmt.Errorf("bad oci-dir stages storage address %q: path required", address)
+	}
+
+	absLayoutPath, err := filepath.Abs(layoutPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path for %q: %s", layoutPath, err)
+	}
+
+	ociLayout := docker_registry.NewOciLayout(absLayoutPath)
+
+	return &OciDirStagesStorage{
+		RepoStagesStorage: &RepoStagesStorage{
+			RepoAddress:      fmt.Sprintf(OciDirStage_RepoFormat, util.MurmurHash(absLayoutPath)),
+			DockerRegistry:   ociLayout,
+			ContainerRuntime: containerRuntime,
+		},
+		StorageAddress: address,
+		OciLayout:      ociLayout,
+	}, nil
+}
+
+func (storage *OciDirStagesStorage) FetchImage(ctx context.Context, img container_runtime.Image) error {
+	dockerImage := img.(*container_runtime.DockerImage)
+	imageName := dockerImage.Image.Name()
+
+	return withTmpArchive(func(archivePath string) error {
+		logboek.Context(ctx).Debug().LogF("-- OciDirStagesStorage.FetchImage %s via archive %s\n", imageName, archivePath)
+
+		if err := storage.OciLayout.SaveImageToArchive(ctx, imageName, archivePath); err != nil {
+			return err
+		}
+
+		switch storage.ContainerRuntime.(type) {
+		case *container_runtime.LocalDockerServerRuntime:
+			if err := docker.CliLoad(ctx, "--input", archivePath); err != nil {
+				return fmt.Errorf("unable to load image %s: %s", imageName, err)
+			}
+		case *container_runtime.BuildahRuntime:
+			if err := buildah.PullFromArchive(ctx, archivePath); err != nil {
+				return fmt.Errorf("unable to load image %s: %s", imageName, err)
+			}
+		default:
+			return fmt.Errorf("container runtime %s is not supported by the oci-dir stages storage", storage.ContainerRuntime.String())
+		}
+
+		return storage.ContainerRuntime.RefreshImageObject(ctx, img)
+	})
+}
+
+func (storage *OciDirStagesStorage) StoreImage(ctx context.Context, img container_runtime.Image) error {
+	dockerImage := img.(*container_runtime.DockerImage)
+	imageName := dockerImage.Image.Name()
+
+	if dockerImage.Image.GetBuiltId() != "" {
+		if err := dockerImage.Image.TagBuiltImage(ctx); err != nil {
+			return fmt.Errorf("unable to tag built image by name %s: %s", imageName, err)
+		}
+	}
+
+	return withTmpArchive(func(archivePath string) error {
+		logboek.Context(ctx).Debug().LogF("-- OciDirStagesStorage.StoreImage %s via archive %s\n", imageName, archivePath)
+
+		switch storage.ContainerRuntime.(type) {
+		case *container_runtime.LocalDockerServerRuntime:
+			if err := docker.CliSave(ctx, "--output", archivePath, imageName); err != nil {
+				return fmt.Errorf("unable to save image %s: %s", imageName, err)
+			}
+		case *container_runtime.BuildahRuntime:
+			if err := buildah.PushToArchive(ctx, imageName, archivePath); err != nil {
+				return fmt.Errorf("unable to save image %s: %s", imageName, err)
+			}
+		default:
+			return fmt.Errorf("container runtime %s is not supported by the oci-dir stages storage", storage.ContainerRuntime.String())
+		}
+
+		return storage.OciLayout.AppendImageFromArchive(ctx, imageName, archivePath)
+	})
+}
+
+func (storage *OciDirStagesStorage) String() string {
+	return storage.StorageAddress
+}
+
+func (storage *OciDirStagesStorage) Address() string {
+	return storage.StorageAddress
+}
+
+func withTmpArchive(f func(archivePath string) error) error {
+	tmpDir, err := ioutil.TempDir(werf.GetTmpDir(), "oci-dir-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	return f(filepath.Join(tmpDir, "image.tar"))
+}
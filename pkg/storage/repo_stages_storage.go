@@ -250,6 +250,8 @@ func (storage *RepoStagesStorage) FetchImage(ctx context.Context, img container_
 	switch containerRuntime := storage.ContainerRuntime.(type) {
 	case *container_runtime.LocalDockerServerRuntime:
 		return containerRuntime.PullImageFromRegistry(ctx, img)
+	case *container_runtime.BuildahRuntime:
+		return containerRuntime.PullImageFromRegistry(ctx, img)
 	default:
 		// TODO: case *container_runtime.LocalHostRuntime:
 		panic("not implemented")
@@ -267,6 +269,15 @@ func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_
 			return containerRuntime.PushImage(ctx, img)
 		}
 
+	case *container_runtime.BuildahRuntime:
+		dockerImage := img.(*container_runtime.DockerImage)
+
+		if dockerImage.Image.GetBuiltId() != "" {
+			return containerRuntime.PushBuiltImage(ctx, img)
+		} else {
+			return containerRuntime.PushImage(ctx, img)
+		}
+
 	default:
 		// TODO: case *container_runtime.LocalHostRuntime:
 		panic("not implemented")
@@ -275,7 +286,7 @@ func (storage *RepoStagesStorage) StoreImage(ctx context.Context, img container_
 
 func (storage *RepoStagesStorage) ShouldFetchImage(_ context.Context, img container_runtime.Image) (bool, error) {
 	switch storage.ContainerRuntime.(type) {
-	case *container_runtime.LocalDockerServerRuntime:
+	case *container_runtime.LocalDockerServerRuntime, *container_runtime.BuildahRuntime:
 		dockerImage := img.(*container_runtime.DockerImage)
 		return !dockerImage.Image.IsExistsLocally(), nil
 	default:
@@ -283,6 +294,28 @@ func (storage *RepoStagesStorage) ShouldFetchImage(_ context.Context, img contai
 	}
 }
 
+func (storage *RepoStagesStorage) StoreManifestList(ctx context.Context, projectName, digest string, uniqueID int64, platformStages map[string]*image.StageDescription) (*image.StageDescription, error) {
+	platformImages := map[string]string{}
+	for platform, stageDesc := range platformStages {
+		platformImages[platform] = stageDesc.Info.Name
+	}
+
+	stageImageName := storage.ConstructStageImageName(projectName, digest, uniqueID)
+	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.StoreManifestList %s %#v\n", stageImageName, platformImages)
+
+	if err := storage.DockerRegistry.PushImage(ctx, stageImageName, &docker_registry.PushImageOptions{PlatformImages: platformImages}); err != nil {
+		return nil, fmt.Errorf("unable to push manifest list %s: %s", stageImageName, err)
+	}
+
+	if stageDesc, err := storage.GetStageDescription(ctx, projectName, digest, uniqueID); err != nil {
+		return nil, err
+	} else if stageDesc == nil {
+		return nil, fmt.Errorf("manifest list %s not found after successful push", stageImageName)
+	} else {
+		return stageDesc, nil
+	}
+}
+
 func (storage *RepoStagesStorage) PutImageMetadata(ctx context.Context, projectName, imageName, commit, stageID string) error {
 	logboek.Context(ctx).Debug().LogF("-- RepoStagesStorage.PutImageMetadata %s %s %s %s\n", projectName, imageName, commit, stageID)
 
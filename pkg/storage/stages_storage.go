@@ -29,6 +29,8 @@ type StagesStorage interface {
 	// StoreImage will store a local image into the container-runtime, local built image should exist prior running store
 	StoreImage(ctx context.Context, img container_runtime.Image) error
 	ShouldFetchImage(ctx context.Context, img container_runtime.Image) (bool, error)
+	// StoreManifestList will store the manifest list of the stages built for different platforms as the stage with the digest and uniqueID
+	StoreManifestList(ctx context.Context, projectName, digest string, uniqueID int64, platformStages map[string]*image.StageDescription) (*image.StageDescription, error)
 
 	CreateRepo(ctx context.Context) error
 	DeleteRepo(ctx context.Context) error
@@ -73,7 +75,13 @@ type StagesStorageOptions struct {
 
 func NewStagesStorage(stagesStorageAddress string, containerRuntime container_runtime.ContainerRuntime, options StagesStorageOptions) (StagesStorage, error) {
 	if stagesStorageAddress == LocalStorageAddress {
-		return NewLocalDockerServerStagesStorage(containerRuntime.(*container_runtime.LocalDockerServerRuntime)), nil
+		localDockerServerRuntime, ok := containerRuntime.(*container_runtime.LocalDockerServerRuntime)
+		if !ok {
+			return nil, fmt.Errorf("%s stages storage is not supported by %s container runtime: specify repo address", LocalStorageAddress, containerRuntime.String())
+		}
+		return NewLocalDockerServerStagesStorage(localDockerServerRuntime), nil
+	} else if IsOciDirStorageAddress(stagesStorageAddress) {
+		return NewOciDirStagesStorage(stagesStorageAddress, containerRuntime)
 	} else { // Docker registry based stages storage
 		return NewRepoStagesStorage(stagesStorageAddress, containerRuntime, options.RepoStagesStorageOptions)
 	}
@@ -0,0 +1,50 @@
+package synchronization_server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+func NewBuildCoordinatorHttpClient(url string) *BuildCoordinatorHttpClient {
+	return &BuildCoordinatorHttpClient{
+		URL:        url,
+		HttpClient: &http.Client{},
+	}
+}
+
+type BuildCoordinatorHttpClient struct {
+	URL        string
+	HttpClient *http.Client
+}
+
+func (client *BuildCoordinatorHttpClient) String() string {
+	return fmt.Sprintf("http-client %s", client.URL)
+}
+
+func (client *BuildCoordinatorHttpClient) ClaimImage(_ context.Context, projectName, sessionID, runnerID string, imageNames []string) (string, error) {
+	var request = ClaimImageRequest{projectName, sessionID, runnerID, imageNames}
+	var response ClaimImageResponse
+	if err := PerformPost(client.HttpClient, fmt.Sprintf("%s/v1/%s", client.URL, "claim-image"), request, &response); err != nil {
+		return "", err
+	}
+	return response.ImageName, response.Err.Error
+}
+
+func (client *BuildCoordinatorHttpClient) ClaimStage(_ context.Context, projectName, sessionID, runnerID, digest string) (bool, error) {
+	var request = ClaimStageRequest{projectName, sessionID, runnerID, digest}
+	var response ClaimStageResponse
+	if err := PerformPost(client.HttpClient, fmt.Sprintf("%s/v1/%s", client.URL, "claim-stage"), request, &response); err != nil {
+		return false, err
+	}
+	return response.Claimed, response.Err.Error
+}
+
+func (client *BuildCoordinatorHttpClient) Heartbeat(_ context.Context, projectName, sessionID, runnerID string) error {
+	var request = HeartbeatRequest{projectName, sessionID, runnerID}
+	var response HeartbeatResponse
+	if err := PerformPost(client.HttpClient, fmt.Sprintf("%s/v1/%s", client.URL, "heartbeat"), request, &response); err != nil {
+		return err
+	}
+	return response.Err.Error
+}
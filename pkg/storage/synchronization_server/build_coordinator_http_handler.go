@@ -0,0 +1,94 @@
+package synchronization_server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/storage"
+	"github.com/werf/werf/pkg/util"
+)
+
+func NewBuildCoordinatorHttpHandler(buildCoordinator storage.BuildCoordinator) *BuildCoordinatorHttpHandler {
+	handler := &BuildCoordinatorHttpHandler{
+		BuildCoordinator: buildCoordinator,
+		ServeMux:         http.NewServeMux(),
+	}
+	handler.HandleFunc("/claim-image", handler.handleClaimImage())
+	handler.HandleFunc("/claim-stage", handler.handleClaimStage())
+	handler.HandleFunc("/heartbeat", handler.handleHeartbeat())
+	return handler
+}
+
+type BuildCoordinatorHttpHandler struct {
+	*http.ServeMux
+	BuildCoordinator storage.BuildCoordinator
+}
+
+type ClaimImageRequest struct {
+	ProjectName string   `json:"projectName"`
+	SessionID   string   `json:"sessionID"`
+	RunnerID    string   `json:"runnerID"`
+	ImageNames  []string `json:"imageNames"`
+}
+type ClaimImageResponse struct {
+	Err       util.SerializableError `json:"err"`
+	ImageName string                 `json:"imageName"`
+}
+
+type ClaimStageRequest struct {
+	ProjectName string `json:"projectName"`
+	SessionID   string `json:"sessionID"`
+	RunnerID    string `json:"runnerID"`
+	Digest      string `json:"digest"`
+}
+type ClaimStageResponse struct {
+	Err     util.SerializableError `json:"err"`
+	Claimed bool                   `json:"claimed"`
+}
+
+type HeartbeatRequest struct {
+	ProjectName string `json:"projectName"`
+	SessionID   string `json:"sessionID"`
+	RunnerID    string `json:"runnerID"`
+}
+type HeartbeatResponse struct {
+	Err util.SerializableError `json:"err"`
+}
+
+func (handler *BuildCoordinatorHttpHandler) handleClaimImage() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request ClaimImageRequest
+		var response ClaimImageResponse
+		HandleRequest(w, r, &request, &response, func() {
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- ClaimImage request %#v\n", request)
+			response.ImageName, response.Err.Error = handler.BuildCoordinator.ClaimImage(context.Background(), request.ProjectName, request.SessionID, request.RunnerID, request.ImageNames)
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- ClaimImage response %#v\n", response)
+		})
+	}
+}
+
+func (handler *BuildCoordinatorHttpHandler) handleClaimStage() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request ClaimStageRequest
+		var response ClaimStageResponse
+		HandleRequest(w, r, &request, &response, func() {
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- ClaimStage request %#v\n", request)
+			response.Claimed, response.Err.Error = handler.BuildCoordinator.ClaimStage(context.Background(), request.ProjectName, request.SessionID, request.RunnerID, request.Digest)
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- ClaimStage response %#v\n", response)
+		})
+	}
+}
+
+func (handler *BuildCoordinatorHttpHandler) handleHeartbeat() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request HeartbeatRequest
+		var response HeartbeatResponse
+		HandleRequest(w, r, &request, &response, func() {
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- Heartbeat request %#v\n", request)
+			response.Err.Error = handler.BuildCoordinator.Heartbeat(context.Background(), request.ProjectName, request.SessionID, request.RunnerID)
+			logboek.Debug().LogF("BuildCoordinatorHttpHandler -- Heartbeat response %#v\n", response)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package synchronization_server
+
+// Database is the persistent storage of the synchronization server state, values are grouped into buckets by keys
+type Database interface {
+	Get(bucket, key string) (string, bool, error)
+	List(bucket string) (map[string]string, error)
+	Put(bucket, key, value string) error
+	Delete(bucket, key string) error
+	DeleteBucket(bucket string) error
+
+	String() string
+}
@@ -0,0 +1,54 @@
+package synchronization_server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+// DatabaseBuildCoordinatorStateStore stores build coordinator sessions in the database bucket by the project and session key
+type DatabaseBuildCoordinatorStateStore struct {
+	Database Database
+	Bucket   string
+}
+
+func NewDatabaseBuildCoordinatorStateStore(database Database, bucket string) *DatabaseBuildCoordinatorStateStore {
+	return &DatabaseBuildCoordinatorStateStore{Database: database, Bucket: bucket}
+}
+
+func (store *DatabaseBuildCoordinatorStateStore) String() string {
+	return fmt.Sprintf("%s/%s", store.Database.String(), store.Bucket)
+}
+
+func (store *DatabaseBuildCoordinatorStateStore) GetSessions() (map[string]*storage.BuildSession, error) {
+	records, err := store.Database.List(store.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]*storage.BuildSession{}
+	for key, data := range records {
+		session := &storage.BuildSession{}
+		if err := json.Unmarshal([]byte(data), session); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal build session %s: %s", key, err)
+		}
+
+		res[key] = session
+	}
+
+	return res, nil
+}
+
+func (store *DatabaseBuildCoordinatorStateStore) PutSession(key string, session *storage.BuildSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("unable to marshal build session %s: %s", key, err)
+	}
+
+	return store.Database.Put(store.Bucket, key, string(data))
+}
+
+func (store *DatabaseBuildCoordinatorStateStore) DeleteSession(key string) error {
+	return store.Database.Delete(store.Bucket, key)
+}
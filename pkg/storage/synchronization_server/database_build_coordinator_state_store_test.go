@@ -0,0 +1,39 @@
+package synchronization_server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/werf/pkg/storage"
+)
+
+func TestDatabaseBuildCoordinatorStateStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	db := openTestFileDatabase(t, path)
+
+	coordinator, err := storage.NewMemoryBuildCoordinatorWithStateStore(NewDatabaseBuildCoordinatorStateStore(db, "build-coordinator/client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imageName, err := coordinator.ClaimImage(ctx, "project", "session", "runner-1", []string{"backend", "frontend"}); err != nil || imageName != "backend" {
+		t.Fatalf("unexpected claimed image %q: %v", imageName, err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// claims of the alive runner are kept after the server restart
+	reopenedDB := openTestFileDatabase(t, path)
+	restoredCoordinator, err := storage.NewMemoryBuildCoordinatorWithStateStore(NewDatabaseBuildCoordinatorStateStore(reopenedDB, "build-coordinator/client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imageName, err := restoredCoordinator.ClaimImage(ctx, "project", "session", "runner-2", []string{"backend", "frontend"}); err != nil || imageName != "frontend" {
+		t.Fatalf("unexpected claimed image %q: %v", imageName, err)
+	}
+}
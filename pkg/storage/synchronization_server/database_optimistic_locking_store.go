@@ -0,0 +1,55 @@
+package synchronization_server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+)
+
+// DatabaseOptimisticLockingStore persists values of the in-memory store, which keeps records versions, into the database bucket
+type DatabaseOptimisticLockingStore struct {
+	*optimistic_locking_store.InMemoryStore
+	Database Database
+	Bucket   string
+
+	mutex sync.Mutex
+}
+
+func NewDatabaseOptimisticLockingStore(database Database, bucket string) (*DatabaseOptimisticLockingStore, error) {
+	store := &DatabaseOptimisticLockingStore{
+		InMemoryStore: optimistic_locking_store.NewInMemoryStore(),
+		Database:      database,
+		Bucket:        bucket,
+	}
+
+	records, err := database.List(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %s bucket %q: %s", database.String(), bucket, err)
+	}
+
+	for key, data := range records {
+		// new in-memory record is created with the initial version
+		value, err := store.InMemoryStore.GetValue(key)
+		if err != nil {
+			return nil, err
+		}
+		value.Data = data
+	}
+
+	return store, nil
+}
+
+func (store *DatabaseOptimisticLockingStore) PutValue(key string, value *optimistic_locking_store.Value) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.InMemoryStore.PutValue(key, value); err != nil {
+		return err
+	}
+
+	if value.Data == "" {
+		return store.Database.Delete(store.Bucket, key)
+	}
+	return store.Database.Put(store.Bucket, key, value.Data)
+}
@@ -0,0 +1,69 @@
+package synchronization_server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/werf/lockgate/pkg/distributed_locker/optimistic_locking_store"
+)
+
+func TestDatabaseOptimisticLockingStore(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	db := openTestFileDatabase(t, path)
+
+	store, err := NewDatabaseOptimisticLockingStore(db, "locks/client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := store.GetValue("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staleValue, err := store.GetValue("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value.Data = "acquired"
+	if err := store.PutValue("lock", value); err != nil {
+		t.Fatal(err)
+	}
+
+	// the value has been changed since it was got
+	staleValue.Data = "acquired by another process"
+	if err := store.PutValue("lock", staleValue); !optimistic_locking_store.IsErrRecordVersionChanged(err) {
+		t.Fatalf("expected record version changed error, got %v", err)
+	}
+
+	checkDatabaseBucket(t, db, "locks/client", map[string]string{"lock": "acquired"})
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the value is restored after the restart
+	reopenedDB := openTestFileDatabase(t, path)
+	restoredStore, err := NewDatabaseOptimisticLockingStore(reopenedDB, "locks/client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restoredValue, err := restoredStore.GetValue("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restoredValue.Data != "acquired" {
+		t.Fatalf("expected restored value %q, got %q", "acquired", restoredValue.Data)
+	}
+
+	// the empty value is deleted from the database
+	restoredValue.Data = ""
+	if err := restoredStore.PutValue("lock", restoredValue); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDatabaseBucket(t, reopenedDB, "locks/client", map[string]string{})
+}
@@ -0,0 +1,87 @@
+package synchronization_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// DatabaseStagesStorageCache stores stages of each project in the separate database bucket by digest
+type DatabaseStagesStorageCache struct {
+	Database     Database
+	BucketPrefix string
+}
+
+func NewDatabaseStagesStorageCache(database Database, bucketPrefix string) *DatabaseStagesStorageCache {
+	return &DatabaseStagesStorageCache{Database: database, BucketPrefix: bucketPrefix}
+}
+
+func (cache *DatabaseStagesStorageCache) String() string {
+	return fmt.Sprintf("%s/%s", cache.Database.String(), cache.BucketPrefix)
+}
+
+func (cache *DatabaseStagesStorageCache) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	records, err := cache.Database.List(cache.bucket(projectName))
+	if err != nil {
+		return false, nil, err
+	}
+
+	if len(records) == 0 {
+		return false, nil, nil
+	}
+
+	var res []image.StageID
+	for digest, data := range records {
+		if stages, ok := cache.unmarshalRecord(ctx, digest, data); ok {
+			res = append(res, stages...)
+		}
+	}
+
+	return true, res, nil
+}
+
+func (cache *DatabaseStagesStorageCache) DeleteAllStages(_ context.Context, projectName string) error {
+	return cache.Database.DeleteBucket(cache.bucket(projectName))
+}
+
+func (cache *DatabaseStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	data, ok, err := cache.Database.Get(cache.bucket(projectName), digest)
+	if err != nil || !ok {
+		return false, nil, err
+	}
+
+	stages, ok := cache.unmarshalRecord(ctx, digest, data)
+	return ok, stages, nil
+}
+
+func (cache *DatabaseStagesStorageCache) StoreStagesByDigest(_ context.Context, projectName, digest string, stages []image.StageID) error {
+	data, err := json.Marshal(storage.StagesStorageCacheRecord{Stages: stages})
+	if err != nil {
+		return err
+	}
+
+	return cache.Database.Put(cache.bucket(projectName), digest, string(data))
+}
+
+func (cache *DatabaseStagesStorageCache) DeleteStagesByDigest(_ context.Context, projectName, digest string) error {
+	return cache.Database.Delete(cache.bucket(projectName), digest)
+}
+
+func (cache *DatabaseStagesStorageCache) bucket(projectName string) string {
+	return fmt.Sprintf("%s/%s", cache.BucketPrefix, projectName)
+}
+
+func (cache *DatabaseStagesStorageCache) unmarshalRecord(ctx context.Context, digest, data string) ([]image.StageID, bool) {
+	res := &storage.StagesStorageCacheRecord{}
+	if err := json.Unmarshal([]byte(data), res); err != nil {
+		logboek.Context(ctx).Error().LogF("Error unmarshalling json of digest %s from %s: %s: will ignore cache\n", digest, cache.String(), err)
+		return nil, false
+	}
+
+	return res.Stages, true
+}
@@ -0,0 +1,266 @@
+package synchronization_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	fileDatabaseOpPut          = "put"
+	fileDatabaseOpDelete       = "delete"
+	fileDatabaseOpDeleteBucket = "deleteBucket"
+
+	// fileDatabaseCompactionMinRecords is the minimal number of log records to compact the file
+	fileDatabaseCompactionMinRecords = 1000
+)
+
+// FileDatabase keeps all buckets in memory and appends each change to the log file as a json line.
+// The log is compacted to the current state, when the number of records is more than twice the number of keys.
+type FileDatabase struct {
+	Path string
+
+	mutex        sync.Mutex
+	buckets      map[string]map[string]string
+	file         *os.File
+	recordsCount int
+	keysCount    int
+}
+
+type fileDatabaseRecord struct {
+	Op     string `json:"op"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+func OpenFileDatabase(path string) (*FileDatabase, error) {
+	db := &FileDatabase{Path: path, buckets: map[string]map[string]string{}}
+
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+
+	if err := db.compact(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *FileDatabase) String() string {
+	return db.Path
+}
+
+func (db *FileDatabase) Close() error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.file == nil {
+		return nil
+	}
+
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
+
+func (db *FileDatabase) Get(bucket, key string) (string, bool, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	value, ok := db.buckets[bucket][key]
+	return value, ok, nil
+}
+
+func (db *FileDatabase) List(bucket string) (map[string]string, error) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	res := map[string]string{}
+	for key, value := range db.buckets[bucket] {
+		res[key] = value
+	}
+
+	return res, nil
+}
+
+func (db *FileDatabase) Put(bucket, key, value string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if currentValue, ok := db.buckets[bucket][key]; ok && currentValue == value {
+		return nil
+	}
+
+	return db.apply(fileDatabaseRecord{Op: fileDatabaseOpPut, Bucket: bucket, Key: key, Value: value})
+}
+
+func (db *FileDatabase) Delete(bucket, key string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, ok := db.buckets[bucket][key]; !ok {
+		return nil
+	}
+
+	return db.apply(fileDatabaseRecord{Op: fileDatabaseOpDelete, Bucket: bucket, Key: key})
+}
+
+func (db *FileDatabase) DeleteBucket(bucket string) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if _, ok := db.buckets[bucket]; !ok {
+		return nil
+	}
+
+	return db.apply(fileDatabaseRecord{Op: fileDatabaseOpDeleteBucket, Bucket: bucket})
+}
+
+// apply appends the record to the log and applies it to the in-memory state
+func (db *FileDatabase) apply(record fileDatabaseRecord) error {
+	if db.file == nil {
+		return fmt.Errorf("database %s is closed", db.Path)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal database record: %s", err)
+	}
+
+	if _, err := db.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write %s: %s", db.Path, err)
+	}
+
+	db.applyRecord(record)
+	db.recordsCount++
+
+	if db.recordsCount >= fileDatabaseCompactionMinRecords && db.recordsCount > 2*db.keysCount {
+		return db.compact()
+	}
+
+	return nil
+}
+
+func (db *FileDatabase) applyRecord(record fileDatabaseRecord) {
+	switch record.Op {
+	case fileDatabaseOpPut:
+		if _, ok := db.buckets[record.Bucket]; !ok {
+			db.buckets[record.Bucket] = map[string]string{}
+		}
+		if _, ok := db.buckets[record.Bucket][record.Key]; !ok {
+			db.keysCount++
+		}
+		db.buckets[record.Bucket][record.Key] = record.Value
+	case fileDatabaseOpDelete:
+		if _, ok := db.buckets[record.Bucket][record.Key]; ok {
+			db.keysCount--
+			delete(db.buckets[record.Bucket], record.Key)
+		}
+		if len(db.buckets[record.Bucket]) == 0 {
+			delete(db.buckets, record.Bucket)
+		}
+	case fileDatabaseOpDeleteBucket:
+		db.keysCount -= len(db.buckets[record.Bucket])
+		delete(db.buckets, record.Bucket)
+	}
+}
+
+// load replays the log, the incomplete last line (e.g. after the crash while writing) is ignored
+func (db *FileDatabase) load() error {
+	data, err := ioutil.ReadFile(db.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read database file %s: %s", db.Path, err)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read database file %s: %s", db.Path, err)
+		}
+
+		var record fileDatabaseRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("unable to unmarshal database file %s line %d: %s", db.Path, lineNumber, err)
+		}
+
+		db.applyRecord(record)
+	}
+}
+
+// compact rewrites the log atomically with the put records of the current state and reopens the log for appending
+func (db *FileDatabase) compact() error {
+	if err := os.MkdirAll(filepath.Dir(db.Path), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(db.Path), err)
+	}
+
+	tmpPath := db.Path + ".tmp"
+	if err := db.writeSnapshot(tmpPath); err != nil {
+		return err
+	}
+
+	if db.file != nil {
+		if err := db.file.Close(); err != nil {
+			return fmt.Errorf("unable to close %s: %s", db.Path, err)
+		}
+		db.file = nil
+	}
+
+	if err := os.Rename(tmpPath, db.Path); err != nil {
+		return fmt.Errorf("unable to rename %s to %s: %s", tmpPath, db.Path, err)
+	}
+
+	f, err := os.OpenFile(db.Path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", db.Path, err)
+	}
+
+	db.file = f
+	db.recordsCount = db.keysCount
+
+	return nil
+}
+
+func (db *FileDatabase) writeSnapshot(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for bucket, records := range db.buckets {
+		for key, value := range records {
+			data, err := json.Marshal(fileDatabaseRecord{Op: fileDatabaseOpPut, Bucket: bucket, Key: key, Value: value})
+			if err != nil {
+				return fmt.Errorf("unable to marshal database record: %s", err)
+			}
+
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return fmt.Errorf("unable to write %s: %s", path, err)
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("unable to write %s: %s", path, err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("unable to sync %s: %s", path, err)
+	}
+
+	return nil
+}
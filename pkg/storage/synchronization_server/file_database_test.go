@@ -0,0 +1,199 @@
+package synchronization_server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openTestFileDatabase(t *testing.T, path string) *FileDatabase {
+	db, err := OpenFileDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func checkDatabaseBucket(t *testing.T, db Database, bucket string, expected map[string]string) {
+	records, err := db.List(bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected bucket %q records %v, got %v", bucket, expected, records)
+	}
+}
+
+func TestFileDatabase(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "db", "werf.db")
+	db := openTestFileDatabase(t, path)
+
+	for _, record := range [][3]string{
+		{"locks", "a", "1"},
+		{"locks", "b", "2"},
+		{"locks", "a", "3"},
+		{"cache", "digest", "stages"},
+	} {
+		if err := db.Put(record[0], record[1], record[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if value, ok, err := db.Get("locks", "a"); err != nil || !ok || value != "3" {
+		t.Errorf("unexpected value %q, %v, %v", value, ok, err)
+	}
+
+	if _, ok, err := db.Get("locks", "c"); err != nil || ok {
+		t.Errorf("expected no value, got %v, %v", ok, err)
+	}
+
+	if err := db.Delete("locks", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DeleteBucket("cache"); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDatabaseBucket(t, db, "locks", map[string]string{"a": "3"})
+	checkDatabaseBucket(t, db, "cache", map[string]string{})
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Put("locks", "b", "4"); err == nil {
+		t.Error("expected error for closed database")
+	}
+
+	reopenedDB := openTestFileDatabase(t, path)
+	checkDatabaseBucket(t, reopenedDB, "locks", map[string]string{"a": "3"})
+	checkDatabaseBucket(t, reopenedDB, "cache", map[string]string{})
+}
+
+func TestFileDatabase_AppendOnly(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	db := openTestFileDatabase(t, path)
+
+	if err := db.Put("locks", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sizeBefore := getFileSize(t, path)
+
+	if err := db.Put("locks", "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the change is appended as a single line without rewriting the file
+	appended := string(data[sizeBefore:])
+	if strings.Count(appended, "\n") != 1 || !strings.Contains(appended, `"key":"b"`) {
+		t.Errorf("unexpected appended data %q", appended)
+	}
+
+	// the same value is not written
+	if err := db.Put("locks", "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if getFileSize(t, path) != int64(len(data)) {
+		t.Error("expected no changes in the file for the same value")
+	}
+}
+
+func TestFileDatabase_Compaction(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	db := openTestFileDatabase(t, path)
+
+	for i := 0; i < 3*fileDatabaseCompactionMinRecords; i++ {
+		value := "locked"
+		if i%2 == 1 {
+			value = "unlocked"
+		}
+
+		if err := db.Put("locks", "lock", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if linesCount := strings.Count(string(data), "\n"); linesCount >= fileDatabaseCompactionMinRecords {
+		t.Errorf("expected log to be compacted, got %d lines", linesCount)
+	}
+
+	reopenedDB := openTestFileDatabase(t, path)
+	checkDatabaseBucket(t, reopenedDB, "locks", map[string]string{"lock": "unlocked"})
+
+	// the log is compacted on open
+	data, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if linesCount := strings.Count(string(data), "\n"); linesCount != 1 {
+		t.Errorf("expected log to be compacted on open, got %d lines", linesCount)
+	}
+}
+
+func TestFileDatabase_IncompleteLastRecord(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	data := `{"op":"put","bucket":"locks","key":"a","value":"1"}` + "\n" + `{"op":"put","bucket":"locks","key":"b","val`
+	if err := ioutil.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := openTestFileDatabase(t, path)
+	checkDatabaseBucket(t, db, "locks", map[string]string{"a": "1"})
+
+	if err := db.Put("locks", "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopenedDB := openTestFileDatabase(t, path)
+	checkDatabaseBucket(t, reopenedDB, "locks", map[string]string{"a": "1", "b": "2"})
+}
+
+func TestFileDatabase_CorruptedRecord(t *testing.T) {
+	path := filepath.Join(newTestTempDir(t), "werf.db")
+	if err := ioutil.WriteFile(path, []byte("not json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileDatabase(path); err == nil {
+		t.Error("expected error for corrupted database file")
+	}
+}
+
+func getFileSize(t *testing.T, path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stat.Size()
+}
+
+func newTestTempDir(t *testing.T) string {
+	tmpDir, err := ioutil.TempDir("", "synchronization-server-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(tmpDir) })
+
+	return tmpDir
+}
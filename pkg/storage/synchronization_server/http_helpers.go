@@ -52,3 +52,23 @@ func HandleRequest(w http.ResponseWriter, r *http.Request, request, response int
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// NewHttpClient returns the client, which authenticates requests with the token if specified
+func NewHttpClient(token string) *http.Client {
+	if token == "" {
+		return &http.Client{}
+	}
+
+	return &http.Client{Transport: &tokenAuthTransport{Token: token, Transport: http.DefaultTransport}}
+}
+
+type tokenAuthTransport struct {
+	Token     string
+	Transport http.RoundTripper
+}
+
+func (t *tokenAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.Token))
+	return t.Transport.RoundTrip(req)
+}
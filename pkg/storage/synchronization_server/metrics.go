@@ -0,0 +1,297 @@
+package synchronization_server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+const metricsNamespace = "werf_synchronization"
+
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requestDuration           *prometheus.HistogramVec
+	lockAcquisitions          *prometheus.CounterVec
+	lockWaitDuration          *prometheus.HistogramVec
+	stagesStorageCacheLookups *prometheus.CounterVec
+
+	mutex sync.Mutex
+	// waiters are the lock waiters by client id and lock name, which have not acquired the lock since the first "should wait" response
+	waiters map[string]*lockWaiter
+	// heldLocks are expiration times of the leased locks by client id and lock uuid
+	heldLocks map[string]map[string]time.Time
+	// expiredCleanedAt is the time of the last cleanup of expired waiters and held locks
+	expiredCleanedAt time.Time
+
+	now func() time.Time
+}
+
+type lockWaiter struct {
+	Since time.Time
+	// LastSeenAt is the time of the last "should wait" response, the waiter, which has not retried within the lease ttl, has given up
+	LastSeenAt time.Time
+}
+
+const metricsLeaseTTL = distributed_locker.DistributedLockLeaseTTLSeconds * time.Second
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of http requests by handler and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler", "code"}),
+		lockAcquisitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "lock_acquisitions_total",
+			Help:      "Lock acquisition attempts by client id and result: acquired, should_wait or error.",
+		}, []string{"client_id", "result"}),
+		lockWaitDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "lock_wait_duration_seconds",
+			Help:      "Time from the first rejected acquisition of the lock to its acquisition by client id.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 3600},
+		}, []string{"client_id"}),
+		stagesStorageCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "stages_storage_cache_requests_total",
+			Help:      "Stages storage cache lookups by client id and result: hit, miss or error.",
+		}, []string{"client_id", "result"}),
+
+		waiters:   map[string]*lockWaiter{},
+		heldLocks: map[string]map[string]time.Time{},
+		now:       time.Now,
+	}
+
+	heldLocksDesc := prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "held_locks"), "Number of currently leased locks by client id.", []string{"client_id"}, nil)
+
+	m.Registry.MustRegister(
+		m.requestDuration,
+		m.lockAcquisitions,
+		m.lockWaitDuration,
+		m.stagesStorageCacheLookups,
+		&heldLocksCollector{Metrics: m, Desc: heldLocksDesc},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// InstrumentHandler observes durations of requests, client id is excluded from the handler label to limit metrics cardinality
+func (m *Metrics) InstrumentHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedAt := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler.ServeHTTP(recorder, r)
+
+		m.requestDuration.WithLabelValues(requestHandlerLabel(r.URL.Path), strconv.Itoa(recorder.status)).Observe(time.Since(startedAt).Seconds())
+	})
+}
+
+func requestHandlerLabel(path string) string {
+	switch path {
+	case "/", "/health", "/new-client-id", "/metrics":
+		return path
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) == 2 {
+		clientPath := "/" + parts[1]
+		for _, prefix := range []string{"/locker/", "/stages-storage-cache/", "/build-coordinator/"} {
+			if strings.HasPrefix(clientPath, prefix) {
+				return clientPath
+			}
+		}
+	}
+
+	return "other"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (m *Metrics) observeLockAcquire(clientID, lockName string, handle lockgate.LockHandle, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	m.cleanupExpired(now)
+
+	waiterKey := clientID + "/" + lockName
+
+	switch {
+	case distributed_locker.IsErrShouldWait(err):
+		m.lockAcquisitions.WithLabelValues(clientID, "should_wait").Inc()
+		if waiter, ok := m.waiters[waiterKey]; ok {
+			waiter.LastSeenAt = now
+		} else {
+			m.waiters[waiterKey] = &lockWaiter{Since: now, LastSeenAt: now}
+		}
+	case err != nil:
+		m.lockAcquisitions.WithLabelValues(clientID, "error").Inc()
+	default:
+		m.lockAcquisitions.WithLabelValues(clientID, "acquired").Inc()
+		if waiter, ok := m.waiters[waiterKey]; ok {
+			m.lockWaitDuration.WithLabelValues(clientID).Observe(now.Sub(waiter.Since).Seconds())
+			delete(m.waiters, waiterKey)
+		} else {
+			m.lockWaitDuration.WithLabelValues(clientID).Observe(0)
+		}
+
+		m.setHeldLock(clientID, handle, now)
+	}
+}
+
+// cleanupExpired drops waiters, which have given up, and expired held locks of crashed clients not more often than once per lease ttl
+func (m *Metrics) cleanupExpired(now time.Time) {
+	if now.Sub(m.expiredCleanedAt) < metricsLeaseTTL {
+		return
+	}
+	m.expiredCleanedAt = now
+
+	for key, waiter := range m.waiters {
+		if now.Sub(waiter.LastSeenAt) > metricsLeaseTTL {
+			delete(m.waiters, key)
+		}
+	}
+
+	for clientID, locks := range m.heldLocks {
+		deleteExpiredHeldLocks(locks, now)
+		if len(locks) == 0 {
+			delete(m.heldLocks, clientID)
+		}
+	}
+}
+
+func deleteExpiredHeldLocks(locks map[string]time.Time, now time.Time) {
+	for uuid, expireAt := range locks {
+		if now.After(expireAt) {
+			delete(locks, uuid)
+		}
+	}
+}
+
+func (m *Metrics) observeLockRenew(clientID string, handle lockgate.LockHandle, err error) {
+	if err != nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.setHeldLock(clientID, handle, m.now())
+}
+
+func (m *Metrics) observeLockRelease(clientID string, handle lockgate.LockHandle, err error) {
+	if err != nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.heldLocks[clientID], handle.UUID)
+}
+
+func (m *Metrics) setHeldLock(clientID string, handle lockgate.LockHandle, now time.Time) {
+	if _, ok := m.heldLocks[clientID]; !ok {
+		m.heldLocks[clientID] = map[string]time.Time{}
+	}
+	m.heldLocks[clientID][handle.UUID] = now.Add(metricsLeaseTTL)
+}
+
+func (m *Metrics) observeStagesStorageCacheLookup(clientID string, found bool, err error) {
+	switch {
+	case err != nil:
+		m.stagesStorageCacheLookups.WithLabelValues(clientID, "error").Inc()
+	case found:
+		m.stagesStorageCacheLookups.WithLabelValues(clientID, "hit").Inc()
+	default:
+		m.stagesStorageCacheLookups.WithLabelValues(clientID, "miss").Inc()
+	}
+}
+
+// heldLocksCollector counts not expired leases, so locks of crashed clients are not counted after the lease ttl
+type heldLocksCollector struct {
+	Metrics *Metrics
+	Desc    *prometheus.Desc
+}
+
+func (c *heldLocksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.Desc
+}
+
+func (c *heldLocksCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Metrics.mutex.Lock()
+	defer c.Metrics.mutex.Unlock()
+
+	now := c.Metrics.now()
+	for clientID, locks := range c.Metrics.heldLocks {
+		deleteExpiredHeldLocks(locks, now)
+
+		ch <- prometheus.MustNewConstMetric(c.Desc, prometheus.GaugeValue, float64(len(locks)), clientID)
+	}
+}
+
+type metricsDistributedLockerBackend struct {
+	distributed_locker.DistributedLockerBackend
+	ClientID string
+	Metrics  *Metrics
+}
+
+func (backend *metricsDistributedLockerBackend) Acquire(lockName string, opts distributed_locker.AcquireOptions) (lockgate.LockHandle, error) {
+	handle, err := backend.DistributedLockerBackend.Acquire(lockName, opts)
+	backend.Metrics.observeLockAcquire(backend.ClientID, lockName, handle, err)
+	return handle, err
+}
+
+func (backend *metricsDistributedLockerBackend) RenewLease(handle lockgate.LockHandle) error {
+	err := backend.DistributedLockerBackend.RenewLease(handle)
+	backend.Metrics.observeLockRenew(backend.ClientID, handle, err)
+	return err
+}
+
+func (backend *metricsDistributedLockerBackend) Release(handle lockgate.LockHandle) error {
+	err := backend.DistributedLockerBackend.Release(handle)
+	backend.Metrics.observeLockRelease(backend.ClientID, handle, err)
+	return err
+}
+
+type metricsStagesStorageCache struct {
+	storage.StagesStorageCache
+	ClientID string
+	Metrics  *Metrics
+}
+
+func (cache *metricsStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	found, stages, err := cache.StagesStorageCache.GetStagesByDigest(ctx, projectName, digest)
+	cache.Metrics.observeStagesStorageCacheLookup(cache.ClientID, found, err)
+	return found, stages, err
+}
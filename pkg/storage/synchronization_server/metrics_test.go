@@ -0,0 +1,130 @@
+package synchronization_server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/lockgate/pkg/distributed_locker"
+)
+
+func newTestMetrics() (*Metrics, *time.Time) {
+	now := time.Now()
+	m := NewMetrics()
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func getMetricFamily(t *testing.T, m *Metrics, name string) *dto.MetricFamily {
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+
+	return &dto.MetricFamily{}
+}
+
+func getLockWaitDurationSampleCountAndSum(t *testing.T, m *Metrics) (uint64, float64) {
+	var count uint64
+	var sum float64
+	for _, metric := range getMetricFamily(t, m, "werf_synchronization_lock_wait_duration_seconds").GetMetric() {
+		count += metric.GetHistogram().GetSampleCount()
+		sum += metric.GetHistogram().GetSampleSum()
+	}
+
+	return count, sum
+}
+
+func TestMetrics_LockAcquire(t *testing.T) {
+	m, now := newTestMetrics()
+	handle := lockgate.LockHandle{UUID: "uuid", LockName: "lock"}
+
+	m.observeLockAcquire("client", "lock", lockgate.LockHandle{}, distributed_locker.ErrShouldWait)
+	*now = now.Add(3 * time.Second)
+	m.observeLockAcquire("client", "lock", lockgate.LockHandle{}, distributed_locker.ErrShouldWait)
+	*now = now.Add(2 * time.Second)
+	m.observeLockAcquire("client", "lock", lockgate.LockHandle{}, errors.New("error"))
+	m.observeLockAcquire("client", "lock", handle, nil)
+
+	for result, expected := range map[string]float64{"should_wait": 2, "error": 1, "acquired": 1} {
+		if got := testutil.ToFloat64(m.lockAcquisitions.WithLabelValues("client", result)); got != expected {
+			t.Errorf("expected %v %s acquisitions, got %v", expected, result, got)
+		}
+	}
+
+	if count, sum := getLockWaitDurationSampleCountAndSum(t, m); count != 1 || sum != 5 {
+		t.Errorf("expected single lock wait duration of 5 seconds, got %d samples with sum %v", count, sum)
+	}
+
+	if len(m.waiters) != 0 {
+		t.Errorf("expected no waiters after the acquisition, got %v", m.waiters)
+	}
+
+	if heldLocks := getMetricFamily(t, m, "werf_synchronization_held_locks").GetMetric(); len(heldLocks) != 1 || heldLocks[0].GetGauge().GetValue() != 1 {
+		t.Errorf("expected single held lock, got %v", heldLocks)
+	}
+
+	m.observeLockRelease("client", handle, nil)
+	if len(m.heldLocks["client"]) != 0 {
+		t.Errorf("expected no held locks after the release, got %v", m.heldLocks)
+	}
+}
+
+func TestMetrics_ExpireWaiters(t *testing.T) {
+	m, now := newTestMetrics()
+
+	m.observeLockAcquire("client", "abandoned-lock", lockgate.LockHandle{}, distributed_locker.ErrShouldWait)
+	m.observeLockAcquire("client", "lock", lockgate.LockHandle{}, distributed_locker.ErrShouldWait)
+	m.observeLockAcquire("client", "held-lock", lockgate.LockHandle{UUID: "crashed-client-lock"}, nil)
+
+	// the waiter of the lock keeps retrying, the waiter of the abandoned lock and the holder of the held lock have crashed
+	for i := 0; i < 4; i++ {
+		*now = now.Add(metricsLeaseTTL / 2)
+		m.observeLockAcquire("client", "lock", lockgate.LockHandle{}, distributed_locker.ErrShouldWait)
+	}
+
+	if _, ok := m.waiters["client/abandoned-lock"]; ok {
+		t.Error("expected waiter, which has given up, to be dropped")
+	}
+
+	if _, ok := m.waiters["client/lock"]; !ok {
+		t.Error("expected retrying waiter to be kept")
+	}
+
+	if _, ok := m.heldLocks["client"]; ok {
+		t.Errorf("expected expired held locks to be dropped, got %v", m.heldLocks)
+	}
+
+	// the wait duration of the retrying waiter is counted since the first rejected acquisition
+	m.observeLockAcquire("client", "lock", lockgate.LockHandle{UUID: "uuid"}, nil)
+	if count, sum := getLockWaitDurationSampleCountAndSum(t, m); count != 2 || sum != (2*metricsLeaseTTL).Seconds() {
+		t.Errorf("unexpected lock wait duration: %d samples with sum %v", count, sum)
+	}
+}
+
+func TestRequestHandlerLabel(t *testing.T) {
+	tests := map[string]string{
+		"/health":                             "/health",
+		"/metrics":                            "/metrics",
+		"/client-id/locker/v1/acquire":        "/locker/v1/acquire",
+		"/client-id/stages-storage-cache/v1/": "/stages-storage-cache/v1/",
+		"/client-id/build-coordinator/v1/claim-stage": "/build-coordinator/v1/claim-stage",
+		"/client-id/unknown":                          "other",
+	}
+
+	for path, expected := range tests {
+		if got := requestHandlerLabel(path); got != expected {
+			t.Errorf("expected %q label for %q, got %q", expected, path, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package synchronization_server
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strings"
@@ -17,9 +18,44 @@ import (
 	"github.com/werf/werf/pkg/storage"
 )
 
-func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error)) error {
-	handler := NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc)
-	return http.ListenAndServe(fmt.Sprintf("%s:%s", ip, port), handler)
+type SynchronizationServerOptions struct {
+	// AuthToken enables authentication of all requests except health checks by the header "Authorization: Bearer TOKEN"
+	AuthToken string
+
+	// TLSCertFile and TLSKeyFile enable https
+	TLSCertFile string
+	TLSKeyFile  string
+}
+
+func RunSynchronizationServer(_ context.Context, ip, port string, distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(clientID string) (storage.StagesStorageCache, error), buildCoordinatorFactoryFunc func(clientID string) (storage.BuildCoordinator, error), opts SynchronizationServerOptions) error {
+	server := NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc, stagesStorageCacheFactoryFunc, buildCoordinatorFactoryFunc)
+
+	var handler http.Handler = server.Metrics.InstrumentHandler(server)
+	if opts.AuthToken != "" {
+		handler = NewTokenAuthHandler(opts.AuthToken, handler)
+	}
+
+	address := fmt.Sprintf("%s:%s", ip, port)
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		return http.ListenAndServeTLS(address, opts.TLSCertFile, opts.TLSKeyFile, handler)
+	}
+	return http.ListenAndServe(address, handler)
+}
+
+// NewTokenAuthHandler returns the handler, which rejects requests without the bearer token, health checks are allowed without the token
+func NewTokenAuthHandler(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			authorization := r.Header.Get("Authorization")
+			if !strings.HasPrefix(authorization, "Bearer ") || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, "Bearer ")), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized: valid bearer token is required", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
 }
 
 type SynchronizationServerHandler struct {
@@ -27,20 +63,25 @@ type SynchronizationServerHandler struct {
 
 	DistributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error)
 	StagesStorageCacheFactoryFunc       func(clientID string) (storage.StagesStorageCache, error)
+	BuildCoordinatorFactoryFunc         func(clientID string) (storage.BuildCoordinator, error)
+	Metrics                             *Metrics
 
 	mux                             sync.Mutex
 	SynchronizationServerByClientID map[string]*SynchronizationServerHandlerByClientID
 }
 
-func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(requestID string) (storage.StagesStorageCache, error)) *SynchronizationServerHandler {
+func NewSynchronizationServerHandler(distributedLockerBackendFactoryFunc func(clientID string) (distributed_locker.DistributedLockerBackend, error), stagesStorageCacheFactoryFunc func(requestID string) (storage.StagesStorageCache, error), buildCoordinatorFactoryFunc func(clientID string) (storage.BuildCoordinator, error)) *SynchronizationServerHandler {
 	srv := &SynchronizationServerHandler{
 		ServeMux:                            http.NewServeMux(),
 		DistributedLockerBackendFactoryFunc: distributedLockerBackendFactoryFunc,
 		StagesStorageCacheFactoryFunc:       stagesStorageCacheFactoryFunc,
+		BuildCoordinatorFactoryFunc:         buildCoordinatorFactoryFunc,
 		SynchronizationServerByClientID:     make(map[string]*SynchronizationServerHandlerByClientID),
+		Metrics:                             NewMetrics(),
 	}
 	srv.HandleFunc("/health", srv.handleHealth)
 	srv.HandleFunc("/new-client-id", srv.handleNewClientID)
+	srv.Handle("/metrics", srv.Metrics.Handler())
 	srv.HandleFunc("/", srv.handleRequestByClientID)
 	return srv
 }
@@ -144,7 +185,17 @@ func (server *SynchronizationServerHandler) getOrCreateHandlerByClientID(clientI
 			return nil, fmt.Errorf("unable to create stages storage cache for clientID %q: %s", clientID, err)
 		}
 
-		handler := NewSynchronizationServerHandlerByClientID(clientID, distributedLockerBackend, stagesStorageCache)
+		buildCoordinator, err := server.BuildCoordinatorFactoryFunc(clientID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create build coordinator for clientID %q: %s", clientID, err)
+		}
+
+		handler := NewSynchronizationServerHandlerByClientID(
+			clientID,
+			&metricsDistributedLockerBackend{DistributedLockerBackend: distributedLockerBackend, ClientID: clientID, Metrics: server.Metrics},
+			&metricsStagesStorageCache{StagesStorageCache: stagesStorageCache, ClientID: clientID, Metrics: server.Metrics},
+			buildCoordinator,
+		)
 		server.SynchronizationServerByClientID[clientID] = handler
 
 		logboek.Debug().LogF("SynchronizationServerHandler -- Created new synchronization server handler by clientID %q: %v\n", clientID, handler)
@@ -158,17 +209,20 @@ type SynchronizationServerHandlerByClientID struct {
 
 	DistributedLockerBackend distributed_locker.DistributedLockerBackend
 	StagesStorageCache       storage.StagesStorageCache
+	BuildCoordinator         storage.BuildCoordinator
 }
 
-func NewSynchronizationServerHandlerByClientID(clientID string, distributedLockerBackend distributed_locker.DistributedLockerBackend, stagesStorageCache storage.StagesStorageCache) *SynchronizationServerHandlerByClientID {
+func NewSynchronizationServerHandlerByClientID(clientID string, distributedLockerBackend distributed_locker.DistributedLockerBackend, stagesStorageCache storage.StagesStorageCache, buildCoordinator storage.BuildCoordinator) *SynchronizationServerHandlerByClientID {
 	srv := &SynchronizationServerHandlerByClientID{
 		ServeMux:                 http.NewServeMux(),
 		ClientID:                 clientID,
 		DistributedLockerBackend: distributedLockerBackend,
 		StagesStorageCache:       stagesStorageCache,
+		BuildCoordinator:         buildCoordinator,
 	}
 	srv.Handle("/locker/", http.StripPrefix("/locker", distributed_locker.NewHttpBackendHandler(srv.DistributedLockerBackend)))
 	srv.Handle("/stages-storage-cache/v1/", http.StripPrefix("/stages-storage-cache/v1", NewStagesStorageCacheHttpHandler(stagesStorageCache)))
 	srv.Handle("/stages-storage-cache/", http.StripPrefix("/stages-storage-cache", NewStagesStorageCacheHttpHandlerLegacy(stagesStorageCache)))
+	srv.Handle("/build-coordinator/v1/", http.StripPrefix("/build-coordinator/v1", NewBuildCoordinatorHttpHandler(srv.BuildCoordinator)))
 	return srv
 }
@@ -0,0 +1,57 @@
+package synchronization_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenAuthHandler(t *testing.T) {
+	server := httptest.NewServer(NewTokenAuthHandler("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "health check without token", path: "/health", expectedStatus: http.StatusOK},
+		{name: "request without token", path: "/client-id/locker/v1/acquire", expectedStatus: http.StatusUnauthorized},
+		{name: "request with wrong token", path: "/client-id/locker/v1/acquire", token: "wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "request with token", path: "/client-id/locker/v1/acquire", token: "secret", expectedStatus: http.StatusOK},
+		{name: "request with bearer authorization", path: "/client-id/locker/v1/acquire", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "request with token without bearer prefix", path: "/client-id/locker/v1/acquire", authorization: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "request with token of another scheme", path: "/client-id/locker/v1/acquire", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp, err := NewHttpClient(tt.token).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("expected WWW-Authenticate header, got %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxExportBatchSize = 512
+	DefaultExportInterval     = 5 * time.Second
+)
+
+type spanExporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+// batchSpanProcessor accumulates ended spans and exports them in the background,
+// when the batch is full or the export interval is elapsed
+type batchSpanProcessor struct {
+	exporter           spanExporter
+	maxExportBatchSize int
+	exportInterval     time.Duration
+
+	mutex     sync.Mutex
+	queue     []*Span
+	exportErr error
+
+	batchReadyCh chan struct{}
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+	stopOnce     sync.Once
+}
+
+func newBatchSpanProcessor(exporter spanExporter, maxExportBatchSize int, exportInterval time.Duration) *batchSpanProcessor {
+	p := &batchSpanProcessor{
+		exporter:           exporter,
+		maxExportBatchSize: maxExportBatchSize,
+		exportInterval:     exportInterval,
+		batchReadyCh:       make(chan struct{}, 1),
+		stopCh:             make(chan struct{}),
+		doneCh:             make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+func (p *batchSpanProcessor) OnEnd(span *Span) {
+	p.mutex.Lock()
+	p.queue = append(p.queue, span)
+	batchReady := len(p.queue) >= p.maxExportBatchSize
+	p.mutex.Unlock()
+
+	if batchReady {
+		select {
+		case p.batchReadyCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Shutdown stops the background export and exports the remaining spans,
+// the first error of all exports is returned
+func (p *batchSpanProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+	<-p.doneCh
+
+	p.export(ctx)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.exportErr
+}
+
+func (p *batchSpanProcessor) run() {
+	defer close(p.doneCh)
+
+	ticker := time.NewTicker(p.exportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.export(context.Background())
+		case <-p.batchReadyCh:
+			p.export(context.Background())
+		}
+	}
+}
+
+// export is called either by the background goroutine or by Shutdown after the goroutine is done,
+// so the exporter is never called concurrently
+func (p *batchSpanProcessor) export(ctx context.Context) {
+	for {
+		p.mutex.Lock()
+		n := len(p.queue)
+		if n > p.maxExportBatchSize {
+			n = p.maxExportBatchSize
+		}
+		batch := p.queue[:n]
+		p.queue = p.queue[n:]
+		p.mutex.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			p.mutex.Lock()
+			if p.exportErr == nil {
+				p.exportErr = err
+			}
+			p.mutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	otlpSpanKindInternal = 1
+
+	otlpStatusCodeOk    = 1
+	otlpStatusCodeError = 2
+)
+
+// otlpExporter writes spans as OTLP/JSON ExportTraceServiceRequest (https://github.com/open-telemetry/opentelemetry-proto)
+type otlpExporter struct {
+	File     string
+	Endpoint string
+}
+
+func (e *otlpExporter) Export(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(newOtlpTracesData(spans))
+	if err != nil {
+		return err
+	}
+
+	if e.File != "" {
+		if err := e.writeFile(data); err != nil {
+			return err
+		}
+	}
+
+	if e.Endpoint != "" {
+		if err := e.send(ctx, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile appends data as a single line, so the file could contain multiple batches of spans and traces of multiple werf invocations
+func (e *otlpExporter) writeFile(data []byte) error {
+	f, err := os.OpenFile(e.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", e.File, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write %s: %s", e.File, err)
+	}
+
+	return nil
+}
+
+func (e *otlpExporter) send(ctx context.Context, data []byte) error {
+	url := strings.TrimSuffix(e.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send traces to %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unable to send traces to %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func newOtlpTracesData(spans []*Span) otlpTracesData {
+	var otlpSpans []otlpSpan
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, newOtlpSpan(span))
+	}
+
+	return otlpTracesData{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: newOtlpKeyValueList(map[string]string{
+						"service.name":    "werf",
+						"service.version": werf.Version,
+					}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "github.com/werf/werf", Version: werf.Version},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func newOtlpSpan(span *Span) otlpSpan {
+	status := otlpStatus{Code: otlpStatusCodeOk}
+	if span.Err != nil {
+		status = otlpStatus{Code: otlpStatusCodeError, Message: span.Err.Error()}
+	}
+
+	return otlpSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      span.ParentSpanID,
+		Name:              span.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Attributes:        newOtlpKeyValueList(span.getAttributes()),
+		Status:            status,
+	}
+}
+
+func newOtlpKeyValueList(attributes map[string]string) []otlpKeyValue {
+	var keys []string
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var res []otlpKeyValue
+	for _, key := range keys {
+		res = append(res, otlpKeyValue{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+
+	return res
+}
@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FileEnvVar     = "WERF_TRACING_OTLP_FILE"
+	EndpointEnvVar = "WERF_TRACING_OTLP_ENDPOINT"
+)
+
+var (
+	stateMutex sync.RWMutex
+	processor  *batchSpanProcessor
+	rootSpan   *Span
+)
+
+type InitOptions struct {
+	// File is the path to the file, which spans will be appended to in the OTLP/JSON format
+	File string
+	// Endpoint is the OTLP/HTTP collector address (e.g. http://localhost:4318), which spans will be sent to
+	Endpoint string
+
+	// RootSpanName is the name of the span, which will be the parent for all spans of the process
+	RootSpanName string
+}
+
+// Init enables tracing if the file or the endpoint is specified, otherwise all tracing functions are no-op
+func Init(opts InitOptions) error {
+	if opts.File == "" && opts.Endpoint == "" {
+		return nil
+	}
+
+	exporter := &otlpExporter{File: opts.File, Endpoint: opts.Endpoint}
+	return initWithProcessor(newBatchSpanProcessor(exporter, DefaultMaxExportBatchSize, DefaultExportInterval), opts.RootSpanName)
+}
+
+func initWithProcessor(p *batchSpanProcessor, rootSpanName string) error {
+	traceID, err := newID(16)
+	if err != nil {
+		return err
+	}
+
+	span, err := newSpan(traceID, "", rootSpanName)
+	if err != nil {
+		return err
+	}
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if processor != nil {
+		return fmt.Errorf("tracing is already initialized")
+	}
+
+	processor = p
+	rootSpan = span
+
+	return nil
+}
+
+func IsEnabled() bool {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	return processor != nil
+}
+
+// RootSpan returns the parent span of the process or nil if tracing is disabled
+func RootSpan() *Span {
+	stateMutex.RLock()
+	defer stateMutex.RUnlock()
+	return rootSpan
+}
+
+// Shutdown ends the root span and exports all remaining spans, should be called before the process exit
+func Shutdown(ctx context.Context, err error) error {
+	span := RootSpan()
+	if span == nil {
+		return nil
+	}
+
+	span.End(err)
+
+	// tracing is disabled after shutdown, so the next call is no-op
+	stateMutex.Lock()
+	p := processor
+	processor = nil
+	rootSpan = nil
+	stateMutex.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	if exportErr := p.Shutdown(ctx); exportErr != nil {
+		return fmt.Errorf("unable to export tracing spans: %s", exportErr)
+	}
+
+	return nil
+}
+
+type spanCtxKey struct{}
+
+// StartSpan starts the span as a child of the span from the context (or the root span).
+// Returned span is nil if tracing is disabled, all span methods could be called on nil span.
+func StartSpan(ctx context.Context, name string, attributes ...string) (context.Context, *Span) {
+	root := RootSpan()
+	if root == nil {
+		return ctx, nil
+	}
+
+	parent, ok := ctx.Value(spanCtxKey{}).(*Span)
+	if !ok {
+		parent = root
+	}
+
+	span, err := newSpan(parent.TraceID, parent.SpanID, name)
+	if err != nil {
+		return ctx, nil
+	}
+
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.SetAttribute(attributes[i], attributes[i+1])
+	}
+
+	return context.WithValue(ctx, spanCtxKey{}, span), span
+}
+
+// Do wraps f into the span, which is ended with the error returned by f
+func Do(ctx context.Context, name string, f func(ctx context.Context) error, attributes ...string) error {
+	ctx, span := StartSpan(ctx, name, attributes...)
+	err := f(ctx)
+	span.End(err)
+	return err
+}
+
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	StartTime    time.Time
+	EndTime      time.Time
+	Err          error
+
+	mutex      sync.Mutex
+	attributes map[string]string
+}
+
+func newSpan(traceID, parentSpanID, name string) (*Span, error) {
+	spanID, err := newID(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Span{
+		TraceID:      traceID,
+		SpanID:       spanID,
+		ParentSpanID: parentSpanID,
+		Name:         name,
+		StartTime:    time.Now(),
+		attributes:   map[string]string{},
+	}, nil
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.EndTime = time.Now()
+	s.Err = err
+	s.mutex.Unlock()
+
+	stateMutex.RLock()
+	p := processor
+	stateMutex.RUnlock()
+
+	if p != nil {
+		p.OnEnd(s)
+	}
+}
+
+func (s *Span) getAttributes() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res := map[string]string{}
+	for k, v := range s.attributes {
+		res[k] = v
+	}
+	return res
+}
+
+func newID(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate tracing id: %s", err)
+	}
+	return strings.ToLower(hex.EncodeToString(b)), nil
+}
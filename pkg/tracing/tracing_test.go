@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeExporter struct {
+	mutex   sync.Mutex
+	batches [][]*Span
+	err     error
+}
+
+func (e *fakeExporter) Export(_ context.Context, spans []*Span) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.batches = append(e.batches, append([]*Span(nil), spans...))
+	return e.err
+}
+
+func (e *fakeExporter) getSpanNames() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var res []string
+	for _, batch := range e.batches {
+		for _, span := range batch {
+			res = append(res, span.Name)
+		}
+	}
+	return res
+}
+
+func (e *fakeExporter) getBatchesCount() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.batches)
+}
+
+func initTestTracing(t *testing.T, exporter *fakeExporter, maxExportBatchSize int, exportInterval time.Duration) {
+	if err := initWithProcessor(newBatchSpanProcessor(exporter, maxExportBatchSize, exportInterval), "root"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Shutdown(context.Background(), nil) })
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition is not met within timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	if err := Init(InitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if IsEnabled() {
+		t.Fatal("tracing should be disabled")
+	}
+
+	_, span := StartSpan(context.Background(), "span")
+	if span != nil {
+		t.Fatalf("expected nil span, got %+v", span)
+	}
+	span.SetAttribute("key", "value")
+	span.End(nil)
+
+	if err := Shutdown(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchExportBeforeShutdown(t *testing.T) {
+	exporter := &fakeExporter{}
+	initTestTracing(t, exporter, 2, time.Hour)
+
+	ctx := context.Background()
+	_, span1 := StartSpan(ctx, "span1")
+	span1.End(nil)
+	_, span2 := StartSpan(ctx, "span2")
+	span2.End(nil)
+
+	waitFor(t, func() bool { return exporter.getBatchesCount() == 1 })
+
+	if err := Shutdown(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(exporter.getSpanNames()); got != "[span1 span2 root]" {
+		t.Fatalf("unexpected exported spans: %s", got)
+	}
+}
+
+func TestPeriodicExport(t *testing.T) {
+	exporter := &fakeExporter{}
+	initTestTracing(t, exporter, 100, 20*time.Millisecond)
+
+	_, span := StartSpan(context.Background(), "span")
+	span.End(nil)
+
+	waitFor(t, func() bool { return exporter.getBatchesCount() == 1 })
+
+	if got := fmt.Sprint(exporter.getSpanNames()); got != "[span]" {
+		t.Fatalf("unexpected exported spans: %s", got)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	exporter := &fakeExporter{err: errors.New("export error")}
+	initTestTracing(t, exporter, 100, time.Hour)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child", "key", "value")
+	child.End(nil)
+	parent.End(nil)
+
+	if err := Shutdown(context.Background(), errors.New("command error")); err == nil {
+		t.Fatal("expected export error")
+	}
+
+	if IsEnabled() || RootSpan() != nil {
+		t.Fatal("tracing should be disabled after shutdown")
+	}
+
+	if got := fmt.Sprint(exporter.getSpanNames()); got != "[child parent root]" {
+		t.Fatalf("unexpected exported spans: %s", got)
+	}
+
+	if child.ParentSpanID != parent.SpanID || child.TraceID != parent.TraceID {
+		t.Fatalf("child span %+v is not linked to parent span %+v", child, parent)
+	}
+
+	if got := child.getAttributes()["key"]; got != "value" {
+		t.Fatalf("unexpected child attribute: %q", got)
+	}
+
+	root := exporter.batches[0][2]
+	if parent.ParentSpanID != root.SpanID || root.Err == nil || root.Err.Error() != "command error" {
+		t.Fatalf("unexpected root span %+v", root)
+	}
+
+	// the next call is no-op
+	if err := Shutdown(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentSpans(t *testing.T) {
+	exporter := &fakeExporter{}
+	initTestTracing(t, exporter, 10, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = Do(context.Background(), fmt.Sprintf("span-%d-%d", i, j), func(ctx context.Context) error {
+					_, span := StartSpan(ctx, "child")
+					span.End(nil)
+					return nil
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := Shutdown(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(exporter.getSpanNames()); got != 10*100*2+1 {
+		t.Fatalf("expected %d exported spans, got %d", 10*100*2+1, got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
@@ -79,6 +80,166 @@ func CreateArchive(archivePath string, f func(tw *tar.Writer) error) error {
 	return f(tw)
 }
 
+// ExtractArchive extracts the archive into the destination dir,
+// entries and symlinks pointing outside of the destination dir are rejected
+func ExtractArchive(archivePath, destinationDir string) error {
+	destinationDir, err := filepath.Abs(destinationDir)
+	if err != nil {
+		return fmt.Errorf("unable to get absolute path of %q: %s", destinationDir, err)
+	}
+
+	resolvedDestinationDir, err := filepath.EvalSymlinks(destinationDir)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %q: %s", destinationDir, err)
+	}
+
+	source, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %s", archivePath, err)
+	}
+	defer source.Close()
+
+	tr := tar.NewReader(source)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("unable to read archive %q: %s", archivePath, err)
+		}
+
+		path := filepath.Join(destinationDir, filepath.FromSlash(hdr.Name))
+		if !isPathInsideDir(destinationDir, path) {
+			return fmt.Errorf("invalid archive entry %q: path is outside of the destination dir", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeSymlink, tar.TypeReg, tar.TypeRegA:
+		default:
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(path), err)
+		}
+
+		// the parent dir may be a symlink created by the previous entries
+		resolvedDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+		if err != nil {
+			return fmt.Errorf("unable to resolve %q: %s", filepath.Dir(path), err)
+		} else if !isPathInsideDir(resolvedDestinationDir, resolvedDir) {
+			return fmt.Errorf("invalid archive entry %q: path is outside of the destination dir", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)|0700); err != nil {
+				return fmt.Errorf("unable to create dir %q: %s", path, err)
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("invalid archive entry %q: symlink target %q is outside of the destination dir", hdr.Name, hdr.Linkname)
+			}
+
+			// the target is resolved from the real location of the symlink, the lexical path may go through the symlinks
+			if target, err := resolveSymlinkTarget(resolvedDir, hdr.Linkname); err != nil {
+				return fmt.Errorf("invalid archive entry %q: %s", hdr.Name, err)
+			} else if !isPathInsideDir(resolvedDestinationDir, target) {
+				return fmt.Errorf("invalid archive entry %q: symlink target %q is outside of the destination dir", hdr.Name, hdr.Linkname)
+			}
+
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return fmt.Errorf("unable to create symlink %q: %s", path, err)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := extractArchiveFile(tr, filepath.Join(resolvedDir, filepath.Base(path)), os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isPathInsideDir(dir, path string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return relPath != ".." && !strings.HasPrefix(relPath, ".."+string(os.PathSeparator))
+}
+
+// resolveSymlinkTarget resolves the symlink target component by component as the system does,
+// the components after a nonexistent one cannot be resolved and must not go up
+func resolveSymlinkTarget(dir, linkname string) (string, error) {
+	path := dir
+	exist := true
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch {
+		case part == "" || part == ".":
+			continue
+		case part == "..":
+			if !exist {
+				return "", fmt.Errorf("symlink target %q cannot be resolved", linkname)
+			}
+
+			path = filepath.Dir(path)
+			continue
+		}
+
+		path = filepath.Join(path, part)
+		if !exist {
+			continue
+		}
+
+		stat, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			exist = false
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("unable to stat %q: %s", path, err)
+		}
+
+		if stat.Mode()&os.ModeSymlink != 0 {
+			resolvedPath, err := filepath.EvalSymlinks(path)
+			if os.IsNotExist(err) {
+				exist = false
+				continue
+			} else if err != nil {
+				return "", fmt.Errorf("unable to resolve %q: %s", path, err)
+			}
+
+			path = resolvedPath
+		}
+	}
+
+	return path, nil
+}
+
+// extractArchiveFile replaces the existing symlink instead of writing through it
+func extractArchiveFile(tr *tar.Reader, path string, mode os.FileMode) error {
+	if stat, err := os.Lstat(path); err == nil && stat.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove symlink %q: %s", path, err)
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to stat %q: %s", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("unable to create %q: %s", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, tr); err != nil {
+		return fmt.Errorf("unable to write %q: %s", path, err)
+	}
+
+	return nil
+}
+
 func CopyFileIntoTar(tw *tar.Writer, tarEntryName string, filePath string) error {
 	stat, err := os.Lstat(filePath)
 	if err != nil {
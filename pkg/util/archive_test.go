@@ -0,0 +1,189 @@
+package util
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveEntry struct {
+	name     string
+	linkname string
+	data     string
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name          string
+		entries       []archiveEntry
+		expectedFiles map[string]string
+		expectedError string
+	}{
+		{
+			name: "files and relative symlink",
+			entries: []archiveEntry{
+				{name: "dir/file", data: "content"},
+				{name: "link", linkname: "dir/file"},
+			},
+			expectedFiles: map[string]string{"dir/file": "content", "link": "content"},
+		},
+		{
+			name:          "entry outside of the destination dir",
+			entries:       []archiveEntry{{name: "../file", data: "content"}},
+			expectedError: "path is outside of the destination dir",
+		},
+		{
+			name:          "absolute symlink target",
+			entries:       []archiveEntry{{name: "link", linkname: "/etc/passwd"}},
+			expectedError: "is outside of the destination dir",
+		},
+		{
+			name:          "relative symlink target outside of the destination dir",
+			entries:       []archiveEntry{{name: "dir/link", linkname: "../../file"}},
+			expectedError: "is outside of the destination dir",
+		},
+		{
+			name: "symlinks to symlinks inside of the destination dir",
+			entries: []archiveEntry{
+				{name: "dir/file", data: "content"},
+				{name: "dirlink", linkname: "dir"},
+				{name: "other/link", linkname: "../dirlink/file"},
+			},
+			expectedFiles: map[string]string{"other/link": "content"},
+		},
+		{
+			name: "symlink created through the chained symlink",
+			entries: []archiveEntry{
+				{name: "dir/link", linkname: ".."},
+				{name: "dir/link/x", linkname: "../victim"},
+				{name: "x", data: "content"},
+			},
+			expectedError: "is outside of the destination dir",
+		},
+		{
+			name: "symlink target going up through the symlink",
+			entries: []archiveEntry{
+				{name: "dir/link", linkname: ".."},
+				{name: "x", linkname: "dir/link/../victim"},
+			},
+			expectedError: "is outside of the destination dir",
+		},
+		{
+			name: "symlink target going up through the nonexistent path",
+			entries: []archiveEntry{
+				{name: "x", linkname: "nonexistent/../file"},
+			},
+			expectedError: "cannot be resolved",
+		},
+		{
+			name: "file replaces symlink",
+			entries: []archiveEntry{
+				{name: "file", data: "content"},
+				{name: "link", linkname: "file"},
+				{name: "link", data: "new content"},
+			},
+			expectedFiles: map[string]string{"file": "content", "link": "new content"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "extract-archive-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			archivePath := filepath.Join(tmpDir, "archive.tar")
+			if err := CreateArchive(archivePath, func(tw *tar.Writer) error {
+				return writeArchiveEntries(tw, tt.entries)
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			destinationDir := filepath.Join(tmpDir, "destination")
+			if err := os.MkdirAll(destinationDir, 0777); err != nil {
+				t.Fatal(err)
+			}
+
+			err = ExtractArchive(archivePath, destinationDir)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, expectedData := range tt.expectedFiles {
+				data, err := ioutil.ReadFile(filepath.Join(destinationDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(data) != expectedData {
+					t.Errorf("expected %q content %q, got %q", name, expectedData, string(data))
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchive_WriteThroughSymlink(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "extract-archive-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	destinationDir := filepath.Join(tmpDir, "destination")
+	outsideDir := filepath.Join(tmpDir, "outside")
+	for _, dir := range []string{destinationDir, outsideDir} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the symlink might be left in the destination dir by someone else
+	if err := os.Symlink(outsideDir, filepath.Join(destinationDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(tmpDir, "archive.tar")
+	if err := CreateArchive(archivePath, func(tw *tar.Writer) error {
+		return writeArchiveEntries(tw, []archiveEntry{{name: "link/file", data: "content"}})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractArchive(archivePath, destinationDir); err == nil || !strings.Contains(err.Error(), "path is outside of the destination dir") {
+		t.Fatalf("expected outside of the destination dir error, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outsideDir, "file")); !os.IsNotExist(err) {
+		t.Errorf("expected file not to be written outside of the destination dir")
+	}
+}
+
+func writeArchiveEntries(tw *tar.Writer, entries []archiveEntry) error {
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.data)), Typeflag: tar.TypeReg}
+		if entry.linkname != "" {
+			hdr = &tar.Header{Name: entry.name, Mode: 0777, Linkname: entry.linkname, Typeflag: tar.TypeSymlink}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write([]byte(entry.data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}